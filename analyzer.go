@@ -0,0 +1,166 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Analyzer refreshes the query planner statistics (ANALYZE) in a background goroutine,
+// on a dedicated connection, when the number of rows changed by the watched connections
+// (see Conn.TotalChanges) since the last analysis exceeds a threshold.
+// See also Conn.SetOptimizeOnClose.
+type Analyzer struct {
+	analyses int64 // accessed atomically
+	failures int64 // accessed atomically
+	changes  int64 // accessed atomically, rows changed since the last analysis
+
+	threshold int64
+	conn      *Conn // dedicated connection
+
+	mu      sync.Mutex // held while analyzing
+	lastErr error
+	watched map[*Conn]HookToken
+
+	notify chan bool
+	done   chan bool
+	exited chan bool
+}
+
+// AnalyzerBusyTimeout is the time an analysis waits for the write lock.
+var AnalyzerBusyTimeout = time.Second
+
+// AnalyzerAnalysisLimit bounds the number of rows visited per index by an analysis
+// (0 for no limit). (See http://sqlite.org/pragma.html#pragma_analysis_limit)
+var AnalyzerAnalysisLimit = 1000
+
+// NewAnalyzer starts an analyzer for the specified database of c:
+// ANALYZE is run when the watched connections have changed more than threshold rows.
+// A commit hook is added to c.
+func NewAnalyzer(c *Conn, dbName string, threshold int) (*Analyzer, error) {
+	if len(dbName) == 0 {
+		dbName = "main"
+	}
+	if threshold <= 0 {
+		return nil, errors.New("no analysis threshold specified")
+	}
+	filename := c.Filename(dbName)
+	if len(filename) == 0 {
+		return nil, fmt.Errorf("cannot analyze temporary or in-memory database %q in background", dbName)
+	}
+	conn, err := Open(filename, OpenReadWrite, OpenFullMutex)
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.BusyTimeout(AnalyzerBusyTimeout); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var limit int
+	if err = conn.OneValue(fmt.Sprintf("PRAGMA analysis_limit=%d", AnalyzerAnalysisLimit), &limit); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	a := &Analyzer{threshold: int64(threshold), conn: conn, watched: make(map[*Conn]HookToken),
+		notify: make(chan bool, 1), done: make(chan bool), exited: make(chan bool)}
+	a.Watch(c)
+	go a.loop()
+	return a, nil
+}
+
+// Watch adds a commit hook to another connection to the same database
+// so that its changes are taken into account.
+func (a *Analyzer) Watch(c *Conn) {
+	last := c.TotalChanges()
+	token := c.AddCommitHook(func(udp interface{}) bool {
+		n := c.TotalChanges()
+		if atomic.AddInt64(&a.changes, int64(n-last)) >= a.threshold {
+			a.signal()
+		}
+		last = n
+		return false
+	}, nil)
+	a.mu.Lock()
+	a.watched[c] = token
+	a.mu.Unlock()
+}
+
+func (a *Analyzer) signal() {
+	select {
+	case a.notify <- true:
+	default:
+	}
+}
+
+func (a *Analyzer) loop() {
+	defer close(a.exited)
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-a.notify:
+			if atomic.LoadInt64(&a.changes) >= a.threshold {
+				a.AnalyzeNow()
+			}
+		}
+	}
+}
+
+// AnalyzeNow runs ANALYZE (waiting for the current analysis to complete).
+func (a *Analyzer) AnalyzeNow() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	changes := atomic.LoadInt64(&a.changes)
+	if err := a.conn.exec("ANALYZE main"); err != nil {
+		atomic.AddInt64(&a.failures, 1)
+		a.lastErr = err
+		return err
+	}
+	atomic.AddInt64(&a.changes, -changes)
+	atomic.AddInt64(&a.analyses, 1)
+	return nil
+}
+
+// Analyses returns the number of successful analyses.
+func (a *Analyzer) Analyses() int64 {
+	return atomic.LoadInt64(&a.analyses)
+}
+
+// Failures returns the number of failed (usually busy) analyses.
+func (a *Analyzer) Failures() int64 {
+	return atomic.LoadInt64(&a.failures)
+}
+
+// LastError returns the error of the last failed analysis.
+func (a *Analyzer) LastError() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.lastErr
+}
+
+// Close stops the analyzer, removes the commit hooks of the watched connections
+// (it should be called from the goroutine using them) and closes the dedicated connection.
+func (a *Analyzer) Close() error {
+	select {
+	case <-a.done:
+		return nil
+	default:
+		close(a.done)
+	}
+	<-a.exited
+	a.mu.Lock()
+	for c, token := range a.watched {
+		if !c.IsClosed() {
+			c.RemoveHook(token)
+		}
+	}
+	a.watched = nil
+	a.mu.Unlock()
+	return a.conn.Close()
+}
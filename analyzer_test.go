@@ -0,0 +1,63 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	"fmt"
+	. "github.com/gwenn/gosqlite"
+	"github.com/gwenn/gosqlite/sqlitetest"
+	"testing"
+	"time"
+)
+
+func TestAnalyzer(t *testing.T) {
+	db := sqlitetest.Open(t, sqlitetest.Wal())
+	createTable(db, t)
+	checkNoError(t, db.Exec("CREATE INDEX test_idx ON test (a_string)"), "create index error: %s")
+
+	a, err := NewAnalyzer(db, "", 20)
+	checkNoError(t, err, "couldn't start analyzer: %s")
+	defer a.Close()
+	for i := 0; i < 10; i++ {
+		checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES (?)", "a"), "insert error: %s")
+	}
+	time.Sleep(10 * time.Millisecond)
+	assertEquals(t, "expected %d analyses but got %d", int64(0), a.Analyses())
+	checkNoError(t, db.Transaction(Immediate, func(c *Conn) error {
+		for i := 0; i < 10; i++ {
+			if err := c.Exec("INSERT INTO test (a_string) VALUES (?)", "b"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}), "insert error: %s")
+
+	for i := 0; i < 1000 && a.Analyses() == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assertEquals(t, "expected %d analyses but got %d", int64(1), a.Analyses())
+	checkNoError(t, a.LastError(), "unexpected analysis error: %s")
+	var n int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM sqlite_stat1 WHERE idx = 'test_idx'", &n), "select error: %s")
+	assertEquals(t, "expected %d but got %d", 1, n)
+	checkNoError(t, a.Close(), "couldn't close analyzer: %s")
+
+	mem := open(t)
+	defer checkClose(mem, t)
+	_, err = NewAnalyzer(mem, "", 20)
+	assert(t, "in-memory database error expected", err != nil)
+}
+
+func TestOptimizeOnClose(t *testing.T) {
+	db, err := Open(":memory:")
+	checkNoError(t, err, "couldn't open database: %s")
+	var traced []string
+	db.Trace(func(udp interface{}, sql string) {
+		traced = append(traced, sql)
+	}, nil)
+	db.SetOptimizeOnClose(true)
+	checkNoError(t, db.Close(), "couldn't close database: %s")
+	assertEquals(t, "expected %q but got %q", "[PRAGMA optimize]", fmt.Sprint(traced))
+}
@@ -0,0 +1,312 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// WriteArrow steps the statement and writes the result set to w in the Arrow IPC streaming format:
+// a schema message, one record batch message per batch of at most size rows (see Stmt.Batches)
+// and the end-of-stream marker.
+// The stream can be read with pyarrow.ipc.open_stream or the ipc.Reader of the Arrow Go library for example.
+// Args are for binding.
+// (See https://arrow.apache.org/docs/format/Columnar.html#ipc-streaming-format)
+func (s *Stmt) WriteArrow(w io.Writer, size int, args ...interface{}) error {
+	schema := false
+	err := s.Batches(size, func(b *RecordBatch) error {
+		if !schema {
+			schema = true
+			if err := writeArrowMessage(w, arrowHeaderSchema, arrowSchema(b.Fields), nil); err != nil {
+				return err
+			}
+		}
+		body, header := arrowRecordBatch(b)
+		return writeArrowMessage(w, arrowHeaderBatch, header, body)
+	}, args...)
+	if err != nil {
+		return err
+	}
+	if !schema { // empty result set
+		if err = writeArrowMessage(w, arrowHeaderSchema, arrowSchema(s.Schema()), nil); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	return err
+}
+
+// Arrow flatbuffers constants (See https://github.com/apache/arrow/blob/main/format/Message.fbs and Schema.fbs)
+const (
+	arrowMetadataV5      = 4
+	arrowHeaderSchema    = 1
+	arrowHeaderBatch     = 3
+	arrowTypeInt         = 2
+	arrowTypeFloat       = 3
+	arrowTypeBinary      = 4
+	arrowTypeUtf8        = 5
+	arrowPrecisionDouble = 2
+)
+
+// writeArrowMessage writes an encapsulated message:
+// continuation marker, metadata length, flatbuffers Message (padded to 8 bytes) and body.
+func writeArrowMessage(w io.Writer, headerType uint64, header fbTable, body []byte) error {
+	meta := fbFinish(fbTable{
+		fbScalar(2, arrowMetadataV5),
+		fbScalar(1, headerType),
+		fbRef(header),
+		fbScalar(8, uint64(len(body))),
+	})
+	prefix := make([]byte, 8)
+	binary.LittleEndian.PutUint32(prefix, 0xffffffff)
+	binary.LittleEndian.PutUint32(prefix[4:], uint32(len(meta)))
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+	if _, err := w.Write(meta); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+func arrowSchema(fields []Field) fbTable {
+	tables := make([]fbTable, len(fields))
+	for i, f := range fields {
+		var typeType uint64
+		var typ fbTable
+		switch f.Kind {
+		case KindInt64:
+			typeType, typ = arrowTypeInt, fbTable{fbScalar(4, 64), fbScalar(1, 1)} // bitWidth, is_signed
+		case KindFloat64:
+			typeType, typ = arrowTypeFloat, fbTable{fbScalar(2, arrowPrecisionDouble)}
+		case KindBinary:
+			typeType, typ = arrowTypeBinary, fbTable{}
+		default:
+			typeType, typ = arrowTypeUtf8, fbTable{}
+		}
+		tables[i] = fbTable{
+			fbRef(f.Name),
+			fbScalar(1, 1), // nullable
+			fbScalar(1, typeType),
+			fbRef(typ),
+			nil,                // dictionary
+			fbRef([]fbTable{}), // children
+		}
+	}
+	return fbTable{nil, fbRef(tables)} // little endian (default)
+}
+
+// arrowRecordBatch returns the body and the RecordBatch header of b:
+// for each column, a validity bitmap followed by the values (or the offsets and the data for utf8 and binary).
+func arrowRecordBatch(b *RecordBatch) ([]byte, fbTable) {
+	var body, nodes, buffers []byte
+	appendBuffer := func(data []byte) {
+		buffers = appendUint64s(buffers, uint64(len(body)), uint64(len(data)))
+		body = append(body, data...)
+		for len(body)%8 != 0 {
+			body = append(body, 0)
+		}
+	}
+	for i, field := range b.Fields {
+		c := &b.Columns[i]
+		bitmap := make([]byte, (b.Rows+7)/8)
+		var nulls uint64
+		for j, valid := range c.Valid {
+			if valid {
+				bitmap[j/8] |= 1 << uint(j%8)
+			} else {
+				nulls++
+			}
+		}
+		nodes = appendUint64s(nodes, uint64(b.Rows), nulls)
+		appendBuffer(bitmap)
+		switch field.Kind {
+		case KindInt64:
+			values := make([]byte, 0, 8*b.Rows)
+			for _, v := range c.Int64s {
+				values = appendUint64s(values, uint64(v))
+			}
+			appendBuffer(values)
+		case KindFloat64:
+			values := make([]byte, 0, 8*b.Rows)
+			for _, v := range c.Float64s {
+				values = appendUint64s(values, math.Float64bits(v))
+			}
+			appendBuffer(values)
+		default:
+			offsets := make([]byte, 4, 4*(b.Rows+1))
+			var data []byte
+			for j := 0; j < b.Rows; j++ {
+				if field.Kind == KindBinary {
+					data = append(data, c.Binaries[j]...)
+				} else {
+					data = append(data, c.Strings[j]...)
+				}
+				offsets = appendUint32(offsets, uint32(len(data)))
+			}
+			appendBuffer(offsets)
+			appendBuffer(data)
+		}
+	}
+	return body, fbTable{
+		fbScalar(8, uint64(b.Rows)),
+		fbRef(fbStructs(nodes)),   // FieldNode {length, null_count}
+		fbRef(fbStructs(buffers)), // Buffer {offset, length}
+	}
+}
+
+func appendUint64s(b []byte, values ...uint64) []byte {
+	for _, v := range values {
+		b = appendUint64(b, v)
+	}
+	return b
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24), byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+// Minimal flatbuffers encoder, enough for the Arrow IPC metadata.
+// Objects are laid out front to back (a table is followed by the objects it references)
+// so that all offsets are positive, as required by the format.
+// (See https://flatbuffers.dev/flatbuffers_internals.html)
+
+// fbTable is a table with its fields indexed by id (nil for an absent field).
+type fbTable []*fbField
+
+// fbStructs is a vector of structs of 16 bytes (two longs), 8-byte aligned.
+type fbStructs []byte
+
+type fbField struct {
+	size  int         // of the scalar (1, 2, 4 or 8)
+	value uint64      // scalar value
+	obj   interface{} // referenced fbTable, string, []fbTable or fbStructs
+}
+
+func fbScalar(size int, value uint64) *fbField {
+	return &fbField{size: size, value: value}
+}
+
+func fbRef(obj interface{}) *fbField {
+	return &fbField{size: 4, obj: obj}
+}
+
+type fbBuilder struct {
+	buf []byte
+}
+
+func fbFinish(root fbTable) []byte {
+	b := &fbBuilder{buf: make([]byte, 4)}
+	b.putUint32(0, uint32(b.table(root)))
+	b.pad(8, 0)
+	return b.buf
+}
+
+// pad appends zeros until the length plus extra is a multiple of align.
+func (b *fbBuilder) pad(align, extra int) {
+	for (len(b.buf)+extra)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *fbBuilder) putUint32(pos int, v uint32) {
+	binary.LittleEndian.PutUint32(b.buf[pos:], v)
+}
+
+func (b *fbBuilder) ref(pos int, obj interface{}) {
+	b.putUint32(pos, uint32(b.object(obj)-pos))
+}
+
+func (b *fbBuilder) object(obj interface{}) int {
+	switch o := obj.(type) {
+	case fbTable:
+		return b.table(o)
+	case string:
+		b.pad(4, 0)
+		pos := len(b.buf)
+		b.buf = appendUint32(b.buf, uint32(len(o)))
+		b.buf = append(append(b.buf, o...), 0)
+		return pos
+	case []fbTable:
+		b.pad(4, 0)
+		pos := len(b.buf)
+		b.buf = appendUint32(b.buf, uint32(len(o)))
+		b.buf = append(b.buf, make([]byte, 4*len(o))...)
+		for i, t := range o {
+			b.ref(pos+4+4*i, t)
+		}
+		return pos
+	case fbStructs:
+		b.pad(8, 4) // the elements follow the length
+		pos := len(b.buf)
+		b.buf = appendUint32(b.buf, uint32(len(o)/16))
+		b.buf = append(b.buf, o...)
+		return pos
+	}
+	panic("unsupported flatbuffers object")
+}
+
+// table writes the vtable, then the table (soffset to the vtable and inline fields, largest first),
+// then the referenced objects. Returns the position of the table.
+func (b *fbBuilder) table(t fbTable) int {
+	offsets := make([]int, len(t))
+	size := 4 // soffset
+	for _, fieldSize := range []int{8, 4, 2, 1} {
+		for i, f := range t {
+			if f == nil || f.size != fieldSize {
+				continue
+			}
+			for size%fieldSize != 0 {
+				size++
+			}
+			offsets[i] = size
+			size += fieldSize
+		}
+	}
+	b.pad(2, 0)
+	vtable := len(b.buf)
+	b.buf = appendUint16(b.buf, uint16(4+2*len(t)))
+	b.buf = appendUint16(b.buf, uint16(size))
+	for _, offset := range offsets {
+		b.buf = appendUint16(b.buf, uint16(offset))
+	}
+	b.pad(8, 0)
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	b.putUint32(pos, uint32(pos-vtable))
+	for i, f := range t {
+		if f == nil || f.obj != nil {
+			continue
+		}
+		field := b.buf[pos+offsets[i]:]
+		switch f.size {
+		case 8:
+			binary.LittleEndian.PutUint64(field, f.value)
+		case 4:
+			binary.LittleEndian.PutUint32(field, uint32(f.value))
+		case 2:
+			binary.LittleEndian.PutUint16(field, uint16(f.value))
+		default:
+			field[0] = byte(f.value)
+		}
+	}
+	for i, f := range t {
+		if f != nil && f.obj != nil {
+			b.ref(pos+offsets[i], f.obj)
+		}
+	}
+	return pos
+}
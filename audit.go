@@ -0,0 +1,172 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Audit records the changes made to some tables into an audit table, with triggers.
+// Old and new row images are stored as JSON objects (blobs are stored as hex strings).
+// The actor is given by the audit_actor() SQL function, registered on the connection by NewAudit:
+// as the triggers are persistent, each connection modifying an audited table must call NewAudit.
+type Audit struct {
+	c     *Conn
+	table string
+	actor string
+}
+
+// AuditEntry is one row of the audit table.
+type AuditEntry struct {
+	Id    int64
+	Table string
+	Op    string // INSERT, UPDATE or DELETE
+	RowId int64
+	Old   json.RawMessage // nil for INSERT
+	New   json.RawMessage // nil for DELETE
+	Time  time.Time       // UTC
+	Actor string
+}
+
+// OldInto unmarshals the old row image into dest (see json.Unmarshal).
+func (e *AuditEntry) OldInto(dest interface{}) error {
+	return json.Unmarshal(e.Old, dest)
+}
+
+// NewInto unmarshals the new row image into dest (see json.Unmarshal).
+func (e *AuditEntry) NewInto(dest interface{}) error {
+	return json.Unmarshal(e.New, dest)
+}
+
+const auditTimeLayout = "2006-01-02 15:04:05.000"
+
+// NewAudit creates the audit table in the main database (if it doesn't exist)
+// and registers the audit_actor() function on c.
+func NewAudit(c *Conn, auditTable string) (*Audit, error) {
+	a := &Audit{c: c, table: auditTable}
+	err := c.Exec(Mprintf(`CREATE TABLE IF NOT EXISTS "%w" (
+		id INTEGER PRIMARY KEY,
+		table_name TEXT NOT NULL,
+		op TEXT NOT NULL,
+		row_id INTEGER NOT NULL,
+		old_row TEXT,
+		new_row TEXT,
+		ts TEXT NOT NULL DEFAULT (strftime('%%Y-%%m-%%d %%H:%%M:%%f', 'now')),
+		actor TEXT)`, auditTable) + c.tableOptions())
+	if err != nil {
+		return nil, err
+	}
+	err = c.CreateScalarFunction("audit_actor", 0, nil, func(ctx *ScalarContext, nArg int) {
+		if len(a.actor) == 0 {
+			ctx.ResultNull()
+		} else {
+			ctx.ResultText(a.actor)
+		}
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// SetActor sets the actor recorded with the next changes made through the connection.
+func (a *Audit) SetActor(actor string) {
+	a.actor = actor
+}
+
+// Install generates and creates the audit triggers of the specified rowid table (of the main database).
+// Columns added afterwards are not audited unless triggers are installed again.
+func (a *Audit) Install(table string) error {
+	columns, err := a.c.Columns("main", table)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return a.c.specificError("no such table: %s", table)
+	}
+	image := func(prefix string) string {
+		args := make([]string, len(columns))
+		for i, column := range columns {
+			ref := Mprintf(prefix+`."%w"`, column.Name)
+			args[i] = Mprintf("%Q, ", column.Name) +
+				"CASE typeof(" + ref + ") WHEN 'blob' THEN hex(" + ref + ") ELSE " + ref + " END"
+		}
+		return "json_object(" + strings.Join(args, ", ") + ")"
+	}
+	return a.c.Transaction(Immediate, func(c *Conn) error {
+		if err := a.Uninstall(table); err != nil {
+			return err
+		}
+		for _, t := range []struct{ event, rowId, old, new string }{
+			{"INSERT", "NEW.rowid", "NULL", image("NEW")},
+			{"UPDATE", "NEW.rowid", image("OLD"), image("NEW")},
+			{"DELETE", "OLD.rowid", image("OLD"), "NULL"},
+		} {
+			sql := Mprintf2(`CREATE TRIGGER "%w" AFTER `+t.event+` ON "%w" BEGIN `, auditTrigger(table, t.event), table) +
+				Mprintf(`INSERT INTO "%w" (table_name, op, row_id, old_row, new_row, actor) VALUES (`, a.table) +
+				Mprintf("%Q, '", table) + t.event + "', " + t.rowId + ", " + t.old + ", " + t.new + ", audit_actor()); END"
+			if err := c.Exec(sql); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Uninstall drops the audit triggers of the specified table (the history is kept).
+func (a *Audit) Uninstall(table string) error {
+	for _, event := range []string{"INSERT", "UPDATE", "DELETE"} {
+		if err := a.c.Exec(Mprintf(`DROP TRIGGER IF EXISTS "%w"`, auditTrigger(table, event))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func auditTrigger(table, event string) string {
+	return table + "_audit_" + strings.ToLower(event)
+}
+
+// History returns the audit entries of the specified table, in chronological order.
+func (a *Audit) History(table string) ([]AuditEntry, error) {
+	return a.history(Mprintf(`SELECT id, table_name, op, row_id, old_row, new_row, ts, actor FROM "%w" WHERE table_name = ? ORDER BY id`, a.table), table)
+}
+
+// RowHistory returns the audit entries of the specified row, in chronological order.
+func (a *Audit) RowHistory(table string, rowId int64) ([]AuditEntry, error) {
+	return a.history(Mprintf(`SELECT id, table_name, op, row_id, old_row, new_row, ts, actor FROM "%w" WHERE table_name = ? AND row_id = ? ORDER BY id`, a.table), table, rowId)
+}
+
+func (a *Audit) history(query string, args ...interface{}) ([]AuditEntry, error) {
+	s, err := a.c.Prepare(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Finalize()
+	var entries []AuditEntry
+	err = s.Select(func(s *Stmt) error {
+		var e AuditEntry
+		var old, new, ts string
+		if err := s.Scan(&e.Id, &e.Table, &e.Op, &e.RowId, &old, &new, &ts, &e.Actor); err != nil {
+			return err
+		}
+		if len(old) > 0 {
+			e.Old = json.RawMessage(old)
+		}
+		if len(new) > 0 {
+			e.New = json.RawMessage(new)
+		}
+		t, err := time.ParseInLocation(auditTimeLayout, ts, time.UTC)
+		if err != nil {
+			return err
+		}
+		e.Time = t
+		entries = append(entries, e)
+		return nil
+	})
+	return entries, err
+}
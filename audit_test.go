@@ -0,0 +1,60 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"testing"
+	"time"
+)
+
+func TestAudit(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+
+	audit, err := NewAudit(db, "audit")
+	checkNoError(t, err, "couldn't create audit: %s")
+	checkNoError(t, audit.Install("test"), "couldn't install audit triggers: %s")
+	checkNoError(t, audit.Install("test"), "couldn't reinstall audit triggers: %s")
+	err = audit.Install("unknown")
+	assert(t, "unknown table error expected", err != nil)
+
+	audit.SetActor("bart")
+	checkNoError(t, db.Exec("INSERT INTO test (int_num, a_string) VALUES (1, 'a')"), "insert error: %s")
+	audit.SetActor("lisa")
+	checkNoError(t, db.Exec("UPDATE test SET a_string = 'b'"), "update error: %s")
+	checkNoError(t, db.Exec("DELETE FROM test"), "delete error: %s")
+	checkNoError(t, audit.Uninstall("test"), "couldn't uninstall audit triggers: %s")
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('c')"), "insert error: %s")
+
+	entries, err := audit.RowHistory("test", 1)
+	checkNoError(t, err, "history error: %s")
+	assertEquals(t, "expected %d entries but got %d", 3, len(entries))
+	assertEquals(t, "expected %q but got %q", "INSERT", entries[0].Op)
+	assertEquals(t, "expected %q but got %q", "bart", entries[0].Actor)
+	assert(t, "no old row image expected on insert", entries[0].Old == nil)
+	assert(t, "recent timestamp expected", time.Since(entries[0].Time) < time.Minute)
+	assertEquals(t, "expected %q but got %q", "UPDATE", entries[1].Op)
+	assertEquals(t, "expected %q but got %q", "lisa", entries[1].Actor)
+
+	type row struct {
+		Id      int64   `json:"id"`
+		IntNum  int     `json:"int_num"`
+		AString string  `json:"a_string"`
+		Float   *string `json:"float_num"`
+	}
+	var old, new row
+	checkNoError(t, entries[1].OldInto(&old), "unmarshal error: %s")
+	checkNoError(t, entries[1].NewInto(&new), "unmarshal error: %s")
+	assert(t, "unexpected old image", old.Id == 1 && old.IntNum == 1 && old.AString == "a" && old.Float == nil)
+	assertEquals(t, "expected %q but got %q", "b", new.AString)
+	assertEquals(t, "expected %q but got %q", "DELETE", entries[2].Op)
+	assert(t, "no new row image expected on delete", entries[2].New == nil)
+
+	entries, err = audit.History("test")
+	checkNoError(t, err, "history error: %s")
+	assertEquals(t, "expected %d entries but got %d", 3, len(entries))
+}
@@ -0,0 +1,115 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"strings"
+)
+
+// AuthorizerRule matches authorizer requests.
+// Empty fields match anything.
+// Names are compared case-insensitively.
+type AuthorizerRule struct {
+	Actions []Action // empty matches any action
+	DbName  string   // "main", "temp" or attached database name
+	Table   string   // table (or view) impacted by the action (see AuthTable)
+}
+
+// AuthorizerRules is a declarative access policy:
+// Deny rules are checked first, then Ignore rules, then Allow rules.
+// When no rule matches, the Default policy applies (AuthOk when not specified).
+//
+//	rules := &AuthorizerRules{Default: AuthDeny, Allow: []AuthorizerRule{
+//		{Actions: []Action{Select}},
+//		{Actions: []Action{Read}, Table: "products"},
+//	}}
+//	err := db.SetAuthorizer(rules.Authorizer(), nil)
+type AuthorizerRules struct {
+	Default Auth
+	Deny    []AuthorizerRule
+	Ignore  []AuthorizerRule // Read of ignored columns returns NULL
+	Allow   []AuthorizerRule
+}
+
+var allActions = []Action{CreateIndex, CreateTable, CreateTempIndex, CreateTempTable, CreateTempTrigger,
+	CreateTempView, CreateTrigger, CreateView, Delete, DropIndex, DropTable, DropTempIndex, DropTempTable,
+	DropTempTrigger, DropTempView, DropTrigger, DropView, Insert, Pragma, Read, Select, Transaction, Update,
+	Attach, Detach, AlterTable, Reindex, Analyze, CreateVTable, DropVTable, Function, Savepoint, Recursive}
+
+type authRule struct {
+	dbName string
+	table  string
+	auth   Auth
+}
+
+// AuthTable returns the name of the table (or view) impacted by an authorizer request.
+// Returns "" when the action is not related to a table.
+func AuthTable(action Action, arg1, arg2 string) string {
+	switch action {
+	case CreateIndex, CreateTempIndex, DropIndex, DropTempIndex,
+		CreateTrigger, CreateTempTrigger, DropTrigger, DropTempTrigger, AlterTable:
+		return arg2
+	case CreateTable, CreateTempTable, DropTable, DropTempTable, CreateView, CreateTempView, DropView, DropTempView,
+		Insert, Delete, Read, Update, Analyze, CreateVTable, DropVTable:
+		return arg1
+	}
+	return ""
+}
+
+// Authorizer compiles the rules into an Authorizer callback.
+// Rules are indexed by action so that each request only checks the relevant rules.
+// Subsequent changes to the rules are not taken into account.
+func (r *AuthorizerRules) Authorizer() Authorizer {
+	rules := make(map[Action][]authRule)
+	compile := func(list []AuthorizerRule, auth Auth) {
+		for _, rule := range list {
+			actions := rule.Actions
+			if len(actions) == 0 {
+				actions = allActions
+			}
+			ar := authRule{strings.ToLower(rule.DbName), strings.ToLower(rule.Table), auth}
+			for _, action := range actions {
+				rules[action] = append(rules[action], ar)
+			}
+		}
+	}
+	compile(r.Deny, AuthDeny)
+	compile(r.Ignore, AuthIgnore)
+	compile(r.Allow, AuthOk)
+	def := r.Default
+	return func(udp interface{}, action Action, arg1, arg2, dbName, triggerName string) Auth {
+		candidates := rules[action]
+		if len(candidates) == 0 {
+			return def
+		}
+		var table string
+		tableResolved := false
+		for _, ar := range candidates {
+			if len(ar.dbName) > 0 && !strings.EqualFold(ar.dbName, dbName) {
+				continue
+			}
+			if len(ar.table) > 0 {
+				if !tableResolved {
+					table = strings.ToLower(AuthTable(action, arg1, arg2))
+					tableResolved = true
+				}
+				if ar.table != table {
+					continue
+				}
+			}
+			return ar.auth
+		}
+		return def
+	}
+}
+
+// SetAuthorizerRules sets (or clears when rules is nil) a declarative access policy.
+// (See AuthorizerRules and Conn.SetAuthorizer)
+func (c *Conn) SetAuthorizerRules(rules *AuthorizerRules) error {
+	if rules == nil {
+		return c.SetAuthorizer(nil, nil)
+	}
+	return c.SetAuthorizer(rules.Authorizer(), nil)
+}
@@ -0,0 +1,45 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"testing"
+)
+
+func TestAuthorizerRules(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE secret (pwd TEXT); INSERT INTO test (a_string) VALUES ('visible')"), "exec error: %s")
+
+	rules := &AuthorizerRules{
+		Default: AuthDeny,
+		Deny:    []AuthorizerRule{{Table: "SECRET"}},
+		Ignore:  []AuthorizerRule{{Actions: []Action{Read}, Table: "test", DbName: "temp"}},
+		Allow: []AuthorizerRule{
+			{Actions: []Action{Select, Function}},
+			{Actions: []Action{Read}, DbName: "main"},
+		},
+	}
+	checkNoError(t, db.SetAuthorizerRules(rules), "couldn't set authorizer rules: %s")
+
+	var s string
+	checkNoError(t, db.OneValue("SELECT a_string FROM test", &s), "read error: %s")
+	assertEquals(t, "expected %q but got %q", "visible", s)
+	err := db.OneValue("SELECT pwd FROM secret", &s)
+	assert(t, "denied read expected", err != nil)
+	err = db.Exec("INSERT INTO test (a_string) VALUES ('denied')")
+	assert(t, "denied insert expected (default policy)", err != nil)
+
+	checkNoError(t, db.SetAuthorizerRules(nil), "couldn't clear authorizer rules: %s")
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('allowed')"), "insert error: %s")
+}
+
+func TestAuthTable(t *testing.T) {
+	assertEquals(t, "expected %q but got %q", "test", AuthTable(CreateIndex, "idx", "test"))
+	assertEquals(t, "expected %q but got %q", "test", AuthTable(Read, "test", "col"))
+	assertEquals(t, "expected %q but got %q", "", AuthTable(Pragma, "user_version", ""))
+}
@@ -33,7 +33,11 @@ func NewBackup(dst *Conn, dstDbName string, src *Conn, srcDbName string) (*Backu
 	if sb == nil {
 		return nil, dst.error(C.sqlite3_errcode(dst.db), "backup init failed")
 	}
-	return &Backup{sb, dst, src}, nil
+	b := &Backup{sb, dst, src}
+	if debugLeaks {
+		setLeakFinalizer(b, "backup")
+	}
+	return b, nil
 }
 
 // The Backup object records state information about an ongoing online backup operation.
@@ -49,13 +53,20 @@ func (b *Backup) Step(npage int) error {
 	if b == nil {
 		return errors.New("nil sqlite backup")
 	}
-	rv := C.sqlite3_backup_step(b.sb, C.int(npage))
-	if rv == C.SQLITE_OK || Errno(rv) == ErrBusy || Errno(rv) == ErrLocked { // TODO Trace busy/locked errors
+	rv := b.step(npage)
+	if rv == C.SQLITE_OK || Errno(rv) == ErrBusy || Errno(rv) == ErrLocked {
 		return nil
 	}
 	return Errno(rv)
 }
 
+// step copies up to npage pages and counts the busy/locked errors (see Conn.BusyCount).
+func (b *Backup) step(npage int) C.int {
+	rv := C.sqlite3_backup_step(b.sb, C.int(npage))
+	b.src.countBusy(rv)
+	return rv
+}
+
 // BackupStatus reports backup progression
 type BackupStatus struct {
 	Remaining int
@@ -75,19 +86,70 @@ func (b *Backup) Status() BackupStatus {
 // - closing the backup when done or when an error happens.
 // Sleeping is disabled if 'sleepNs' is zero or negative.
 // Notification is disabled if 'c' is null.
+// Steps failing with SQLITE_BUSY or SQLITE_LOCKED are retried (see BackupOptions).
 // (See http://sqlite.org/c3ref/backup_finish.html#sqlite3backupstep, sqlite3_backup_remaining and sqlite3_backup_pagecount)
 func (b *Backup) Run(npage int, sleepNs time.Duration, c chan<- BackupStatus) error {
+	return b.RunWithOptions(BackupOptions{Pages: npage, Sleep: sleepNs}, c)
+}
+
+// BackupOptions configures the pacing of Backup.RunWithOptions.
+type BackupOptions struct {
+	Pages          int           // pages copied at each step (0 or negative means all remaining pages)
+	Sleep          time.Duration // minimum pause between steps
+	PagesPerSecond int           // page budget: steps are delayed to copy at most this number of pages per second on average (0 means no budget)
+	MaxRetries     int           // consecutive SQLITE_BUSY/SQLITE_LOCKED steps tolerated before giving up (0 means no limit)
+	RetryDelay     time.Duration // pause before retrying a busy/locked step (default is 10ms)
+}
+
+// RunWithOptions starts the backup like Run but paced by opts, so that it doesn't starve the writers of the source database:
+//
+//	err := bck.RunWithOptions(BackupOptions{Pages: 100, PagesPerSecond: 1000, MaxRetries: 50}, nil)
+//
+// When a step fails with SQLITE_BUSY or SQLITE_LOCKED, it is retried after RetryDelay
+// (up to MaxRetries consecutive times, then the error is returned).
+func (b *Backup) RunWithOptions(opts BackupOptions, c chan<- BackupStatus) error {
+	if b == nil {
+		return errors.New("nil sqlite backup")
+	}
+	npage := opts.Pages
+	if npage == 0 {
+		npage = -1
+	}
+	retryDelay := opts.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = 10 * time.Millisecond
+	}
+	start := time.Now()
 	var err error
+	var retries int
 	for {
-		err = b.Step(npage)
-		if err != nil {
+		rv := b.step(npage)
+		if Errno(rv) == ErrBusy || Errno(rv) == ErrLocked {
+			retries++
+			if opts.MaxRetries > 0 && retries > opts.MaxRetries {
+				err = Errno(rv)
+				break
+			}
+			time.Sleep(retryDelay)
+			continue
+		} else if rv != C.SQLITE_OK {
+			err = Errno(rv)
 			break
 		}
+		retries = 0
+		status := b.Status()
 		if c != nil {
-			c <- b.Status()
+			c <- status
+		}
+		pause := opts.Sleep
+		if opts.PagesPerSecond > 0 {
+			copied := time.Duration(status.PageCount - status.Remaining)
+			if wait := copied*time.Second/time.Duration(opts.PagesPerSecond) - time.Since(start); wait > pause {
+				pause = wait
+			}
 		}
-		if sleepNs > 0 {
-			time.Sleep(sleepNs)
+		if pause > 0 {
+			time.Sleep(pause)
 		}
 	}
 	if err != Done {
@@ -117,3 +179,43 @@ func (b *Backup) Close() error {
 	b.sb = nil
 	return nil
 }
+
+// BackupAllTo copies the main database and all the attached databases of c to the databases
+// with the same names on dst, in their index order ("temp" is skipped).
+// The attached databases must already exist on dst (see Conn.Attach): they are all checked before any copy.
+func (c *Conn) BackupAllTo(dst *Conn) error {
+	if dst == nil {
+		return errors.New("nil sqlite backup destination")
+	}
+	databases, err := c.Databases()
+	if err != nil {
+		return err
+	}
+	dstDatabases, err := dst.Databases()
+	if err != nil {
+		return err
+	}
+	attached := make(map[string]bool, len(dstDatabases))
+	for _, db := range dstDatabases {
+		attached[db.Name] = true
+	}
+	names := make([]string, 0, len(databases))
+	for _, db := range databases {
+		if db.Name == "temp" {
+			continue
+		} else if !attached[db.Name] {
+			return dst.specificError("no database named %q attached to the backup destination", db.Name)
+		}
+		names = append(names, db.Name)
+	}
+	for _, name := range names {
+		bck, err := NewBackup(dst, name, c, name)
+		if err != nil {
+			return err
+		}
+		if err = bck.Run(-1, 0, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
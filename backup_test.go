@@ -5,8 +5,11 @@
 package sqlite_test
 
 import (
+	"bytes"
 	. "github.com/gwenn/gosqlite"
+	"github.com/gwenn/gosqlite/sqlitetest"
 	"testing"
+	"time"
 )
 
 func TestBackup(t *testing.T) {
@@ -47,3 +50,115 @@ func TestBackupMisuse(t *testing.T) {
 	assert(t, "misuse expected", err != nil)
 	//println(err.Error())
 }
+
+func TestBackupPacing(t *testing.T) {
+	dst := open(t)
+	defer checkClose(dst, t)
+	src := open(t)
+	defer checkClose(src, t)
+	fill(nil, src, 1000)
+
+	bck, err := NewBackup(dst, "main", src, "main")
+	checkNoError(t, err, "couldn't init backup: %#v")
+	cbs := make(chan BackupStatus, 100)
+	start := time.Now()
+	err = bck.RunWithOptions(BackupOptions{Pages: 1, PagesPerSecond: 100}, cbs)
+	checkNoError(t, err, "couldn't do backup: %#v")
+	elapsed := time.Since(start)
+	close(cbs)
+	var pageCount int
+	for s := range cbs {
+		pageCount = s.PageCount
+	}
+	// no pause after the last page
+	assert(t, "backup should be paced", elapsed >= time.Duration(pageCount-1)*10*time.Millisecond)
+	var count int
+	checkNoError(t, dst.OneValue("SELECT count(*) FROM test", &count), "count error: %s")
+	assertEquals(t, "expected %d rows but got %d", 1000, count)
+}
+
+func TestBackupBusyRetry(t *testing.T) {
+	src := sqlitetest.Open(t, sqlitetest.TempFile())
+	defer checkClose(src, t)
+	createTable(src, t)
+	writer, err := Open(src.Filename("main"))
+	checkNoError(t, err, "couldn't open writer: %s")
+	defer checkClose(writer, t)
+	dst := open(t)
+	defer checkClose(dst, t)
+
+	checkNoError(t, writer.BeginTransaction(Exclusive), "couldn't lock database: %s")
+	bck, err := NewBackup(dst, "main", src, "main")
+	checkNoError(t, err, "couldn't init backup: %#v")
+	busy := src.BusyCount()
+	err = bck.RunWithOptions(BackupOptions{MaxRetries: 2, RetryDelay: time.Millisecond}, nil)
+	assertEquals(t, "expected %v but got %v", ErrBusy, err)
+	assertEquals(t, "expected %d busy errors but got %d", busy+3, src.BusyCount())
+
+	bck, err = NewBackup(dst, "main", src, "main")
+	checkNoError(t, err, "couldn't init backup: %#v")
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		writer.Rollback()
+	}()
+	err = bck.RunWithOptions(BackupOptions{RetryDelay: time.Millisecond}, nil)
+	checkNoError(t, err, "couldn't do backup: %#v")
+	var count int
+	checkNoError(t, dst.OneValue("SELECT count(*) FROM test", &count), "count error: %s")
+	assertEquals(t, "expected %d rows but got %d", 0, count)
+}
+
+func TestBackupAllTo(t *testing.T) {
+	src := open(t)
+	defer checkClose(src, t)
+	createTable(src, t)
+	checkNoError(t, src.Exec("INSERT INTO test (a_string) VALUES ('main')"), "insert error: %s")
+	checkNoError(t, src.Attach(":memory:", "aux"), "attach error: %s")
+	checkNoError(t, src.Exec("CREATE TABLE aux.t (v); INSERT INTO aux.t VALUES (1), (2)"), "exec error: %s")
+
+	dst := open(t)
+	defer checkClose(dst, t)
+	err := src.BackupAllTo(dst)
+	assert(t, "missing attached database error expected", err != nil)
+
+	checkNoError(t, dst.Attach(":memory:", "aux"), "attach error: %s")
+	checkNoError(t, src.BackupAllTo(dst), "backup error: %s")
+	var s string
+	checkNoError(t, dst.OneValue("SELECT a_string FROM main.test", &s), "select error: %s")
+	assertEquals(t, "expected %q but got %q", "main", s)
+	var count int
+	checkNoError(t, dst.OneValue("SELECT count(*) FROM aux.t", &count), "count error: %s")
+	assertEquals(t, "expected %d but got %d", 2, count)
+}
+
+func TestBackupToRestoreFrom(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('a'), ('b')"), "insert error: %s")
+
+	var buf bytes.Buffer
+	n, err := db.BackupTo(&buf, "main")
+	checkNoError(t, err, "backup error: %s")
+	assertEquals(t, "expected %d bytes but got %d", int64(buf.Len()), n)
+	assert(t, "database header expected", bytes.HasPrefix(buf.Bytes(), []byte("SQLite format 3\x00")))
+
+	data, err := db.Serialize("main")
+	checkNoError(t, err, "serialize error: %s")
+	assert(t, "database header expected", bytes.HasPrefix(data, []byte("SQLite format 3\x00")))
+
+	dst := open(t)
+	defer checkClose(dst, t)
+	checkNoError(t, dst.RestoreFrom(&buf, "main"), "restore error: %s")
+	var count int
+	checkNoError(t, dst.OneValue("SELECT count(*) FROM test", &count), "count error: %s")
+	assertEquals(t, "expected %d but got %d", 2, count)
+
+	mem := open(t)
+	defer checkClose(mem, t)
+	checkNoError(t, mem.Deserialize("main", data, true), "deserialize error: %s")
+	checkNoError(t, mem.OneValue("SELECT count(*) FROM test", &count), "count error: %s")
+	assertEquals(t, "expected %d but got %d", 2, count)
+	err = mem.Exec("DELETE FROM test")
+	assert(t, "read-only error expected", err != nil)
+}
@@ -0,0 +1,221 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BackupConfig configures a BackupManager.
+type BackupConfig struct {
+	Dir string // target directory (created if needed)
+	// Template of the backup filenames: "{name}" is replaced by the database filename (without extension)
+	// and "{time}" by the UTC backup time (sortable). Default is "{name}-{time}.db".
+	Template string
+	Interval time.Duration // time between two periodic backups (<= 0 disables periodic backups)
+	KeepLast int           // number of most recent backups kept (0 keeps all)
+	KeepFor  time.Duration // backups older than that are removed (0 keeps all)
+	// Pacing to limit the I/O impact on the source database:
+	PagesPerStep int           // pages copied at each step (default 100)
+	StepDelay    time.Duration // pause between two steps
+	// Notifications (called from the backup goroutine):
+	OnSuccess func(path string, elapsed time.Duration)
+	OnFailure func(err error)
+}
+
+const backupTimeLayout = "20060102T150405.000000Z"
+
+// BackupManager runs periodic online backups of a database file, in a background goroutine,
+// on a dedicated read-only connection, and removes the old backups.
+type BackupManager struct {
+	backups  int64 // accessed atomically
+	failures int64 // accessed atomically
+
+	cfg    BackupConfig
+	name   string
+	source *Conn // dedicated connection
+
+	mu      sync.Mutex // held while backing up
+	lastErr error
+
+	done   chan bool
+	exited chan bool
+}
+
+// NewBackupManager starts a backup manager for the database file filename.
+func NewBackupManager(filename string, cfg BackupConfig) (*BackupManager, error) {
+	if len(cfg.Dir) == 0 {
+		return nil, errors.New("no backup directory specified")
+	}
+	if len(cfg.Template) == 0 {
+		cfg.Template = "{name}-{time}.db"
+	}
+	if !strings.Contains(cfg.Template, "{time}") {
+		return nil, fmt.Errorf("no {time} in backup filename template: %q", cfg.Template)
+	}
+	if cfg.PagesPerStep <= 0 {
+		cfg.PagesPerStep = 100
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+	source, err := Open(filename, OpenReadOnly, OpenFullMutex)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Base(filename)
+	m := &BackupManager{cfg: cfg, name: strings.TrimSuffix(base, filepath.Ext(base)), source: source,
+		done: make(chan bool), exited: make(chan bool)}
+	go m.loop()
+	return m, nil
+}
+
+func (m *BackupManager) loop() {
+	defer close(m.exited)
+	if m.cfg.Interval <= 0 {
+		<-m.done
+		return
+	}
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			m.BackupNow()
+		}
+	}
+}
+
+// BackupNow runs a backup (waiting for the current one to complete), removes the old backups
+// and returns the path of the new backup.
+func (m *BackupManager) BackupNow() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	start := time.Now()
+	path, err := m.backup(start.UTC())
+	if err == nil {
+		err = m.rotate(start)
+	}
+	if err != nil {
+		atomic.AddInt64(&m.failures, 1)
+		m.lastErr = err
+		if m.cfg.OnFailure != nil {
+			m.cfg.OnFailure(err)
+		}
+		return path, err
+	}
+	atomic.AddInt64(&m.backups, 1)
+	if m.cfg.OnSuccess != nil {
+		m.cfg.OnSuccess(path, time.Since(start))
+	}
+	return path, nil
+}
+
+func (m *BackupManager) filename(t string) string {
+	return strings.Replace(strings.Replace(m.cfg.Template, "{name}", m.name, -1), "{time}", t, -1)
+}
+
+func (m *BackupManager) backup(t time.Time) (string, error) {
+	path := filepath.Join(m.cfg.Dir, m.filename(t.Format(backupTimeLayout)))
+	tmp := path + ".tmp"
+	dst, err := Open(tmp, OpenReadWrite, OpenCreate, OpenFullMutex)
+	if err != nil {
+		return "", err
+	}
+	bck, err := NewBackup(dst, "main", m.source, "main")
+	if err == nil {
+		err = bck.Run(m.cfg.PagesPerStep, m.cfg.StepDelay, nil)
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path) // the backup is visible only once complete
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return path, nil
+}
+
+// List returns the paths of the existing backups, from the oldest to the most recent.
+func (m *BackupManager) List() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(m.cfg.Dir, m.filename("*")))
+	if err != nil {
+		return nil, err
+	}
+	backups := paths[:0]
+	for _, path := range paths {
+		if !strings.HasSuffix(path, ".tmp") {
+			backups = append(backups, path)
+		}
+	}
+	sort.Strings(backups) // the time format is sortable
+	return backups, nil
+}
+
+func (m *BackupManager) rotate(now time.Time) error {
+	backups, err := m.List()
+	if err != nil {
+		return err
+	}
+	for i, path := range backups {
+		remove := m.cfg.KeepLast > 0 && i < len(backups)-m.cfg.KeepLast
+		if !remove && m.cfg.KeepFor > 0 {
+			if fi, err := os.Stat(path); err == nil && now.Sub(fi.ModTime()) > m.cfg.KeepFor {
+				remove = true
+			}
+		}
+		if remove {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Backups returns the number of successful backups.
+func (m *BackupManager) Backups() int64 {
+	return atomic.LoadInt64(&m.backups)
+}
+
+// Failures returns the number of failed backups.
+func (m *BackupManager) Failures() int64 {
+	return atomic.LoadInt64(&m.failures)
+}
+
+// LastError returns the error of the last failed backup.
+func (m *BackupManager) LastError() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastErr
+}
+
+// Close stops the periodic backups (waiting for the current one to complete)
+// and closes the dedicated connection.
+func (m *BackupManager) Close() error {
+	select {
+	case <-m.done:
+		return nil
+	default:
+		close(m.done)
+	}
+	<-m.exited
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.source.Close()
+}
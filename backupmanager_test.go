@@ -0,0 +1,72 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"github.com/gwenn/gosqlite/sqlitetest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupManager(t *testing.T) {
+	db := sqlitetest.Open(t, sqlitetest.TempFile())
+	createTable(db, t)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('a'), ('b')"), "insert error: %s")
+	dir, err := ioutil.TempDir("", "gosqlite-backups")
+	checkNoError(t, err, "couldn't create temp dir: %s")
+	defer os.RemoveAll(dir)
+
+	var succeeded []string
+	m, err := NewBackupManager(db.Filename("main"), BackupConfig{Dir: dir, Template: "test-{time}.db", KeepLast: 2,
+		PagesPerStep: 1, OnSuccess: func(path string, elapsed time.Duration) {
+			succeeded = append(succeeded, path)
+		}})
+	checkNoError(t, err, "couldn't create backup manager: %s")
+	defer func() {
+		checkNoError(t, m.Close(), "couldn't close backup manager: %s")
+	}()
+
+	for i := 0; i < 3; i++ {
+		path, err := m.BackupNow()
+		checkNoError(t, err, "backup error: %s")
+		assert(t, "templated filename expected", strings.HasPrefix(filepath.Base(path), "test-"))
+	}
+	assertEquals(t, "expected %d backups but got %d", int64(3), m.Backups())
+	assertEquals(t, "expected %d notifications but got %d", 3, len(succeeded))
+	backups, err := m.List()
+	checkNoError(t, err, "list error: %s")
+	assertEquals(t, "expected %d kept backups but got %d", 2, len(backups))
+	assertEquals(t, "expected %q but got %q", succeeded[2], backups[1])
+
+	bck, err := Open(backups[1], OpenReadOnly)
+	checkNoError(t, err, "couldn't open backup: %s")
+	defer checkClose(bck, t)
+	var count int
+	checkNoError(t, bck.OneValue("SELECT count(*) FROM test", &count), "count error: %s")
+	assertEquals(t, "expected %d but got %d", 2, count)
+}
+
+func TestBackupManagerInterval(t *testing.T) {
+	db := sqlitetest.Open(t, sqlitetest.TempFile())
+	createTable(db, t)
+	dir, err := ioutil.TempDir("", "gosqlite-backups")
+	checkNoError(t, err, "couldn't create temp dir: %s")
+	defer os.RemoveAll(dir)
+
+	m, err := NewBackupManager(db.Filename("main"), BackupConfig{Dir: dir, Interval: 10 * time.Millisecond, KeepFor: time.Hour})
+	checkNoError(t, err, "couldn't create backup manager: %s")
+	deadline := time.Now().Add(5 * time.Second)
+	for m.Backups() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	checkNoError(t, m.Close(), "couldn't close backup manager: %s")
+	assert(t, "periodic backup expected", m.Backups() > 0)
+	checkNoError(t, m.LastError(), "backup error: %s")
+}
@@ -0,0 +1,197 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"strings"
+	"unsafe"
+)
+
+// ColumnKind is the columnar (Apache Arrow compatible) type of a result column.
+type ColumnKind int
+
+// Columnar types (with the matching Arrow types)
+const (
+	KindInt64   ColumnKind = iota // arrow.PrimitiveTypes.Int64
+	KindFloat64                   // arrow.PrimitiveTypes.Float64
+	KindString                    // arrow.BinaryTypes.String
+	KindBinary                    // arrow.BinaryTypes.Binary
+)
+
+func (k ColumnKind) String() string {
+	switch k {
+	case KindInt64:
+		return "int64"
+	case KindFloat64:
+		return "float64"
+	case KindString:
+		return "utf8"
+	case KindBinary:
+		return "binary"
+	}
+	return "unknown"
+}
+
+// Field describes one column of a RecordBatch.
+type Field struct {
+	Name string
+	Kind ColumnKind
+}
+
+// BatchColumn holds the values of one result column in a RecordBatch.
+// Only the slice matching the column kind is filled;
+// Valid is false for NULL values (the matching slot holds the zero value).
+type BatchColumn struct {
+	Valid    []bool
+	Int64s   []int64
+	Float64s []float64
+	Strings  []string
+	Binaries [][]byte
+}
+
+// RecordBatch is a set of rows stored column by column,
+// ready to be appended to Arrow array builders (one builder per field) for analytics pipelines.
+// (or written in the Arrow IPC streaming format, see Stmt.WriteArrow).
+type RecordBatch struct {
+	Fields  []Field
+	Columns []BatchColumn
+	Rows    int
+}
+
+// Schema returns the columnar schema of the statement result set.
+// The kind of a column is derived from its declared type, with the SQLite affinity rules
+// (INTEGER: int64, REAL: float64, TEXT: utf8, BLOB: binary).
+// Expressions, untyped columns and columns with NUMERIC affinity (DECIMAL, BOOLEAN, DATE, ...)
+// are typed with the value of the current row (utf8 when there is none).
+// (See http://sqlite.org/datatype3.html#determination_of_column_affinity)
+func (s *Stmt) Schema() []Field {
+	fields := make([]Field, s.ColumnCount())
+	for i := range fields {
+		fields[i].Name = s.ColumnName(i)
+		if kind, ok := declaredKind(s.ColumnDeclaredType(i)); ok {
+			fields[i].Kind = kind
+		} else {
+			fields[i].Kind = s.valueKind(i)
+		}
+	}
+	return fields
+}
+
+// declaredKind returns false when the kind depends on the storage class of the values
+// (no declared type or NUMERIC affinity).
+func declaredKind(declType string) (ColumnKind, bool) {
+	declType = strings.ToUpper(declType)
+	switch {
+	case len(declType) == 0:
+		return 0, false
+	case strings.Contains(declType, "INT"):
+		return KindInt64, true
+	case strings.Contains(declType, "CHAR"), strings.Contains(declType, "CLOB"), strings.Contains(declType, "TEXT"):
+		return KindString, true
+	case strings.Contains(declType, "BLOB"):
+		return KindBinary, true
+	case strings.Contains(declType, "REAL"), strings.Contains(declType, "FLOA"), strings.Contains(declType, "DOUB"):
+		return KindFloat64, true
+	}
+	return 0, false // NUMERIC
+}
+
+func (s *Stmt) valueKind(i int) ColumnKind {
+	if s.stmt == nil || C.sqlite3_stmt_busy(s.stmt) == 0 {
+		return KindString
+	}
+	switch s.ColumnType(i) {
+	case Integer:
+		return KindInt64
+	case Float:
+		return KindFloat64
+	case Blob:
+		return KindBinary
+	}
+	return KindString
+}
+
+// Batches steps the statement and calls f with record batches of at most size rows.
+// Values whose storage class does not match the column kind are converted by SQLite
+// (See http://sqlite.org/c3ref/column_blob.html).
+// The batch passed to f must not be retained as it is reused.
+// Args are for binding.
+func (s *Stmt) Batches(size int, f func(b *RecordBatch) error, args ...interface{}) error {
+	if size <= 0 {
+		return errors.New("invalid batch size")
+	}
+	if len(args) > 0 {
+		if err := s.Bind(args...); err != nil {
+			return err
+		}
+	}
+	var b *RecordBatch
+	for {
+		ok, err := s.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if b == nil {
+			b = &RecordBatch{Fields: s.Schema()}
+			b.Columns = make([]BatchColumn, len(b.Fields))
+		} else if b.Rows == size {
+			if err = f(b); err != nil {
+				return err
+			}
+			b.reset()
+		}
+		s.appendRow(b)
+	}
+	if b != nil && b.Rows > 0 {
+		return f(b)
+	}
+	return nil
+}
+
+func (b *RecordBatch) reset() {
+	for i := range b.Columns {
+		c := &b.Columns[i]
+		c.Valid, c.Int64s, c.Float64s, c.Strings, c.Binaries = c.Valid[:0], c.Int64s[:0], c.Float64s[:0], c.Strings[:0], c.Binaries[:0]
+	}
+	b.Rows = 0
+}
+
+func (s *Stmt) appendRow(b *RecordBatch) {
+	for i, field := range b.Fields {
+		c := &b.Columns[i]
+		valid := s.ColumnType(i) != Null
+		c.Valid = append(c.Valid, valid)
+		switch field.Kind {
+		case KindInt64:
+			c.Int64s = append(c.Int64s, int64(C.sqlite3_column_int64(s.stmt, C.int(i))))
+		case KindFloat64:
+			c.Float64s = append(c.Float64s, float64(C.sqlite3_column_double(s.stmt, C.int(i))))
+		case KindString:
+			var v string
+			if valid {
+				p := C.sqlite3_column_text(s.stmt, C.int(i))
+				v = C.GoStringN((*C.char)(unsafe.Pointer(p)), C.sqlite3_column_bytes(s.stmt, C.int(i)))
+			}
+			c.Strings = append(c.Strings, v)
+		case KindBinary:
+			var v []byte
+			if valid {
+				p := C.sqlite3_column_blob(s.stmt, C.int(i))
+				v = C.GoBytes(p, C.sqlite3_column_bytes(s.stmt, C.int(i)))
+			}
+			c.Binaries = append(c.Binaries, v)
+		}
+	}
+	b.Rows++
+}
@@ -0,0 +1,191 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	. "github.com/gwenn/gosqlite"
+	"testing"
+)
+
+func TestBatches(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE measure (id INTEGER, value REAL, label TEXT, raw BLOB)"), "create error: %s")
+	checkNoError(t, db.Exec("INSERT INTO measure VALUES (1, 1.5, 'a', x'01'), (2, NULL, 'b', NULL), (3, 3.5, NULL, x'0304')"), "insert error: %s")
+
+	s, err := db.Prepare("SELECT id, value, label, raw, id * 2 AS twice FROM measure ORDER BY id")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	var rows []int
+	var ids []int64
+	err = s.Batches(2, func(b *RecordBatch) error {
+		if len(rows) == 0 {
+			expected := []Field{{"id", KindInt64}, {"value", KindFloat64}, {"label", KindString}, {"raw", KindBinary}, {"twice", KindInt64}}
+			assertEquals(t, "expected %d fields but got %d", len(expected), len(b.Fields))
+			for i, f := range expected {
+				assertEquals(t, "expected %v but got %v", f, b.Fields[i])
+			}
+			assert(t, "NULL value expected", !b.Columns[1].Valid[1])
+			assertEquals(t, "expected %d but got %d", 1, len(b.Columns[3].Binaries[0]))
+		}
+		rows = append(rows, b.Rows)
+		ids = append(ids, b.Columns[4].Int64s...)
+		return nil
+	})
+	checkNoError(t, err, "batches error: %s")
+	assertEquals(t, "expected %d batches but got %d", 2, len(rows))
+	assertEquals(t, "expected %d rows but got %d", 1, rows[1])
+	assertEquals(t, "expected %v but got %v", "[2 4 6]", fmt.Sprint(ids))
+}
+
+func TestSchemaNumericAffinity(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE typed (price DECIMAL(10,2), day DATE, flag BOOLEAN, ratio DOUBLE)"), "create error: %s")
+	checkNoError(t, db.Exec("INSERT INTO typed VALUES (1.5, '2020-01-01', 1, 2)"), "insert error: %s")
+
+	s, err := db.Prepare("SELECT price, day, flag, ratio FROM typed")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	ok, err := s.Next()
+	checkNoError(t, err, "step error: %s")
+	assert(t, "row expected", ok)
+	expected := []Field{{"price", KindFloat64}, {"day", KindString}, {"flag", KindInt64}, {"ratio", KindFloat64}}
+	for i, f := range s.Schema() {
+		assertEquals(t, "expected %v but got %v", expected[i], f)
+	}
+}
+
+// fbReader reads a flatbuffers table (enough to check the Arrow IPC metadata).
+type fbReader struct {
+	buf []byte
+	pos int
+}
+
+func (t fbReader) field(id int) int {
+	vtable := t.pos - int(int32(binary.LittleEndian.Uint32(t.buf[t.pos:])))
+	if 4+2*id >= int(binary.LittleEndian.Uint16(t.buf[vtable:])) {
+		return 0
+	}
+	if offset := int(binary.LittleEndian.Uint16(t.buf[vtable+4+2*id:])); offset != 0 {
+		return t.pos + offset
+	}
+	return 0
+}
+
+func (t fbReader) deref(id int) int {
+	pos := t.field(id)
+	return pos + int(binary.LittleEndian.Uint32(t.buf[pos:]))
+}
+
+func (t fbReader) table(id int) fbReader {
+	return fbReader{t.buf, t.deref(id)}
+}
+
+func (t fbReader) vector(id int) (int, int) { // position of the first element and length
+	pos := t.deref(id)
+	return pos + 4, int(binary.LittleEndian.Uint32(t.buf[pos:]))
+}
+
+func (t fbReader) string(id int) string {
+	pos, n := t.vector(id)
+	return string(t.buf[pos : pos+n])
+}
+
+func readArrowMessage(t *testing.T, r *bytes.Reader) (fbReader, []byte) {
+	prefix := make([]byte, 8)
+	_, err := r.Read(prefix)
+	checkNoError(t, err, "read error: %s")
+	assertEquals(t, "expected continuation marker but got %x", uint32(0xffffffff), binary.LittleEndian.Uint32(prefix))
+	meta := make([]byte, binary.LittleEndian.Uint32(prefix[4:]))
+	if len(meta) == 0 {
+		return fbReader{}, nil
+	}
+	_, err = r.Read(meta)
+	checkNoError(t, err, "read error: %s")
+	assertEquals(t, "expected 8-byte aligned metadata but got %d bytes", 0, len(meta)%8)
+	msg := fbReader{meta, int(binary.LittleEndian.Uint32(meta))}
+	assertEquals(t, "expected metadata version %d but got %d", uint16(4), binary.LittleEndian.Uint16(meta[msg.field(0):]))
+	body := make([]byte, binary.LittleEndian.Uint64(meta[msg.field(3):]))
+	if len(body) > 0 {
+		_, err = r.Read(body)
+		checkNoError(t, err, "read error: %s")
+	}
+	return msg, body
+}
+
+func TestWriteArrow(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE measure (id INTEGER, value REAL, label TEXT, raw BLOB)"), "create error: %s")
+	checkNoError(t, db.Exec("INSERT INTO measure VALUES (1, 1.5, 'a', x'01'), (2, NULL, 'bc', NULL), (3, 3.5, NULL, x'0304')"), "insert error: %s")
+
+	s, err := db.Prepare("SELECT id, value, label, raw FROM measure ORDER BY id")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	var buf bytes.Buffer
+	checkNoError(t, s.WriteArrow(&buf, 2), "arrow error: %s")
+	r := bytes.NewReader(buf.Bytes())
+
+	msg, _ := readArrowMessage(t, r)
+	assertEquals(t, "expected schema header but got %d", byte(1), msg.buf[msg.field(1)])
+	schema := msg.table(2)
+	fields, n := schema.vector(1)
+	assertEquals(t, "expected %d fields but got %d", 4, n)
+	names := []string{"id", "value", "label", "raw"}
+	types := []byte{2, 3, 5, 4} // Int, FloatingPoint, Utf8, Binary
+	for i := 0; i < n; i++ {
+		slot := fields + 4*i
+		field := fbReader{schema.buf, slot + int(binary.LittleEndian.Uint32(schema.buf[slot:]))}
+		assertEquals(t, "expected %q but got %q", names[i], field.string(0))
+		assertEquals(t, "expected type %d but got %d", types[i], field.buf[field.field(2)])
+		_, children := field.vector(5)
+		assertEquals(t, "expected %d children but got %d", 0, children)
+	}
+
+	var ids []int64
+	var labels []string
+	for _, rows := range []int{2, 1} {
+		msg, body := readArrowMessage(t, r)
+		assertEquals(t, "expected record batch header but got %d", byte(3), msg.buf[msg.field(1)])
+		batch := msg.table(2)
+		assertEquals(t, "expected %d rows but got %d", uint64(rows), binary.LittleEndian.Uint64(batch.buf[batch.field(0):]))
+		nodes, n := batch.vector(1)
+		assertEquals(t, "expected %d nodes but got %d", 4, n)
+		assertEquals(t, "expected 8-byte aligned nodes but got %d", 0, nodes%8)
+		buffers, n := batch.vector(2)
+		assertEquals(t, "expected %d buffers but got %d", 10, n)
+		buffer := func(i int) []byte {
+			offset := binary.LittleEndian.Uint64(batch.buf[buffers+16*i:])
+			length := binary.LittleEndian.Uint64(batch.buf[buffers+16*i+8:])
+			assertEquals(t, "expected 8-byte aligned buffer but got %d", uint64(0), offset%8)
+			return body[offset : offset+length]
+		}
+		values := buffer(1)
+		for i := 0; i < rows; i++ {
+			ids = append(ids, int64(binary.LittleEndian.Uint64(values[8*i:])))
+		}
+		validity, offsets, data := buffer(4), buffer(5), buffer(6)
+		for i := 0; i < rows; i++ {
+			if validity[i/8]&(1<<uint(i%8)) == 0 {
+				labels = append(labels, "NULL")
+				continue
+			}
+			labels = append(labels, string(data[binary.LittleEndian.Uint32(offsets[4*i:]):binary.LittleEndian.Uint32(offsets[4*i+4:])]))
+		}
+		if rows == 2 {
+			nullCount := binary.LittleEndian.Uint64(batch.buf[nodes+16+8:]) // value column
+			assertEquals(t, "expected %d null but got %d", uint64(1), nullCount)
+		}
+	}
+	assertEquals(t, "expected %v but got %v", "[1 2 3]", fmt.Sprint(ids))
+	assertEquals(t, "expected %v but got %v", "[a bc NULL]", fmt.Sprint(labels))
+	msg, _ = readArrowMessage(t, r)
+	assert(t, "end of stream expected", msg.buf == nil)
+	assertEquals(t, "expected %d remaining bytes but got %d", 0, r.Len())
+}
@@ -6,6 +6,7 @@ package sqlite_test
 
 import (
 	. "github.com/gwenn/gosqlite"
+	"strings"
 	"testing"
 )
 
@@ -76,6 +77,26 @@ func BenchmarkScan(b *testing.B) {
 	}
 }
 
+func BenchmarkNextRow(b *testing.B) {
+	b.StopTimer()
+	db, err := Open(":memory:")
+	panicOnError(b, err)
+	defer db.Close()
+	fill(b, db, 1)
+
+	cs, err := db.Prepare("SELECT float_num, int_num, a_string FROM test")
+	panicOnError(b, err)
+	defer cs.Finalize()
+
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if row, _ := cs.NextRow(); row != nil {
+			_ = row[2].(string)
+		}
+		cs.Reset()
+	}
+}
+
 func BenchmarkNamedScan(b *testing.B) {
 	b.StopTimer()
 	db, err := Open(":memory:")
@@ -120,6 +141,40 @@ func BenchmarkInsert(b *testing.B) {
 	panicOnError(b, db.Commit())
 }
 
+func BenchmarkBindText(b *testing.B) {
+	db, err := Open(":memory:")
+	panicOnError(b, err)
+	defer db.Close()
+	s, err := db.Prepare("SELECT ?")
+	panicOnError(b, err)
+	defer s.Finalize()
+
+	var text interface{} = strings.Repeat("hello", 20)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.BindByIndex(1, text)
+	}
+}
+
+func BenchmarkBindInts(b *testing.B) {
+	db, err := Open(":memory:")
+	panicOnError(b, err)
+	defer db.Close()
+	s, err := db.Prepare("SELECT ?, ?, ?")
+	panicOnError(b, err)
+	defer s.Finalize()
+
+	var i32, u, u64 interface{} = int32(1), uint(2), uint64(3)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.BindByIndex(1, i32)
+		s.BindByIndex(2, u)
+		s.BindByIndex(3, u64)
+	}
+}
+
 func BenchmarkNamedInsert(b *testing.B) {
 	b.StopTimer()
 	db, err := Open(":memory:")
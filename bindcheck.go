@@ -0,0 +1,70 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"strconv"
+	"strings"
+)
+
+func (s *Stmt) setBound(index int) {
+	if s.bound == nil {
+		s.bound = make([]bool, s.BindParameterCount()+1)
+	}
+	if index > 0 && index < len(s.bound) {
+		s.bound[index] = true
+	}
+}
+
+// parameters calls f once for each parameter of the statement (in their order of appearance in the SQL)
+// with its index and its name (anonymous "?" parameters are named ?NNN with their index).
+func (s *Stmt) parameters(f func(index int, name string)) {
+	seen := make(map[int]bool)
+	var max int
+	scanSQL(s.SQL(), func(kind sqlToken, token string) {
+		if kind != parameterToken {
+			return
+		}
+		var index int
+		if token == "?" { // anonymous parameters are numbered after the largest index already assigned
+			index = max + 1
+			token += strconv.Itoa(index)
+		} else {
+			index = s.bindParameterIndex(token)
+		}
+		if index <= 0 || seen[index] {
+			return
+		}
+		seen[index] = true
+		if index > max {
+			max = index
+		}
+		f(index, token)
+	})
+}
+
+// UnboundParameters returns the parameters used by the statement but not bound
+// since it has been prepared or since the last ClearBindings (they are silently NULL):
+// named parameters (:AAA, @AAA, $AAA and ?NNN) by their name and anonymous ones (?) as ?NNN with their index.
+// The gaps in the parameter indexes (see Stmt.BindParameterIndices) are ignored.
+func (s *Stmt) UnboundParameters() []string {
+	var unbound []string
+	s.parameters(func(index int, name string) {
+		if index >= len(s.bound) || !s.bound[index] {
+			unbound = append(unbound, name)
+		}
+	})
+	return unbound
+}
+
+// CheckBindings returns an error listing the unbound parameters (see UnboundParameters), if any.
+// It should be called before stepping statements with ?NNN parameters or mixed parameter styles
+// where a missed binding would silently be NULL.
+func (s *Stmt) CheckBindings() error {
+	if unbound := s.UnboundParameters(); len(unbound) > 0 {
+		return s.specificError("unbound parameter(s): %s", strings.Join(unbound, ", "))
+	}
+	return nil
+}
@@ -20,10 +20,20 @@ import (
 // BlobReader is an io.ReadCloser adapter for BLOB
 // (See http://sqlite.org/c3ref/blob.html)
 type BlobReader struct {
-	c      *Conn
-	bl     *C.sqlite3_blob
+	c *Conn
+	*blobHandle
 	size   int
 	offset int
+	// to re-open the handle (see Refresh)
+	dbName, table, column string
+	row                   int64
+	write                 bool
+}
+
+// blobHandle is the part of a BlobReader tracked by the connection (see Conn.closeLeaks)
+// so that an unclosed BlobReader can still be garbage collected (and reported, see setLeakFinalizer).
+type blobHandle struct {
+	bl *C.sqlite3_blob
 }
 
 // BlobReadWriter is an io.ReadWriteCloser adapter for BLOB
@@ -31,6 +41,11 @@ type BlobReadWriter struct {
 	BlobReader
 }
 
+// ErrBlobExpired is returned by BlobReader.Read and BlobReadWriter.Write
+// when the row of the BLOB has been modified or deleted since the handle was opened.
+// The handle can be re-opened on the same row with BlobReader.Refresh.
+var ErrBlobExpired = errors.New("sqlite blob expired: its row has been modified or deleted")
+
 // ZeroBlobLength is used to reserve space for a BLOB that is later written.
 type ZeroBlobLength int
 
@@ -42,7 +57,12 @@ func (c *Conn) NewBlobReader(db, table, column string, row int64) (*BlobReader,
 	if err != nil {
 		return nil, err
 	}
-	return &BlobReader{c, bl, -1, 0}, nil
+	r := &BlobReader{c: c, blobHandle: &blobHandle{bl}, size: -1, dbName: db, table: table, column: column, row: row}
+	c.trackBlob(r.blobHandle)
+	if debugLeaks {
+		setLeakFinalizer(r, fmt.Sprintf("blob (db: %q, tbl: %q, col: %q, row: %d)", db, table, column, row))
+	}
+	return r, nil
 }
 
 // NewBlobReadWriter opens a BLOB for incremental I/O.
@@ -52,7 +72,19 @@ func (c *Conn) NewBlobReadWriter(db, table, column string, row int64) (*BlobRead
 	if err != nil {
 		return nil, err
 	}
-	return &BlobReadWriter{BlobReader{c, bl, -1, 0}}, nil
+	w := &BlobReadWriter{BlobReader{c: c, blobHandle: &blobHandle{bl}, size: -1, dbName: db, table: table, column: column, row: row, write: true}}
+	c.trackBlob(w.blobHandle)
+	if debugLeaks {
+		setLeakFinalizer(w, fmt.Sprintf("blob (db: %q, tbl: %q, col: %q, row: %d)", db, table, column, row))
+	}
+	return w, nil
+}
+
+func (c *Conn) trackBlob(h *blobHandle) {
+	if c.blobs == nil {
+		c.blobs = make(map[*blobHandle]bool)
+	}
+	c.blobs[h] = true
 }
 
 func (c *Conn) blobOpen(db, table, column string, row int64, write bool) (*C.sqlite3_blob, error) {
@@ -83,10 +115,11 @@ func (r *BlobReader) Close() error {
 		return errors.New("nil sqlite blob")
 	}
 	rv := C.sqlite3_blob_close(r.bl)
+	delete(r.c.blobs, r.blobHandle)
+	r.bl = nil
 	if rv != C.SQLITE_OK {
 		return r.c.error(rv, "BlobReader.Close")
 	}
-	r.bl = nil
 	return nil
 }
 
@@ -109,7 +142,9 @@ func (r *BlobReader) Read(v []byte) (int, error) {
 	p := &v[0]
 	n := len(v)
 	rv := C.sqlite3_blob_read(r.bl, unsafe.Pointer(p), C.int(n), C.int(r.offset))
-	if rv != C.SQLITE_OK {
+	if rv == C.SQLITE_ABORT {
+		return 0, ErrBlobExpired
+	} else if rv != C.SQLITE_OK {
 		return 0, r.c.error(rv, "BlobReader.Read")
 	}
 	r.offset += n
@@ -168,7 +203,9 @@ func (w *BlobReadWriter) Write(v []byte) (int, error) {
 	p := &v[0]
 	n := len(v)
 	rv := C.sqlite3_blob_write(w.bl, unsafe.Pointer(p), C.int(n), C.int(w.offset))
-	if rv != C.SQLITE_OK {
+	if rv == C.SQLITE_ABORT {
+		return 0, ErrBlobExpired
+	} else if rv != C.SQLITE_OK {
 		return 0, w.c.error(rv, "BlobReadWiter.Write")
 	}
 	w.offset += n
@@ -184,5 +221,23 @@ func (r *BlobReader) Reopen(rowid int64) error {
 	}
 	r.size = -1
 	r.offset = 0
+	r.row = rowid
+	return nil
+}
+
+// Refresh re-opens an expired BLOB handle on the same row (see ErrBlobExpired).
+// The offset is kept so that a stream can be resumed (the size may have changed).
+// (See http://sqlite.org/c3ref/blob_open.html)
+func (r *BlobReader) Refresh() error {
+	if r.bl == nil {
+		return errors.New("blob reader already closed")
+	}
+	bl, err := r.c.blobOpen(r.dbName, r.table, r.column, r.row, r.write)
+	if err != nil {
+		return err
+	}
+	C.sqlite3_blob_close(r.bl)
+	r.bl = bl
+	r.size = -1
 	return nil
 }
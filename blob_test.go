@@ -72,3 +72,28 @@ func TestBlobMisuse(t *testing.T) {
 	/*err = bw.Close()
 	assert(t, "error expected", err != nil)*/
 }
+
+func TestBlobExpired(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE test (content BLOB, n INTEGER)"), "error creating table: %s")
+	checkNoError(t, db.Exec("INSERT INTO test VALUES (x'0102030405', 0)"), "insert error: %s")
+	rowid := db.LastInsertRowid()
+
+	br, err := db.NewBlobReader("main", "test", "content", rowid)
+	checkNoError(t, err, "blob open error: %s")
+	defer br.Close()
+	content := make([]byte, 2)
+	_, err = br.Read(content)
+	checkNoError(t, err, "blob read error: %s")
+
+	checkNoError(t, db.Exec("UPDATE test SET n = n + 1"), "update error: %s")
+	_, err = br.Read(content)
+	assertEquals(t, "expected %v but got %v", ErrBlobExpired, err)
+
+	checkNoError(t, br.Refresh(), "blob refresh error: %s")
+	n, err := br.Read(content)
+	checkNoError(t, err, "blob read error: %s")
+	assertEquals(t, "expected %d bytes but got %d", 2, n)
+	assertEquals(t, "expected %d but got %d", byte(3), content[0])
+}
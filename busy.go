@@ -0,0 +1,151 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// Backoff is a busy handling policy with exponential backoff, jitter and a total deadline.
+// Unlike Conn.BusyTimeout, which retries at a fixed pace, waits grow from Initial to Max
+// so that contending connections spread their retries.
+// One Backoff can be shared by many connections (see Conn.BusyBackoff):
+// its counters are then aggregated.
+type Backoff struct {
+	Initial    time.Duration // first wait (default 1ms)
+	Max        time.Duration // maximum single wait (default 100ms)
+	Multiplier float64       // wait growth factor (default 2)
+	Deadline   time.Duration // total wait before SQLITE_BUSY is returned (default 5s)
+
+	waits    int64 // accessed atomically
+	waited   int64 // accessed atomically
+	timeouts int64 // accessed atomically
+}
+
+// Waits returns the number of times the handler slept.
+func (b *Backoff) Waits() int64 {
+	return atomic.LoadInt64(&b.waits)
+}
+
+// Waited returns the cumulated time spent sleeping.
+func (b *Backoff) Waited() time.Duration {
+	return time.Duration(atomic.LoadInt64(&b.waited))
+}
+
+// Timeouts returns the number of times the deadline was reached (and SQLITE_BUSY returned).
+func (b *Backoff) Timeouts() int64 {
+	return atomic.LoadInt64(&b.timeouts)
+}
+
+// Handler returns a BusyHandler implementing the policy.
+// The returned handler must not be shared by connections
+// because it keeps track of the current busy episode.
+func (b *Backoff) Handler() BusyHandler {
+	var start time.Time
+	return func(udp interface{}, count int) bool {
+		if count == 0 {
+			start = time.Now()
+		}
+		deadline := b.Deadline
+		if deadline <= 0 {
+			deadline = 5 * time.Second
+		}
+		remaining := deadline - time.Since(start)
+		if remaining <= 0 {
+			atomic.AddInt64(&b.timeouts, 1)
+			return false
+		}
+		d := b.delay(count)
+		if d > remaining {
+			d = remaining
+		}
+		time.Sleep(d)
+		atomic.AddInt64(&b.waits, 1)
+		atomic.AddInt64(&b.waited, int64(d))
+		return true
+	}
+}
+
+// delay returns the wait before the specified retry: a random duration in [d/2, d]
+// where d = min(Initial * Multiplier^count, Max).
+func (b *Backoff) delay(count int) time.Duration {
+	initial, max, multiplier := b.Initial, b.Max, b.Multiplier
+	if initial <= 0 {
+		initial = time.Millisecond
+	}
+	if max <= 0 {
+		max = 100 * time.Millisecond
+	}
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	d := float64(initial)
+	for i := 0; i < count && d < float64(max); i++ {
+		d *= multiplier
+	}
+	if d > float64(max) {
+		d = float64(max)
+	}
+	return time.Duration(d/2 + rand.Float64()*d/2)
+}
+
+// BusyBackoff registers a busy handler implementing the specified backoff policy.
+// (See Backoff and Conn.BusyHandler)
+func (c *Conn) BusyBackoff(b *Backoff) error {
+	return c.BusyHandler(b.Handler(), nil)
+}
+
+// BusyStats reports the lock contention encountered by a connection (see Conn.BusyStats).
+type BusyStats struct {
+	Errors      int64         // SQLITE_BUSY or SQLITE_LOCKED errors (see Conn.BusyCount)
+	Invocations int64         // busy handler calls (see Conn.BusyHandler and Conn.InstrumentedBusyTimeout)
+	Blocked     time.Duration // total time spent in the busy handler
+}
+
+// BusyStats returns the lock contention counters of the connection.
+func (c *Conn) BusyStats() BusyStats {
+	return BusyStats{
+		Errors:      atomic.LoadInt64(&c.nBusy),
+		Invocations: atomic.LoadInt64(&c.nBusyCalls),
+		Blocked:     time.Duration(atomic.LoadInt64(&c.busyBlocked)),
+	}
+}
+
+// InstrumentedBusyTimeout sets a busy timeout (<= 0 clears the busy handler)
+// with a Go handler sleeping like the one registered by sqlite3_busy_timeout
+// so that its invocations and the time spent in it are reported by Conn.BusyStats.
+// The cost is a cgo callback per invocation and PRAGMA busy_timeout reporting 0.
+func (c *Conn) InstrumentedBusyTimeout(d time.Duration) error {
+	if d <= 0 {
+		return c.BusyHandler(nil, nil)
+	}
+	return c.BusyHandler(busyTimeoutHandler(d), nil)
+}
+
+// busyDelays are the sleeps of the default SQLite busy handler.
+var busyDelays = [...]time.Duration{1, 2, 5, 10, 15, 20, 25, 25, 25, 50, 50, 100}
+
+// busyTimeoutHandler returns a BusyHandler sleeping like the default SQLite one until timeout is reached.
+func busyTimeoutHandler(timeout time.Duration) BusyHandler {
+	return func(udp interface{}, count int) bool {
+		var delay, prior time.Duration
+		for i := 0; i <= count; i++ {
+			prior += delay
+			if i < len(busyDelays) {
+				delay = busyDelays[i] * time.Millisecond
+			}
+		}
+		if prior+delay > timeout {
+			delay = timeout - prior
+			if delay <= 0 {
+				return false
+			}
+		}
+		time.Sleep(delay)
+		return true
+	}
+}
@@ -5,7 +5,9 @@
 package sqlite_test
 
 import (
+	"errors"
 	. "github.com/gwenn/gosqlite"
+	"github.com/gwenn/gosqlite/sqlitetest"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -34,6 +36,54 @@ func TestInterrupt(t *testing.T) {
 	}
 }
 
+func TestNextInterrupted(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	interrupt := true
+	db.CreateScalarFunction("maybe_interrupt", 0, nil, func(ctx *ScalarContext, nArg int) {
+		if interrupt {
+			db.Interrupt()
+		}
+		ctx.ResultText("ok")
+	}, nil)
+	s, err := db.Prepare("SELECT maybe_interrupt() FROM (SELECT 1 UNION SELECT 2)")
+	checkNoError(t, err, "couldn't prepare stmt: %#v")
+	defer checkFinalize(s, t)
+
+	err = s.Select(func(s *Stmt) error {
+		return nil
+	})
+	assert(t, "interrupted error expected", errors.Is(err, ErrInterrupted))
+	assert(t, "unexpected stale snapshot error", !errors.Is(err, ErrBusySnapshot))
+	assert(t, "statement expected to be reset", !s.Busy())
+
+	interrupt = false
+	ok, err := s.Next()
+	checkNoError(t, err, "couldn't step reset stmt: %s")
+	assert(t, "row expected", ok)
+}
+
+func TestNextBusySnapshot(t *testing.T) {
+	db1 := sqlitetest.Open(t, sqlitetest.Wal())
+	db2, err := Open(db1.Filename("main"), OpenReadWrite, OpenFullMutex)
+	checkNoError(t, err, "couldn't open database file: %s")
+	defer checkClose(db2, t)
+	createTable(db1, t)
+
+	checkNoError(t, db2.Begin(), "couldn't begin transaction: %s")
+	defer db2.Rollback()
+	var count int
+	checkNoError(t, db2.OneValue("SELECT count(1) FROM test", &count), "%s")
+	checkNoError(t, db1.Exec("INSERT INTO test (a_string) VALUES ('first')"), "insert error: %s")
+
+	s, err := db2.Prepare("INSERT INTO test (a_string) VALUES ('second')")
+	checkNoError(t, err, "couldn't prepare stmt: %#v")
+	defer checkFinalize(s, t)
+	_, err = s.Next()
+	assert(t, "stale snapshot error expected", errors.Is(err, ErrBusySnapshot))
+	assert(t, "unexpected interrupted error", !errors.Is(err, ErrInterrupted))
+}
+
 func openTwoConnSameDb(t *testing.T) (*os.File, *Conn, *Conn) {
 	f, err := ioutil.TempFile("", "gosqlite-test")
 	checkNoError(t, f.Close(), "couldn't close temp file: %s")
@@ -59,6 +109,7 @@ func TestDefaultBusy(t *testing.T) {
 	if se, ok := err.(*StmtError); !ok || se.Code() != ErrBusy {
 		t.Fatalf("Exepted lock but got %#v", err)
 	}
+	assert(t, "busy count expected", db2.BusyCount() > 0)
 }
 
 func TestBusyTimeout(t *testing.T) {
@@ -81,6 +132,34 @@ func TestBusyTimeout(t *testing.T) {
 	//<- join
 }
 
+func TestBusyTimeoutPragma(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.BusyTimeout(250*time.Millisecond), "couldn't set busy timeout: %s")
+	var ms int
+	checkNoError(t, db.OneValue("PRAGMA busy_timeout", &ms), "couldn't read busy timeout: %s")
+	assertEquals(t, "expected %d but got %d", 250, ms)
+}
+
+func TestBusyStats(t *testing.T) {
+	f, db1, db2 := openTwoConnSameDb(t)
+	defer os.Remove(f.Name())
+	defer checkClose(db1, t)
+	defer checkClose(db2, t)
+	checkNoError(t, db2.InstrumentedBusyTimeout(20*time.Millisecond), "couldn't set busy timeout: %s")
+	checkNoError(t, db1.BeginTransaction(Exclusive), "couldn't begin transaction: %s")
+	defer db1.Rollback()
+
+	start := time.Now()
+	_, err := db2.SchemaVersion("")
+	assert(t, "busy error expected", err != nil)
+	assert(t, "busy timeout not respected", time.Since(start) >= 20*time.Millisecond)
+	stats := db2.BusyStats()
+	assert(t, "busy error expected", stats.Errors > 0)
+	assert(t, "busy handler invocations expected", stats.Invocations > 1)
+	assert(t, "blocked time expected", stats.Blocked >= 15*time.Millisecond)
+}
+
 func TestBusyHandler(t *testing.T) {
 	f, db1, db2 := openTwoConnSameDb(t)
 	defer os.Remove(f.Name())
@@ -110,3 +189,59 @@ func TestBusyHandler(t *testing.T) {
 	checkNoError(t, err, "couldn't query schema version: %#v")
 	assert(t, "busy handler not called!", called)
 }
+
+func TestBusyBackoff(t *testing.T) {
+	f, db1, db2 := openTwoConnSameDb(t)
+	defer os.Remove(f.Name())
+	defer checkClose(db1, t)
+	defer checkClose(db2, t)
+
+	b := &Backoff{Deadline: 20 * time.Millisecond}
+	checkNoError(t, db2.BusyBackoff(b), "couldn't set busy handler: %s")
+	checkNoError(t, db1.BeginTransaction(Exclusive), "couldn't begin transaction: %s")
+
+	_, err := db2.SchemaVersion("")
+	assert(t, "busy error expected", err != nil)
+	assertEquals(t, "expected %d timeouts but got %d", int64(1), b.Timeouts())
+	assert(t, "waits expected", b.Waits() > 0)
+	assert(t, "deadline not respected", b.Waited() <= 20*time.Millisecond)
+
+	b.Deadline = time.Second
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		db1.Rollback()
+	}()
+	_, err = db2.SchemaVersion("")
+	checkNoError(t, err, "couldn't query schema version: %#v")
+	assertEquals(t, "expected %d timeouts but got %d", int64(1), b.Timeouts())
+}
+
+func TestRetryPolicy(t *testing.T) {
+	f, db1, db2 := openTwoConnSameDb(t)
+	defer os.Remove(f.Name())
+	defer checkClose(db1, t)
+	defer checkClose(db2, t)
+	createTable(db1, t)
+	checkNoError(t, db1.BeginTransaction(Exclusive), "couldn't begin transaction: %s")
+
+	db2.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	err := db2.Exec("INSERT INTO test (a_string) VALUES ('retry')")
+	assert(t, "busy error expected", err != nil)
+	assertEquals(t, "expected %d retries but got %d", int64(2), db2.RetryCount())
+
+	db2.SetRetryPolicy(RetryPolicy{MaxAttempts: 100, BaseDelay: time.Millisecond, Jitter: 0.5})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		db1.Rollback()
+	}()
+	checkNoError(t, db2.Exec("INSERT INTO test (a_string) VALUES ('retry')"), "insert error: %s")
+	assert(t, "retries expected", db2.RetryCount() > 2)
+
+	db2.SetRetryPolicy(RetryPolicy{})
+	checkNoError(t, db1.BeginTransaction(Exclusive), "couldn't begin transaction: %s")
+	defer db1.Rollback()
+	retries := db2.RetryCount()
+	err = db2.Exec("INSERT INTO test (a_string) VALUES ('retry')")
+	assert(t, "busy error expected", err != nil)
+	assertEquals(t, "expected %d retries but got %d", retries, db2.RetryCount())
+}
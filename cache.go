@@ -18,6 +18,11 @@ type cache struct {
 	m       sync.Mutex
 	l       *list.List
 	maxSize int // Cache turned off when maxSize <= 0
+	hits    int
+	misses  int
+
+	checkSchema   bool // see Conn.SetCacheSchemaCheck
+	schemaVersion int
 }
 
 func newCache() *cache {
@@ -43,11 +48,14 @@ func (c *cache) find(sql string) *Stmt {
 			c.l.Remove(e)
 			if err := s.ClearBindings(); err != nil {
 				s.finalize()
+				c.misses++
 				return nil
 			}
+			c.hits++
 			return s
 		}
 	}
+	c.misses++
 	return nil
 }
 
@@ -56,10 +64,14 @@ func (c *cache) release(s *Stmt) error {
 	if c.maxSize <= 0 || len(s.tail) > 0 || s.Busy() {
 		return s.finalize()
 	}
+	s.collectScanStats()
 	if err := s.Reset(); err != nil {
 		s.finalize()
 		return err
 	}
+	s.limits = nil
+	s.StrictNull = false
+	s.typeMismatch = s.c.typeMismatch
 	c.m.Lock()
 	defer c.m.Unlock()
 	c.l.PushFront(s)
@@ -93,6 +105,15 @@ func (c *Conn) CacheSize() (int, int) {
 	return c.stmtCache.l.Len(), c.stmtCache.maxSize
 }
 
+// CacheStats returns the number of lookups in the prepared statements cache
+// that found (hits) or did not find (misses) a reusable statement.
+// Lookups are not counted while the cache is turned off.
+func (c *Conn) CacheStats() (hits, misses int) {
+	c.stmtCache.m.Lock()
+	defer c.stmtCache.m.Unlock()
+	return c.stmtCache.hits, c.stmtCache.misses
+}
+
 // SetCacheSize sets the size of prepared statements cache.
 // Cache is turned off (and flushed) when size <= 0
 func (c *Conn) SetCacheSize(size int) {
@@ -93,3 +93,16 @@ func BenchmarkEnabledCache(b *testing.B) {
 		b.Errorf("%d <> %d || %d <> %d", 1, size, 10, maxSize)
 	}
 }
+
+func TestCacheStats(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	for i := 0; i < 3; i++ {
+		s, err := db.Prepare("SELECT 1")
+		checkNoError(t, err, "couldn't prepare stmt: %#v")
+		checkFinalize(s, t)
+	}
+	hits, misses := db.CacheStats()
+	assertEquals(t, "expected %d hits but got %d", 2, hits)
+	assertEquals(t, "expected %d misses but got %d", 1, misses)
+}
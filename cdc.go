@@ -0,0 +1,190 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_preupdate
+// +build sqlite_preupdate
+
+package sqlite
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// CDCEvent is a row-level change data capture event.
+type CDCEvent struct {
+	DbName   string        `json:"db"`
+	Table    string        `json:"table"`
+	Op       string        `json:"op"` // INSERT, UPDATE or DELETE
+	OldRowId int64         `json:"oldRowId,omitempty"`
+	NewRowId int64         `json:"newRowId,omitempty"`
+	Old      []interface{} `json:"old,omitempty"` // values before UPDATE/DELETE
+	New      []interface{} `json:"new,omitempty"` // values after INSERT/UPDATE
+}
+
+// CDCSink receives the events of each committed transaction, in commit order.
+type CDCSink func(events []CDCEvent) error
+
+// JSONSink returns a sink writing each event as one line of JSON.
+func JSONSink(w io.Writer) CDCSink {
+	enc := json.NewEncoder(w)
+	return func(events []CDCEvent) error {
+		for i := range events {
+			if err := enc.Encode(&events[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// CDCPolicy tells what happens on commit when the sink is lagging and the buffer is full.
+type CDCPolicy int
+
+const (
+	CDCBlock  CDCPolicy = iota // the committing call waits for the sink once committed (the write lock is released)
+	CDCReject                  // the commit is converted into a rollback
+)
+
+// CDC captures the changes made through a connection (with the pre-update hook)
+// and sends them to a sink, from another goroutine, once their transaction is committed.
+// Changes of rolled back transactions are dropped (even when the rollback is decided by another commit hook)
+// and so are the changes undone by ROLLBACK TO a savepoint.
+// Only available with the sqlite_preupdate build tag.
+type CDC struct {
+	c       *Conn
+	tokens  []HookToken
+	current []CDCEvent     // events of the current transaction
+	marks   savepointMarks // length of current at each savepoint
+	commit  []CDCEvent     // events accepted by the commit hook, published once committed
+	policy  CDCPolicy
+	txs     chan []CDCEvent
+	exited  chan bool
+
+	mu       sync.Mutex
+	err      error
+	rejected int
+}
+
+// NewCDC starts capturing the changes made through c.
+// buffer is the number of committed transactions that can wait for the sink
+// (with CDCReject, it must be positive otherwise all commits with changes are rejected).
+// The pre-update hook of c is replaced.
+func NewCDC(c *Conn, sink CDCSink, buffer int, policy CDCPolicy) *CDC {
+	cdc := &CDC{c: c, policy: policy, txs: make(chan []CDCEvent, buffer), exited: make(chan bool)}
+	c.PreUpdateHook(func(udp interface{}, d *PreUpdateData) {
+		e := CDCEvent{DbName: d.DbName, Table: d.TableName}
+		var err error
+		switch d.Op {
+		case Insert:
+			e.Op, e.NewRowId = "INSERT", d.NewRowId
+			e.New, err = d.NewRow()
+		case Update:
+			e.Op, e.OldRowId, e.NewRowId = "UPDATE", d.OldRowId, d.NewRowId
+			if e.Old, err = d.OldRow(); err == nil {
+				e.New, err = d.NewRow()
+			}
+		case Delete:
+			e.Op, e.OldRowId = "DELETE", d.OldRowId
+			e.Old, err = d.OldRow()
+		}
+		if err != nil {
+			cdc.setError(err)
+		}
+		cdc.current = append(cdc.current, e)
+	}, nil)
+	cdc.tokens = []HookToken{
+		c.AddCommitHook(func(udp interface{}) bool {
+			return cdc.reject()
+		}, nil),
+		c.AddRollbackHook(func(udp interface{}) {
+			cdc.current = nil
+			cdc.marks.reset()
+			cdc.commit = nil
+		}, nil),
+		c.addSavepointHook(func(op savepointOp, name string) {
+			cdc.current = cdc.current[:cdc.marks.apply(op, name, len(cdc.current))]
+		}),
+		c.addAfterCommitHook(cdc.publish),
+	}
+	go cdc.loop(sink)
+	return cdc
+}
+
+// reject is called by the commit hook: it never blocks
+// and tells if the commit must be converted into a rollback (CDCReject policy and full buffer).
+// The buffer cannot be filled before the events are published because the connection is the only sender.
+func (cdc *CDC) reject() bool {
+	if len(cdc.current) == 0 {
+		return false
+	}
+	if cdc.policy == CDCReject && len(cdc.txs) >= cap(cdc.txs) {
+		cdc.mu.Lock()
+		cdc.rejected++
+		cdc.mu.Unlock()
+		return true
+	}
+	cdc.commit = append(cdc.commit, cdc.current...)
+	cdc.current = nil
+	cdc.marks.reset()
+	return false
+}
+
+// publish is called once the transaction is committed.
+func (cdc *CDC) publish() {
+	if len(cdc.commit) == 0 {
+		return
+	}
+	events := cdc.commit
+	cdc.commit = nil
+	cdc.txs <- events
+}
+
+func (cdc *CDC) loop(sink CDCSink) {
+	defer close(cdc.exited)
+	for events := range cdc.txs {
+		if err := sink(events); err != nil {
+			cdc.setError(err)
+		}
+	}
+}
+
+func (cdc *CDC) setError(err error) {
+	cdc.mu.Lock()
+	if cdc.err == nil {
+		cdc.err = err
+	}
+	cdc.mu.Unlock()
+}
+
+// Err returns the first error encountered while capturing or sending events.
+func (cdc *CDC) Err() error {
+	cdc.mu.Lock()
+	defer cdc.mu.Unlock()
+	return cdc.err
+}
+
+// Rejected returns the number of commits converted into rollbacks (CDCReject policy).
+func (cdc *CDC) Rejected() int {
+	cdc.mu.Lock()
+	defer cdc.mu.Unlock()
+	return cdc.rejected
+}
+
+// Close stops capturing changes (it must be called from the goroutine using the connection),
+// waits for the buffered transactions to be sent and returns the first error.
+func (cdc *CDC) Close() error {
+	if cdc.tokens == nil {
+		return cdc.Err()
+	}
+	cdc.c.PreUpdateHook(nil, nil)
+	for _, token := range cdc.tokens {
+		cdc.c.RemoveHook(token)
+	}
+	cdc.tokens = nil
+	close(cdc.txs)
+	<-cdc.exited
+	return cdc.Err()
+}
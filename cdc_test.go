@@ -0,0 +1,114 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_preupdate
+// +build sqlite_preupdate
+
+package sqlite_test
+
+import (
+	"bytes"
+	"encoding/json"
+	. "github.com/gwenn/gosqlite"
+	"strings"
+	"testing"
+)
+
+func TestCDC(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+
+	var buf bytes.Buffer
+	cdc := NewCDC(db, JSONSink(&buf), 10, CDCBlock)
+	checkNoError(t, db.Exec("INSERT INTO test (int_num, a_string) VALUES (1, 'a')"), "insert error: %s")
+	checkNoError(t, db.Exec("UPDATE test SET a_string = 'b'"), "update error: %s")
+	checkNoError(t, db.Begin(), "begin error: %s")
+	checkNoError(t, db.Exec("DELETE FROM test"), "delete error: %s")
+	checkNoError(t, db.Rollback(), "rollback error: %s")
+	checkNoError(t, db.Exec("DELETE FROM test"), "delete error: %s")
+	checkNoError(t, cdc.Close(), "cdc error: %s")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assertEquals(t, "expected %d events but got %d", 3, len(lines))
+	var events []CDCEvent
+	for _, line := range lines {
+		var e CDCEvent
+		checkNoError(t, json.Unmarshal([]byte(line), &e), "unmarshal error: %s")
+		events = append(events, e)
+	}
+	assertEquals(t, "expected %q but got %q", "INSERT", events[0].Op)
+	assertEquals(t, "expected %q but got %q", "test", events[0].Table)
+	assertEquals(t, "expected %v but got %v", "a", events[0].New[3])
+	assertEquals(t, "expected %q but got %q", "UPDATE", events[1].Op)
+	assertEquals(t, "expected %v but got %v", "a", events[1].Old[3])
+	assertEquals(t, "expected %v but got %v", "b", events[1].New[3])
+	assertEquals(t, "expected %q but got %q", "DELETE", events[2].Op)
+	assertEquals(t, "expected %d but got %d", int64(1), events[2].OldRowId)
+}
+
+func TestCDCReject(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+
+	taken, block := make(chan bool, 1), make(chan bool)
+	cdc := NewCDC(db, func(events []CDCEvent) error {
+		taken <- true
+		<-block
+		return nil
+	}, 1, CDCReject)
+	// the first transaction is taken by the sink, the second one is buffered
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('a')"), "insert error: %s")
+	<-taken
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('b')"), "insert error: %s")
+	err := db.Exec("INSERT INTO test (a_string) VALUES ('c')")
+	assert(t, "commit rejection expected", err != nil)
+	assertEquals(t, "expected %d but got %d", 1, cdc.Rejected())
+	var count int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM test", &count), "count error: %s")
+	assertEquals(t, "expected %d but got %d", 2, count)
+	close(block)
+	checkNoError(t, cdc.Close(), "cdc error: %s")
+}
+
+func TestCDCVetoedCommit(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+
+	var buf bytes.Buffer
+	cdc := NewCDC(db, JSONSink(&buf), 10, CDCBlock)
+	token := db.AddCommitHook(func(udp interface{}) bool { return true }, nil)
+	err := db.Exec("INSERT INTO test (a_string) VALUES ('a')")
+	assert(t, "commit hook error expected", err != nil)
+	db.RemoveHook(token)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('b')"), "insert error: %s")
+	checkNoError(t, cdc.Close(), "cdc error: %s")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assertEquals(t, "expected %d event but got %d", 1, len(lines))
+	var e CDCEvent
+	checkNoError(t, json.Unmarshal([]byte(lines[0]), &e), "unmarshal error: %s")
+	assertEquals(t, "expected %v but got %v", "b", e.New[3])
+}
+
+func TestCDCRollbackToSavepoint(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+
+	var events []CDCEvent
+	cdc := NewCDC(db, func(tx []CDCEvent) error {
+		events = append(events, tx...)
+		return nil
+	}, 10, CDCBlock)
+	checkNoError(t, db.Exec("BEGIN; INSERT INTO test (id) VALUES (9); SAVEPOINT a; INSERT INTO test (id) VALUES (10); "+
+		"ROLLBACK TO a; RELEASE a; COMMIT"), "exec error: %s")
+	checkNoError(t, cdc.Close(), "cdc error: %s")
+
+	assertEquals(t, "expected %d events but got %d", 1, len(events))
+	assertEquals(t, "expected %q but got %q", "INSERT", events[0].Op)
+	assertEquals(t, "expected %d but got %d", int64(9), events[0].NewRowId)
+}
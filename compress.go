@@ -0,0 +1,173 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// CompressFormat is the format of the BLOBs returned by the compress SQL function (see Conn.CreateCompressFunctions).
+type CompressFormat int
+
+const (
+	Gzip CompressFormat = iota // with the standard compress/gzip package
+	Zstd                       // with the zstd library (libzstd), only available with the sqlite_zstd build tag
+)
+
+// zstdMagic starts the zstd frames (See https://www.rfc-editor.org/rfc/rfc8878#section-3.1.1)
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// CreateCompressFunctions registers the compress(x[, level]) and uncompress(x) SQL functions
+// to store large texts or BLOBs compactly, in the specified format (gzip by default).
+// compress returns a BLOB and uncompress a BLOB (to be cast to TEXT for texts).
+// uncompress detects the format of its argument so that gzip and zstd BLOBs can be mixed in a column.
+// The default level is used when it is not specified (the levels range from -1 to 9 with gzip and from -7 to 22 with zstd).
+// NULL arguments give NULL results.
+func (c *Conn) CreateCompressFunctions(format ...CompressFormat) error {
+	compress := gzipCompress
+	if len(format) > 0 && format[0] == Zstd {
+		if !zstdSupported {
+			return c.specificError("zstd compression requires the sqlite_zstd build tag")
+		}
+		compress = zstdCompress
+	}
+	err := c.CreateScalarFunction("compress", -1, nil, func(ctx *ScalarContext, nArg int) {
+		if nArg < 1 || nArg > 2 {
+			ctx.ResultError("wrong number of arguments to function compress()")
+			return
+		}
+		if ctx.Type(0) == Null {
+			ctx.ResultNull()
+			return
+		}
+		var level *int
+		if nArg > 1 {
+			l := ctx.Int(1)
+			level = &l
+		}
+		b, err := compress(ctx.Blob(0), level)
+		if err != nil {
+			ctx.ResultError(err.Error())
+			return
+		}
+		ctx.ResultBlob(b)
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return c.CreateScalarFunction("uncompress", 1, nil, func(ctx *ScalarContext, nArg int) {
+		if ctx.Type(0) == Null {
+			ctx.ResultNull()
+			return
+		}
+		uncompress := gzipUncompress
+		b := ctx.Blob(0)
+		if bytes.HasPrefix(b, zstdMagic) {
+			uncompress = zstdUncompress
+		}
+		b, err := uncompress(b)
+		if err != nil {
+			ctx.ResultError(fmt.Sprintf("uncompress: %s", err))
+			return
+		}
+		ctx.ResultBlob(b)
+	}, nil)
+}
+
+func gzipCompress(b []byte, level *int) ([]byte, error) {
+	l := gzip.DefaultCompression
+	if level != nil {
+		l = *level
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, l)
+	if err == nil {
+		if _, err = w.Write(b); err == nil {
+			err = w.Close()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gzipUncompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// CreateCompressedView creates the view named view over table (of the main database)
+// which uncompresses the specified columns (stored compressed in table),
+// and the INSTEAD OF triggers which compress them when rows are inserted or updated through the view.
+// The compression functions (see Conn.CreateCompressFunctions) must be registered on the connections using the view.
+// The table must have a PRIMARY KEY (used by the UPDATE and DELETE triggers).
+func (c *Conn) CreateCompressedView(table, view string, compressed ...string) error {
+	columns, err := c.Columns("main", table)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return c.specificError("no such table: %s", table)
+	}
+	isCompressed := make(map[string]bool, len(compressed))
+	for _, name := range compressed {
+		isCompressed[strings.ToLower(name)] = true
+	}
+	var names, selects, values, sets, keys []string
+	for _, column := range columns {
+		name := Mprintf(`"%w"`, column.Name)
+		names = append(names, name)
+		if isCompressed[strings.ToLower(column.Name)] {
+			delete(isCompressed, strings.ToLower(column.Name))
+			if strings.Contains(strings.ToUpper(column.DataType), "BLOB") {
+				selects = append(selects, "uncompress("+name+") AS "+name)
+			} else {
+				selects = append(selects, "CAST(uncompress("+name+") AS TEXT) AS "+name)
+			}
+			values = append(values, "compress(NEW."+name+")")
+		} else {
+			selects = append(selects, name)
+			values = append(values, "NEW."+name)
+		}
+		sets = append(sets, name+" = "+values[len(values)-1])
+		if column.Pk > 0 {
+			keys = append(keys, name+" = OLD."+name)
+		}
+	}
+	for _, name := range compressed {
+		if isCompressed[strings.ToLower(name)] { // not found
+			return c.specificError("no such column: %s.%s", table, name)
+		}
+	}
+	if len(keys) == 0 {
+		return c.specificError("table without primary key: %s", table)
+	}
+	quotedTable := Mprintf(`"%w"`, table)
+	return c.Transaction(Immediate, func(c *Conn) error {
+		if err := c.Exec(Mprintf(`CREATE VIEW "%w" AS SELECT `, view) + strings.Join(selects, ", ") + " FROM " + quotedTable); err != nil {
+			return err
+		}
+		for _, t := range []struct{ event, action string }{
+			{"INSERT", "INSERT INTO " + quotedTable + " (" + strings.Join(names, ", ") + ") VALUES (" + strings.Join(values, ", ") + ")"},
+			{"UPDATE", "UPDATE " + quotedTable + " SET " + strings.Join(sets, ", ") + " WHERE " + strings.Join(keys, " AND ")},
+			{"DELETE", "DELETE FROM " + quotedTable + " WHERE " + strings.Join(keys, " AND ")},
+		} {
+			sql := Mprintf2(`CREATE TRIGGER "%w" INSTEAD OF `+t.event+` ON "%w" BEGIN `, view+"_"+strings.ToLower(t.event), view) +
+				t.action + "; END"
+			if err := c.Exec(sql); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
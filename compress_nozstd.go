@@ -0,0 +1,24 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !sqlite_zstd
+// +build !sqlite_zstd
+
+package sqlite
+
+import (
+	"errors"
+)
+
+const zstdSupported = false
+
+var errNoZstd = errors.New("zstd support requires the sqlite_zstd build tag")
+
+func zstdCompress(b []byte, level *int) ([]byte, error) {
+	return nil, errNoZstd
+}
+
+func zstdUncompress(b []byte) ([]byte, error) {
+	return nil, errNoZstd
+}
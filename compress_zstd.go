@@ -0,0 +1,102 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_zstd
+// +build sqlite_zstd
+
+package sqlite
+
+/*
+#cgo LDFLAGS: -lzstd
+#include <stdlib.h>
+#include <zstd.h>
+
+// my_zstd_decompress decompresses all the frames of src into a buffer allocated with malloc
+// (the decompressed size is not always stored in the frames).
+static void *my_zstd_decompress(const void *src, size_t srcSize, size_t *dstSize, const char **zErr) {
+	ZSTD_DCtx *dctx = ZSTD_createDCtx();
+	ZSTD_inBuffer in = {src, srcSize, 0};
+	size_t cap = ZSTD_DStreamOutSize(), len = 0, rc;
+	char *dst = malloc(cap), *p;
+	*zErr = 0;
+	if (dctx == 0 || dst == 0) {
+		*zErr = "out of memory";
+		goto error;
+	}
+	for (;;) {
+		if (len == cap) {
+			cap *= 2;
+			if ((p = realloc(dst, cap)) == 0) {
+				*zErr = "out of memory";
+				goto error;
+			}
+			dst = p;
+		}
+		ZSTD_outBuffer out = {dst + len, cap - len, 0};
+		rc = ZSTD_decompressStream(dctx, &out, &in);
+		if (ZSTD_isError(rc)) {
+			*zErr = ZSTD_getErrorName(rc);
+			goto error;
+		}
+		len += out.pos;
+		if (in.pos == in.size) {
+			if (rc == 0) {
+				break;
+			} else if (out.pos < out.size) {
+				*zErr = "truncated frame";
+				goto error;
+			}
+		}
+	}
+	ZSTD_freeDCtx(dctx);
+	*dstSize = len;
+	return dst;
+error:
+	ZSTD_freeDCtx(dctx);
+	free(dst);
+	return 0;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+const zstdSupported = true
+
+// zstdCompress compresses b into one zstd frame (with the decompressed size).
+func zstdCompress(b []byte, level *int) ([]byte, error) {
+	var l C.int // default level
+	if level != nil {
+		l = C.int(*level)
+	}
+	dst := make([]byte, C.ZSTD_compressBound(C.size_t(len(b))))
+	n := C.ZSTD_compress(unsafe.Pointer(&dst[0]), C.size_t(len(dst)), zstdBuffer(b), C.size_t(len(b)), l)
+	if C.ZSTD_isError(n) != 0 {
+		return nil, errors.New("zstd: " + C.GoString(C.ZSTD_getErrorName(n)))
+	}
+	return dst[:n], nil
+}
+
+func zstdUncompress(b []byte) ([]byte, error) {
+	var size C.size_t
+	var zErr *C.char
+	p := C.my_zstd_decompress(zstdBuffer(b), C.size_t(len(b)), &size, &zErr)
+	if p == nil {
+		return nil, errors.New("zstd: " + C.GoString(zErr))
+	}
+	defer C.free(p)
+	dst := make([]byte, int(size))
+	copy(dst, unsafe.Slice((*byte)(p), int(size)))
+	return dst, nil
+}
+
+func zstdBuffer(b []byte) unsafe.Pointer {
+	if len(b) == 0 {
+		return nil
+	}
+	return unsafe.Pointer(&b[0])
+}
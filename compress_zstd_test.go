@@ -0,0 +1,45 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_zstd
+// +build sqlite_zstd
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"testing"
+)
+
+func TestZstdCompressFunctions(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.CreateCompressFunctions(Zstd), "couldn't create compress functions: %s")
+
+	var magic string
+	checkNoError(t, db.OneValue("SELECT hex(substr(compress('hello'), 1, 4))", &magic), "compress error: %s")
+	assertEquals(t, "expected %q but got %q", "28B52FFD", magic)
+	var n int
+	checkNoError(t, db.OneValue("SELECT length(compress(zeroblob(100000), 19))", &n), "compress error: %s")
+	assert(t, "compressed data expected", n > 0 && n < 1000)
+	checkNoError(t, db.OneValue("SELECT length(uncompress(compress(zeroblob(1000000))))", &n), "uncompress error: %s")
+	assertEquals(t, "expected %d but got %d", 1000000, n)
+	var s string
+	checkNoError(t, db.OneValue("SELECT CAST(uncompress(compress('hello')) AS TEXT)", &s), "uncompress error: %s")
+	assertEquals(t, "expected %q but got %q", "hello", s)
+	checkNoError(t, db.OneValue("SELECT CAST(uncompress(compress('')) AS TEXT)", &s), "uncompress error: %s")
+	assertEquals(t, "expected %q but got %q", "", s)
+
+	// two concatenated frames, the second one without content size (zstd --no-content-size)
+	checkNoError(t, db.OneValue("SELECT CAST(uncompress(compress('ab') || x'28b52ffd00001100006364') AS TEXT)", &s), "uncompress error: %s")
+	assertEquals(t, "expected %q but got %q", "abcd", s)
+	// truncated frame
+	err := db.OneValue("SELECT uncompress(substr(compress(randomblob(1000)), 1, 100))", &s)
+	assert(t, "truncated frame error expected", err != nil)
+
+	// gzip BLOBs are still readable
+	checkNoError(t, db.CreateCompressFunctions(Gzip), "couldn't create compress functions: %s")
+	checkNoError(t, db.OneValue("SELECT CAST(uncompress(compress('gzip')) AS TEXT)", &s), "uncompress error: %s")
+	assertEquals(t, "expected %q but got %q", "gzip", s)
+}
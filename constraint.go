@@ -0,0 +1,122 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"strings"
+)
+
+// ConstraintKind enumerates the kinds of constraint violations
+// (See http://sqlite.org/rescode.html#constraint)
+type ConstraintKind int
+
+const (
+	ConstraintOther ConstraintKind = iota
+	ConstraintUnique
+	ConstraintPrimaryKey
+	ConstraintForeignKey
+	ConstraintNotNull
+	ConstraintCheck
+	ConstraintDatatype // value of the wrong type stored in a STRICT table column
+)
+
+func (k ConstraintKind) String() string {
+	switch k {
+	case ConstraintUnique:
+		return "UNIQUE"
+	case ConstraintPrimaryKey:
+		return "PRIMARY KEY"
+	case ConstraintForeignKey:
+		return "FOREIGN KEY"
+	case ConstraintNotNull:
+		return "NOT NULL"
+	case ConstraintCheck:
+		return "CHECK"
+	case ConstraintDatatype:
+		return "DATATYPE"
+	}
+	return "OTHER"
+}
+
+// ConstraintError describes a constraint violation.
+// Table and Columns are derived from the error message when possible
+// (SQLite doesn't report them for FOREIGN KEY violations).
+type ConstraintError struct {
+	Kind    ConstraintKind
+	Table   string
+	Columns []string
+	Name    string // name (or expression) of the violated CHECK constraint
+	Err     error  // original error (*ConnError or *StmtError)
+}
+
+func (e *ConstraintError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the original error.
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// AsConstraintError returns the details of a constraint violation
+// or false if err is not a SQLITE_CONSTRAINT error returned by this package.
+func AsConstraintError(err error) (*ConstraintError, bool) {
+	var ce *ConnError
+	switch e := err.(type) {
+	case *ConnError:
+		ce = e
+	case *StmtError:
+		ce = &e.ConnError
+	case *ConstraintError:
+		return e, true
+	default:
+		return nil, false
+	}
+	if ce.code&0xff != ErrConstraint {
+		return nil, false
+	}
+	cerr := &ConstraintError{Err: err}
+	switch ce.ExtendedCode() {
+	case C.SQLITE_CONSTRAINT_UNIQUE:
+		cerr.Kind = ConstraintUnique
+	case C.SQLITE_CONSTRAINT_PRIMARYKEY:
+		cerr.Kind = ConstraintPrimaryKey
+	case C.SQLITE_CONSTRAINT_FOREIGNKEY:
+		cerr.Kind = ConstraintForeignKey
+	case C.SQLITE_CONSTRAINT_NOTNULL:
+		cerr.Kind = ConstraintNotNull
+	case C.SQLITE_CONSTRAINT_CHECK:
+		cerr.Kind = ConstraintCheck
+	case C.SQLITE_CONSTRAINT_DATATYPE:
+		cerr.Kind = ConstraintDatatype
+	}
+	// "UNIQUE constraint failed: t.a, t.b", "NOT NULL constraint failed: t.a", "CHECK constraint failed: name",
+	// "cannot store TEXT value in INTEGER column t.a"
+	var detail string
+	if i := strings.Index(ce.msg, "constraint failed: "); i >= 0 {
+		detail = ce.msg[i+len("constraint failed: "):]
+	} else if i = strings.LastIndex(ce.msg, " column "); i >= 0 && cerr.Kind == ConstraintDatatype {
+		detail = ce.msg[i+len(" column "):]
+	} else {
+		return cerr, true
+	}
+	if cerr.Kind == ConstraintCheck {
+		cerr.Name = detail
+		return cerr, true
+	}
+	for _, column := range strings.Split(detail, ", ") {
+		if j := strings.IndexByte(column, '.'); j >= 0 {
+			cerr.Table = column[:j]
+			column = column[j+1:]
+		}
+		cerr.Columns = append(cerr.Columns, column)
+	}
+	return cerr, true
+}
@@ -0,0 +1,167 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// ContentHash is the SHA-256 of a blob stored in a ContentStore.
+type ContentHash [sha256.Size]byte
+
+// String returns the hexadecimal form of the hash.
+func (h ContentHash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// ErrContentNotFound is returned by ContentStore methods when there is no blob with the specified hash.
+var ErrContentNotFound = errors.New("content not found")
+
+// ContentStore stores blobs keyed by their SHA-256 (content-addressed):
+// storing the same content twice only increments its reference count
+// and the blob is deleted when its last reference is released.
+//
+//	store, err := NewContentStore(db, "attachment")
+//	h, err := store.Put(f, size)
+//	r, err := store.Open(h)
+//	err = store.Release(h)
+//
+// Blobs are written and read with incremental BLOB I/O (see ZeroBlobLength and BlobReader).
+type ContentStore struct {
+	c     *Conn
+	table string
+}
+
+// NewContentStore creates the blobs table in the main database (if it doesn't exist).
+func NewContentStore(c *Conn, table string) (*ContentStore, error) {
+	err := c.Exec(Mprintf(`CREATE TABLE IF NOT EXISTS "%w" (
+		hash BLOB UNIQUE,
+		refs INTEGER NOT NULL,
+		data BLOB NOT NULL)`, table) + c.tableOptions())
+	if err != nil {
+		return nil, err
+	}
+	return &ContentStore{c: c, table: table}, nil
+}
+
+// PutBytes stores data (see Put).
+func (s *ContentStore) PutBytes(data []byte) (ContentHash, error) {
+	return s.Put(bytes.NewReader(data), int64(len(data)))
+}
+
+// Put stores the size bytes read from r and returns their hash.
+// The content is streamed into the database (and hashed on the fly):
+// if the same content is already stored, the copy is discarded and the reference count incremented.
+func (s *ContentStore) Put(r io.Reader, size int64) (h ContentHash, err error) {
+	const savepoint = "content_store_put"
+	if err = s.c.Savepoint(savepoint); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			s.c.RollbackSavepoint(savepoint)
+		}
+		if rerr := s.c.ReleaseSavepoint(savepoint); err == nil {
+			err = rerr
+		}
+	}()
+	err = s.c.Exec(Mprintf(`INSERT INTO "%w" (hash, refs, data) VALUES (NULL, 1, ?)`, s.table), ZeroBlobLength(size))
+	if err != nil {
+		return
+	}
+	rowid := s.c.LastInsertRowid()
+	if size > 0 {
+		var bw *BlobReadWriter
+		bw, err = s.c.NewBlobReadWriter("main", s.table, "data", rowid)
+		if err != nil {
+			return
+		}
+		hasher := sha256.New()
+		var n int64
+		n, err = io.Copy(bw, io.TeeReader(io.LimitReader(r, size), hasher))
+		if cerr := bw.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return
+		} else if n < size {
+			err = io.ErrUnexpectedEOF
+			return
+		}
+		hasher.Sum(h[:0])
+	} else {
+		h = sha256.Sum256(nil)
+	}
+	err = s.c.Exec(Mprintf(`UPDATE "%w" SET refs = refs + 1 WHERE hash = ?`, s.table), h[:])
+	if err != nil {
+		return
+	}
+	if s.c.Changes() > 0 {
+		err = s.c.Exec(Mprintf(`DELETE FROM "%w" WHERE rowid = ?`, s.table), rowid)
+	} else {
+		err = s.c.Exec(Mprintf(`UPDATE "%w" SET hash = ? WHERE rowid = ?`, s.table), h[:], rowid)
+	}
+	return
+}
+
+// Retain increments the reference count of the blob with the specified hash.
+func (s *ContentStore) Retain(h ContentHash) error {
+	err := s.c.Exec(Mprintf(`UPDATE "%w" SET refs = refs + 1 WHERE hash = ?`, s.table), h[:])
+	if err != nil {
+		return err
+	} else if s.c.Changes() == 0 {
+		return ErrContentNotFound
+	}
+	return nil
+}
+
+// Release decrements the reference count of the blob with the specified hash
+// and deletes it when there is no more reference.
+func (s *ContentStore) Release(h ContentHash) error {
+	err := s.c.Exec(Mprintf(`UPDATE "%w" SET refs = refs - 1 WHERE hash = ?`, s.table), h[:])
+	if err != nil {
+		return err
+	} else if s.c.Changes() == 0 {
+		return ErrContentNotFound
+	}
+	return s.c.Exec(Mprintf(`DELETE FROM "%w" WHERE hash = ? AND refs <= 0`, s.table), h[:])
+}
+
+// Refs returns the reference count of the blob with the specified hash (0 if it is not stored).
+func (s *ContentStore) Refs(h ContentHash) (int, error) {
+	var refs int
+	err := s.c.OneValue(Mprintf(`SELECT refs FROM "%w" WHERE hash = ?`, s.table), &refs, h[:])
+	if err == io.EOF {
+		return 0, nil
+	}
+	return refs, err
+}
+
+// Open returns a reader for the blob with the specified hash.
+// The reader must be closed.
+func (s *ContentStore) Open(h ContentHash) (*BlobReader, error) {
+	var rowid int64
+	err := s.c.OneValue(Mprintf(`SELECT rowid FROM "%w" WHERE hash = ?`, s.table), &rowid, h[:])
+	if err == io.EOF {
+		return nil, ErrContentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return s.c.NewBlobReader("main", s.table, "data", rowid)
+}
+
+// Get returns the content of the blob with the specified hash.
+func (s *ContentStore) Get(h ContentHash) ([]byte, error) {
+	var data []byte
+	err := s.c.OneValue(Mprintf(`SELECT data FROM "%w" WHERE hash = ?`, s.table), &data, h[:])
+	if err == io.EOF {
+		return nil, ErrContentNotFound
+	}
+	return data, err
+}
@@ -0,0 +1,64 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	. "github.com/gwenn/gosqlite"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestContentStore(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	store, err := NewContentStore(db, "content")
+	checkNoError(t, err, "couldn't create store: %s")
+
+	data := []byte(strings.Repeat("attachment", 100))
+	h1, err := store.PutBytes(data)
+	checkNoError(t, err, "put error: %s")
+	assertEquals(t, "expected %v but got %v", ContentHash(sha256.Sum256(data)), h1)
+	h2, err := store.Put(bytes.NewReader(data), int64(len(data)))
+	checkNoError(t, err, "put error: %s")
+	assertEquals(t, "expected %v but got %v", h1, h2)
+	var n int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM content", &n), "count error: %s")
+	assertEquals(t, "expected %d blob but got %d", 1, n)
+	refs, err := store.Refs(h1)
+	checkNoError(t, err, "refs error: %s")
+	assertEquals(t, "expected %d refs but got %d", 2, refs)
+
+	r, err := store.Open(h1)
+	checkNoError(t, err, "open error: %s")
+	content, err := ioutil.ReadAll(r)
+	checkNoError(t, err, "read error: %s")
+	checkNoError(t, r.Close(), "close error: %s")
+	assert(t, "same content expected", bytes.Equal(data, content))
+
+	_, err = store.Put(bytes.NewReader(data[:10]), 20)
+	assertEquals(t, "expected %v but got %v", io.ErrUnexpectedEOF, err)
+	checkNoError(t, db.OneValue("SELECT count(*) FROM content", &n), "count error: %s")
+	assertEquals(t, "expected %d blob but got %d", 1, n)
+
+	empty, err := store.PutBytes(nil)
+	checkNoError(t, err, "put error: %s")
+	content, err = store.Get(empty)
+	checkNoError(t, err, "get error: %s")
+	assertEquals(t, "expected %d bytes but got %d", 0, len(content))
+
+	checkNoError(t, store.Release(h1), "release error: %s")
+	checkNoError(t, store.Release(h1), "release error: %s")
+	refs, err = store.Refs(h1)
+	checkNoError(t, err, "refs error: %s")
+	assertEquals(t, "expected %d refs but got %d", 0, refs)
+	_, err = store.Get(h1)
+	assertEquals(t, "expected %v but got %v", ErrContentNotFound, err)
+	assertEquals(t, "expected %v but got %v", ErrContentNotFound, store.Release(h1))
+	assertEquals(t, "expected %v but got %v", ErrContentNotFound, store.Retain(h1))
+}
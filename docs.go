@@ -0,0 +1,169 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrDocNotFound is returned by Docs methods when there is no document with the specified id.
+var ErrDocNotFound = errors.New("document not found")
+
+// Docs stores JSON documents in a table (id INTEGER PRIMARY KEY, doc TEXT) using the JSON1 functions:
+//
+//	docs, err := NewDocs(db, "users")
+//	id, err := docs.Insert(User{Name: "bob", Age: 42})
+//	err = docs.Set(id, "$.age", 43)
+//	err = docs.CreateIndex("$.name")
+//	users, err := FindDocs[User](docs, docs.Path("$.name")+" = ?", "bob")
+//
+// Paths use the JSON1 syntax. (See http://sqlite.org/json1.html#path_arguments)
+type Docs struct {
+	c     *Conn
+	table string
+}
+
+// NewDocs creates the documents table in the main database (if it doesn't exist).
+func NewDocs(c *Conn, table string) (*Docs, error) {
+	err := c.Exec(Mprintf(`CREATE TABLE IF NOT EXISTS "%w" (
+		id INTEGER PRIMARY KEY,
+		doc TEXT NOT NULL CHECK (json_valid(doc)))`, table) + c.tableOptions())
+	if err != nil {
+		return nil, err
+	}
+	return &Docs{c: c, table: table}, nil
+}
+
+// Insert marshals v (see json.Marshal) and stores it as a new document.
+func (d *Docs) Insert(v interface{}) (int64, error) {
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return -1, err
+	}
+	if err = d.c.Exec(Mprintf(`INSERT INTO "%w" (doc) VALUES (json(?))`, d.table), string(doc)); err != nil {
+		return -1, err
+	}
+	return d.c.LastInsertRowid(), nil
+}
+
+// Replace replaces the document with the specified id by v.
+func (d *Docs) Replace(id int64, v interface{}) error {
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return d.update(Mprintf(`UPDATE "%w" SET doc = json(?) WHERE id = ?`, d.table), string(doc), id)
+}
+
+// Set updates only the value at path (created if missing) in the document with the specified id:
+// value is marshalled (see json.Marshal) and inserted with json_set.
+func (d *Docs) Set(id int64, path string, value interface{}) error {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return d.update(Mprintf(`UPDATE "%w" SET doc = json_set(doc, ?, json(?)) WHERE id = ?`, d.table), path, string(v), id)
+}
+
+// Remove removes the value at path in the document with the specified id (see json_remove).
+func (d *Docs) Remove(id int64, path string) error {
+	return d.update(Mprintf(`UPDATE "%w" SET doc = json_remove(doc, ?) WHERE id = ?`, d.table), path, id)
+}
+
+// Delete deletes the document with the specified id.
+func (d *Docs) Delete(id int64) error {
+	return d.update(Mprintf(`DELETE FROM "%w" WHERE id = ?`, d.table), id)
+}
+
+func (d *Docs) update(sql string, args ...interface{}) error {
+	if err := d.c.Exec(sql, args...); err != nil {
+		return err
+	} else if d.c.Changes() == 0 {
+		return ErrDocNotFound
+	}
+	return nil
+}
+
+// Get unmarshals the document with the specified id into v (see json.Unmarshal).
+func (d *Docs) Get(id int64, v interface{}) error {
+	var doc string
+	err := d.c.OneValue(Mprintf(`SELECT doc FROM "%w" WHERE id = ?`, d.table), &doc, id)
+	if err == io.EOF {
+		return ErrDocNotFound
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(doc), v)
+}
+
+// Path returns the SQL expression extracting the value at path from the documents.
+// It must be used in the WHERE clauses for the expression indexes (see CreateIndex) to be used.
+func (d *Docs) Path(path string) string {
+	return Mprintf(`json_extract(doc, %Q)`, path)
+}
+
+// CreateIndex creates (if it doesn't exist) an expression index on the value at path.
+func (d *Docs) CreateIndex(path string) error {
+	if !strings.HasPrefix(path, "$") {
+		return fmt.Errorf("invalid JSON path: %q", path)
+	}
+	return d.c.Exec(Mprintf2(`CREATE INDEX IF NOT EXISTS "%w" ON "%w" (`, d.indexName(path), d.table) + d.Path(path) + ")")
+}
+
+// DropIndex drops the expression index on the value at path (if it exists).
+func (d *Docs) DropIndex(path string) error {
+	return d.c.Exec(Mprintf(`DROP INDEX IF EXISTS "%w"`, d.indexName(path)))
+}
+
+// indexName derives the index name from the path: letters and digits are kept,
+// '_' is doubled and any other character is replaced by its hexadecimal code point between '_'
+// so that two paths never share an index ("$.a_b" gives "a__b" and "$.a.b" gives "a_2e_b").
+func (d *Docs) indexName(path string) string {
+	var b strings.Builder
+	b.WriteString(d.table)
+	b.WriteByte('_')
+	for _, r := range strings.TrimPrefix(path, "$.") {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '_':
+			b.WriteString("__")
+		default:
+			fmt.Fprintf(&b, "_%x_", r)
+		}
+	}
+	return b.String()
+}
+
+// FindDocs returns the documents matching the where clause (all documents if it is empty),
+// ordered by id and unmarshalled into Ts (see OneValue for why it is not a method):
+//
+//	adults, err := FindDocs[User](docs, docs.Path("$.age")+" >= ?", 18)
+func FindDocs[T any](d *Docs, where string, args ...interface{}) ([]T, error) {
+	sql := Mprintf(`SELECT doc FROM "%w"`, d.table)
+	if len(where) > 0 {
+		sql += " WHERE " + where
+	}
+	s, err := d.c.Prepare(sql+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Finalize()
+	var docs []T
+	err = s.Select(func(s *Stmt) error {
+		doc, _ := s.ScanBlob(0)
+		var v T
+		if err := json.Unmarshal(doc, &v); err != nil {
+			return err
+		}
+		docs = append(docs, v)
+		return nil
+	})
+	return docs, err
+}
@@ -0,0 +1,68 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"strings"
+	"testing"
+)
+
+type docUser struct {
+	Name string   `json:"name"`
+	Age  int      `json:"age"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+func TestDocs(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	docs, err := NewDocs(db, "users")
+	checkNoError(t, err, "couldn't create docs: %s")
+
+	bob, err := docs.Insert(docUser{Name: "bob", Age: 42})
+	checkNoError(t, err, "insert error: %s")
+	_, err = docs.Insert(docUser{Name: "alice", Age: 17, Tags: []string{"admin"}})
+	checkNoError(t, err, "insert error: %s")
+
+	checkNoError(t, docs.Set(bob, "$.age", 43), "set error: %s")
+	checkNoError(t, docs.Set(bob, "$.tags", []string{"ops"}), "set error: %s")
+	var u docUser
+	checkNoError(t, docs.Get(bob, &u), "get error: %s")
+	assertEquals(t, "expected %d but got %d", 43, u.Age)
+	assertEquals(t, "expected %v but got %v", 1, len(u.Tags))
+	checkNoError(t, docs.Remove(bob, "$.tags"), "remove error: %s")
+	u = docUser{}
+	checkNoError(t, docs.Get(bob, &u), "get error: %s")
+	assertEquals(t, "expected %v but got %v", 0, len(u.Tags))
+
+	checkNoError(t, docs.CreateIndex("$.name"), "create index error: %s")
+	roots, err := db.QueryPlan(`SELECT doc FROM users WHERE `+docs.Path("$.name")+" = ?", "bob")
+	checkNoError(t, err, "query plan error: %s")
+	assert(t, "index expected in "+roots[0].Detail, strings.Contains(roots[0].Detail, "users_name"))
+
+	checkNoError(t, docs.CreateIndex("$.a_b"), "create index error: %s")
+	checkNoError(t, docs.CreateIndex("$.a.b"), "create index error: %s")
+	var n int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM sqlite_master WHERE type = 'index' AND name IN ('users_a__b', 'users_a_2e_b')", &n), "select error: %s")
+	assertEquals(t, "expected %d indexes but got %d", 2, n)
+
+	users, err := FindDocs[docUser](docs, docs.Path("$.name")+" = ?", "bob")
+	checkNoError(t, err, "find error: %s")
+	assertEquals(t, "expected %d docUser but got %d", 1, len(users))
+	assertEquals(t, "expected %q but got %q", "bob", users[0].Name)
+	users, err = FindDocs[docUser](docs, docs.Path("$.age")+" < ?", 18)
+	checkNoError(t, err, "find error: %s")
+	assertEquals(t, "expected %d docUser but got %d", 1, len(users))
+	assertEquals(t, "expected %q but got %q", "alice", users[0].Name)
+	users, err = FindDocs[docUser](docs, "")
+	checkNoError(t, err, "find error: %s")
+	assertEquals(t, "expected %d users but got %d", 2, len(users))
+
+	checkNoError(t, docs.DropIndex("$.name"), "drop index error: %s")
+	checkNoError(t, docs.Delete(bob), "delete error: %s")
+	assertEquals(t, "expected %v but got %v", ErrDocNotFound, docs.Get(bob, &u))
+	assertEquals(t, "expected %v but got %v", ErrDocNotFound, docs.Set(bob, "$.age", 1))
+}
@@ -5,13 +5,19 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"errors"
+	"fmt"
 	"io"
 	"log"
+	"math"
+	"net/url"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 	"unsafe"
 )
@@ -37,14 +43,26 @@ type stmt struct {
 	rowsRef      bool // true if there is a rowsImpl associated to this statement that has not been closed.
 	pendingClose bool
 }
+type result struct {
+	lastInsertId int64
+	rowsAffected int64
+	noInsertId   bool // the statement is not an INSERT (see Conn.SetStrictInsertIds)
+}
 type rowsImpl struct {
 	s           *stmt
 	columnNames []string // cache
+	cur         *Stmt    // current result set: s.s or a statement prepared from its tail
+	tail        string
+	args        []driver.Value // arguments of the statements not yet prepared
+	ctx         context.Context
+	stop        func() // stops interrupting the connection when ctx is done
 }
 
 // Open opens a new database connection.
 // ":memory:" for memory db,
-// "" for temp file db
+// "" for temp file db.
+// With an URI filename, the "type_affinity" parameter enables declared-type-directed scanning (see Conn.SetTypeAffinity).
+// and the "strict_insert_ids" parameter makes LastInsertId fail after statements other than INSERT (see Conn.SetStrictInsertIds).
 func (d *impl) Open(name string) (driver.Conn, error) {
 	// OpenNoMutex == multi-thread mode (http://sqlite.org/compile.html#threadsafe and http://sqlite.org/threadsafe.html)
 	c, err := Open(name, OpenUri, OpenNoMutex, OpenReadWrite, OpenCreate)
@@ -52,6 +70,16 @@ func (d *impl) Open(name string) (driver.Conn, error) {
 		return nil, err
 	}
 	c.BusyTimeout(time.Duration(10) * time.Second)
+	if i := strings.IndexByte(name, '?'); i >= 0 && strings.HasPrefix(name, "file:") {
+		if params, err := url.ParseQuery(name[i+1:]); err == nil {
+			if b, err := strconv.ParseBool(params.Get("type_affinity")); err == nil {
+				c.SetTypeAffinity(b)
+			}
+			if b, err := strconv.ParseBool(params.Get("strict_insert_ids")); err == nil {
+				c.SetStrictInsertIds(b)
+			}
+		}
+	}
 	return &conn{c}, nil
 }
 
@@ -66,20 +94,30 @@ func (c *conn) Exec(query string, args []driver.Value) (driver.Result, error) {
 		h.Len = len(args)
 		h.Cap = cap(args)
 	}
+	total := c.c.TotalChanges()
 	if err := c.c.Exec(query, iargs...); err != nil {
 		return nil, err
 	}
-	return c, nil // FIXME RowAffected/noRows
-}
-
-// TODO How to know that the last Stmt has done an INSERT? An authorizer?
-func (c *conn) LastInsertId() (int64, error) {
-	return c.c.LastInsertRowid(), nil
+	r := &result{lastInsertId: c.c.LastInsertRowid(), noInsertId: c.c.strictInsertIds && !c.c.inserted}
+	if c.c.TotalChanges() != total {
+		r.rowsAffected = int64(c.c.Changes())
+	}
+	return r, nil
 }
 
-// TODO How to know that the last Stmt has done a DELETE/INSERT/UPDATE? An authorizer?
-func (c *conn) RowsAffected() (int64, error) {
-	return int64(c.c.Changes()), nil
+// ExecContext is like Exec but the execution is interrupted when ctx is done.
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	dargs, err := values(args)
+	if err != nil {
+		return nil, err
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	stop := interruptOnDone(ctx, c.c)
+	defer stop()
+	r, err := c.Exec(query, dargs)
+	return r, ctxError(ctx, err)
 }
 
 func (c *conn) Prepare(query string) (driver.Stmt, error) {
@@ -90,8 +128,9 @@ func (c *conn) Prepare(query string) (driver.Stmt, error) {
 	return &stmt{s: s}, nil
 }
 
+// Close closes the connection gracefully: statements still open are finalized later by database/sql.
 func (c *conn) Close() error {
-	return c.c.Close()
+	return c.c.CloseV2()
 }
 
 func (c *conn) Begin() (driver.Tx, error) {
@@ -108,6 +147,24 @@ func (c *conn) Rollback() error {
 	return c.c.Rollback()
 }
 
+// ErrNoInsertId is returned by the LastInsertId method of the database/sql results
+// when the statement is not an INSERT (see Conn.SetStrictInsertIds).
+var ErrNoInsertId = errors.New("sqlite: no rowid inserted by the statement")
+
+// LastInsertId returns the last inserted rowid when the statement was executed.
+func (r *result) LastInsertId() (int64, error) {
+	if r.noInsertId {
+		return -1, ErrNoInsertId
+	}
+	return r.lastInsertId, nil
+}
+
+// RowsAffected returns the number of rows modified by the statement
+// (0 for statements other than INSERT/UPDATE/DELETE).
+func (r *result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
 func (s *stmt) Close() error {
 	if s.rowsRef { // Currently, it never happens because the sql.Stmt doesn't call driver.Stmt in this case
 		s.pendingClose = true
@@ -116,75 +173,292 @@ func (s *stmt) Close() error {
 	return s.s.Finalize()
 }
 
+// NumInput returns the number of parameters actually used (gaps of the ?NNN form are skipped)
+// or -1 for a multi-statement query (the arguments are bound to the statements in turn).
 func (s *stmt) NumInput() int {
-	return s.s.BindParameterCount()
+	if hasStatement(s.s.tail) {
+		return -1
+	}
+	return len(s.s.BindParameterIndices())
 }
 
 func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
-	if err := s.bind(args); err != nil {
+	if rest, err := bind(s.s, args); err != nil {
 		return nil, err
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("too many arguments: %d unused", len(rest))
 	}
-	if err := s.s.exec(); err != nil {
+	noInsertId := s.s.c.strictInsertIds && !s.s.inserts()
+	changes, lastInsertRowid, err := s.s.execResult()
+	if err != nil {
 		return nil, err
 	}
-	return s, nil // FIXME RowAffected/noRows
-}
-
-// TODO How to know that this Stmt has done an INSERT? An authorizer?
-func (s *stmt) LastInsertId() (int64, error) {
-	return s.s.c.LastInsertRowid(), nil
-}
-
-// TODO How to know that this Stmt has done a DELETE/INSERT/UPDATE? An authorizer?
-func (s *stmt) RowsAffected() (int64, error) {
-	return int64(s.s.c.Changes()), nil
+	return &result{lastInsertId: lastInsertRowid, rowsAffected: changes, noInsertId: noInsertId}, nil
 }
 
 func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
 	if s.rowsRef {
 		return nil, errors.New("Previously returned Rows still not closed")
 	}
-	if err := s.bind(args); err != nil {
+	rest, err := bind(s.s, args)
+	if err != nil {
 		return nil, err
 	}
 	s.rowsRef = true
-	return &rowsImpl{s, nil}, nil
+	return &rowsImpl{s: s, cur: s.s, tail: s.s.tail, args: rest}, nil
 }
 
-func (s *stmt) bind(args []driver.Value) error {
-	for i, v := range args {
-		if err := s.s.BindByIndex(i+1, v); err != nil {
-			return err
+// ExecContext is like Exec but the execution is interrupted when ctx is done.
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	dargs, err := values(args)
+	if err != nil {
+		return nil, err
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	stop := interruptOnDone(ctx, s.s.c)
+	defer stop()
+	r, err := s.Exec(dargs)
+	return r, ctxError(ctx, err)
+}
+
+// QueryContext is like Query but the steps are interrupted when ctx is done (until the rows are closed).
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	dargs, err := values(args)
+	if err != nil {
+		return nil, err
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	rows, err := s.Query(dargs)
+	if err != nil {
+		return nil, err
+	}
+	r := rows.(*rowsImpl)
+	r.ctx, r.stop = ctx, interruptOnDone(ctx, s.s.c)
+	return r, nil
+}
+
+func values(args []driver.NamedValue) ([]driver.Value, error) {
+	dargs := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if len(arg.Name) > 0 {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		dargs[i] = arg.Value
+	}
+	return dargs, nil
+}
+
+// interruptOnDone interrupts the connection when ctx is done, until the returned function is called.
+func interruptOnDone(ctx context.Context, c *Conn) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan bool)
+	exited := make(chan bool)
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			c.Interrupt()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited // no interruption after stop returns
+	}
+}
+
+// ctxError replaces the error of an operation interrupted because ctx is done by the context error.
+func ctxError(ctx context.Context, err error) error {
+	if err == nil || ctx == nil || ctx.Err() == nil {
+		return err
+	}
+	var code Errno
+	switch e := err.(type) {
+	case *ConnError:
+		code = e.Code()
+	case *StmtError:
+		code = e.Code()
+	}
+	if code == ErrInterrupt {
+		return ctx.Err()
+	}
+	return err
+}
+
+// bind binds the first arguments to the parameters of s and returns the other ones.
+func bind(s *Stmt, args []driver.Value) ([]driver.Value, error) {
+	indices := s.BindParameterIndices()
+	if len(args) < len(indices) {
+		return nil, fmt.Errorf("not enough arguments: %d expected but got %d", len(indices), len(args))
+	}
+	for i, index := range indices {
+		if err := s.BindByIndex(index, args[i]); err != nil {
+			return nil, err
+		}
+	}
+	return args[len(indices):], nil
+}
+
+// hasStatement tells if sql contains something else than white-spaces, semicolons and comments.
+func hasStatement(sql string) bool {
+	for {
+		sql = strings.TrimLeft(sql, " \t\r\n\f;")
+		if strings.HasPrefix(sql, "--") {
+			i := strings.IndexByte(sql, '\n')
+			if i < 0 {
+				return false
+			}
+			sql = sql[i+1:]
+		} else if strings.HasPrefix(sql, "/*") {
+			i := strings.Index(sql[2:], "*/")
+			if i < 0 {
+				return false
+			}
+			sql = sql[i+4:]
+		} else {
+			return len(sql) > 0
 		}
 	}
-	return nil
 }
 
 func (r *rowsImpl) Columns() []string {
 	if r.columnNames == nil {
-		r.columnNames = r.s.s.ColumnNames()
+		r.columnNames = r.cur.ColumnNames()
 	}
 	return r.columnNames
 }
 
 func (r *rowsImpl) Next(dest []driver.Value) error {
-	ok, err := r.s.s.Next()
+	if !r.cur.c.typeAffinity {
+		ok, err := r.cur.nextRow(*(*[]interface{})(unsafe.Pointer(&dest)), true)
+		if err != nil {
+			return ctxError(r.ctx, err)
+		}
+		if !ok {
+			return io.EOF
+		}
+		return nil
+	}
+	ok, err := r.cur.Next()
 	if err != nil {
-		return err
+		return ctxError(r.ctx, err)
 	}
 	if !ok {
 		return io.EOF
 	}
 	for i := range dest {
-		dest[i], _ = r.s.s.ScanValue(i, true)
-		/*if !driver.IsScanValue(dest[i]) {
-			panic("Invalid type returned by ScanValue")
-		}*/
+		if dest[i], _, err = r.cur.scanAffinity(i, true); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// ColumnTypeLength returns the length declared for text and blob columns (like VARCHAR(50)),
+// math.MaxInt64 when there is none.
+func (r *rowsImpl) ColumnTypeLength(index int) (length int64, ok bool) {
+	name, args := parseDeclaredType(r.cur.ColumnDeclaredType(index))
+	if !strings.Contains(name, "CHAR") && !strings.Contains(name, "CLOB") && !strings.Contains(name, "TEXT") &&
+		!strings.Contains(name, "BLOB") && !strings.Contains(name, "BINARY") {
+		return 0, false
+	}
+	if len(args) > 0 {
+		return args[0], true
+	}
+	return math.MaxInt64, true
+}
+
+// ColumnTypePrecisionScale returns the precision and scale declared for decimal columns (like DECIMAL(10,2)).
+func (r *rowsImpl) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	name, args := parseDeclaredType(r.cur.ColumnDeclaredType(index))
+	if !strings.Contains(name, "DECIMAL") && !strings.Contains(name, "NUMERIC") || len(args) == 0 {
+		return 0, 0, false
+	}
+	if len(args) > 1 {
+		scale = args[1]
+	}
+	return args[0], scale, true
+}
+
+// parseDeclaredType splits a declared type like "DECIMAL(10, 2)" into its upper-cased name and its arguments.
+func parseDeclaredType(declType string) (string, []int64) {
+	declType = strings.ToUpper(declType)
+	i := strings.IndexByte(declType, '(')
+	if i < 0 {
+		return strings.TrimSpace(declType), nil
+	}
+	name := strings.TrimSpace(declType[:i])
+	j := strings.IndexByte(declType[i:], ')')
+	if j < 0 {
+		return name, nil
+	}
+	var args []int64
+	for _, arg := range strings.Split(declType[i+1:i+j], ",") {
+		n, err := strconv.ParseInt(strings.TrimSpace(arg), 10, 64)
+		if err != nil {
+			return name, nil
+		}
+		args = append(args, n)
+	}
+	return name, args
+}
+
+// HasNextResultSet tells if the query contains another statement.
+func (r *rowsImpl) HasNextResultSet() bool {
+	return hasStatement(r.tail)
+}
+
+// NextResultSet prepares the next statement of the query (only when the current one has been consumed,
+// so that it can depend on the changes made by the previous ones) and binds the remaining arguments.
+func (r *rowsImpl) NextResultSet() error {
+	for hasStatement(r.tail) {
+		s, err := r.cur.c.prepare(r.tail)
+		if err != nil {
+			return err
+		}
+		r.tail = s.tail
+		if s.stmt == nil { // comment or white-space
+			continue
+		}
+		if r.args, err = bind(s, r.args); err != nil {
+			s.finalize()
+			return err
+		}
+		if err = r.closeCurrent(); err != nil {
+			s.finalize()
+			return err
+		}
+		r.cur, r.columnNames = s, nil
+		return nil
+	}
+	return io.EOF
+}
+
+// closeCurrent finalizes the current statement unless it is the prepared one.
+func (r *rowsImpl) closeCurrent() error {
+	if r.cur == r.s.s {
+		return r.cur.Reset()
+	}
+	return r.cur.finalize()
+}
+
 func (r *rowsImpl) Close() error {
+	if r.stop != nil {
+		r.stop()
+		r.stop = nil
+	}
+	if r.cur != r.s.s {
+		if err := r.closeCurrent(); err != nil {
+			return err
+		}
+		r.cur = r.s.s
+	}
 	r.s.rowsRef = false
 	if r.s.pendingClose {
 		return r.s.Close()
@@ -5,8 +5,12 @@
 package sqlite_test
 
 import (
+	"context"
 	"database/sql"
+	. "github.com/gwenn/gosqlite"
+	"math"
 	"testing"
+	"time"
 )
 
 const (
@@ -143,6 +147,32 @@ func TestSqlPrepare(t *testing.T) {
 	assertEquals(t, "expected %d got %d RowsAffected", int64(1), changes)
 }
 
+func TestSqlResultIsolation(t *testing.T) {
+	db := sqlCreate(ddl+dml, t)
+	defer checkSqlDbClose(db, t)
+	db.SetMaxOpenConns(1)
+
+	result, err := db.Exec(insert, "Maggie")
+	checkNoError(t, err, "Error while inserting: %s")
+	_, err = db.Exec("UPDATE test SET name = name || '!'")
+	checkNoError(t, err, "Error while updating: %s")
+	_, err = db.Exec("INSERT INTO test (name) VALUES ('Homer')")
+	checkNoError(t, err, "Error while inserting: %s")
+
+	id, err := result.LastInsertId()
+	checkNoError(t, err, "Error while calling LastInsertId: %s")
+	assertEquals(t, "expected %d got %d LastInsertId", int64(3), id)
+	changes, err := result.RowsAffected()
+	checkNoError(t, err, "Error while calling RowsAffected: %s")
+	assertEquals(t, "expected %d got %d RowsAffected", int64(1), changes)
+
+	result, err = db.Exec("CREATE TABLE other (id INTEGER)")
+	checkNoError(t, err, "Error while creating table: %s")
+	changes, err = result.RowsAffected()
+	checkNoError(t, err, "Error while calling RowsAffected: %s")
+	assertEquals(t, "expected %d got %d RowsAffected", int64(0), changes)
+}
+
 func TestRowsWithStmtClosed(t *testing.T) {
 	db := sqlCreate(ddl+dml, t)
 	defer checkSqlDbClose(db, t)
@@ -161,3 +191,137 @@ func TestRowsWithStmtClosed(t *testing.T) {
 		checkNoError(t, err, "Error while scanning: %s")
 	}
 }
+
+func TestSqlTypeAffinity(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:affinity.db?mode=memory&type_affinity=true")
+	checkNoError(t, err, "Error opening database: %s")
+	defer checkSqlDbClose(db, t)
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE typed (d DATE, b BOOL); INSERT INTO typed VALUES ('2012-01-02', 0)")
+	checkNoError(t, err, "Error creating table: %s")
+
+	var d, b interface{}
+	checkNoError(t, db.QueryRow("SELECT d, b FROM typed").Scan(&d, &b), "Error while scanning: %s")
+	assertEquals(t, "expected %v but got %v", time.Date(2012, 1, 2, 0, 0, 0, 0, time.UTC), d)
+	assertEquals(t, "expected %v but got %v", false, b)
+}
+
+func TestSqlStrictInsertIds(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:insertids.db?mode=memory&strict_insert_ids=true")
+	checkNoError(t, err, "Error opening database: %s")
+	defer checkSqlDbClose(db, t)
+	db.SetMaxOpenConns(1)
+	result, err := db.Exec(ddl)
+	checkNoError(t, err, "Error creating table: %s")
+	_, err = result.LastInsertId()
+	assert(t, "error expected when calling LastInsertId after DDL", err == ErrNoInsertId)
+
+	result, err = db.Exec(dml)
+	checkNoError(t, err, "Error updating data: %s")
+	id, err := result.LastInsertId()
+	checkNoError(t, err, "Error while calling LastInsertId: %s")
+	assertEquals(t, "expected %d got %d LastInsertId", int64(2), id)
+
+	result, err = db.Exec("UPDATE test SET name = ?", "Maggie")
+	checkNoError(t, err, "Error updating data: %s")
+	_, err = result.LastInsertId()
+	assert(t, "error expected when calling LastInsertId after UPDATE", err == ErrNoInsertId)
+
+	stmt, err := db.Prepare(insert)
+	checkNoError(t, err, "Error while preparing stmt: %s")
+	defer checkSqlStmtClose(stmt, t)
+	result, err = stmt.Exec("Homer")
+	checkNoError(t, err, "Error inserting data: %s")
+	id, err = result.LastInsertId()
+	checkNoError(t, err, "Error while calling LastInsertId: %s")
+	assertEquals(t, "expected %d got %d LastInsertId", int64(3), id)
+}
+
+func TestSqlNextResultSet(t *testing.T) {
+	db := sqlCreate(ddl+dml, t)
+	defer checkSqlDbClose(db, t)
+	db.SetMaxOpenConns(1)
+
+	rows, err := db.Query("SELECT name FROM test WHERE name LIKE ?; -- names\n SELECT count(*), ? FROM test; /* end */", "%", "x")
+	checkNoError(t, err, "Error while querying: %s")
+	defer checkSqlRowsClose(rows, t)
+	var names []string
+	for rows.Next() {
+		var name string
+		checkNoError(t, rows.Scan(&name), "Error while scanning: %s")
+		names = append(names, name)
+	}
+	assertEquals(t, "expected %d names but got %d", 2, len(names))
+	assert(t, "second result set expected", rows.NextResultSet())
+	columns, err := rows.Columns()
+	checkNoError(t, err, "Error while getting columns: %s")
+	assertEquals(t, "expected %d columns but got %d", 2, len(columns))
+	assert(t, "one row expected", rows.Next())
+	var count int
+	var x string
+	checkNoError(t, rows.Scan(&count, &x), "Error while scanning: %s")
+	assertEquals(t, "expected %d but got %d", 2, count)
+	assertEquals(t, "expected %q but got %q", "x", x)
+	assert(t, "no more row expected", !rows.Next())
+	assert(t, "no more result set expected", !rows.NextResultSet())
+	checkNoError(t, rows.Err(), "Error while iterating: %s")
+
+	var n int
+	checkNoError(t, db.QueryRow("SELECT count(*) FROM test WHERE name LIKE ?", "%").Scan(&n), "Error while scanning: %s")
+	assertEquals(t, "expected %d but got %d", 2, n)
+}
+
+func TestSqlColumnTypeLengthPrecision(t *testing.T) {
+	db := sqlCreate("CREATE TABLE typed (code VARCHAR(50), note TEXT, price DECIMAL(10, 2), qty NUMERIC(5), id INTEGER)", t)
+	defer checkSqlDbClose(db, t)
+	rows, err := db.Query("SELECT * FROM typed")
+	checkNoError(t, err, "Error while querying: %s")
+	defer checkSqlRowsClose(rows, t)
+	types, err := rows.ColumnTypes()
+	checkNoError(t, err, "Error while getting column types: %s")
+
+	length, ok := types[0].Length()
+	assert(t, "length expected", ok)
+	assertEquals(t, "expected %d but got %d", int64(50), length)
+	length, ok = types[1].Length()
+	assert(t, "length expected", ok)
+	assertEquals(t, "expected %d but got %d", int64(math.MaxInt64), length)
+	_, ok = types[4].Length()
+	assert(t, "no length expected", !ok)
+
+	precision, scale, ok := types[2].DecimalSize()
+	assert(t, "decimal size expected", ok)
+	assertEquals(t, "expected %d but got %d", int64(10), precision)
+	assertEquals(t, "expected %d but got %d", int64(2), scale)
+	precision, scale, ok = types[3].DecimalSize()
+	assert(t, "decimal size expected", ok)
+	assertEquals(t, "expected %d but got %d", int64(5), precision)
+	assertEquals(t, "expected %d but got %d", int64(0), scale)
+	_, _, ok = types[0].DecimalSize()
+	assert(t, "no decimal size expected", !ok)
+}
+
+func TestSqlQueryContextCancel(t *testing.T) {
+	db := sqlOpen(t)
+	defer checkSqlDbClose(db, t)
+	db.SetMaxOpenConns(1)
+	const longQuery = "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT count(*) FROM c"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var n int64
+	err := db.QueryRowContext(ctx, longQuery).Scan(&n)
+	assertEquals(t, "expected %v but got %v", context.DeadlineExceeded, err)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, err = db.ExecContext(ctx, "CREATE TABLE big AS "+longQuery)
+	assertEquals(t, "expected %v but got %v", context.Canceled, err)
+
+	// the connection is still usable
+	checkNoError(t, db.QueryRowContext(context.Background(), "SELECT 1").Scan(&n), "Error while scanning: %s")
+	assertEquals(t, "expected %d but got %d", int64(1), n)
+}
@@ -0,0 +1,266 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FSDirModule is an eponymous-only virtual table module listing the files of a directory tree,
+// like the fsdir table-valued function of the SQLite shell:
+//
+//	db.CreateEponymousModule("fsdir", NewFSDirModule(nil))
+//	SELECT name, size, mtime FROM fsdir('/path') WHERE mode & 0xF000 = 0x8000
+//
+// fsdir(path[, dir]) returns path and, if it is a directory, all its descendants (recursively).
+// When dir is specified, path is relative to dir (and so are the names returned).
+// The columns are: name, mode (Unix st_mode), mtime (Unix time in seconds), size, data
+// (the file content, only read when requested) and the HIDDEN path and dir arguments.
+type FSDirModule struct {
+	fsys fs.FS // nil for the operating system filesystem
+}
+
+// NewFSDirModule creates a fsdir module backed by fsys
+// or by the operating system filesystem when fsys is nil.
+// With a fs.FS, the paths must be valid (see fs.ValidPath): slash-separated and unrooted.
+func NewFSDirModule(fsys fs.FS) *FSDirModule {
+	return &FSDirModule{fsys}
+}
+
+// Create is not supported (eponymous-only module).
+func (m *FSDirModule) Create(c *Conn, args []string) (VTab, error) {
+	return nil, errors.New("fsdir is an eponymous-only virtual table")
+}
+
+// Connect declares the virtual table.
+func (m *FSDirModule) Connect(c *Conn, args []string) (VTab, error) {
+	err := c.DeclareVTab("CREATE TABLE x(name TEXT, mode INT, mtime INT, size INT, data BLOB, path HIDDEN, dir HIDDEN)")
+	if err != nil {
+		return nil, err
+	}
+	return &fsdirVTab{m}, nil
+}
+
+// Destroy does nothing.
+func (m *FSDirModule) Destroy() {
+}
+
+const (
+	fsdirData = 4
+	fsdirPath = 5
+	fsdirDir  = 6
+)
+
+type fsdirVTab struct {
+	m *FSDirModule
+}
+
+func (v *fsdirVTab) BestIndex() error {
+	return nil
+}
+
+// BestIndexInfo uses the path and dir arguments.
+// idxNum is 1 with path only and 2 with both path and dir (plans without path are rejected).
+func (v *fsdirVTab) BestIndexInfo(info *IndexInfo) error {
+	pathIdx, dirIdx := -1, -1
+	for i, c := range info.Constraints {
+		if !c.Usable || c.Op != IndexConstraintEq {
+			continue
+		}
+		switch c.Column {
+		case fsdirPath:
+			pathIdx = i
+		case fsdirDir:
+			dirIdx = i
+		}
+	}
+	if pathIdx < 0 {
+		return ErrConstraint // path is required
+	}
+	info.Constraints[pathIdx].ArgvIndex = 1
+	info.Constraints[pathIdx].Omit = true
+	info.IdxNum = 1
+	if dirIdx >= 0 {
+		info.Constraints[dirIdx].ArgvIndex = 2
+		info.Constraints[dirIdx].Omit = true
+		info.IdxNum = 2
+	}
+	info.EstimatedCost = 10
+	return nil
+}
+func (v *fsdirVTab) Disconnect() error {
+	return nil
+}
+func (v *fsdirVTab) Destroy() error {
+	return nil
+}
+func (v *fsdirVTab) Open() (VTabCursor, error) {
+	return &fsdirVTabCursor{vTab: v}, nil
+}
+
+type fsdirEntry struct {
+	name string // as returned
+	path string // as read
+	info fs.FileInfo
+}
+
+type fsdirVTabCursor struct {
+	vTab      *fsdirVTab
+	path, dir string
+	hasDir    bool
+	entries   []fsdirEntry
+	index     int
+}
+
+func (vc *fsdirVTabCursor) Close() error {
+	return nil
+}
+func (vc *fsdirVTabCursor) Filter() error {
+	return vc.FilterArgs(0, nil)
+}
+func (vc *fsdirVTabCursor) FilterArgs(idxNum int, args []interface{}) error {
+	vc.entries, vc.index = nil, 0
+	if idxNum == 0 || len(args) == 0 {
+		return errors.New("table function fsdir requires an argument")
+	}
+	root, ok := args[0].(string)
+	if !ok {
+		return errors.New("table function fsdir requires a non-NULL path argument")
+	}
+	var dir string
+	if len(args) > 1 && args[1] != nil {
+		dir = fmt.Sprint(args[1])
+	}
+	vc.path, vc.dir, vc.hasDir = root, dir, len(args) > 1 && args[1] != nil
+	fsys := vc.vTab.m.fsys
+	walk := func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name := p
+		if len(dir) > 0 {
+			if fsys == nil {
+				name, err = filepath.Rel(dir, p)
+			} else if p == dir {
+				name = "."
+			} else {
+				name = strings.TrimPrefix(p, dir+"/")
+			}
+			if err != nil {
+				return err
+			}
+		}
+		vc.entries = append(vc.entries, fsdirEntry{name, p, info})
+		return nil
+	}
+	var err error
+	if fsys == nil {
+		if len(dir) > 0 {
+			root = filepath.Join(dir, root)
+		}
+		err = filepath.WalkDir(root, walk)
+	} else {
+		if len(dir) > 0 {
+			root = path.Join(dir, root)
+		}
+		err = fs.WalkDir(fsys, root, walk)
+	}
+	if err != nil {
+		vc.entries = nil
+		return fmt.Errorf("fsdir: %s", err)
+	}
+	return nil
+}
+func (vc *fsdirVTabCursor) Next() error {
+	vc.index++
+	return nil
+}
+func (vc *fsdirVTabCursor) Eof() bool {
+	return vc.index >= len(vc.entries)
+}
+func (vc *fsdirVTabCursor) Column(c *Context, col int) error {
+	e := vc.entries[vc.index]
+	switch col {
+	case 0:
+		c.ResultText(e.name)
+	case 1:
+		c.ResultInt64(int64(unixMode(e.info.Mode())))
+	case 2:
+		c.ResultInt64(e.info.ModTime().Unix())
+	case 3:
+		c.ResultInt64(e.info.Size())
+	case fsdirData:
+		if !e.info.Mode().IsRegular() {
+			c.ResultNull()
+			return nil
+		}
+		var data []byte
+		var err error
+		if fsys := vc.vTab.m.fsys; fsys == nil {
+			data, err = os.ReadFile(e.path)
+		} else {
+			data, err = fs.ReadFile(fsys, e.path)
+		}
+		if err != nil {
+			return err
+		}
+		c.ResultBlob(data)
+	case fsdirPath:
+		c.ResultText(vc.path)
+	case fsdirDir:
+		if vc.hasDir {
+			c.ResultText(vc.dir)
+		} else {
+			c.ResultNull()
+		}
+	default:
+		return fmt.Errorf("column index out of bounds: %d", col)
+	}
+	return nil
+}
+func (vc *fsdirVTabCursor) Rowid() (int64, error) {
+	return int64(vc.index), nil
+}
+
+// unixMode converts a Go file mode to a Unix st_mode.
+func unixMode(m fs.FileMode) uint32 {
+	mode := uint32(m.Perm())
+	switch {
+	case m.IsDir():
+		mode |= 0040000
+	case m&fs.ModeSymlink != 0:
+		mode |= 0120000
+	case m&fs.ModeNamedPipe != 0:
+		mode |= 0010000
+	case m&fs.ModeSocket != 0:
+		mode |= 0140000
+	case m&fs.ModeCharDevice != 0:
+		mode |= 0020000
+	case m&fs.ModeDevice != 0:
+		mode |= 0060000
+	default:
+		mode |= 0100000
+	}
+	if m&fs.ModeSetuid != 0 {
+		mode |= 04000
+	}
+	if m&fs.ModeSetgid != 0 {
+		mode |= 02000
+	}
+	if m&fs.ModeSticky != 0 {
+		mode |= 01000
+	}
+	return mode
+}
@@ -0,0 +1,96 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	"fmt"
+	. "github.com/gwenn/gosqlite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFSDir(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	dir, err := ioutil.TempDir("", "gosqlite-fsdir")
+	checkNoError(t, err, "couldn't create temp dir: %s")
+	defer os.RemoveAll(dir)
+	checkNoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755), "couldn't create dir: %s")
+	checkNoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "a.txt"), []byte("hello"), 0644), "couldn't write file: %s")
+
+	checkNoError(t, db.CreateEponymousModule("fsdir", NewFSDirModule(nil)), "couldn't create module: %s")
+	var n int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM fsdir(?)", &n, dir), "select error: %s")
+	assertEquals(t, "expected %d entries but got %d", 3, n)
+
+	var name string
+	var size int64
+	var data []byte
+	checkNoError(t, db.OneValue("SELECT name FROM fsdir('sub', ?) WHERE mode & 0xF000 = 0x8000", &name, dir), "select error: %s")
+	assertEquals(t, "expected %q but got %q", filepath.Join("sub", "a.txt"), name)
+	checkNoError(t, db.OneValue("SELECT size FROM fsdir(?) WHERE name LIKE '%.txt'", &size, dir), "select error: %s")
+	assertEquals(t, "expected %d but got %d", int64(5), size)
+	checkNoError(t, db.OneValue("SELECT data FROM fsdir(?) WHERE name LIKE '%.txt'", &data, dir), "select error: %s")
+	assertEquals(t, "expected %q but got %q", "hello", string(data))
+
+	err = db.OneValue("SELECT count(*) FROM fsdir", &n)
+	assert(t, "missing argument error expected", err != nil)
+	err = db.OneValue("SELECT count(*) FROM fsdir(?)", &n, filepath.Join(dir, "missing"))
+	assert(t, "missing path error expected", err != nil)
+	err = db.Exec("CREATE VIRTUAL TABLE temp.x USING fsdir")
+	assert(t, "eponymous-only error expected", err != nil)
+}
+
+func TestFSDirFS(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"root/a.txt":   {Data: []byte("a"), ModTime: mtime},
+		"root/b/c.txt": {Data: []byte("cc"), ModTime: mtime},
+	}
+	checkNoError(t, db.CreateEponymousModule("fsdir", NewFSDirModule(fsys)), "couldn't create module: %s")
+
+	s, err := db.Prepare("SELECT name, size, mtime FROM fsdir('root') ORDER BY name")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	var names []string
+	err = s.Select(func(s *Stmt) error {
+		var name string
+		var size, ts int64
+		if err := s.Scan(&name, &size, &ts); err != nil {
+			return err
+		}
+		if name == "root/a.txt" {
+			assertEquals(t, "expected %d but got %d", int64(1), size)
+			assertEquals(t, "expected %d but got %d", mtime.Unix(), ts)
+		}
+		names = append(names, name)
+		return nil
+	})
+	checkNoError(t, err, "select error: %s")
+	assertEquals(t, "expected %q but got %q", "[root root/a.txt root/b root/b/c.txt]", fmt.Sprint(names))
+
+	var name string
+	checkNoError(t, db.OneValue("SELECT name FROM fsdir('b', 'root') WHERE size = 2", &name), "select error: %s")
+	assertEquals(t, "expected %q but got %q", "b/c.txt", name)
+
+	// path equal to dir
+	names = nil
+	s2, err := db.Prepare("SELECT name FROM fsdir('.', 'root/b') ORDER BY name")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s2, t)
+	err = s2.Select(func(s *Stmt) error {
+		name, _ := s.ScanText(0)
+		names = append(names, name)
+		return nil
+	})
+	checkNoError(t, err, "select error: %s")
+	assertEquals(t, "expected %q but got %q", "[. c.txt]", fmt.Sprint(names))
+}
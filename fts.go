@@ -0,0 +1,173 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FTS is a full-text index (an external content FTS5 table) on some columns of a table,
+// kept in sync with the table by triggers:
+//
+//	type Article struct {
+//		ID    int64  `sql:"id"`
+//		Title string `sql:"title"`
+//		Body  string `sql:"body"`
+//	}
+//	fts, err := NewFTS(db, "article", Article{}, "Title", "Body")
+//	hits, err := SearchFTS[Article](fts, "sqlite AND go", 10)
+//
+// (See http://sqlite.org/fts5.html#external_content_tables)
+type FTS struct {
+	c          *Conn
+	table      string
+	name       string // FTS5 table
+	columns    []string
+	start, end string // highlight markers
+}
+
+// NewFTS creates (if it doesn't exist) the full-text index named table_fts on the columns
+// bound to the specified fields of the struct v (see Stmt.ScanStruct for the mapping),
+// the triggers keeping it in sync and indexes the existing rows.
+func NewFTS(c *Conn, table string, v interface{}, fields ...string) (*FTS, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("NewFTS expects a struct but got %T", v)
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("no field to index")
+	}
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		f, ok := t.FieldByName(field)
+		if !ok {
+			return nil, fmt.Errorf("no field %q in %s", field, t)
+		}
+		for name, index := range fieldsOf(t) {
+			if reflect.DeepEqual(index, f.Index) {
+				columns[i] = name
+				break
+			}
+		}
+		if len(columns[i]) == 0 {
+			return nil, fmt.Errorf("field %q of %s is not bound to a column", field, t)
+		}
+	}
+	f := &FTS{c: c, table: table, name: table + "_fts", columns: columns, start: "<b>", end: "</b>"}
+	if err := c.Exec(f.schema()); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FTS) schema() string {
+	quoted := make([]string, len(f.columns))
+	newCols := make([]string, len(f.columns))
+	oldCols := make([]string, len(f.columns))
+	for i, column := range f.columns {
+		quoted[i] = Mprintf(`"%w"`, column)
+		newCols[i] = "new." + quoted[i]
+		oldCols[i] = "old." + quoted[i]
+	}
+	cols := strings.Join(quoted, ", ")
+	name := Mprintf(`"%w"`, f.name)
+	table := Mprintf(`"%w"`, f.table)
+	insert := fmt.Sprintf("INSERT INTO %s (rowid, %s) VALUES (new.rowid, %s);", name, cols, strings.Join(newCols, ", "))
+	del := fmt.Sprintf("INSERT INTO %s (%s, rowid, %s) VALUES ('delete', old.rowid, %s);", name, name, cols, strings.Join(oldCols, ", "))
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(%s, content=%s, content_rowid=rowid);\n",
+		name, cols, Mprintf("%Q", f.table))
+	fmt.Fprintf(&b, "CREATE TRIGGER IF NOT EXISTS %s AFTER INSERT ON %s BEGIN %s END;\n", Mprintf(`"%w"`, f.name+"_ai"), table, insert)
+	fmt.Fprintf(&b, "CREATE TRIGGER IF NOT EXISTS %s AFTER DELETE ON %s BEGIN %s END;\n", Mprintf(`"%w"`, f.name+"_ad"), table, del)
+	fmt.Fprintf(&b, "CREATE TRIGGER IF NOT EXISTS %s AFTER UPDATE ON %s BEGIN %s %s END;\n", Mprintf(`"%w"`, f.name+"_au"), table, del, insert)
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ('rebuild')", name, name)
+	return b.String()
+}
+
+// SetHighlight sets the markers inserted around the matching terms (default is <b> and </b>).
+func (f *FTS) SetHighlight(start, end string) {
+	f.start, f.end = start, end
+}
+
+// Rebuild reindexes all the rows of the table.
+func (f *FTS) Rebuild() error {
+	return f.c.Exec(Mprintf2(`INSERT INTO "%w" ("%w") VALUES ('rebuild')`, f.name, f.name))
+}
+
+// Drop drops the triggers and the full-text index.
+func (f *FTS) Drop() error {
+	var b strings.Builder
+	for _, suffix := range []string{"_ai", "_ad", "_au"} {
+		b.WriteString(Mprintf(`DROP TRIGGER IF EXISTS "%w";`, f.name+suffix))
+	}
+	b.WriteString(Mprintf(`DROP TABLE IF EXISTS "%w"`, f.name))
+	return f.c.Exec(b.String())
+}
+
+// FTSHit is a row matching a full-text query (see SearchFTS).
+type FTSHit[T any] struct {
+	Rowid      int64
+	Rank       float64 // bm25 (the lower, the better)
+	Row        T
+	Highlights map[string]string // indexed column values with the matching terms highlighted
+}
+
+// SearchFTS returns the rows of the table matching the FTS5 query, best ranked first,
+// scanned into Ts (see Stmt.ScanStruct and OneValue for why it is not a method).
+// limit <= 0 means no limit.
+// (See http://sqlite.org/fts5.html#full_text_query_syntax)
+func SearchFTS[T any](f *FTS, query string, limit int) ([]FTSHit[T], error) {
+	if t := reflect.TypeOf((*T)(nil)).Elem(); t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("SearchFTS expects a struct type but got %s", t)
+	}
+	var b strings.Builder
+	b.WriteString(Mprintf(`SELECT t.*, t.rowid AS fts_rowid, "%w".rank AS fts_rank`, f.name))
+	for i := range f.columns {
+		fmt.Fprintf(&b, ", highlight(%s, %d, ?, ?) AS fts_hl%d", Mprintf(`"%w"`, f.name), i, i)
+	}
+	b.WriteString(Mprintf2(` FROM "%w" JOIN "%w" t ON t.rowid = `, f.name, f.table))
+	b.WriteString(Mprintf2(`"%w".rowid WHERE "%w" MATCH ? ORDER BY fts_rank`, f.name, f.name))
+	if limit <= 0 {
+		limit = -1
+	}
+	b.WriteString(" LIMIT ?")
+	args := make([]interface{}, 0, 2*len(f.columns)+2)
+	for range f.columns {
+		args = append(args, f.start, f.end)
+	}
+	args = append(args, query, limit)
+	s, err := f.c.Prepare(b.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Finalize()
+	first := s.ColumnCount() - len(f.columns) - 2
+	var hits []FTSHit[T]
+	err = s.Select(func(s *Stmt) error {
+		var hit FTSHit[T]
+		if err := s.scanStruct(reflect.ValueOf(&hit.Row).Elem()); err != nil {
+			return err
+		}
+		if _, err := s.ScanByIndex(first, &hit.Rowid); err != nil {
+			return err
+		}
+		if _, err := s.ScanByIndex(first+1, &hit.Rank); err != nil {
+			return err
+		}
+		hit.Highlights = make(map[string]string, len(f.columns))
+		for i, column := range f.columns {
+			hit.Highlights[column], _ = s.ScanText(first + 2 + i)
+		}
+		hits = append(hits, hit)
+		return nil
+	})
+	return hits, err
+}
@@ -0,0 +1,61 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"testing"
+)
+
+type article struct {
+	ID    int64  `sql:"id"`
+	Title string `sql:"title"`
+	Body  string `sql:"body"`
+	Views int    `sql:"views"`
+}
+
+func TestFTS(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE article (id INTEGER PRIMARY KEY, title TEXT, body TEXT, views INTEGER)"), "create error: %s")
+	checkNoError(t, db.Exec("INSERT INTO article (title, body, views) VALUES ('SQLite', 'an embedded database engine', 10)"), "insert error: %s")
+
+	fts, err := NewFTS(db, "article", article{}, "Title", "Body")
+	checkNoError(t, err, "couldn't create full-text index: %s")
+	_, err = NewFTS(db, "article", article{}, "Missing")
+	assert(t, "error expected with unknown field", err != nil)
+
+	checkNoError(t, db.Exec("INSERT INTO article (title, body, views) VALUES ('Go', 'a language with a database/sql package', 5)"), "insert error: %s")
+	checkNoError(t, db.Exec("INSERT INTO article (title, body, views) VALUES ('Cooking', 'pasta and tomatoes', 1)"), "insert error: %s")
+
+	hits, err := SearchFTS[article](fts, "database", 0)
+	checkNoError(t, err, "search error: %s")
+	assertEquals(t, "expected %d hits but got %d", 2, len(hits))
+	for _, hit := range hits {
+		assertEquals(t, "expected rowid %d but got %d", hit.Row.ID, hit.Rowid)
+		assert(t, "rank expected", hit.Rank < 0)
+	}
+
+	fts.SetHighlight("[", "]")
+	hits, err = SearchFTS[article](fts, "sqlite", 10)
+	checkNoError(t, err, "search error: %s")
+	assertEquals(t, "expected %d hit but got %d", 1, len(hits))
+	assertEquals(t, "expected %d views but got %d", 10, hits[0].Row.Views)
+	assertEquals(t, "expected %q but got %q", "[SQLite]", hits[0].Highlights["title"])
+
+	// kept in sync by triggers
+	checkNoError(t, db.Exec("UPDATE article SET title = 'Italian' WHERE title = 'Cooking'"), "update error: %s")
+	checkNoError(t, db.Exec("DELETE FROM article WHERE title = 'SQLite'"), "delete error: %s")
+	hits, err = SearchFTS[article](fts, "sqlite OR cooking", 0)
+	checkNoError(t, err, "search error: %s")
+	assertEquals(t, "expected %d hit but got %d", 0, len(hits))
+	hits, err = SearchFTS[article](fts, "title:italian", 0)
+	checkNoError(t, err, "search error: %s")
+	assertEquals(t, "expected %d hit but got %d", 1, len(hits))
+
+	checkNoError(t, fts.Rebuild(), "rebuild error: %s")
+	checkNoError(t, fts.Drop(), "drop error: %s")
+	checkNoError(t, db.Exec("INSERT INTO article (title) VALUES ('after drop')"), "insert error: %s")
+}
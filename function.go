@@ -320,6 +320,21 @@ func (c *FunctionContext) Value(i int) (value interface{}) {
 	return
 }
 
+func valueOf(v *C.sqlite3_value) interface{} {
+	switch C.sqlite3_value_type(v) {
+	case C.SQLITE_INTEGER:
+		return int64(C.sqlite3_value_int64(v))
+	case C.SQLITE_FLOAT:
+		return float64(C.sqlite3_value_double(v))
+	case C.SQLITE_TEXT:
+		p := C.sqlite3_value_text(v)
+		return C.GoStringN((*C.char)(unsafe.Pointer(p)), C.sqlite3_value_bytes(v))
+	case C.SQLITE_BLOB:
+		return C.GoBytes(C.sqlite3_value_blob(v), C.sqlite3_value_bytes(v))
+	}
+	return nil
+}
+
 // ScalarFunction is the expected signature of scalar function implemented in Go
 type ScalarFunction func(ctx *ScalarContext, nArg int)
 
@@ -5,11 +5,14 @@
 package sqlite_test
 
 import (
+	"fmt"
 	. "github.com/gwenn/gosqlite"
 	"math/rand"
 	"os"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 )
 
 func half(ctx *ScalarContext, nArg int) {
@@ -211,3 +214,98 @@ func BenchmarkRegexp(b *testing.B) {
 		cs.Reset()
 	}
 }
+
+func TestTimeFunctions(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.CreateTimeFunctions(), "couldn't create time functions: %s")
+
+	var s string
+	checkNoError(t, db.OneValue("SELECT go_now()", &s), "go_now error: %s")
+	now, err := time.Parse(time.RFC3339Nano, s)
+	checkNoError(t, err, "go_now result error: %s")
+	assert(t, "current time expected", time.Since(now) < time.Minute)
+
+	for _, c := range []struct {
+		sql      string
+		expected string
+	}{
+		{"SELECT go_format('2021-03-28 01:30:00', '02/01/2006 15:04 MST', 'Europe/Paris')", "28/03/2021 03:30 CEST"},
+		{"SELECT go_format(0, '2006-01-02')", "1970-01-01"},
+		{"SELECT go_parse('28/03/2021 03:30', '02/01/2006 15:04', 'Europe/Paris')", "2021-03-28T01:30:00Z"},
+		{"SELECT go_tz('2021-01-15T12:00:00Z', 'America/New_York')", "2021-01-15T07:00:00-05:00"},
+		{"SELECT datetime(go_tz('2021-01-15 12:00:00', 'Asia/Tokyo'))", "2021-01-15 12:00:00"},
+		{"SELECT ifnull(go_format(NULL, '2006'), 'null')", "null"},
+	} {
+		checkNoError(t, db.OneValue(c.sql, &s), c.sql+": %s")
+		assertEquals(t, "expected %q but got %q", c.expected, s)
+	}
+	err = db.OneValue("SELECT go_tz('2021-01-15', 'Nowhere/Unknown')", &s)
+	assert(t, "unknown time zone error expected", err != nil)
+	err = db.OneValue("SELECT go_format('2021-01-15')", &s)
+	assert(t, "wrong number of arguments error expected", err != nil)
+}
+
+func TestCompressFunctions(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.CreateCompressFunctions(), "couldn't create compress functions: %s")
+
+	var n int
+	checkNoError(t, db.OneValue("SELECT length(compress(zeroblob(10000)))", &n), "compress error: %s")
+	assert(t, "compressed data expected", n > 0 && n < 1000)
+	var s string
+	checkNoError(t, db.OneValue("SELECT CAST(uncompress(compress('hello', 9)) AS TEXT)", &s), "uncompress error: %s")
+	assertEquals(t, "expected %q but got %q", "hello", s)
+	err := db.OneValue("SELECT uncompress(x'00')", &s)
+	assert(t, "uncompress error expected", err != nil)
+	err = db.OneValue("SELECT uncompress(x'28b52ffd0000')", &s)
+	assert(t, "zstd error expected", err != nil && strings.Contains(err.Error(), "zstd"))
+
+	checkNoError(t, db.Exec("CREATE TABLE doc (id INTEGER PRIMARY KEY, title TEXT, body TEXT, raw BLOB)"), "create error: %s")
+	err = db.CreateCompressedView("doc", "doc_view", "unknown")
+	assert(t, "unknown column error expected", err != nil)
+	checkNoError(t, db.CreateCompressedView("doc", "doc_view", "body", "raw"), "couldn't create view: %s")
+	checkNoError(t, db.Exec("INSERT INTO doc_view VALUES (1, 'a', ?, x'0102')", strings.Repeat("text ", 1000)), "insert error: %s")
+	checkNoError(t, db.OneValue("SELECT length(body) FROM doc", &n), "select error: %s")
+	assert(t, "compressed body expected", n < 1000)
+	checkNoError(t, db.OneValue("SELECT length(body) FROM doc_view", &n), "select error: %s")
+	assertEquals(t, "expected %d but got %d", 5000, n)
+
+	checkNoError(t, db.Exec("UPDATE doc_view SET body = 'short' WHERE id = 1"), "update error: %s")
+	var raw []byte
+	checkNoError(t, db.OneValue("SELECT body || title FROM doc_view", &s), "select error: %s")
+	assertEquals(t, "expected %q but got %q", "shorta", s)
+	checkNoError(t, db.OneValue("SELECT raw FROM doc_view", &raw), "select error: %s")
+	assertEquals(t, "expected %v but got %v", "[1 2]", fmt.Sprint(raw))
+	checkNoError(t, db.Exec("DELETE FROM doc_view WHERE id = 1"), "delete error: %s")
+	checkNoError(t, db.OneValue("SELECT count(*) FROM doc", &n), "select error: %s")
+	assertEquals(t, "expected %d but got %d", 0, n)
+}
+
+func TestHashFunctions(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.CreateHashFunctions(), "couldn't create hash functions: %s")
+
+	for _, c := range []struct {
+		sql      string
+		expected string
+	}{
+		{"SELECT lower(hex(md5('abc')))", "900150983cd24fb0d6963f7d28e17f72"},
+		{"SELECT lower(hex(sha1('abc')))", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{"SELECT lower(hex(sha256('abc')))", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"SELECT lower(hex(sha256(x'616263')))", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"SELECT length(sha512('abc'))", "64"},
+		{"SELECT lower(hex(hmac('sha256', 'key', 'The quick brown fox jumps over the lazy dog')))",
+			"f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{"SELECT ifnull(sha1(NULL), 'null')", "null"},
+	} {
+		var s string
+		checkNoError(t, db.OneValue(c.sql, &s), c.sql+": %s")
+		assertEquals(t, "expected %q but got %q", c.expected, s)
+	}
+	var b []byte
+	err := db.OneValue("SELECT hmac('crc32', 'key', 'x')", &b)
+	assert(t, "unsupported algorithm error expected", err != nil)
+}
@@ -0,0 +1,127 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <pthread.h>
+#include <stdint.h>
+
+static uintptr_t my_thread_id(void) {
+	return (uintptr_t)pthread_self();
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// connGuard protects the cgo entry points of one connection.
+// In serialized mode, callers from other goroutines wait for the owner to leave.
+// Otherwise (debug build with OpenNoMutex), they panic.
+// The guard is reentrant so that callbacks (functions, hooks, ...) can use the connection:
+// the owner goroutine is locked to its thread (runtime.LockOSThread) until it leaves
+// so that the thread id identifies it (callbacks are invoked on the same thread).
+type connGuard struct {
+	m         sync.Mutex
+	cond      *sync.Cond
+	serialize bool
+	owner     C.uintptr_t // thread id
+	depth     int
+}
+
+func newConnGuard(serialize bool) *connGuard {
+	g := &connGuard{serialize: serialize}
+	g.cond = sync.NewCond(&g.m)
+	return g
+}
+
+func (g *connGuard) lock() {
+	runtime.LockOSThread() // calls are counted, undone by unlock
+	id := C.my_thread_id()
+	g.m.Lock()
+	defer g.m.Unlock()
+	for g.owner != 0 && g.owner != id {
+		if !g.serialize {
+			runtime.UnlockOSThread()
+			panic(fmt.Sprintf("sqlite: concurrent use of a connection opened with OpenNoMutex by goroutine %d while another goroutine is using it "+
+				"(use OpenFullMutex, Conn.SetSerializedAccess(true) or one connection per goroutine)", goid()))
+		}
+		g.cond.Wait()
+	}
+	g.owner = id
+	g.depth++
+}
+
+func (g *connGuard) unlock() {
+	g.m.Lock()
+	defer g.m.Unlock()
+	g.depth--
+	if g.depth == 0 {
+		g.owner = 0
+		g.cond.Signal()
+	}
+	runtime.UnlockOSThread()
+}
+
+// goid returns the current goroutine id (parsed from the stack header "goroutine 123 [running]:"),
+// only used for the misuse diagnostic.
+func goid() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
+
+// enter and leave count the calls in progress atomically:
+// a connection opened with OpenFullMutex may be used by many goroutines without guard.
+func (c *Conn) enter() {
+	if c.guard != nil {
+		c.guard.lock()
+	}
+	atomic.AddInt32(&c.depth, 1)
+}
+
+func (c *Conn) leave() {
+	if atomic.AddInt32(&c.depth, -1) == 0 && c.recovery != nil {
+		c.recoverPending()
+	}
+	if c.guard != nil {
+		c.guard.unlock()
+	}
+}
+
+// SetSerializedAccess makes the connection safe for concurrent use by several goroutines
+// even when it has been opened with OpenNoMutex:
+// statement preparation, binding, stepping, column accessors (Stmt.Scan*, Stmt.Column*, Stmt.PeekType, ...),
+// reset, finalization and Conn.Close are serialized by an internal (goroutine reentrant) mutex.
+// Other methods (Conn.Status, Stmt.ColumnCount, ...) and the BLOB handles are not.
+// It must not be called while the connection is in use.
+//
+// When the package is built with the 'sqlite_debug' tag, connections opened with OpenNoMutex
+// (and without serialized access) panic with a clear message when they are used concurrently.
+func (c *Conn) SetSerializedAccess(b bool) {
+	if b {
+		c.guard = newConnGuard(true)
+	} else if debugGuard && c.noMutex {
+		c.guard = newConnGuard(false)
+	} else {
+		c.guard = nil
+	}
+}
+
+// SerializedAccess tells if the connection access is serialized by an internal mutex.
+func (c *Conn) SerializedAccess() bool {
+	return c.guard != nil && c.guard.serialize
+}
@@ -0,0 +1,42 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_debug
+// +build sqlite_debug
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"testing"
+)
+
+func TestConcurrentMisuse(t *testing.T) {
+	db := openNoMutex(t)
+	defer checkClose(db, t)
+	inside := make(chan bool)
+	release := make(chan bool)
+	err := db.CreateScalarFunction("wait", 0, nil, func(ctx *ScalarContext, nArg int) {
+		inside <- true
+		<-release
+		ctx.ResultInt(1)
+	}, nil)
+	checkNoError(t, err, "couldn't create function: %s")
+	done := make(chan error)
+	go func() {
+		var i int
+		done <- db.OneValue("SELECT wait()", &i)
+	}()
+	<-inside
+	panicked := func() (p bool) {
+		defer func() {
+			p = recover() != nil
+		}()
+		db.Exists("SELECT 1")
+		return
+	}()
+	close(release)
+	checkNoError(t, <-done, "couldn't call function: %s")
+	assert(t, "panic expected on concurrent use", panicked)
+}
@@ -0,0 +1,89 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"sync"
+	"testing"
+)
+
+func openNoMutex(t *testing.T) *Conn {
+	db, err := Open(":memory:", OpenReadWrite, OpenCreate, OpenNoMutex)
+	checkNoError(t, err, "couldn't open database file: %s")
+	return db
+}
+
+func TestSerializedAccess(t *testing.T) {
+	db := openNoMutex(t)
+	defer checkClose(db, t)
+	db.SetSerializedAccess(true)
+	assert(t, "serialized access expected", db.SerializedAccess())
+	createTable(db, t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if err := db.Exec("INSERT INTO test (int_num) VALUES (?)", i*100+j); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := db.Exists("SELECT 1 FROM test WHERE int_num = ?", i*100+j); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	var count int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM test", &count), "couldn't count rows: %s")
+	assertEquals(t, "expected %d rows but got %d", 400, count)
+}
+
+func TestFullMutexConcurrentUse(t *testing.T) {
+	db, err := Open(":memory:", OpenReadWrite, OpenCreate, OpenFullMutex)
+	checkNoError(t, err, "couldn't open database file: %s")
+	defer checkClose(db, t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var n int
+			for j := 0; j < 50; j++ {
+				if err := db.OneValue("SELECT 1", &n); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSerializedAccessReentrant(t *testing.T) {
+	db := openNoMutex(t)
+	defer checkClose(db, t)
+	db.SetSerializedAccess(true)
+	err := db.CreateScalarFunction("nested", 0, nil, func(ctx *ScalarContext, nArg int) {
+		var i int
+		if err := db.OneValue("SELECT 41 + 1", &i); err != nil {
+			ctx.ResultError(err.Error())
+			return
+		}
+		ctx.ResultInt(i)
+	}, nil)
+	checkNoError(t, err, "couldn't create function: %s")
+	var i int
+	checkNoError(t, db.OneValue("SELECT nested()", &i), "couldn't call function: %s")
+	assertEquals(t, "expected %d but got %d", 42, i)
+	db.SetSerializedAccess(false)
+	assert(t, "serialized access not expected", !db.SerializedAccess())
+}
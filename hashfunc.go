@@ -0,0 +1,59 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"strings"
+)
+
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// CreateHashFunctions registers the md5(x), sha1(x), sha256(x), sha512(x)
+// and hmac(algorithm, key, x) SQL functions (algorithm is one of 'md5', 'sha1', 'sha256' or 'sha512').
+// Like the sha3 function of the sqlite3 shell, they return a BLOB (use hex() to get a text)
+// and hash texts as UTF-8 and numbers as their text representation.
+// NULL arguments give NULL results.
+func (c *Conn) CreateHashFunctions() error {
+	for name, h := range hashes {
+		h := h
+		err := c.CreateScalarFunction(name, 1, nil, func(ctx *ScalarContext, nArg int) {
+			if ctx.Type(0) == Null {
+				ctx.ResultNull()
+				return
+			}
+			d := h()
+			d.Write(ctx.Blob(0))
+			ctx.ResultBlob(d.Sum(nil))
+		}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return c.CreateScalarFunction("hmac", 3, nil, func(ctx *ScalarContext, nArg int) {
+		if ctx.Type(0) == Null || ctx.Type(1) == Null || ctx.Type(2) == Null {
+			ctx.ResultNull()
+			return
+		}
+		h, ok := hashes[strings.ToLower(ctx.Text(0))]
+		if !ok {
+			ctx.ResultError("unsupported hmac algorithm: " + ctx.Text(0))
+			return
+		}
+		mac := hmac.New(h, ctx.Blob(1))
+		mac.Write(ctx.Blob(2))
+		ctx.ResultBlob(mac.Sum(nil))
+	}, nil)
+}
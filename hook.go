@@ -10,11 +10,12 @@ package sqlite
 void* goSqlite3CommitHook(sqlite3 *db, void *udp);
 void* goSqlite3RollbackHook(sqlite3 *db, void *udp);
 void* goSqlite3UpdateHook(sqlite3 *db, void *udp);
-//void* goSqlite3WalHook(sqlite3 *db, void *udp);
+void* goSqlite3WalHook(sqlite3 *db, void *udp);
 */
 import "C"
 
 import (
+	"fmt"
 	"unsafe"
 )
 
@@ -22,100 +23,372 @@ import (
 // If the callback on a commit hook function returns true, then the commit is converted into a rollback.
 type CommitHook func(udp interface{}) bool
 
+// HookToken identifies a hook added with Conn.AddCommitHook, Conn.AddRollbackHook or Conn.AddUpdateHook.
+// The zero value identifies the hooks registered with Conn.CommitHook, Conn.RollbackHook and Conn.UpdateHook.
+type HookToken uint64
+
+// CommitErrorHook is the signature of a commit hook which vetoes the commit with a diagnostic:
+// if it returns an error, the commit is converted into a rollback
+// and the committing caller gets a *CommitAbortedError wrapping this error.
+type CommitErrorHook func(udp interface{}) error
+
+// CommitAbortedError is returned when a commit has been converted into a rollback
+// by a CommitErrorHook (see Conn.AddCommitErrorHook).
+type CommitAbortedError struct {
+	Err   error // error returned by the (first failing) commit hook
+	Cause error // original SQLITE_CONSTRAINT_COMMITHOOK error (*ConnError or *StmtError)
+}
+
+func (e *CommitAbortedError) Error() string {
+	return fmt.Sprintf("commit aborted by hook: %s", e.Err)
+}
+
+// Unwrap returns the error returned by the commit hook and the original error
+// so that errors.Is and errors.As match both.
+func (e *CommitAbortedError) Unwrap() []error {
+	return []error{e.Err, e.Cause}
+}
+
 type sqliteCommitHook struct {
-	f   CommitHook
-	udp interface{}
+	f     CommitHook
+	fe    CommitErrorHook
+	udp   interface{}
+	token HookToken
+}
+
+// hookChain multiplexes the commit, rollback and update hooks of a connection.
+// Slices are never modified in place so that hooks can be added or removed by a hook.
+type hookChain struct {
+	commit    []*sqliteCommitHook
+	rollback  []*sqliteRollbackHook
+	update    []*sqliteUpdateHook
+	lastToken HookToken
+	commitErr error // error returned by a CommitErrorHook during the last commit
+
+	afterCommit []*sqliteAfterCommitHook
+	committing  bool // the commit hooks have accepted the current commit
+	savepoint   []*sqliteSavepointHook
+}
+
+// sqliteAfterCommitHook is invoked once the transaction accepted by the commit hooks is really committed,
+// outside of the commit hook (see Conn.afterStep).
+type sqliteAfterCommitHook struct {
+	f     func()
+	token HookToken
+}
+
+func (c *Conn) initHooks() *hookChain {
+	if c.hooks == nil {
+		c.hooks = &hookChain{}
+	}
+	return c.hooks
 }
 
 //export goXCommitHook
 func goXCommitHook(udp unsafe.Pointer) C.int {
-	arg := (*sqliteCommitHook)(udp)
-	return btocint(arg.f(arg.udp))
+	chain := (*hookChain)(udp)
+	var rollback bool
+	chain.commitErr = nil
+	for _, h := range chain.commit {
+		if h.fe != nil {
+			if err := h.fe(h.udp); err != nil {
+				if chain.commitErr == nil {
+					chain.commitErr = err
+				}
+				rollback = true
+			}
+		} else if h.f(h.udp) {
+			rollback = true
+		}
+	}
+	chain.committing = !rollback
+	return btocint(rollback)
+}
+
+// addAfterCommitHook adds a callback to be invoked after each successful commit
+// (the caller must also add a commit hook and a rollback hook for the commit to be tracked).
+// Returns a token to be used with Conn.RemoveHook.
+func (c *Conn) addAfterCommitHook(f func()) HookToken {
+	chain := c.initHooks()
+	chain.lastToken++
+	hooks := make([]*sqliteAfterCommitHook, len(chain.afterCommit), len(chain.afterCommit)+1)
+	copy(hooks, chain.afterCommit)
+	chain.afterCommit = append(hooks, &sqliteAfterCommitHook{f, chain.lastToken})
+	return chain.lastToken
+}
+
+// afterStep is called after each step: the after-commit hooks are invoked
+// once the connection is back in autocommit mode after a commit accepted by the commit hooks
+// (a commit failing afterward is rolled back and the rollback hook resets the flag).
+func (c *Conn) afterStep() {
+	chain := c.hooks
+	if chain == nil || !chain.committing || !c.autocommit() {
+		return
+	}
+	chain.committing = false
+	for _, h := range chain.afterCommit {
+		h.f()
+	}
+}
+
+// commitAborted returns a *CommitAbortedError wrapping err
+// if the commit has been vetoed by a CommitErrorHook, err otherwise.
+func (c *Conn) commitAborted(err error, extCode int) error {
+	if extCode != C.SQLITE_CONSTRAINT_COMMITHOOK || c.hooks == nil || c.hooks.commitErr == nil {
+		return err
+	}
+	herr := c.hooks.commitErr
+	c.hooks.commitErr = nil
+	return &CommitAbortedError{Err: herr, Cause: err}
 }
 
 // CommitHook registers a callback function to be invoked whenever a transaction is committed.
+// It replaces the hook previously registered by CommitHook but not those added with AddCommitHook.
 // (See http://sqlite.org/c3ref/commit_hook.html)
 func (c *Conn) CommitHook(f CommitHook, udp interface{}) {
-	if f == nil {
-		c.commitHook = nil
+	chain := c.initHooks()
+	hooks := removeCommitHook(chain.commit, 0)
+	if f != nil {
+		hooks = append([]*sqliteCommitHook{{f: f, udp: udp}}, hooks...)
+	}
+	c.setCommitHooks(hooks)
+}
+
+// AddCommitHook adds a callback function to be invoked whenever a transaction is committed,
+// after the ones previously registered.
+// The commit is converted into a rollback if any callback returns true (all callbacks are invoked anyway).
+// Returns a token to be used with Conn.RemoveHook.
+func (c *Conn) AddCommitHook(f CommitHook, udp interface{}) HookToken {
+	return c.addCommitHook(&sqliteCommitHook{f: f, udp: udp})
+}
+
+// AddCommitErrorHook adds a callback function to be invoked whenever a transaction is committed,
+// after the ones previously registered.
+// If it returns an error, the commit is converted into a rollback
+// and the caller of the commit (Conn.Commit, Stmt.Exec, ...) gets a *CommitAbortedError
+// wrapping the error (only the first one if many hooks fail).
+// Returns a token to be used with Conn.RemoveHook.
+func (c *Conn) AddCommitErrorHook(f CommitErrorHook, udp interface{}) HookToken {
+	return c.addCommitHook(&sqliteCommitHook{fe: f, udp: udp})
+}
+
+func (c *Conn) addCommitHook(h *sqliteCommitHook) HookToken {
+	chain := c.initHooks()
+	chain.lastToken++
+	h.token = chain.lastToken
+	hooks := make([]*sqliteCommitHook, len(chain.commit), len(chain.commit)+1)
+	copy(hooks, chain.commit)
+	c.setCommitHooks(append(hooks, h))
+	return chain.lastToken
+}
+
+func removeCommitHook(hooks []*sqliteCommitHook, token HookToken) []*sqliteCommitHook {
+	var result []*sqliteCommitHook
+	for _, h := range hooks {
+		if h.token != token {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+func (c *Conn) setCommitHooks(hooks []*sqliteCommitHook) {
+	chain := c.initHooks()
+	wasEmpty := len(chain.commit) == 0
+	chain.commit = hooks
+	if len(hooks) == 0 {
 		C.sqlite3_commit_hook(c.db, nil, nil)
-		return
+	} else if wasEmpty {
+		// To make sure it is not gced, the chain is referenced by the connection.
+		C.goSqlite3CommitHook(c.db, unsafe.Pointer(chain))
 	}
-	// To make sure it is not gced, keep a reference in the connection.
-	c.commitHook = &sqliteCommitHook{f, udp}
-	C.goSqlite3CommitHook(c.db, unsafe.Pointer(c.commitHook))
 }
 
 // RollbackHook is the callback function signature.
 type RollbackHook func(udp interface{})
 
 type sqliteRollbackHook struct {
-	f   RollbackHook
-	udp interface{}
+	f     RollbackHook
+	udp   interface{}
+	token HookToken
 }
 
 //export goXRollbackHook
 func goXRollbackHook(udp unsafe.Pointer) {
-	arg := (*sqliteRollbackHook)(udp)
-	arg.f(arg.udp)
+	chain := (*hookChain)(udp)
+	chain.committing = false
+	for _, h := range chain.rollback {
+		h.f(h.udp)
+	}
 }
 
 // RollbackHook registers a callback to be invoked each time a transaction is rolled back.
+// It replaces the hook previously registered by RollbackHook but not those added with AddRollbackHook.
 // (See http://sqlite.org/c3ref/commit_hook.html)
 func (c *Conn) RollbackHook(f RollbackHook, udp interface{}) {
-	if f == nil {
-		c.rollbackHook = nil
+	chain := c.initHooks()
+	hooks := removeRollbackHook(chain.rollback, 0)
+	if f != nil {
+		hooks = append([]*sqliteRollbackHook{{f, udp, 0}}, hooks...)
+	}
+	c.setRollbackHooks(hooks)
+}
+
+// AddRollbackHook adds a callback to be invoked each time a transaction is rolled back,
+// after the ones previously registered.
+// Returns a token to be used with Conn.RemoveHook.
+func (c *Conn) AddRollbackHook(f RollbackHook, udp interface{}) HookToken {
+	chain := c.initHooks()
+	chain.lastToken++
+	hooks := make([]*sqliteRollbackHook, len(chain.rollback), len(chain.rollback)+1)
+	copy(hooks, chain.rollback)
+	c.setRollbackHooks(append(hooks, &sqliteRollbackHook{f, udp, chain.lastToken}))
+	return chain.lastToken
+}
+
+func removeRollbackHook(hooks []*sqliteRollbackHook, token HookToken) []*sqliteRollbackHook {
+	var result []*sqliteRollbackHook
+	for _, h := range hooks {
+		if h.token != token {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+func (c *Conn) setRollbackHooks(hooks []*sqliteRollbackHook) {
+	chain := c.initHooks()
+	wasEmpty := len(chain.rollback) == 0
+	chain.rollback = hooks
+	if len(hooks) == 0 {
 		C.sqlite3_rollback_hook(c.db, nil, nil)
-		return
+	} else if wasEmpty {
+		// To make sure it is not gced, the chain is referenced by the connection.
+		C.goSqlite3RollbackHook(c.db, unsafe.Pointer(chain))
 	}
-	// To make sure it is not gced, keep a reference in the connection.
-	c.rollbackHook = &sqliteRollbackHook{f, udp}
-	C.goSqlite3RollbackHook(c.db, unsafe.Pointer(c.rollbackHook))
 }
 
 // UpdateHook is the callback function signature.
 type UpdateHook func(udp interface{}, a Action, dbName, tableName string, rowId int64)
 
 type sqliteUpdateHook struct {
-	f   UpdateHook
-	udp interface{}
+	f     UpdateHook
+	udp   interface{}
+	token HookToken
 }
 
 //export goXUpdateHook
 func goXUpdateHook(udp unsafe.Pointer, action int, dbName, tableName *C.char, rowId C.sqlite3_int64) {
-	arg := (*sqliteUpdateHook)(udp)
-	arg.f(arg.udp, Action(action), C.GoString(dbName), C.GoString(tableName), int64(rowId))
+	chain := (*hookChain)(udp)
+	db, table := C.GoString(dbName), C.GoString(tableName)
+	for _, h := range chain.update {
+		h.f(h.udp, Action(action), db, table, int64(rowId))
+	}
 }
 
 // UpdateHook registers a callback to be invoked each time a row is updated,
 // inserted or deleted using this database connection.
+// It replaces the hook previously registered by UpdateHook but not those added with AddUpdateHook.
 // (See http://sqlite.org/c3ref/update_hook.html)
 func (c *Conn) UpdateHook(f UpdateHook, udp interface{}) {
-	if f == nil {
-		c.updateHook = nil
+	chain := c.initHooks()
+	hooks := removeUpdateHook(chain.update, 0)
+	if f != nil {
+		hooks = append([]*sqliteUpdateHook{{f, udp, 0}}, hooks...)
+	}
+	c.setUpdateHooks(hooks)
+}
+
+// AddUpdateHook adds a callback to be invoked each time a row is updated, inserted or deleted,
+// after the ones previously registered.
+// Returns a token to be used with Conn.RemoveHook.
+func (c *Conn) AddUpdateHook(f UpdateHook, udp interface{}) HookToken {
+	chain := c.initHooks()
+	chain.lastToken++
+	hooks := make([]*sqliteUpdateHook, len(chain.update), len(chain.update)+1)
+	copy(hooks, chain.update)
+	c.setUpdateHooks(append(hooks, &sqliteUpdateHook{f, udp, chain.lastToken}))
+	return chain.lastToken
+}
+
+func removeUpdateHook(hooks []*sqliteUpdateHook, token HookToken) []*sqliteUpdateHook {
+	var result []*sqliteUpdateHook
+	for _, h := range hooks {
+		if h.token != token {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+func (c *Conn) setUpdateHooks(hooks []*sqliteUpdateHook) {
+	chain := c.initHooks()
+	wasEmpty := len(chain.update) == 0
+	chain.update = hooks
+	if len(hooks) == 0 {
 		C.sqlite3_update_hook(c.db, nil, nil)
-		return
+	} else if wasEmpty {
+		// To make sure it is not gced, the chain is referenced by the connection.
+		C.goSqlite3UpdateHook(c.db, unsafe.Pointer(chain))
 	}
-	// To make sure it is not gced, keep a reference in the connection.
-	c.updateHook = &sqliteUpdateHook{f, udp}
-	C.goSqlite3UpdateHook(c.db, unsafe.Pointer(c.updateHook))
 }
 
-/*
+// RemoveHook removes a commit, rollback or update hook added with
+// Conn.AddCommitHook, Conn.AddRollbackHook or Conn.AddUpdateHook.
+// Returns false if there is no hook with the specified token.
+func (c *Conn) RemoveHook(token HookToken) bool {
+	if c.hooks == nil || token == 0 {
+		return false
+	}
+	chain := c.hooks
+	if hooks := removeCommitHook(chain.commit, token); len(hooks) != len(chain.commit) {
+		c.setCommitHooks(hooks)
+		return true
+	}
+	if hooks := removeRollbackHook(chain.rollback, token); len(hooks) != len(chain.rollback) {
+		c.setRollbackHooks(hooks)
+		return true
+	}
+	if hooks := removeUpdateHook(chain.update, token); len(hooks) != len(chain.update) {
+		c.setUpdateHooks(hooks)
+		return true
+	}
+	for i, h := range chain.afterCommit {
+		if h.token == token {
+			hooks := make([]*sqliteAfterCommitHook, 0, len(chain.afterCommit)-1)
+			chain.afterCommit = append(append(hooks, chain.afterCommit[:i]...), chain.afterCommit[i+1:]...)
+			return true
+		}
+	}
+	for i, h := range chain.savepoint {
+		if h.token == token {
+			hooks := make([]*sqliteSavepointHook, 0, len(chain.savepoint)-1)
+			chain.savepoint = append(append(hooks, chain.savepoint[:i]...), chain.savepoint[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// WalHook is the callback function signature.
+// It must return SQLITE_OK (0) or an error code (which is then propagated back up through the COMMIT statement).
 type WalHook func(udp interface{}, c *Conn, dbName string, nEntry int) int
 
 type sqliteWalHook struct {
 	f   WalHook
 	udp interface{}
+	c   *Conn
 }
 
 //export goXWalHook
 func goXWalHook(udp, db unsafe.Pointer, dbName *C.char, nEntry C.int) C.int {
-	return 0
+	arg := (*sqliteWalHook)(udp)
+	return C.int(arg.f(arg.udp, arg.c, C.GoString(dbName), int(nEntry)))
 }
 
-// Register a callback to be invoked each time a transaction is written
+// WalHook registers a callback to be invoked each time a transaction is written
 // into the write-ahead-log by this database connection.
+// It replaces the default auto-checkpoint behaviour (see Conn.WalAutoCheckpoint).
 // (See http://sqlite.org/c3ref/wal_hook.html)
 func (c *Conn) WalHook(f WalHook, udp interface{}) {
 	if f == nil {
@@ -124,7 +397,6 @@ func (c *Conn) WalHook(f WalHook, udp interface{}) {
 		return
 	}
 	// To make sure it is not gced, keep a reference in the connection.
-	c.walHook = &sqliteWalHook{f, udp}
+	c.walHook = &sqliteWalHook{f, udp, c}
 	C.goSqlite3WalHook(c.db, unsafe.Pointer(c.walHook))
 }
-*/
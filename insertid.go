@@ -0,0 +1,144 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+
+int goSqlite3SetAuthorizer(sqlite3 *db, void *udp);
+*/
+import "C"
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// SetLastInsertRowid sets the value returned by LastInsertRowid.
+// (See http://sqlite.org/c3ref/set_last_insert_rowid.html)
+func (c *Conn) SetLastInsertRowid(id int64) {
+	C.sqlite3_set_last_insert_rowid(c.db, C.sqlite3_int64(id))
+}
+
+// SetStrictInsertIds makes Stmt.Insert (-1) and the database/sql driver (error) not report
+// the last inserted rowid when the executed statement is not an INSERT into a rowid table
+// (the rowid of a previous INSERT would otherwise be returned after an UPDATE or a DELETE).
+// The statements are inspected once, when they are first executed, by preparing them again
+// with an authorizer recording the tables they insert into (the ones executed by Conn.Exec are not cached).
+// With an URI filename, the "strict_insert_ids" parameter enables it for database/sql.
+func (c *Conn) SetStrictInsertIds(on bool) {
+	c.strictInsertIds = on
+}
+
+// inserts tells if the statement updates the last inserted rowid (see insertProbe).
+// It must be called before the first execution (a DDL statement cannot be prepared again once executed).
+func (s *Stmt) inserts() bool {
+	if s.insert == 0 {
+		s.insert = -1
+		var inserts bool
+		if inserts, s.withoutRowid = s.c.probeInserts(s.SQL()); inserts {
+			s.insert = 1
+		}
+	}
+	return s.insert > 0
+}
+
+// insertProbe records the tables a statement inserts into while it is prepared
+// (SQLITE_INSERT actions not coming from a trigger, see goXAuth).
+type insertProbe struct {
+	tables []insertedTable
+}
+
+type insertedTable struct {
+	dbName, name string
+}
+
+func (p *insertProbe) record(action Action, arg1, dbName string, inTrigger bool) {
+	if action != Insert || inTrigger {
+		return
+	}
+	switch strings.ToLower(arg1) {
+	case "sqlite_master", "sqlite_temp_master", "sqlite_schema", "sqlite_temp_schema": // DDL
+		return
+	}
+	p.tables = append(p.tables, insertedTable{dbName, arg1})
+}
+
+// probeInserts tells if the specified statement updates the last inserted rowid:
+// it inserts into a rowid table (or a virtual table), not a view nor a WITHOUT ROWID table.
+// It returns true when it cannot tell.
+// Otherwise, it also returns the name of a WITHOUT ROWID table inserted into by the statement (if any).
+// When no authorizer is set, one is registered while the statement is prepared
+// (the other statements are then prepared again by SQLite before their next execution).
+func (c *Conn) probeInserts(sql string) (bool, string) {
+	if c.authorizer == nil {
+		c.authorizer = &sqliteAuthorizer{c: c}
+		if err := c.error(C.goSqlite3SetAuthorizer(c.db, unsafe.Pointer(c.authorizer)), "Conn.probeInserts"); err != nil {
+			c.authorizer = nil
+			return true, ""
+		}
+		defer c.SetAuthorizer(nil, nil)
+	}
+	probe := &insertProbe{}
+	c.insertProbe = probe
+	s, err := c.prepare(sql)
+	c.insertProbe = nil
+	if err != nil {
+		return true, ""
+	}
+	s.finalize()
+	var withoutRowid string
+	for _, t := range probe.tables {
+		typ, def, err := c.schemaObject(t.dbName, t.name)
+		if err != nil {
+			return true, ""
+		}
+		if typ != "table" {
+			continue
+		} else if !isWithoutRowid(def) {
+			return true, ""
+		}
+		withoutRowid = t.name
+	}
+	return false, withoutRowid
+}
+
+// schemaObject returns the type ("table", "view", ...) and the definition of the named schema object.
+func (c *Conn) schemaObject(dbName, name string) (typ, sql string, err error) {
+	s, err := c.prepare(Mprintf2(`SELECT type, sql FROM "%w".sqlite_master WHERE name = %Q COLLATE NOCASE`, dbName, name))
+	if err != nil {
+		return "", "", err
+	}
+	defer s.finalize()
+	if ok, err := s.Next(); err != nil {
+		return "", "", err
+	} else if !ok {
+		return "", "", c.specificError("no such table: %s.%s", dbName, name)
+	}
+	typ, _ = s.ScanText(0)
+	sql, _ = s.ScanText(1)
+	return typ, sql, nil
+}
+
+// isWithoutRowid tells if the table options following the column definitions of
+// the specified CREATE TABLE statement include WITHOUT ROWID.
+func isWithoutRowid(createTable string) bool {
+	var options []string // words after the last closing parenthesis
+	scanSQL(createTable, func(kind sqlToken, token string) {
+		if kind != otherToken {
+			return
+		} else if token == ")" {
+			options = options[:0]
+		} else if isIdentChar(token[0]) {
+			options = append(options, token)
+		}
+	})
+	for i := 0; i+1 < len(options); i++ {
+		if strings.EqualFold(options[i], "WITHOUT") && strings.EqualFold(options[i+1], "ROWID") {
+			return true
+		}
+	}
+	return false
+}
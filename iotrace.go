@@ -0,0 +1,128 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"sync"
+	"time"
+)
+
+// VFSOpStats reports the calls of one file operation made through a TracingVFS.
+type VFSOpStats struct {
+	Calls    int64
+	Bytes    int64         // bytes read or written
+	Duration time.Duration // total duration (including the injected latency)
+	Max      time.Duration // longest call
+}
+
+// TracingVFS is a VFS shim recording the number and the duration of the file operations
+// (reads, writes, syncs, ...) and optionally injecting latency to reproduce a slow disk:
+//
+//	tv, err := RegisterTracingVFS("traced", "", false)
+//	tv.SetLatency(VFSSync, 10*time.Millisecond)
+//	db, err := OpenVfs("test.db", tv.Name())
+//	collector.RegisterVFS(tv)
+type TracingVFS struct {
+	name    string
+	mu      sync.Mutex
+	stats   [VFSDelete + 1]VFSOpStats
+	latency [VFSDelete + 1]time.Duration
+}
+
+// RegisterTracingVFS registers a tracing VFS shim named name on top of the parent VFS
+// (see RegisterVFSShim).
+func RegisterTracingVFS(name, parent string, makeDefault bool) (*TracingVFS, error) {
+	v := &TracingVFS{name: name}
+	if err := RegisterVFSShim(name, parent, v.shim, makeDefault); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Name returns the name of the VFS (see OpenVfs).
+func (v *TracingVFS) Name() string {
+	return v.name
+}
+
+// SetLatency adds a delay before each call of the specified operation (0 to disable).
+func (v *TracingVFS) SetLatency(op VFSOp, d time.Duration) {
+	if op < 0 || op > VFSDelete {
+		return
+	}
+	v.mu.Lock()
+	v.latency[op] = d
+	v.mu.Unlock()
+}
+
+// Stats returns the statistics of the specified operation.
+func (v *TracingVFS) Stats(op VFSOp) VFSOpStats {
+	if op < 0 || op > VFSDelete {
+		return VFSOpStats{}
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.stats[op]
+}
+
+// Reset clears the statistics.
+func (v *TracingVFS) Reset() {
+	v.mu.Lock()
+	v.stats = [VFSDelete + 1]VFSOpStats{}
+	v.mu.Unlock()
+}
+
+// Unregister unregisters the VFS. No connection must be using it.
+func (v *TracingVFS) Unregister() error {
+	return UnregisterVFSShim(v.name)
+}
+
+func (v *TracingVFS) shim(call *VFSCall, next func() error) error {
+	v.mu.Lock()
+	latency := v.latency[call.Op]
+	v.mu.Unlock()
+	start := time.Now()
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	err := next()
+	elapsed := time.Since(start)
+	v.mu.Lock()
+	s := &v.stats[call.Op]
+	s.Calls++
+	s.Bytes += int64(len(call.Data))
+	s.Duration += elapsed
+	if elapsed > s.Max {
+		s.Max = elapsed
+	}
+	v.mu.Unlock()
+	return err
+}
+
+var vfsMetricDescs = []MetricDesc{
+	{"sqlite_vfs_calls_total", "Number of file operations.", Counter, []string{"op", "vfs"}},
+	{"sqlite_vfs_bytes_total", "Number of bytes read or written.", Counter, []string{"op", "vfs"}},
+	{"sqlite_vfs_seconds_total", "Time spent in file operations.", Counter, []string{"op", "vfs"}},
+	{"sqlite_vfs_max_seconds", "Duration of the longest file operation.", Gauge, []string{"op", "vfs"}},
+}
+
+// metrics returns one sample of the statistics of the operations called at least once.
+func (v *TracingVFS) metrics() []Metric {
+	v.mu.Lock()
+	stats := v.stats
+	v.mu.Unlock()
+	var metrics []Metric
+	for op, s := range stats {
+		if s.Calls == 0 {
+			continue
+		}
+		labels := map[string]string{"vfs": v.name, "op": VFSOp(op).String()}
+		metrics = append(metrics,
+			vfsMetricDescs[0].metric(labels, float64(s.Calls)),
+			vfsMetricDescs[1].metric(labels, float64(s.Bytes)),
+			vfsMetricDescs[2].metric(labels, s.Duration.Seconds()),
+			vfsMetricDescs[3].metric(labels, s.Max.Seconds()))
+	}
+	return metrics
+}
@@ -0,0 +1,47 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTracingVFS(t *testing.T) {
+	tv, err := RegisterTracingVFS("traced", "", false)
+	checkNoError(t, err, "couldn't register tracing VFS: %s")
+	defer tv.Unregister()
+	tv.SetLatency(VFSSync, 5*time.Millisecond)
+
+	dir, err := ioutil.TempDir("", "gosqlite-trace")
+	checkNoError(t, err, "couldn't create temp dir: %s")
+	defer os.RemoveAll(dir)
+	db, err := OpenVfs(filepath.Join(dir, "test.db"), tv.Name())
+	checkNoError(t, err, "couldn't open database: %s")
+	defer checkClose(db, t)
+	createTable(db, t)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('a')"), "insert error: %s")
+
+	writes := tv.Stats(VFSWrite)
+	assert(t, "writes expected", writes.Calls > 0 && writes.Bytes > 0)
+	syncs := tv.Stats(VFSSync)
+	assert(t, "syncs expected", syncs.Calls > 0)
+	assert(t, "sync latency expected", syncs.Max >= 5*time.Millisecond && syncs.Duration >= time.Duration(syncs.Calls)*5*time.Millisecond)
+
+	collector := NewCollector()
+	collector.RegisterVFS(tv)
+	m, ok := findMetric(collector.Sample(), "sqlite_vfs_calls_total")
+	assert(t, "VFS metric expected", ok && m.Labels["vfs"] == "traced" && m.Value > 0)
+	collector.UnregisterVFS(tv.Name())
+	_, ok = findMetric(collector.Sample(), "sqlite_vfs_calls_total")
+	assert(t, "no VFS metric expected", !ok)
+
+	tv.Reset()
+	assertEquals(t, "expected %d writes but got %d", int64(0), tv.Stats(VFSWrite).Calls)
+}
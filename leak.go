@@ -0,0 +1,108 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// LeakPolicy tells what Conn.Close does with the statements and BLOB handles still open.
+type LeakPolicy int
+
+const (
+	LeakFinalize LeakPolicy = iota // finalize/close them silently (only logged), like sqlite3_close_v2 (default)
+	LeakReport                     // finalize/close them and return a *LeakError
+)
+
+// LeakError lists the statements and BLOB handles not finalized/closed before Conn.Close
+// (see Conn.SetLeakPolicy).
+type LeakError struct {
+	Statements []string // SQL of the leaked statements
+	Blobs      int      // number of leaked BLOB handles
+}
+
+func (e *LeakError) Error() string {
+	var s []string
+	if len(e.Statements) > 0 {
+		s = append(s, fmt.Sprintf("%d statement(s) not finalized: %q", len(e.Statements), e.Statements))
+	}
+	if e.Blobs > 0 {
+		s = append(s, fmt.Sprintf("%d blob(s) not closed", e.Blobs))
+	}
+	return "sqlite connection closed with " + strings.Join(s, " and ")
+}
+
+// SetLeakPolicy sets what Conn.Close does with the statements and BLOB handles still open.
+// Whatever the policy, they are finalized/closed so that the connection can be closed.
+func (c *Conn) SetLeakPolicy(p LeakPolicy) {
+	c.leakPolicy = p
+}
+
+// closeLeaks finalizes the dangling statements and closes the BLOB handles still open.
+// Cached statements must be flushed before.
+func (c *Conn) closeLeaks() *LeakError {
+	var leaks LeakError
+	// blobs first as they are backed by statements
+	for h := range c.blobs {
+		Log(C.SQLITE_MISUSE, "Dangling blob (not closed)")
+		C.sqlite3_blob_close(h.bl)
+		h.bl = nil
+		leaks.Blobs++
+	}
+	c.blobs = nil
+	stmt := C.sqlite3_next_stmt(c.db, nil)
+	for stmt != nil {
+		sql := C.GoString(C.sqlite3_sql(stmt))
+		if C.sqlite3_stmt_busy(stmt) != 0 {
+			Log(C.SQLITE_MISUSE, "Dangling statement (not reset): \""+sql+"\"")
+		} else {
+			Log(C.SQLITE_MISUSE, "Dangling statement (not finalize): \""+sql+"\"")
+		}
+		leaks.Statements = append(leaks.Statements, sql)
+		C.sqlite3_finalize(stmt)
+		stmt = C.sqlite3_next_stmt(c.db, nil)
+	}
+	if len(leaks.Statements) == 0 && leaks.Blobs == 0 {
+		return nil
+	}
+	return &leaks
+}
+
+// setLeakFinalizer makes the garbage collection of a statement, BLOB handle or backup
+// not finalized/closed logged with its allocation stack (see ConfigLog).
+// Only used with the sqlite_debug build tag (see debugLeaks).
+func setLeakFinalizer(obj interface{}, desc string) {
+	buf := make([]byte, 4096)
+	stack := string(buf[:runtime.Stack(buf, false)])
+	runtime.SetFinalizer(obj, func(obj interface{}) {
+		var closed bool
+		switch o := obj.(type) {
+		case *Stmt:
+			closed = o.stmt == nil
+		case *BlobReader:
+			closed = o.bl == nil
+		case *BlobReadWriter:
+			closed = o.bl == nil
+		case *Backup:
+			closed = o.sb == nil
+		}
+		if closed {
+			return
+		}
+		msg := "Leaked " + desc + " (garbage collected without being closed), allocated at:\n" + stack
+		if l := logger; l != nil { // directly as sqlite3_log truncates long messages
+			l.f(l.udp, ErrMisuse, msg)
+		} else {
+			Log(C.SQLITE_MISUSE, msg)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_debug
+// +build sqlite_debug
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeakFinalizer(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	leaks := make(chan string, 10)
+	logHook.Store(func(err error, msg string) {
+		if strings.HasPrefix(msg, "Leaked") {
+			leaks <- msg
+		}
+	})
+	defer logHook.Store(func(error, string) {})
+
+	func() {
+		s, err := db.Prepare("SELECT 'leaked'")
+		checkNoError(t, err, "prepare error: %s")
+		s.Cacheable = false
+		s, err = db.Prepare("SELECT 'finalized'")
+		checkNoError(t, err, "prepare error: %s")
+		checkFinalize(s, t)
+	}()
+	timeout := time.After(5 * time.Second)
+	for {
+		runtime.GC()
+		select {
+		case msg := <-leaks:
+			assert(t, "leaked statement SQL expected", strings.Contains(msg, "SELECT 'leaked'"))
+			assert(t, "allocation stack expected", strings.Contains(msg, "TestLeakFinalizer"))
+			return
+		case <-timeout:
+			t.Fatal("leak not logged")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestBlobLeakFinalizer(t *testing.T) {
+	db := open(t)
+	leaks := make(chan string, 10)
+	logHook.Store(func(err error, msg string) {
+		if strings.HasPrefix(msg, "Leaked") {
+			leaks <- msg
+		}
+	})
+	defer logHook.Store(func(error, string) {})
+	checkNoError(t, db.Exec("CREATE TABLE test (content BLOB); INSERT INTO test VALUES (zeroblob(10))"), "create error: %s")
+
+	func() {
+		_, err := db.NewBlobReader("main", "test", "content", db.LastInsertRowid())
+		checkNoError(t, err, "blob open error: %s")
+	}()
+	timeout := time.After(5 * time.Second)
+	for {
+		runtime.GC()
+		select {
+		case msg := <-leaks:
+			assert(t, "leaked blob expected", strings.Contains(msg, "blob (db: \"main\", tbl: \"test\""))
+			assert(t, "allocation stack expected", strings.Contains(msg, "TestBlobLeakFinalizer"))
+			// the handle is closed with the connection
+			db.SetLeakPolicy(LeakReport)
+			err := db.Close()
+			leak, ok := err.(*LeakError)
+			assert(t, "leak error expected", ok)
+			assertEquals(t, "expected %d leaked blob but got %d", 1, leak.Blobs)
+			return
+		case <-timeout:
+			t.Fatal("leak not logged")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,10 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !sqlite_debug
+// +build !sqlite_debug
+
+package sqlite
+
+const debugLeaks = false
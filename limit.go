@@ -0,0 +1,119 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+
+// Returns the size of the current row (8 bytes for numbers, the length of texts and blobs).
+static sqlite3_int64 my_row_bytes(sqlite3_stmt *stmt) {
+	sqlite3_int64 n = 0;
+	int i, ncol = sqlite3_column_count(stmt);
+	for (i = 0; i < ncol; i++) {
+		switch (sqlite3_column_type(stmt, i)) {
+		case SQLITE_INTEGER:
+		case SQLITE_FLOAT:
+			n += 8;
+			break;
+		case SQLITE_TEXT:
+		case SQLITE_BLOB:
+			n += sqlite3_column_bytes(stmt, i);
+			break;
+		}
+	}
+	return n;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded is wrapped by the *LimitError returned when a statement
+// exceeds the limits set by Stmt.SetMaxRows or Stmt.SetMaxResultBytes.
+var ErrLimitExceeded = errors.New("sqlite statement limit exceeded")
+
+// LimitError tells which statement limit has been exceeded.
+type LimitError struct {
+	SQL   string
+	Limit string // "rows" or "bytes"
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: more than %d %s returned by %q", ErrLimitExceeded, e.Max, e.Limit, e.SQL)
+}
+
+// Unwrap returns ErrLimitExceeded.
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// stmtLimits holds the limits of a statement and the counters of its current execution.
+type stmtLimits struct {
+	maxRows, maxBytes int64
+	rows, bytes       int64
+}
+
+// SetMaxRows makes Next (and NextRow) fail with a *LimitError
+// when the statement returns more than n rows in one execution.
+// n <= 0 removes the limit.
+func (s *Stmt) SetMaxRows(n int64) {
+	s.setLimits(n, -1)
+}
+
+// SetMaxResultBytes makes Next (and NextRow) fail with a *LimitError
+// when the rows returned by the statement in one execution exceed n bytes
+// (numbers count as 8 bytes, texts and blobs as their length).
+// n <= 0 removes the limit.
+func (s *Stmt) SetMaxResultBytes(n int64) {
+	s.setLimits(-1, n)
+}
+
+func (s *Stmt) setLimits(maxRows, maxBytes int64) {
+	if s.limits == nil {
+		s.limits = &stmtLimits{}
+	}
+	if maxRows >= 0 {
+		s.limits.maxRows = maxRows
+	}
+	if maxBytes >= 0 {
+		s.limits.maxBytes = maxBytes
+	}
+	if s.limits.maxRows <= 0 && s.limits.maxBytes <= 0 {
+		s.limits = nil
+	}
+}
+
+// startStep resets the counters when a new execution is about to start.
+func (s *Stmt) startStep() {
+	if s.limits != nil && C.sqlite3_stmt_busy(s.stmt) == 0 {
+		s.limits.rows, s.limits.bytes = 0, 0
+	}
+}
+
+// checkLimits is called for each new row and resets the statement once a limit is exceeded.
+func (s *Stmt) checkLimits() error {
+	l := s.limits
+	if l == nil {
+		return nil
+	}
+	var err error
+	l.rows++
+	if l.maxRows > 0 && l.rows > l.maxRows {
+		err = &LimitError{SQL: s.SQL(), Limit: "rows", Max: l.maxRows}
+	} else if l.maxBytes > 0 {
+		l.bytes += int64(C.my_row_bytes(s.stmt))
+		if l.bytes > l.maxBytes {
+			err = &LimitError{SQL: s.SQL(), Limit: "bytes", Max: l.maxBytes}
+		}
+	}
+	if err != nil {
+		C.sqlite3_reset(s.stmt)
+	}
+	return err
+}
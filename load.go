@@ -0,0 +1,214 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// LoadOptions configures Conn.Load.
+type LoadOptions struct {
+	Columns      []string         // columns inserted (default is all columns, in table order)
+	BatchSize    int              // rows per transaction (default is 10000)
+	DeferIndexes bool             // non-unique indexes are dropped before loading and recreated after
+	Progress     func(rows int64) // called after each committed batch with the number of rows loaded
+}
+
+// Load streams the rows returned by next (until io.EOF) into the main database table
+// with a prepared insert, committing every BatchSize rows:
+//
+//	n, err := db.Load("event", func() ([]interface{}, error) {
+//		var e Event
+//		if err := dec.Decode(&e); err != nil {
+//			return nil, err // io.EOF at the end
+//		}
+//		return []interface{}{e.ID, e.Name}, nil
+//	}, LoadOptions{BatchSize: 1000})
+//
+// If c is already in a transaction, the rows are loaded in it (no intermediate commit).
+// On error, the current batch is rolled back and the number of rows committed is returned.
+func (c *Conn) Load(table string, next RowIterator, opts LoadOptions) (n int64, err error) {
+	if next == nil {
+		return 0, errors.New("no row iterator")
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 10000
+	}
+	if opts.DeferIndexes {
+		var indexes []string
+		if indexes, err = c.dropIndexes(table); err != nil {
+			return
+		}
+		defer func() {
+			if ierr := c.Exec(strings.Join(indexes, ";")); err == nil {
+				err = ierr
+			}
+		}()
+	}
+	row, err := next()
+	if err == io.EOF {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	sql := Mprintf(`INSERT INTO "%w"`, table)
+	if len(opts.Columns) > 0 {
+		quoted := make([]string, len(opts.Columns))
+		for i, column := range opts.Columns {
+			quoted[i] = Mprintf(`"%w"`, column)
+		}
+		sql += " (" + strings.Join(quoted, ", ") + ")"
+	}
+	sql += " VALUES (" + strings.TrimSuffix(strings.Repeat("?, ", len(row)), ", ") + ")"
+	s, err := c.Prepare(sql)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Finalize()
+
+	batch := c.GetAutocommit()
+	var pending int64 // rows inserted in the current batch
+	end := func(commit bool) error {
+		if !batch {
+			return nil
+		}
+		if !commit {
+			return c.Rollback()
+		}
+		if err := c.Commit(); err != nil {
+			return err
+		}
+		n += pending
+		pending = 0
+		if opts.Progress != nil {
+			opts.Progress(n)
+		}
+		return nil
+	}
+	for {
+		if batch && pending == 0 {
+			if err = c.BeginTransaction(Deferred); err != nil {
+				return
+			}
+		}
+		if err = s.Exec(row...); err != nil {
+			end(false)
+			return
+		}
+		pending++
+		if !batch {
+			n++
+		} else if pending == int64(batchSize) {
+			if err = end(true); err != nil {
+				return
+			}
+		}
+		if row, err = next(); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			end(false)
+			return
+		}
+	}
+	if pending > 0 {
+		err = end(true)
+	}
+	return
+}
+
+// dropIndexes drops the explicit indexes of the table and returns the statements recreating them.
+// UNIQUE indexes are kept (they enforce a constraint on the loaded rows)
+// as well as partial and expression indexes.
+func (c *Conn) dropIndexes(table string) ([]string, error) {
+	s, err := c.prepare(`SELECT m.name, m.sql FROM sqlite_master m JOIN pragma_index_list(?1) l ON l.name = m.name
+WHERE m.type = 'index' AND m.tbl_name = ?1 AND m.sql IS NOT NULL AND NOT l."unique" AND NOT l.partial
+AND NOT EXISTS (SELECT 1 FROM pragma_index_info(m.name) WHERE cid = -2)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer s.finalize()
+	var names, indexes []string
+	err = s.Select(func(s *Stmt) error {
+		name, _ := s.ScanText(0)
+		sql, _ := s.ScanText(1)
+		names = append(names, name)
+		indexes = append(indexes, sql)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range names {
+		if err = c.Exec(Mprintf(`DROP INDEX "%w"`, name)); err != nil {
+			c.Exec(strings.Join(indexes[:i], ";"))
+			return nil, err
+		}
+	}
+	return indexes, nil
+}
+
+// FastLoad runs f (usually a massive load into table, see Conn.Load) with the standard fast-load recipe:
+// synchronous=OFF and an in-memory rollback journal (unless the database is in WAL mode),
+// the explicit non-unique indexes of the table dropped and recreated after f, all inside a savepoint.
+// If f or the indexes recreation fails, everything is rolled back.
+// The previous synchronous and journal modes are restored in any case.
+// c must not be in a transaction and the database is not durable during the load
+// (a power loss may corrupt it).
+func (c *Conn) FastLoad(table string, f func(c *Conn) error) (err error) {
+	if !c.GetAutocommit() {
+		return c.specificError("FastLoad cannot be used inside a transaction")
+	}
+	synchronous, err := c.Synchronous("main")
+	if err != nil {
+		return err
+	}
+	journalMode, err := c.JournalMode("main")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if serr := c.SetSynchronous("main", synchronous); err == nil {
+			err = serr
+		}
+		if journalMode != "wal" && journalMode != "memory" {
+			if _, jerr := c.SetJournalMode("main", journalMode); err == nil {
+				err = jerr
+			}
+		}
+	}()
+	if err = c.SetSynchronous("main", 0); err != nil {
+		return
+	}
+	if journalMode != "wal" && journalMode != "memory" {
+		if _, err = c.SetJournalMode("main", "memory"); err != nil {
+			return
+		}
+	}
+
+	const savepoint = "fast_load"
+	if err = c.Savepoint(savepoint); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			c.RollbackSavepoint(savepoint)
+		}
+		if rerr := c.ReleaseSavepoint(savepoint); err == nil {
+			err = rerr
+		}
+	}()
+	indexes, err := c.dropIndexes(table)
+	if err != nil {
+		return
+	}
+	if err = f(c); err != nil {
+		return
+	}
+	return c.Exec(strings.Join(indexes, ";"))
+}
@@ -0,0 +1,116 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/gwenn/gosqlite"
+	"github.com/gwenn/gosqlite/sqlitetest"
+	"io"
+	"testing"
+)
+
+func rowsUpTo(max int, fail error) RowIterator {
+	i := 0
+	return func() ([]interface{}, error) {
+		if i == max {
+			if fail != nil {
+				return nil, fail
+			}
+			return nil, io.EOF
+		}
+		i++
+		return []interface{}{i, "name"}, nil
+	}
+}
+
+func TestLoad(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE load (id INTEGER PRIMARY KEY, name TEXT, extra TEXT); CREATE INDEX load_name ON load (name)"), "create error: %s")
+
+	var progress []int64
+	n, err := db.Load("load", rowsUpTo(25, nil), LoadOptions{Columns: []string{"id", "name"}, BatchSize: 10, DeferIndexes: true,
+		Progress: func(rows int64) { progress = append(progress, rows) }})
+	checkNoError(t, err, "load error: %s")
+	assertEquals(t, "expected %d rows but got %d", int64(25), n)
+	assertEquals(t, "expected %v but got %v", "[10 20 25]", fmt.Sprint(progress))
+	var count int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM sqlite_master WHERE name = 'load_name'", &count), "index lookup error: %s")
+	assertEquals(t, "expected index to be recreated: %d vs %d", 1, count)
+	assert(t, "autocommit expected", db.GetAutocommit())
+
+	checkNoError(t, db.Exec("DELETE FROM load"), "delete error: %s")
+	boom := errors.New("boom")
+	n, err = db.Load("load", rowsUpTo(15, boom), LoadOptions{Columns: []string{"id", "name"}, BatchSize: 10})
+	assertEquals(t, "expected %v but got %v", boom, err)
+	assertEquals(t, "expected %d rows but got %d", int64(10), n)
+	checkNoError(t, db.OneValue("SELECT count(*) FROM load", &count), "count error: %s")
+	assertEquals(t, "expected %d rows but got %d", 10, count)
+	assert(t, "autocommit expected", db.GetAutocommit())
+
+	n, err = db.Load("load", rowsUpTo(0, nil), LoadOptions{})
+	checkNoError(t, err, "load error: %s")
+	assertEquals(t, "expected %d rows but got %d", int64(0), n)
+}
+
+func TestLoadDeferUniqueIndex(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE load (id INTEGER, name TEXT); CREATE UNIQUE INDEX load_id ON load (id);"+
+		"CREATE INDEX load_expr ON load (lower(name)); CREATE INDEX load_partial ON load (name) WHERE id > 0"), "create error: %s")
+	checkNoError(t, db.Exec("INSERT INTO load VALUES (5, 'name')"), "insert error: %s")
+
+	n, err := db.Load("load", rowsUpTo(10, nil), LoadOptions{DeferIndexes: true})
+	assert(t, "unique constraint error expected", err != nil)
+	assertEquals(t, "expected %d rows but got %d", int64(0), n)
+	var count int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM load", &count), "count error: %s")
+	assertEquals(t, "expected %d row but got %d", 1, count)
+	checkNoError(t, db.OneValue("SELECT count(*) FROM sqlite_master WHERE type = 'index'", &count), "index lookup error: %s")
+	assertEquals(t, "expected %d indexes but got %d", 3, count)
+}
+
+func TestFastLoad(t *testing.T) {
+	db := sqlitetest.Open(t, sqlitetest.TempFile())
+	checkNoError(t, db.Exec("CREATE TABLE load (id INTEGER PRIMARY KEY, name TEXT); CREATE INDEX load_name ON load (name)"), "create error: %s")
+	journalMode, err := db.JournalMode("main")
+	checkNoError(t, err, "journal mode error: %s")
+
+	var indexes int
+	err = db.FastLoad("load", func(c *Conn) error {
+		checkNoError(t, c.OneValue("SELECT count(*) FROM sqlite_master WHERE type = 'index'", &indexes), "index lookup error: %s")
+		assertEquals(t, "expected %d index during load but got %d", 0, indexes)
+		mode, err := c.JournalMode("main")
+		checkNoError(t, err, "journal mode error: %s")
+		assertEquals(t, "expected %q journal mode but got %q", "memory", mode)
+		_, err = c.Load("load", rowsUpTo(100, nil), LoadOptions{})
+		return err
+	})
+	checkNoError(t, err, "fast load error: %s")
+	checkNoError(t, db.OneValue("SELECT count(*) FROM sqlite_master WHERE type = 'index'", &indexes), "index lookup error: %s")
+	assertEquals(t, "expected %d index after load but got %d", 1, indexes)
+	mode, err := db.JournalMode("main")
+	checkNoError(t, err, "journal mode error: %s")
+	assertEquals(t, "expected %q journal mode but got %q", journalMode, mode)
+	synchronous, err := db.Synchronous("main")
+	checkNoError(t, err, "synchronous error: %s")
+	assert(t, "synchronous expected to be restored", synchronous != 0)
+
+	boom := errors.New("boom")
+	err = db.FastLoad("load", func(c *Conn) error {
+		if err := c.Exec("INSERT INTO load (name) SELECT name FROM load"); err != nil {
+			return err
+		}
+		return boom
+	})
+	assertEquals(t, "expected %v but got %v", boom, err)
+	var count int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM load", &count), "count error: %s")
+	assertEquals(t, "expected %d rows but got %d", 100, count)
+	checkNoError(t, db.OneValue("SELECT count(*) FROM sqlite_master WHERE type = 'index'", &indexes), "index lookup error: %s")
+	assertEquals(t, "expected %d index after rollback but got %d", 1, indexes)
+}
@@ -0,0 +1,280 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ErrLOBNotFound is returned by LOBStore.Open and LOBStore.Size when there is no object with the specified id.
+var ErrLOBNotFound = errors.New("large object not found")
+
+// LOBCorruptionError is returned when the hash of a chunk does not match its content.
+type LOBCorruptionError struct {
+	ID  string
+	Seq int64 // chunk number
+}
+
+func (e *LOBCorruptionError) Error() string {
+	return fmt.Sprintf("large object %q: chunk %d corrupted (hash mismatch)", e.ID, e.Seq)
+}
+
+// LOBStore stores large objects (bigger than practical single-BLOB sizes, see LimitLength)
+// as ordered chunks across the rows of a table, with a SHA-256 hash per chunk
+// checked when the chunk is read:
+//
+//	store, err := NewLOBStore(db, "lob", 1<<20)
+//	w, err := store.Create("video.mp4")
+//	io.Copy(w, f)
+//	err = w.Close()
+//	r, err := store.Open("video.mp4") // io.ReadSeekCloser
+//
+// Chunks are written and read with incremental BLOB I/O (see BlobReadWriter and BlobReader).
+// Writing an object is not atomic unless it is done inside a transaction.
+type LOBStore struct {
+	c         *Conn
+	table     string
+	chunkSize int
+}
+
+// NewLOBStore creates the chunks table in the main database (if it doesn't exist).
+// chunkSize is the size of the chunks written (default is 1 MiB).
+func NewLOBStore(c *Conn, table string, chunkSize int) (*LOBStore, error) {
+	if chunkSize <= 0 {
+		chunkSize = 1 << 20
+	}
+	err := c.Exec(Mprintf(`CREATE TABLE IF NOT EXISTS "%w" (
+		id TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		data BLOB NOT NULL,
+		hash BLOB NOT NULL,
+		UNIQUE (id, seq))`, table) + c.tableOptions())
+	if err != nil {
+		return nil, err
+	}
+	return &LOBStore{c: c, table: table, chunkSize: chunkSize}, nil
+}
+
+// Create deletes the object with the specified id (if any) and returns a writer for its new content.
+// The last chunk is written when the writer is closed.
+func (s *LOBStore) Create(id string) (*LOBWriter, error) {
+	if err := s.Delete(id); err != nil {
+		return nil, err
+	}
+	return &LOBWriter{s: s, id: id, buf: make([]byte, 0, s.chunkSize)}, nil
+}
+
+// Delete deletes the object with the specified id (no error if it doesn't exist).
+func (s *LOBStore) Delete(id string) error {
+	return s.c.Exec(Mprintf(`DELETE FROM "%w" WHERE id = ?`, s.table), id)
+}
+
+// Size returns the size of the object with the specified id.
+func (s *LOBStore) Size(id string) (int64, error) {
+	var size interface{}
+	err := s.c.OneValue(Mprintf(`SELECT sum(length(data)) FROM "%w" WHERE id = ?`, s.table), &size, id)
+	if err != nil {
+		return -1, err
+	} else if size == nil {
+		return -1, ErrLOBNotFound
+	}
+	return size.(int64), nil
+}
+
+// LOBWriter writes the content of a large object (see LOBStore.Create).
+type LOBWriter struct {
+	s      *LOBStore
+	id     string
+	seq    int64
+	buf    []byte
+	closed bool
+}
+
+// Write buffers p and writes the complete chunks.
+func (w *LOBWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("large object writer already closed")
+	}
+	n := 0
+	for len(p) > 0 {
+		m := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		p = p[m:]
+		n += m
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (w *LOBWriter) flush() error {
+	hash := sha256.Sum256(w.buf)
+	err := w.s.c.Exec(Mprintf(`INSERT INTO "%w" (id, seq, data, hash) VALUES (?, ?, ?, ?)`, w.s.table),
+		w.id, w.seq, ZeroBlobLength(len(w.buf)), hash[:])
+	if err != nil {
+		return err
+	}
+	if len(w.buf) > 0 {
+		bw, err := w.s.c.NewBlobReadWriter("main", w.s.table, "data", w.s.c.LastInsertRowid())
+		if err != nil {
+			return err
+		}
+		_, err = bw.Write(w.buf)
+		if cerr := bw.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	w.seq++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close writes the last chunk (an empty object is stored as one empty chunk).
+func (w *LOBWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if len(w.buf) > 0 || w.seq == 0 {
+		return w.flush()
+	}
+	return nil
+}
+
+type lobChunk struct {
+	seq    int64
+	rowid  int64
+	offset int64
+	size   int64
+	hash   []byte
+}
+
+// LOBReader reads the content of a large object (see LOBStore.Open).
+// Each chunk is read entirely (and its hash checked) before being returned.
+type LOBReader struct {
+	s      *LOBStore
+	id     string
+	chunks []lobChunk
+	size   int64
+	offset int64
+	blob   *BlobReader
+	cur    int    // index of the chunk in buf
+	buf    []byte // content of the current chunk
+}
+
+// Open returns a reader for the object with the specified id.
+func (s *LOBStore) Open(id string) (*LOBReader, error) {
+	stmt, err := s.c.Prepare(Mprintf(`SELECT seq, rowid, length(data), hash FROM "%w" WHERE id = ? ORDER BY seq`, s.table))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Finalize()
+	r := &LOBReader{s: s, id: id, cur: -1}
+	err = stmt.Select(func(stmt *Stmt) error {
+		var chunk lobChunk
+		if err := stmt.Scan(&chunk.seq, &chunk.rowid, &chunk.size, &chunk.hash); err != nil {
+			return err
+		}
+		chunk.offset = r.size
+		r.size += chunk.size
+		r.chunks = append(r.chunks, chunk)
+		return nil
+	}, id)
+	if err != nil {
+		return nil, err
+	} else if len(r.chunks) == 0 {
+		return nil, ErrLOBNotFound
+	}
+	return r, nil
+}
+
+// Size returns the size of the object.
+func (r *LOBReader) Size() int64 {
+	return r.size
+}
+
+// Read reads data from the current offset.
+func (r *LOBReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	i := sort.Search(len(r.chunks), func(i int) bool {
+		return r.chunks[i].offset+r.chunks[i].size > r.offset
+	})
+	if err := r.load(i); err != nil {
+		return 0, err
+	}
+	n := copy(p, r.buf[r.offset-r.chunks[i].offset:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *LOBReader) load(i int) error {
+	if r.cur == i {
+		return nil
+	}
+	chunk := r.chunks[i]
+	var err error
+	if r.blob == nil {
+		r.blob, err = r.s.c.NewBlobReader("main", r.s.table, "data", chunk.rowid)
+	} else {
+		err = r.blob.Reopen(chunk.rowid)
+	}
+	if err != nil {
+		return err
+	}
+	if int64(cap(r.buf)) < chunk.size {
+		r.buf = make([]byte, chunk.size)
+	}
+	r.buf = r.buf[:chunk.size]
+	r.cur = -1
+	if _, err = io.ReadFull(r.blob, r.buf); err != nil {
+		return err
+	}
+	if hash := sha256.Sum256(r.buf); !bytes.Equal(hash[:], chunk.hash) {
+		return &LOBCorruptionError{r.id, chunk.seq}
+	}
+	r.cur = i
+	return nil
+}
+
+// Seek sets the offset for the next Read.
+func (r *LOBReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("bad seek whence: %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative seek offset: %d", offset)
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Close closes the BLOB handle.
+func (r *LOBReader) Close() error {
+	if r.blob == nil {
+		return nil
+	}
+	err := r.blob.Close()
+	r.blob = nil
+	return err
+}
@@ -0,0 +1,78 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	"bytes"
+	. "github.com/gwenn/gosqlite"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+func TestLOBStore(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	store, err := NewLOBStore(db, "lob", 1000)
+	checkNoError(t, err, "couldn't create store: %s")
+
+	data := make([]byte, 2500)
+	rand.New(rand.NewSource(1)).Read(data)
+	w, err := store.Create("obj")
+	checkNoError(t, err, "create error: %s")
+	for i := 0; i < len(data); i += 300 {
+		end := i + 300
+		if end > len(data) {
+			end = len(data)
+		}
+		_, err = w.Write(data[i:end])
+		checkNoError(t, err, "write error: %s")
+	}
+	checkNoError(t, w.Close(), "close error: %s")
+	var chunks int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM lob WHERE id = 'obj'", &chunks), "count error: %s")
+	assertEquals(t, "expected %d chunks but got %d", 3, chunks)
+	size, err := store.Size("obj")
+	checkNoError(t, err, "size error: %s")
+	assertEquals(t, "expected %d but got %d", int64(len(data)), size)
+
+	r, err := store.Open("obj")
+	checkNoError(t, err, "open error: %s")
+	read, err := ioutil.ReadAll(r)
+	checkNoError(t, err, "read error: %s")
+	assert(t, "same content expected", bytes.Equal(data, read))
+	_, err = r.Seek(-600, io.SeekEnd)
+	checkNoError(t, err, "seek error: %s")
+	tail := make([]byte, 600)
+	_, err = io.ReadFull(r, tail)
+	checkNoError(t, err, "read error: %s")
+	assert(t, "same tail expected", bytes.Equal(data[1900:], tail))
+	checkNoError(t, r.Close(), "close error: %s")
+
+	checkNoError(t, db.Exec("UPDATE lob SET data = zeroblob(1000) WHERE id = 'obj' AND seq = 1"), "update error: %s")
+	r, err = store.Open("obj")
+	checkNoError(t, err, "open error: %s")
+	defer r.Close()
+	_, err = ioutil.ReadAll(r)
+	if cerr, ok := err.(*LOBCorruptionError); ok {
+		assertEquals(t, "expected chunk %d but got %d", int64(1), cerr.Seq)
+	} else {
+		t.Errorf("expected LOBCorruptionError but got %v", err)
+	}
+
+	w, err = store.Create("empty")
+	checkNoError(t, err, "create error: %s")
+	checkNoError(t, w.Close(), "close error: %s")
+	size, err = store.Size("empty")
+	checkNoError(t, err, "size error: %s")
+	assertEquals(t, "expected %d but got %d", int64(0), size)
+
+	checkNoError(t, store.Delete("obj"), "delete error: %s")
+	_, err = store.Open("obj")
+	assertEquals(t, "expected %v but got %v", ErrLOBNotFound, err)
+	_, err = store.Size("obj")
+	assertEquals(t, "expected %v but got %v", ErrLOBNotFound, err)
+}
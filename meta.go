@@ -28,33 +28,54 @@ static int my_table_column_metadata(
 import "C"
 
 import (
+	"errors"
 	"fmt"
 	"unsafe"
 )
 
-// Databases returns one couple (name, file) for each database attached to the current database connection.
-// (See http://www.sqlite.org/pragma.html#pragma_database_list)
-func (c *Conn) Databases() (map[string]string, error) {
-	s, err := c.prepare("PRAGMA database_list")
-	if err != nil {
-		return nil, err
+// Database describes one database (schema) attached to a connection.
+type Database struct {
+	Name     string // "main", "temp" or the name specified in an ATTACH statement
+	File     string // "" for temporary or in-memory database
+	ReadOnly bool
+}
+
+// Databases returns one triple (name, file, readonly) for each database attached to the current database connection.
+// Databases are returned in their index order ("main" first, then "temp").
+// (See http://sqlite.org/c3ref/db_name.html, http://sqlite.org/c3ref/db_filename.html and http://sqlite.org/c3ref/db_readonly.html)
+func (c *Conn) Databases() ([]Database, error) {
+	if c.IsClosed() {
+		return nil, errors.New("nil sqlite database")
 	}
-	defer s.finalize()
-	var databases = make(map[string]string)
-	var name, file string
-	err = s.Select(func(s *Stmt) (err error) {
-		if err = s.Scan(nil, &name, &file); err != nil {
-			return
+	var databases []Database
+	for i := 0; ; i++ {
+		zName := C.sqlite3_db_name(c.db, C.int(i))
+		if zName == nil {
+			break
 		}
-		databases[name] = file
-		return
-	})
-	if err != nil {
-		return nil, err
+		databases = append(databases, Database{
+			Name:     C.GoString(zName),
+			File:     C.GoString(C.sqlite3_db_filename(c.db, zName)),
+			ReadOnly: C.sqlite3_db_readonly(c.db, zName) == 1,
+		})
 	}
 	return databases, nil
 }
 
+// Attach adds another database file to the current database connection.
+// The filename may be an URI ("file:...") if URI handling is enabled (OpenUri or ConfigUri).
+// Both the filename and the database name are quoted.
+// (See http://sqlite.org/lang_attach.html)
+func (c *Conn) Attach(filename, dbName string) error {
+	return c.exec(Mprintf2("ATTACH DATABASE %Q AS %Q", filename, dbName))
+}
+
+// Detach detaches a database previously attached with Conn.Attach.
+// (See http://sqlite.org/lang_detach.html)
+func (c *Conn) Detach(dbName string) error {
+	return c.exec(Mprintf("DETACH DATABASE %Q", dbName))
+}
+
 // Tables returns tables (no view) from 'sqlite_master' and filters system tables out.
 // TODO create Views method to return views...
 func (c *Conn) Tables(dbName string) ([]string, error) {
@@ -81,6 +102,45 @@ func (c *Conn) Tables(dbName string) ([]string, error) {
 	return tables, nil
 }
 
+// TableInfo is the description of one table
+// See Conn.TableInfo
+type TableInfo struct {
+	Schema       string
+	Name         string
+	Type         string // "table", "view", "shadow" (virtual table storage) or "virtual"
+	NCol         int
+	WithoutRowid bool
+	Strict       bool
+}
+
+// TableInfo returns the description of the named table (nil when there is no such table).
+// (See http://www.sqlite.org/pragma.html#pragma_table_list)
+func (c *Conn) TableInfo(dbName, table string) (*TableInfo, error) {
+	var pragma string
+	if len(dbName) == 0 {
+		pragma = Mprintf("PRAGMA table_list(%Q)", table)
+	} else {
+		pragma = Mprintf2("PRAGMA %Q.table_list(%Q)", dbName, table)
+	}
+	s, err := c.prepare(pragma)
+	if err != nil {
+		return nil, err
+	}
+	defer s.finalize()
+	var info *TableInfo
+	err = s.Select(func(s *Stmt) (err error) {
+		if info != nil { // temp table shadowing a main table
+			return
+		}
+		info = &TableInfo{}
+		return s.Scan(&info.Schema, &info.Name, &info.Type, &info.NCol, &info.WithoutRowid, &info.Strict)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
 // Column is the description of one table's column
 // See Conn.Columns/IndexColumns
 type Column struct {
@@ -92,9 +152,27 @@ type Column struct {
 	Pk        int
 	Autoinc   bool
 	CollSeq   string
+	Hidden    ColumnHidden // see Conn.ExtendedColumns
+}
+
+// ColumnHidden tells if a column is hidden or generated
+// (See http://www.sqlite.org/pragma.html#pragma_table_xinfo)
+type ColumnHidden int
+
+// Hidden column kinds
+const (
+	ColumnVisible    ColumnHidden = iota
+	ColumnHiddenVtab              // hidden column of a virtual table
+	ColumnVirtual                 // generated column computed when read (GENERATED ALWAYS AS ... VIRTUAL)
+	ColumnStored                  // generated column computed when written (GENERATED ALWAYS AS ... STORED)
+)
+
+// Generated tells if the column is a generated column (whose value cannot be inserted or updated).
+func (c Column) Generated() bool {
+	return c.Hidden == ColumnVirtual || c.Hidden == ColumnStored
 }
 
-// Columns returns a description for each column in the named table.
+// Columns returns a description for each column in the named table (generated columns excluded).
 // Column.Autoinc and Column.CollSeq are left unspecified.
 // (See http://www.sqlite.org/pragma.html#pragma_table_info)
 func (c *Conn) Columns(dbName, table string) ([]Column, error) {
@@ -124,8 +202,40 @@ func (c *Conn) Columns(dbName, table string) ([]Column, error) {
 	return columns, nil
 }
 
+// ExtendedColumns is like Columns but includes the hidden columns of virtual tables
+// and the generated columns (see Column.Hidden).
+// (See http://www.sqlite.org/pragma.html#pragma_table_xinfo)
+func (c *Conn) ExtendedColumns(dbName, table string) ([]Column, error) {
+	var pragma string
+	if len(dbName) == 0 {
+		pragma = Mprintf("PRAGMA table_xinfo(%Q)", table)
+	} else {
+		pragma = Mprintf2("PRAGMA %Q.table_xinfo(%Q)", dbName, table)
+	}
+	s, err := c.prepare(pragma)
+	if err != nil {
+		return nil, err
+	}
+	defer s.finalize()
+	var columns = make([]Column, 0, 20)
+	err = s.Select(func(s *Stmt) (err error) {
+		c := Column{}
+		var hidden int
+		if err = s.Scan(&c.Cid, &c.Name, &c.DataType, &c.NotNull, &c.DfltValue, &c.Pk, &hidden); err != nil {
+			return
+		}
+		c.Hidden = ColumnHidden(hidden)
+		columns = append(columns, c)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
 // Column extracts metadata about a column of a table.
-// Column.Cid and Column.DfltValue are left unspecified.
+// Column.Cid, Column.DfltValue and Column.Hidden are left unspecified.
 // (See http://sqlite.org/c3ref/table_column_metadata.html)
 func (c *Conn) Column(dbName, tableName, columnName string) (*Column, error) {
 	var zDbName *C.char
@@ -146,7 +256,7 @@ func (c *Conn) Column(dbName, tableName, columnName string) (*Column, error) {
 	}
 	// TODO How to avoid copy?
 	return &Column{-1, columnName, C.GoString(zDataType), notNull == 1, "", int(primaryKey),
-		autoinc == 1, C.GoString(zCollSeq)}, nil
+		autoinc == 1, C.GoString(zCollSeq), ColumnVisible}, nil
 }
 
 // ColumnDatabaseName returns the database
@@ -154,6 +264,8 @@ func (c *Conn) Column(dbName, tableName, columnName string) (*Column, error) {
 // The left-most column is column 0.
 // (See http://www.sqlite.org/c3ref/column_database_name.html)
 func (s *Stmt) ColumnDatabaseName(index int) string {
+	s.c.enter()
+	defer s.c.leave()
 	return C.GoString(C.sqlite3_column_database_name(s.stmt, C.int(index)))
 }
 
@@ -162,6 +274,8 @@ func (s *Stmt) ColumnDatabaseName(index int) string {
 // The left-most column is column 0.
 // (See http://www.sqlite.org/c3ref/column_database_name.html)
 func (s *Stmt) ColumnTableName(index int) string {
+	s.c.enter()
+	defer s.c.leave()
 	return C.GoString(C.sqlite3_column_table_name(s.stmt, C.int(index)))
 }
 
@@ -170,6 +284,8 @@ func (s *Stmt) ColumnTableName(index int) string {
 // The left-most column is column 0.
 // (See http://www.sqlite.org/c3ref/column_database_name.html)
 func (s *Stmt) ColumnOriginName(index int) string {
+	s.c.enter()
+	defer s.c.leave()
 	return C.GoString(C.sqlite3_column_origin_name(s.stmt, C.int(index)))
 }
 
@@ -178,6 +294,8 @@ func (s *Stmt) ColumnOriginName(index int) string {
 // The left-most column is column 0.
 // (See http://www.sqlite.org/c3ref/column_decltype.html)
 func (s *Stmt) ColumnDeclaredType(index int) string {
+	s.c.enter()
+	defer s.c.leave()
 	return C.GoString(C.sqlite3_column_decltype(s.stmt, C.int(index)))
 }
 
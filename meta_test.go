@@ -6,6 +6,10 @@ package sqlite_test
 
 import (
 	. "github.com/gwenn/gosqlite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -21,14 +25,38 @@ func TestDatabases(t *testing.T) {
 
 	databases, err := db.Databases()
 	checkNoError(t, err, "error looking for databases: %s")
-	if len(databases) != 1 {
-		t.Errorf("Expected one database but got %d\n", len(databases))
+	if len(databases) != 2 {
+		t.Errorf("Expected two databases but got %d\n", len(databases))
 	}
-	if _, ok := databases["main"]; !ok {
+	if databases[0].Name != "main" {
 		t.Errorf("Expected 'main' database\n")
 	}
 }
 
+func TestAttach(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+
+	f, err := ioutil.TempFile("", "gosqlite-attach")
+	checkNoError(t, err, "couldn't create temp file: %s")
+	checkNoError(t, f.Close(), "couldn't close temp file: %s")
+	defer os.Remove(f.Name())
+
+	checkNoError(t, db.Attach(f.Name(), "other's"), "couldn't attach database: %s")
+	databases, err := db.Databases()
+	checkNoError(t, err, "error looking for databases: %s")
+	assertEquals(t, "expected %d databases but got %d", 3, len(databases))
+	other := databases[2]
+	assertEquals(t, "expected %q but got %q", "other's", other.Name)
+	assert(t, "attached file expected", strings.HasSuffix(other.File, filepath.Base(f.Name())))
+	assert(t, "read-write database expected", !other.ReadOnly)
+	checkNoError(t, db.Exec(`CREATE TABLE "other's".test (data TEXT)`), "couldn't create table: %s")
+	checkNoError(t, db.Detach("other's"), "couldn't detach database: %s")
+	databases, err = db.Databases()
+	checkNoError(t, err, "error looking for databases: %s")
+	assertEquals(t, "expected %d databases but got %d", 2, len(databases))
+}
+
 func TestTables(t *testing.T) {
 	db := open(t)
 	defer checkClose(db, t)
@@ -43,6 +71,27 @@ func TestTables(t *testing.T) {
 	assertEquals(t, "wrong table name: %q <> %q", "test", tables[0])
 }
 
+func TestTableInfo(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+
+	info, err := db.TableInfo("", "test")
+	checkNoError(t, err, "error looking for table: %s")
+	assertEquals(t, "expected %v but got %v", TableInfo{Schema: "main", Name: "test", Type: "table", NCol: 4}, *info)
+	info, err = db.TableInfo("main", "unknown")
+	checkNoError(t, err, "error looking for table: %s")
+	assert(t, "no table expected", info == nil)
+
+	db.SetStrictTables(true)
+	assert(t, "strict tables expected", db.StrictTables())
+	_, err = NewAudit(db, "audit")
+	checkNoError(t, err, "couldn't create audit: %s")
+	info, err = db.TableInfo("main", "audit")
+	checkNoError(t, err, "error looking for table: %s")
+	assert(t, "STRICT table expected", info.Strict && !info.WithoutRowid)
+}
+
 func TestColumns(t *testing.T) {
 	db := open(t)
 	defer checkClose(db, t)
@@ -60,6 +109,23 @@ func TestColumns(t *testing.T) {
 	checkNoError(t, err, "error listing columns: %s")
 }
 
+func TestExtendedColumns(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE gen (a INTEGER, b INTEGER AS (a * 2), c INTEGER AS (a + 1) STORED)"), "create error: %s")
+
+	columns, err := db.Columns("", "gen")
+	checkNoError(t, err, "error listing columns: %s")
+	assertEquals(t, "expected %d columns but got %d", 1, len(columns))
+	columns, err = db.ExtendedColumns("main", "gen")
+	checkNoError(t, err, "error listing columns: %s")
+	assertEquals(t, "expected %d columns but got %d", 3, len(columns))
+	assert(t, "regular column expected", !columns[0].Generated())
+	assertEquals(t, "expected %v but got %v", ColumnVirtual, columns[1].Hidden)
+	assertEquals(t, "expected %v but got %v", ColumnStored, columns[2].Hidden)
+	assert(t, "generated column expected", columns[2].Generated())
+}
+
 func TestColumn(t *testing.T) {
 	db := open(t)
 	defer checkClose(db, t)
@@ -118,6 +184,20 @@ func TestIndexes(t *testing.T) {
 	assertEquals(t, "Wrong column name: %q <> %q", "a_string", column.Name)
 }
 
+func TestExpressionColumnMetadata(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	s, err := db.Prepare("SELECT 1 + 1 AS sum, name FROM sqlite_master")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+
+	assertEquals(t, "expected %q but got %q", "", s.ColumnDatabaseName(0))
+	assertEquals(t, "expected %q but got %q", "", s.ColumnTableName(0))
+	assertEquals(t, "expected %q but got %q", "", s.ColumnOriginName(0))
+	assertEquals(t, "expected %q but got %q", "", s.ColumnDeclaredType(0))
+	assertEquals(t, "expected %q but got %q", "sqlite_master", s.ColumnTableName(1))
+}
+
 func TestColumnMetadata(t *testing.T) {
 	db := open(t)
 	defer checkClose(db, t)
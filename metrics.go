@@ -0,0 +1,322 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"expvar"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricKind tells how a Metric value evolves.
+type MetricKind int
+
+const (
+	Gauge   MetricKind = iota // value may go up and down
+	Counter                   // value only increases (until reset)
+)
+
+// MetricDesc describes the metrics with the same name produced by a Collector (see Collector.Describe).
+// Labels are the names of the labels of each metric (sorted).
+type MetricDesc struct {
+	Name   string
+	Help   string
+	Kind   MetricKind
+	Labels []string
+}
+
+func (d MetricDesc) metric(labels map[string]string, value float64) Metric {
+	return Metric{d.Name, d.Help, d.Kind, labels, value}
+}
+
+// Metric is one sample produced by a Collector.
+type Metric struct {
+	Name   string
+	Help   string
+	Kind   MetricKind
+	Labels map[string]string
+	Value  float64
+}
+
+// String returns the metric in the Prometheus text exposition style: name{label="value"}.
+func (m Metric) String() string {
+	if len(m.Labels) == 0 {
+		return m.Name
+	}
+	keys := make([]string, 0, len(m.Labels))
+	for k := range m.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%q", k, m.Labels[k])
+	}
+	return m.Name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// LabelValues returns the values of the specified labels (the MetricDesc.Labels), in the same order.
+func (m Metric) LabelValues(names []string) []string {
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = m.Labels[name]
+	}
+	return values
+}
+
+var memoryMetricDescs = []MetricDesc{
+	{"sqlite_memory_used_bytes", "Memory currently outstanding (malloced but not freed).", Gauge, nil},
+	{"sqlite_memory_highwater_bytes", "Maximum value of memory used since the high-water mark was last reset.", Gauge, nil},
+}
+
+type dbStatusMetric struct {
+	op DbStatus
+	MetricDesc
+}
+
+var connLabels = []string{"conn"}
+
+var dbStatusMetrics = []dbStatusMetric{
+	{DbStatusLookasideUsed, MetricDesc{"sqlite_lookaside_used", "Number of lookaside memory slots currently checked out.", Gauge, connLabels}},
+	{DbStatusCacheUsed, MetricDesc{"sqlite_cache_used_bytes", "Heap memory used by all pager caches of the connection.", Gauge, connLabels}},
+	{DbStatusSchemaUsed, MetricDesc{"sqlite_schema_used_bytes", "Heap memory used to store the schemas of the connection.", Gauge, connLabels}},
+	{DbStatusStmtUsed, MetricDesc{"sqlite_stmt_used_bytes", "Heap memory used by the prepared statements of the connection.", Gauge, connLabels}},
+	{DbStatusCacheHit, MetricDesc{"sqlite_page_cache_hits_total", "Number of pager cache hits.", Counter, connLabels}},
+	{DbStatusCacheMiss, MetricDesc{"sqlite_page_cache_misses_total", "Number of pager cache misses.", Counter, connLabels}},
+	{DbStatusCacheWrite, MetricDesc{"sqlite_page_cache_writes_total", "Number of dirty cache entries written to disk.", Counter, connLabels}},
+}
+
+// connMetricDescs are in the order of the values sampled by Conn.sample.
+var connMetricDescs = []MetricDesc{
+	{"sqlite_stmt_cache_hits_total", "Number of prepared statements reused from the cache.", Counter, connLabels},
+	{"sqlite_stmt_cache_misses_total", "Number of prepared statements not found in the cache.", Counter, connLabels},
+	{"sqlite_stmt_cache_hit_ratio", "Ratio of cache hits over cache lookups.", Gauge, connLabels},
+	{"sqlite_busy_total", "Number of SQLITE_BUSY/SQLITE_LOCKED errors.", Counter, connLabels},
+	{"sqlite_busy_handler_calls_total", "Number of busy handler invocations.", Counter, connLabels},
+	{"sqlite_busy_blocked_seconds_total", "Time spent waiting in the busy handler.", Counter, connLabels},
+	{"sqlite_retries_total", "Number of statements retried after a transient error.", Counter, connLabels},
+}
+
+// Collector samples SQLite statistics:
+// MemoryUsed/MemoryHighwater, per-connection Conn.Status counters,
+// prepared statements cache hits/misses (Conn.CacheStats) busy counts (Conn.BusyStats), retries (Conn.RetryCount)
+// and the file operations of the registered tracing VFS (see RegisterVFS).
+// Samples can be taken on demand (Sample) or periodically (Start/Stop)
+// and are published via expvar (Publish) or pulled with Describe and Collect.
+//
+// Describe and Collect have the shape of the prometheus.Collector methods
+// but this package does not depend on Prometheus, so an adapter has to convert descriptions and samples:
+//
+//	type promCollector struct{ c *sqlite.Collector }
+//
+//	func (p promCollector) Describe(ch chan<- *prometheus.Desc) {
+//		for _, d := range p.c.Descs() {
+//			ch <- prometheus.NewDesc(d.Name, d.Help, d.Labels, nil)
+//		}
+//	}
+//
+//	func (p promCollector) Collect(ch chan<- prometheus.Metric) {
+//		descs := make(map[string]sqlite.MetricDesc)
+//		for _, d := range p.c.Descs() {
+//			descs[d.Name] = d
+//		}
+//		for _, m := range p.c.Metrics() {
+//			d := descs[m.Name]
+//			typ := prometheus.GaugeValue
+//			if d.Kind == sqlite.Counter {
+//				typ = prometheus.CounterValue
+//			}
+//			ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(d.Name, d.Help, d.Labels, nil), typ, m.Value, m.LabelValues(d.Labels)...)
+//		}
+//	}
+//
+// Sampling only reads counters but it happens on the Collector goroutine,
+// so registered connections should be opened in serialized mode (OpenFullMutex).
+// A registered connection can be closed while it is sampled (closed connections are then skipped).
+type Collector struct {
+	mu      sync.Mutex
+	conns   map[string]*Conn
+	vfs     map[string]*TracingVFS
+	metrics []Metric // last sample
+	done    chan bool
+}
+
+// NewCollector creates a Collector with no registered connection.
+func NewCollector() *Collector {
+	return &Collector{conns: make(map[string]*Conn), vfs: make(map[string]*TracingVFS)}
+}
+
+// Register adds a connection to be sampled.
+// name is used as the value of the "conn" label.
+func (m *Collector) Register(name string, c *Conn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conns[name] = c
+}
+
+// Unregister removes a connection previously registered with the specified name.
+func (m *Collector) Unregister(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.conns, name)
+}
+
+// RegisterVFS adds a tracing VFS to be sampled.
+// Its name is used as the value of the "vfs" label.
+func (m *Collector) RegisterVFS(v *TracingVFS) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.vfs[v.Name()] = v
+}
+
+// UnregisterVFS removes a tracing VFS previously registered.
+func (m *Collector) UnregisterVFS(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.vfs, name)
+}
+
+// Sample collects the current value of all metrics.
+// Closed connections are skipped.
+func (m *Collector) Sample() []Metric {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	metrics := make([]Metric, 0, 2+len(m.conns)*(len(dbStatusMetrics)+4))
+	metrics = append(metrics, memoryMetrics()...)
+	for name, c := range m.conns {
+		metrics = c.sample(name, metrics)
+	}
+	for _, v := range m.vfs {
+		metrics = append(metrics, v.metrics()...)
+	}
+	m.metrics = metrics
+	return metrics
+}
+
+// sample appends the metrics of c unless it is closed
+// (the connection cannot be closed concurrently while it is sampled).
+func (c *Conn) sample(name string, metrics []Metric) []Metric {
+	c.closing.RLock()
+	defer c.closing.RUnlock()
+	if c.IsClosed() {
+		return metrics
+	}
+	labels := map[string]string{"conn": name}
+	for _, dm := range dbStatusMetrics {
+		current, _, err := c.Status(dm.op, false)
+		if err != nil {
+			continue
+		}
+		metrics = append(metrics, dm.metric(labels, float64(current)))
+	}
+	hits, misses := c.CacheStats()
+	busy := c.BusyStats()
+	var ratio float64
+	if hits+misses > 0 {
+		ratio = float64(hits) / float64(hits+misses)
+	}
+	values := []float64{float64(hits), float64(misses), ratio,
+		float64(busy.Errors), float64(busy.Invocations), busy.Blocked.Seconds(), float64(c.RetryCount())}
+	for i, d := range connMetricDescs {
+		metrics = append(metrics, d.metric(labels, values[i]))
+	}
+	return metrics
+}
+
+func memoryMetrics() []Metric {
+	return []Metric{
+		memoryMetricDescs[0].metric(nil, float64(MemoryUsed())),
+		memoryMetricDescs[1].metric(nil, float64(MemoryHighwater(false))),
+	}
+}
+
+// Metrics returns the last sample (or takes one if there is none).
+func (m *Collector) Metrics() []Metric {
+	m.mu.Lock()
+	metrics := m.metrics
+	m.mu.Unlock()
+	if metrics == nil {
+		return m.Sample()
+	}
+	return metrics
+}
+
+// Start samples metrics every interval until Stop is called.
+func (m *Collector) Start(interval time.Duration) {
+	m.mu.Lock()
+	if m.done != nil {
+		m.mu.Unlock()
+		return
+	}
+	done := make(chan bool)
+	m.done = done
+	m.mu.Unlock()
+	m.Sample()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.Sample()
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic sampling started by Start.
+func (m *Collector) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.done != nil {
+		close(m.done)
+		m.done = nil
+	}
+}
+
+// Descs returns the description of all the metrics which may be sampled,
+// whether or not connections or VFS are registered.
+func (m *Collector) Descs() []MetricDesc {
+	descs := make([]MetricDesc, 0, len(memoryMetricDescs)+len(dbStatusMetrics)+len(connMetricDescs)+len(vfsMetricDescs))
+	descs = append(descs, memoryMetricDescs...)
+	for _, dm := range dbStatusMetrics {
+		descs = append(descs, dm.MetricDesc)
+	}
+	descs = append(descs, connMetricDescs...)
+	return append(descs, vfsMetricDescs...)
+}
+
+// Describe sends the description of all the metrics which may be sampled to ch (see Descs).
+func (m *Collector) Describe(ch chan<- MetricDesc) {
+	for _, d := range m.Descs() {
+		ch <- d
+	}
+}
+
+// Collect sends the last sample to ch.
+func (m *Collector) Collect(ch chan<- Metric) {
+	for _, metric := range m.Metrics() {
+		ch <- metric
+	}
+}
+
+// Publish exports the last sample as an expvar variable with the specified name.
+// Each metric is keyed by its String() form.
+// Like expvar.Publish, it panics if the name is already registered.
+func (m *Collector) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		metrics := m.Metrics()
+		values := make(map[string]float64, len(metrics))
+		for _, metric := range metrics {
+			values[metric.String()] = metric.Value
+		}
+		return values
+	}))
+}
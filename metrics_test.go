@@ -0,0 +1,128 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"testing"
+	"time"
+)
+
+func findMetric(metrics []Metric, name string) (Metric, bool) {
+	for _, m := range metrics {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Metric{}, false
+}
+
+func TestCollector(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	for i := 0; i < 2; i++ {
+		s, err := db.Prepare("SELECT 1")
+		checkNoError(t, err, "couldn't prepare stmt: %#v")
+		checkFinalize(s, t)
+	}
+
+	c := NewCollector()
+	c.Register("test", db)
+	metrics := c.Sample()
+	m, ok := findMetric(metrics, "sqlite_memory_used_bytes")
+	assert(t, "memory used metric expected", ok && m.Value >= 0)
+	m, ok = findMetric(metrics, "sqlite_stmt_cache_hits_total")
+	assert(t, "cache hits metric expected", ok)
+	assertEquals(t, "expected %v cache hits but got %v", float64(1), m.Value)
+	assertEquals(t, "expected %q but got %q", `sqlite_stmt_cache_hits_total{conn="test"}`, m.String())
+	_, ok = findMetric(metrics, "sqlite_cache_used_bytes")
+	assert(t, "cache used metric expected", ok)
+
+	ch := make(chan Metric, len(metrics))
+	c.Collect(ch)
+	assertEquals(t, "expected %d collected metrics but got %d", len(metrics), len(ch))
+
+	c.Unregister("test")
+	_, ok = findMetric(c.Sample(), "sqlite_busy_total")
+	assert(t, "no connection metric expected", !ok)
+}
+
+func TestCollectorDescribe(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	c := NewCollector()
+	ch := make(chan MetricDesc, len(c.Descs()))
+	c.Describe(ch) // nothing registered
+	close(ch)
+	descs := make(map[string]MetricDesc)
+	for d := range ch {
+		descs[d.Name] = d
+	}
+	assertEquals(t, "expected %d distinct descriptions but got %d", len(c.Descs()), len(descs))
+
+	c.Register("test", db)
+	for _, m := range c.Sample() {
+		d, ok := descs[m.Name]
+		assert(t, "description expected for "+m.Name, ok)
+		assertEquals(t, "expected %q help but got %q", d.Help, m.Help)
+		assertEquals(t, "expected %v kind but got %v", d.Kind, m.Kind)
+		assertEquals(t, "expected %d labels but got %d", len(d.Labels), len(m.Labels))
+		for i, v := range m.LabelValues(d.Labels) {
+			assertEquals(t, "expected %q but got %q", m.Labels[d.Labels[i]], v)
+			assert(t, "label value expected for "+d.Labels[i], len(v) > 0)
+		}
+	}
+}
+
+func TestCollectorStartStop(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	c := NewCollector()
+	c.Register("test", db)
+	c.Start(time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	c.Stop()
+	assert(t, "sample expected", len(c.Metrics()) > 0)
+}
+
+func TestCollectorClose(t *testing.T) {
+	db, err := Open(":memory:", OpenReadWrite, OpenCreate, OpenFullMutex)
+	checkNoError(t, err, "couldn't open database file: %s")
+	c := NewCollector()
+	c.Register("test", db)
+	c.Start(time.Microsecond)
+	time.Sleep(time.Millisecond)
+	checkNoError(t, db.Close(), "error while closing connection: %s")
+	time.Sleep(time.Millisecond)
+	c.Stop()
+	_, ok := findMetric(c.Sample(), "sqlite_busy_total")
+	assert(t, "no closed connection metric expected", !ok)
+}
+
+func TestMetricsModule(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	collector := NewCollector()
+	collector.Register("test", db)
+	checkNoError(t, db.CreateModule("metrics", NewMetricsModule(collector)), "couldn't create module: %s")
+	checkNoError(t, db.Exec("CREATE VIRTUAL TABLE temp.go_metrics USING metrics(go)"), "couldn't create virtual table: %s")
+	checkNoError(t, db.Exec("CREATE VIRTUAL TABLE temp.db_metrics USING metrics(sqlite)"), "couldn't create virtual table: %s")
+	err := db.Exec("CREATE VIRTUAL TABLE temp.unknown_metrics USING metrics(unknown)")
+	assert(t, "unknown source error expected", err != nil)
+
+	var goroutines float64
+	checkNoError(t, db.OneValue("SELECT value FROM go_metrics WHERE name = 'go_goroutines'", &goroutines), "select error: %s")
+	assert(t, "goroutines expected", goroutines > 0)
+	var kind string
+	checkNoError(t, db.OneValue("SELECT kind FROM go_metrics WHERE name = '/gc/cycles/total:gc-cycles'", &kind), "select error: %s")
+	assertEquals(t, "expected %q but got %q", "counter", kind)
+
+	var labels string
+	checkNoError(t, db.OneValue("SELECT labels FROM db_metrics WHERE name = 'sqlite_busy_total'", &labels), "select error: %s")
+	assertEquals(t, "expected %q but got %q", `conn="test"`, labels)
+	var n int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM db_metrics WHERE name LIKE 'sqlite_memory%'", &n), "select error: %s")
+	assertEquals(t, "expected %d but got %d", 2, n)
+}
@@ -0,0 +1,182 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MetricsModule is a virtual table module exposing the metrics of the running process:
+//
+//	db.CreateModule("metrics", NewMetricsModule(collector))
+//	db.Exec("CREATE VIRTUAL TABLE temp.go_metrics USING metrics(go)")
+//	db.Exec("CREATE VIRTUAL TABLE temp.sqlite_metrics USING metrics(sqlite)")
+//	SELECT name, value FROM go_metrics WHERE name LIKE '/gc/%'
+//
+// The "go" tables expose the Go runtime metrics (runtime/metrics),
+// the GC statistics and the number of goroutines.
+// The "sqlite" tables expose the SQLite memory statistics and the metrics sampled by the collector (if any).
+// Each table has the columns: name, labels, kind ('gauge' or 'counter'), value and help.
+// A snapshot is taken each time a table is scanned.
+type MetricsModule struct {
+	collector *Collector
+}
+
+// NewMetricsModule creates a metrics module (collector may be nil).
+func NewMetricsModule(collector *Collector) *MetricsModule {
+	return &MetricsModule{collector}
+}
+
+// Create declares the virtual table.
+func (m *MetricsModule) Create(c *Conn, args []string) (VTab, error) {
+	source := "go"
+	if len(args) > 3 {
+		source = strings.ToLower(strings.Trim(args[3], `'" `))
+	}
+	if source != "go" && source != "sqlite" {
+		return nil, fmt.Errorf("unknown metrics source: %q (expected go or sqlite)", source)
+	}
+	if err := c.DeclareVTab("CREATE TABLE x(name TEXT, labels TEXT, kind TEXT, value REAL, help TEXT)"); err != nil {
+		return nil, err
+	}
+	return &metricsVTab{m, source}, nil
+}
+
+// Connect is like Create.
+func (m *MetricsModule) Connect(c *Conn, args []string) (VTab, error) {
+	return m.Create(c, args)
+}
+
+// Destroy does nothing.
+func (m *MetricsModule) Destroy() {
+}
+
+type metricsVTab struct {
+	m      *MetricsModule
+	source string
+}
+
+func (v *metricsVTab) BestIndex() error {
+	return nil
+}
+func (v *metricsVTab) Disconnect() error {
+	return nil
+}
+func (v *metricsVTab) Destroy() error {
+	return nil
+}
+func (v *metricsVTab) Open() (VTabCursor, error) {
+	return &metricsVTabCursor{vTab: v}, nil
+}
+
+type metricsVTabCursor struct {
+	vTab    *metricsVTab
+	metrics []Metric // snapshot
+	index   int
+}
+
+func (vc *metricsVTabCursor) Close() error {
+	return nil
+}
+func (vc *metricsVTabCursor) Filter() error {
+	if vc.vTab.source == "go" {
+		vc.metrics = goMetrics()
+	} else {
+		vc.metrics = sqliteMetrics(vc.vTab.m.collector)
+	}
+	vc.index = 0
+	return nil
+}
+func (vc *metricsVTabCursor) Next() error {
+	vc.index++
+	return nil
+}
+func (vc *metricsVTabCursor) Eof() bool {
+	return vc.index >= len(vc.metrics)
+}
+func (vc *metricsVTabCursor) Column(c *Context, col int) error {
+	m := vc.metrics[vc.index]
+	switch col {
+	case 0:
+		c.ResultText(m.Name)
+	case 1:
+		if len(m.Labels) == 0 {
+			c.ResultNull()
+		} else {
+			s := m.String()
+			c.ResultText(s[len(m.Name)+1 : len(s)-1])
+		}
+	case 2:
+		if m.Kind == Counter {
+			c.ResultText("counter")
+		} else {
+			c.ResultText("gauge")
+		}
+	case 3:
+		c.ResultDouble(m.Value)
+	case 4:
+		c.ResultText(m.Help)
+	default:
+		return fmt.Errorf("column index out of bounds: %d", col)
+	}
+	return nil
+}
+func (vc *metricsVTabCursor) Rowid() (int64, error) {
+	return int64(vc.index), nil
+}
+
+// goMetrics samples the scalar Go runtime metrics (histograms are skipped),
+// the GC statistics and the number of goroutines.
+func goMetrics() []Metric {
+	descs := metrics.All()
+	samples := make([]metrics.Sample, len(descs))
+	for i, desc := range descs {
+		samples[i].Name = desc.Name
+	}
+	metrics.Read(samples)
+	result := make([]Metric, 0, len(samples)+4)
+	for i, sample := range samples {
+		m := Metric{Name: sample.Name, Help: descs[i].Description}
+		if descs[i].Cumulative {
+			m.Kind = Counter
+		}
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			m.Value = float64(sample.Value.Uint64())
+		case metrics.KindFloat64:
+			m.Value = sample.Value.Float64()
+		default:
+			continue
+		}
+		result = append(result, m)
+	}
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+	var lastGC float64
+	if !gc.LastGC.IsZero() {
+		lastGC = float64(gc.LastGC.UnixNano()) / float64(time.Second)
+	}
+	result = append(result,
+		Metric{"go_goroutines", "Number of goroutines that currently exist.", Gauge, nil, float64(runtime.NumGoroutine())},
+		Metric{"go_gc_count_total", "Number of completed garbage collections.", Counter, nil, float64(gc.NumGC)},
+		Metric{"go_gc_pause_seconds_total", "Total pause time of the garbage collections.", Counter, nil, gc.PauseTotal.Seconds()},
+		Metric{"go_gc_last_time_seconds", "Time of the last garbage collection (unix epoch).", Gauge, nil, lastGC})
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// sqliteMetrics samples the package-level SQLite statistics and the metrics of the collector (if any).
+func sqliteMetrics(collector *Collector) []Metric {
+	if collector != nil {
+		return collector.Sample()
+	}
+	return memoryMetrics()
+}
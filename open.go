@@ -0,0 +1,124 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// OpenOptions describes a database connection to be opened.
+// The path and the parameters are rendered as a "file:" URI (see Uri)
+// so that manual URI construction/escaping is not needed.
+//
+//	db, err := (&OpenOptions{Path: "/data/app.db", Mode: "ro", Immutable: true}).Open()
+//
+// (See http://sqlite.org/uri.html)
+type OpenOptions struct {
+	Path  string     // database file path, ":memory:" for memory db, "" for temp file db (see LongPath on Windows)
+	Flags []OpenFlag // default is OpenReadWrite, OpenCreate and OpenFullMutex (OpenUri is always added)
+	Vfs   string     // optional VFS name
+
+	Mode      string            // "ro", "rw", "rwc" or "memory"
+	Cache     string            // "shared" or "private"
+	Immutable bool              // the database file cannot be changed (no locking, no change detection)
+	NoPsow    bool              // psow=0: disables the powersafe overwrite property
+	Params    map[string]string // other URI parameters
+}
+
+// Uri returns the "file:" URI matching the path and the parameters.
+func (o *OpenOptions) Uri() string {
+	params := make(map[string]string, len(o.Params)+4)
+	for k, v := range o.Params {
+		params[k] = v
+	}
+	if len(o.Mode) > 0 {
+		params["mode"] = o.Mode
+	}
+	if len(o.Cache) > 0 {
+		params["cache"] = o.Cache
+	}
+	if o.Immutable {
+		params["immutable"] = "1"
+	}
+	if o.NoPsow {
+		params["psow"] = "0"
+	}
+	path := o.Path
+	if !strings.HasPrefix(path, verbatimPrefix) { // \\?\ paths are passed as is (with backslashes) to the VFS
+		path = filepath.ToSlash(path)
+		if vol := filepath.VolumeName(o.Path); len(vol) > 0 && !strings.HasPrefix(path, "/") { // C:/... on windows
+			path = "/" + path
+		}
+		if strings.HasPrefix(path, "//") { // would be interpreted as an authority
+			path = "//" + path
+		}
+	}
+	uri := "file:" + uriEscape(path, "")
+	if len(params) > 0 {
+		keys := make([]string, 0, len(params))
+		for k := range params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			pairs[i] = uriEscape(k, "&=") + "=" + uriEscape(params[k], "&=")
+		}
+		uri += "?" + strings.Join(pairs, "&")
+	}
+	return uri
+}
+
+// Open opens a new database connection with the specified options.
+// (See OpenVfs)
+func (o *OpenOptions) Open() (*Conn, error) {
+	flags := o.Flags
+	if len(flags) == 0 {
+		flags = []OpenFlag{OpenReadWrite, OpenCreate, OpenFullMutex}
+	}
+	flags = append(flags[:len(flags):len(flags)], OpenUri)
+	return OpenVfs(o.Uri(), o.Vfs, flags...)
+}
+
+// uriEscape percent-encodes the characters that SQLite would otherwise interpret:
+// '%', '?', '#', control characters, spaces and the extra specified ones.
+// SQLite only decodes %HH escapes ('+' is not a space).
+func uriEscape(s, extra string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch <= ' ' || ch >= 0x7f || ch == '%' || ch == '?' || ch == '#' || strings.IndexByte(extra, ch) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		} else {
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+const verbatimPrefix = `\\?\`
+
+// LongPath returns the extended-length (\\?\C:\... or \\?\UNC\server\share\...) form of the absolute path
+// on Windows, so that paths longer than MAX_PATH (260 characters) can be opened.
+// The path is returned unchanged on other platforms, for ":memory:", temporary databases ("") and
+// paths already in this form.
+func LongPath(path string) string {
+	if runtime.GOOS != "windows" || len(path) == 0 || path == ":memory:" || strings.HasPrefix(path, verbatimPrefix) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	abs = strings.Replace(abs, "/", `\`, -1)
+	if strings.HasPrefix(abs, `\\`) {
+		return verbatimPrefix + `UNC\` + abs[2:]
+	}
+	return verbatimPrefix + abs
+}
@@ -0,0 +1,94 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestOpenOptionsUri(t *testing.T) {
+	var tests = []struct {
+		options  OpenOptions
+		expected string
+	}{
+		{OpenOptions{}, "file:"},
+		{OpenOptions{Path: ":memory:"}, "file::memory:"},
+		{OpenOptions{Path: "test.db", Mode: "ro", Immutable: true}, "file:test.db?immutable=1&mode=ro"},
+		{OpenOptions{Path: "/tmp/a b?#%.db", Cache: "shared", NoPsow: true}, "file:/tmp/a%20b%3F%23%25.db?cache=shared&psow=0"},
+		{OpenOptions{Path: "x.db", Params: map[string]string{"vfs": "unix-none", "a&b": "c=d"}}, "file:x.db?a%26b=c%3Dd&vfs=unix-none"},
+		{OpenOptions{Path: "/tmp/été.db"}, "file:/tmp/%C3%A9t%C3%A9.db"},
+		{OpenOptions{Path: `\\?\C:\very long\a#b.db`}, `file:\\%3F\C:\very%20long\a%23b.db`},
+	}
+	for _, test := range tests {
+		assertEquals(t, "expected %q but got %q", test.expected, test.options.Uri())
+	}
+}
+
+func TestOpenOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosqlite-options")
+	checkNoError(t, err, "couldn't create temp dir: %s")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "odd ?#%name.db")
+
+	db, err := (&OpenOptions{Path: path, Params: map[string]string{"foo": "bar"}}).Open()
+	checkNoError(t, err, "couldn't open database: %s")
+	assertEquals(t, "expected %q but got %q", path, db.Filename("main"))
+	value, _ := db.UriParameter("main", "foo")
+	assertEquals(t, "expected %q but got %q", "bar", value)
+	createTable(db, t)
+	checkClose(db, t)
+
+	db, err = (&OpenOptions{Path: path, Mode: "ro", Immutable: true}).Open()
+	checkNoError(t, err, "couldn't open database: %s")
+	defer checkClose(db, t)
+	ro, err := db.Readonly("main")
+	checkNoError(t, err, "Readonly status error: %s")
+	assert(t, "readonly expected", ro)
+	err = db.Exec("INSERT INTO test (a_string) VALUES ('ro')")
+	assert(t, "error expected", err != nil)
+	err = db.ProbeWritable("main")
+	assert(t, "read-only error expected", err != nil)
+}
+
+func TestOpenOddPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosqlite paths")
+	checkNoError(t, err, "couldn't create temp dir: %s")
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"with space.db", "hash#.db", "percent%41.db", "données été ü 日本.db"} {
+		path := filepath.Join(dir, name)
+		db, err := (&OpenOptions{Path: path}).Open()
+		checkNoError(t, err, "couldn't open database: %s")
+		createTable(db, t)
+		assertEquals(t, "expected %q but got %q", path, db.Filename("main"))
+		checkClose(db, t)
+		_, err = os.Stat(path)
+		checkNoError(t, err, "database file expected: %s")
+
+		db, err = Open16(path)
+		checkNoError(t, err, "couldn't open database: %s")
+		assertEquals(t, "expected %q but got %q", path, db.Filename("main"))
+		var n int
+		checkNoError(t, db.OneValue("SELECT count(*) FROM test", &n), "select error: %s")
+		checkClose(db, t)
+	}
+}
+
+func TestLongPath(t *testing.T) {
+	assertEquals(t, "expected %q but got %q", ":memory:", LongPath(":memory:"))
+	assertEquals(t, "expected %q but got %q", "", LongPath(""))
+	if runtime.GOOS != "windows" {
+		assertEquals(t, "expected %q but got %q", "dir/x.db", LongPath("dir/x.db"))
+		return
+	}
+	long := LongPath("x.db")
+	assert(t, "extended-length path expected: "+long, strings.HasPrefix(long, `\\?\`) && strings.HasSuffix(long, `\x.db`))
+	assertEquals(t, "expected %q but got %q", `\\?\UNC\server\share\x.db`, LongPath(`\\server\share\x.db`))
+}
@@ -0,0 +1,241 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"strconv"
+	"strings"
+)
+
+// Page tells how to reach the pages around the one just read.
+// An empty cursor means that there is no page in that direction.
+type Page struct {
+	Prev string // cursor to be passed to Paginator.PrevPage
+	Next string // cursor to be passed to Paginator.NextPage
+}
+
+// Paginator implements keyset pagination over a base query:
+// instead of skipping rows with OFFSET, each page starts right after (or before) the key of the last (or first) row read,
+// so that big tables can be paginated with index lookups.
+//
+//	p := NewPaginator(db, "SELECT id, name FROM person WHERE age > ?", []interface{}{18}, []string{"name", "id"}, 20)
+//	page, err := p.NextPage("", func(s *Stmt) error {
+//		// Scan
+//	})
+//	page, err = p.NextPage(page.Next, ...)
+type Paginator struct {
+	c        *Conn
+	query    string
+	args     []interface{}
+	columns  []string
+	desc     []bool
+	pageSize int
+}
+
+// NewPaginator creates a paginator for the specified base query (without ORDER BY nor LIMIT clause).
+// The ordering columns must be result columns of the base query,
+// must not be NULL and must identify a row uniquely (add the primary key if needed).
+// Each ordering column may be followed by "DESC".
+func NewPaginator(c *Conn, query string, args []interface{}, orderBy []string, pageSize int) *Paginator {
+	p := &Paginator{c: c, query: query, args: args, pageSize: pageSize}
+	for _, column := range orderBy {
+		fields := strings.Fields(column)
+		desc := len(fields) > 1 && strings.EqualFold(fields[len(fields)-1], "DESC")
+		if len(fields) > 1 {
+			column = strings.Join(fields[:len(fields)-1], " ")
+		}
+		p.columns = append(p.columns, column)
+		p.desc = append(p.desc, desc)
+	}
+	return p
+}
+
+// NextPage reads the page following the specified cursor ("" for the first page).
+// The rowCallbackHandler is invoked for each row of the page (see Stmt.Select).
+func (p *Paginator) NextPage(cursor string, rowCallbackHandler func(s *Stmt) error) (Page, error) {
+	var key []interface{}
+	if len(cursor) > 0 {
+		var err error
+		if key, err = p.decode(cursor); err != nil {
+			return Page{}, err
+		}
+	}
+	var first, last []interface{}
+	n, err := p.fetch(p.sql(key, false, false, false), key, p.pageSize, func(s *Stmt) (err error) {
+		if last, err = p.key(s); err != nil {
+			return err
+		} else if first == nil {
+			first = last
+		}
+		return rowCallbackHandler(s)
+	})
+	if err != nil {
+		return Page{}, err
+	}
+	var page Page
+	if n > 0 && len(cursor) > 0 {
+		if page.Prev, err = p.encode(first); err != nil {
+			return Page{}, err
+		}
+	}
+	if n > p.pageSize {
+		if page.Next, err = p.encode(last); err != nil {
+			return Page{}, err
+		}
+	}
+	return page, nil
+}
+
+// PrevPage reads the page preceding the specified cursor.
+// Rows are still passed to the rowCallbackHandler in the natural order.
+func (p *Paginator) PrevPage(cursor string, rowCallbackHandler func(s *Stmt) error) (Page, error) {
+	key, err := p.decode(cursor)
+	if err != nil {
+		return Page{}, err
+	}
+	// looks backward for the key of the first row of the page
+	var first, last []interface{}
+	n, err := p.fetch(p.sql(key, true, false, true), key, p.pageSize, func(s *Stmt) (err error) {
+		first, err = p.key(s)
+		return err
+	})
+	if err != nil || n == 0 {
+		return Page{}, err
+	}
+	count := n
+	if count > p.pageSize {
+		count = p.pageSize
+	}
+	_, err = p.fetch(p.sql(first, false, true, false), first, count, func(s *Stmt) (err error) {
+		if last, err = p.key(s); err != nil {
+			return err
+		}
+		return rowCallbackHandler(s)
+	})
+	if err != nil {
+		return Page{}, err
+	}
+	var page Page
+	if page.Next, err = p.encode(last); err != nil {
+		return Page{}, err
+	}
+	if n > p.pageSize {
+		if page.Prev, err = p.encode(first); err != nil {
+			return Page{}, err
+		}
+	}
+	return page, nil
+}
+
+// fetch runs the query and invokes f on at most limit rows.
+// Returns the number of rows returned by the query (at most pageSize+1).
+func (p *Paginator) fetch(query string, key []interface{}, limit int, f func(s *Stmt) error) (int, error) {
+	args := make([]interface{}, 0, len(p.args)+len(key)*(len(key)+1)/2)
+	args = append(args, p.args...)
+	for i := range key {
+		args = append(args, key[:i+1]...)
+	}
+	s, err := p.c.Prepare(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Finalize()
+	var n int
+	err = s.Select(func(s *Stmt) error {
+		n++
+		if n > limit {
+			return nil
+		}
+		return f(s)
+	})
+	return n, err
+}
+
+// sql wraps the base query with the keyset condition, the ordering and the limit.
+// The key condition is: (c0 > ?) OR (c0 = ? AND c1 > ?) OR ...
+func (p *Paginator) sql(key []interface{}, backward, inclusive, keyOnly bool) string {
+	quoted := make([]string, len(p.columns))
+	for i, column := range p.columns {
+		quoted[i] = Mprintf(`"%w"`, column)
+	}
+	var b strings.Builder
+	if keyOnly {
+		b.WriteString("SELECT " + strings.Join(quoted, ", "))
+	} else {
+		b.WriteString("SELECT *")
+	}
+	b.WriteString(" FROM (" + p.query + ")")
+	if len(key) > 0 {
+		b.WriteString(" WHERE ")
+		for i := range key {
+			if i > 0 {
+				b.WriteString(" OR ")
+			}
+			b.WriteByte('(')
+			for j := 0; j < i; j++ {
+				b.WriteString(quoted[j] + " = ? AND ")
+			}
+			op := ">"
+			if p.desc[i] != backward {
+				op = "<"
+			}
+			if inclusive && i == len(key)-1 {
+				op += "="
+			}
+			b.WriteString(quoted[i] + " " + op + " ?)")
+		}
+	}
+	b.WriteString(" ORDER BY ")
+	for i, column := range quoted {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(column)
+		if p.desc[i] != backward {
+			b.WriteString(" DESC")
+		}
+	}
+	b.WriteString(" LIMIT " + strconv.Itoa(p.pageSize+1))
+	return b.String()
+}
+
+// key returns the values of the ordering columns of the current row.
+func (p *Paginator) key(s *Stmt) ([]interface{}, error) {
+	key := make([]interface{}, len(p.columns))
+	for i, column := range p.columns {
+		index, err := s.ColumnIndex(column)
+		if err != nil {
+			return nil, err
+		}
+		key[i], _ = s.ScanValue(index, false)
+	}
+	return key, nil
+}
+
+func (p *Paginator) encode(key []interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(key); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func (p *Paginator) decode(cursor string) ([]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, p.c.specificError("invalid cursor: %s", err)
+	}
+	var key []interface{}
+	if err = gob.NewDecoder(bytes.NewReader(b)).Decode(&key); err != nil {
+		return nil, p.c.specificError("invalid cursor: %s", err)
+	}
+	if len(key) != len(p.columns) {
+		return nil, p.c.specificError("invalid cursor: %d values for %d columns", len(key), len(p.columns))
+	}
+	return key, nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	"fmt"
+	. "github.com/gwenn/gosqlite"
+	"reflect"
+	"testing"
+)
+
+func TestPaginator(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE page (id INTEGER PRIMARY KEY, grp INT, name TEXT)"), "create error: %s")
+	s, err := db.Prepare("INSERT INTO page (grp, name) VALUES (?, ?)")
+	checkNoError(t, err, "prepare error: %s")
+	for i := 0; i < 10; i++ {
+		checkNoError(t, s.Exec(i%3, fmt.Sprintf("n%d", i)), "insert error: %s")
+	}
+	checkFinalize(s, t)
+
+	p := NewPaginator(db, "SELECT id, grp, name FROM page WHERE id > ?", []interface{}{0}, []string{"grp DESC", "id"}, 4)
+	var ids []int
+	read := func(s *Stmt) error {
+		var id int
+		if err := s.Scan(&id, nil, nil); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	}
+	var pages [][]int
+	var page Page
+	for {
+		ids = nil
+		page, err = p.NextPage(page.Next, read)
+		checkNoError(t, err, "next page error: %s")
+		pages = append(pages, ids)
+		if len(page.Next) == 0 {
+			break
+		}
+	}
+	expected := [][]int{{3, 6, 9, 2}, {5, 8, 1, 4}, {7, 10}}
+	assert(t, fmt.Sprintf("expected %v but got %v", expected, pages), reflect.DeepEqual(expected, pages))
+
+	ids = nil
+	page, err = p.PrevPage(page.Prev, read)
+	checkNoError(t, err, "prev page error: %s")
+	assert(t, fmt.Sprintf("expected %v but got %v", expected[1], ids), reflect.DeepEqual(expected[1], ids))
+	ids = nil
+	page, err = p.PrevPage(page.Prev, read)
+	checkNoError(t, err, "prev page error: %s")
+	assert(t, fmt.Sprintf("expected %v but got %v", expected[0], ids), reflect.DeepEqual(expected[0], ids))
+	assertEquals(t, "expected no previous page but got %q", "", page.Prev)
+
+	_, err = p.NextPage("invalid", read)
+	assert(t, "invalid cursor error expected", err != nil)
+}
@@ -0,0 +1,193 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// QueryPlanNode is a node of the EXPLAIN QUERY PLAN tree.
+// (See http://sqlite.org/eqp.html)
+type QueryPlanNode struct {
+	ID       int
+	Parent   int // 0 for the roots
+	Detail   string
+	Children []*QueryPlanNode
+}
+
+// QueryPlan returns the roots of the query plan of the specified statement (which is not executed).
+func (c *Conn) QueryPlan(sql string, args ...interface{}) ([]*QueryPlanNode, error) {
+	s, err := c.prepare("EXPLAIN QUERY PLAN "+sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer s.finalize()
+	var roots []*QueryPlanNode
+	nodes := make(map[int]*QueryPlanNode)
+	err = s.Select(func(s *Stmt) error {
+		n := &QueryPlanNode{}
+		var notUsed int
+		if err := s.Scan(&n.ID, &n.Parent, &notUsed, &n.Detail); err != nil {
+			return err
+		}
+		nodes[n.ID] = n
+		if parent, ok := nodes[n.Parent]; ok {
+			parent.Children = append(parent.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+		return nil
+	})
+	return roots, err
+}
+
+// Opcode is an instruction of the bytecode program of a statement.
+// (See http://sqlite.org/opcode.html)
+type Opcode struct {
+	Addr       int
+	Opcode     string
+	P1, P2, P3 int
+	P4         string
+	P5         int
+	Comment    string // only with SQLITE_ENABLE_EXPLAIN_COMMENTS
+}
+
+// Explain returns the bytecode program of the specified statement (which is not executed).
+func (c *Conn) Explain(sql string, args ...interface{}) ([]Opcode, error) {
+	s, err := c.prepare("EXPLAIN "+sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer s.finalize()
+	var ops []Opcode
+	err = s.Select(func(s *Stmt) error {
+		var op Opcode
+		if err := s.Scan(&op.Addr, &op.Opcode, &op.P1, &op.P2, &op.P3, &op.P4, &op.P5, &op.Comment); err != nil {
+			return err
+		}
+		ops = append(ops, op)
+		return nil
+	})
+	return ops, err
+}
+
+// GraphFormat is the text format of a graph.
+type GraphFormat int
+
+// Graph formats
+const (
+	GraphDOT     GraphFormat = iota // Graphviz
+	GraphMermaid                    // Mermaid flowchart
+)
+
+type graph struct {
+	format GraphFormat
+	b      bytes.Buffer
+}
+
+func newGraph(format GraphFormat, name string) *graph {
+	g := &graph{format: format}
+	if format == GraphMermaid {
+		g.b.WriteString("flowchart TD\n")
+	} else {
+		fmt.Fprintf(&g.b, "digraph %s {\n\tnode [shape=box];\n", name)
+	}
+	return g
+}
+
+func (g *graph) node(id, label string) {
+	if g.format == GraphMermaid {
+		fmt.Fprintf(&g.b, "\t%s[\"%s\"]\n", id, strings.Replace(label, `"`, "#quot;", -1))
+	} else {
+		fmt.Fprintf(&g.b, "\t%s [label=%q];\n", id, label)
+	}
+}
+
+func (g *graph) edge(from, to, label string) {
+	if g.format == GraphMermaid {
+		if len(label) > 0 {
+			fmt.Fprintf(&g.b, "\t%s -->|%s| %s\n", from, label, to)
+		} else {
+			fmt.Fprintf(&g.b, "\t%s --> %s\n", from, to)
+		}
+	} else {
+		if len(label) > 0 {
+			fmt.Fprintf(&g.b, "\t%s -> %s [label=%q];\n", from, to, label)
+		} else {
+			fmt.Fprintf(&g.b, "\t%s -> %s;\n", from, to)
+		}
+	}
+}
+
+func (g *graph) String() string {
+	if g.format != GraphMermaid {
+		g.b.WriteString("}\n")
+	}
+	return g.b.String()
+}
+
+// QueryPlanGraph renders the query plan tree (see Conn.QueryPlan) in the specified format:
+//
+//	roots, err := db.QueryPlan("SELECT * FROM t WHERE a = ?")
+//	fmt.Print(QueryPlanGraph(roots, GraphMermaid))
+func QueryPlanGraph(roots []*QueryPlanNode, format GraphFormat) string {
+	g := newGraph(format, "plan")
+	g.node("n0", "QUERY PLAN")
+	var walk func(parent string, nodes []*QueryPlanNode)
+	walk = func(parent string, nodes []*QueryPlanNode) {
+		for _, n := range nodes {
+			id := fmt.Sprintf("n%d", n.ID)
+			g.node(id, n.Detail)
+			g.edge(parent, id, "")
+			walk(id, n.Children)
+		}
+	}
+	walk("n0", roots)
+	return g.String()
+}
+
+// jumps lists the opcodes which may jump to P2.
+var jumps = map[string]bool{
+	"Goto": true, "Gosub": true, "InitCoroutine": true, "Yield": true, "Init": true, "Once": true,
+	"If": true, "IfNot": true, "IfPos": true, "IfNotZero": true, "DecrJumpZero": true, "IfNullRow": true,
+	"IsNull": true, "NotNull": true, "IsType": true, "MustBeInt": true, "ElseEq": true,
+	"Eq": true, "Ne": true, "Lt": true, "Le": true, "Gt": true, "Ge": true,
+	"Rewind": true, "Last": true, "Next": true, "Prev": true, "SorterSort": true, "Sort": true, "SorterNext": true,
+	"SeekLT": true, "SeekLE": true, "SeekGE": true, "SeekGT": true, "SeekRowid": true, "NotExists": true,
+	"Found": true, "NotFound": true, "NoConflict": true, "IfNoHope": true, "IfSmaller": true,
+	"IdxLE": true, "IdxGT": true, "IdxLT": true, "IdxGE": true,
+	"RowSetRead": true, "RowSetTest": true, "FkIfZero": true, "VFilter": true, "VNext": true, "Program": true,
+}
+
+// ends lists the opcodes after which the execution does not continue with the next instruction.
+var ends = map[string]bool{"Goto": true, "Halt": true, "Return": true, "EndCoroutine": true, "Init": true}
+
+// OpcodesGraph renders the control flow of a bytecode program (see Conn.Explain) in the specified format:
+// each instruction is a node, linked to the next one and to its jump target (P2).
+func OpcodesGraph(ops []Opcode, format GraphFormat) string {
+	g := newGraph(format, "program")
+	for _, op := range ops {
+		label := fmt.Sprintf("%d %s %d %d %d", op.Addr, op.Opcode, op.P1, op.P2, op.P3)
+		if len(op.P4) > 0 {
+			label += " " + op.P4
+		}
+		if len(op.Comment) > 0 {
+			label += " -- " + op.Comment
+		}
+		g.node(fmt.Sprintf("a%d", op.Addr), label)
+	}
+	for i, op := range ops {
+		from := fmt.Sprintf("a%d", op.Addr)
+		if !ends[op.Opcode] && i+1 < len(ops) {
+			g.edge(from, fmt.Sprintf("a%d", ops[i+1].Addr), "")
+		}
+		if jumps[op.Opcode] && op.P2 > 0 && op.P2 < len(ops) {
+			g.edge(from, fmt.Sprintf("a%d", op.P2), "jump")
+		}
+	}
+	return g.String()
+}
@@ -0,0 +1,45 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"strings"
+	"testing"
+)
+
+func TestQueryPlanGraph(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	roots, err := db.QueryPlan("SELECT * FROM test WHERE rowid IN (SELECT rowid FROM test WHERE a_string = ?)", "a")
+	checkNoError(t, err, "query plan error: %s")
+	assert(t, "query plan expected", len(roots) > 0)
+
+	dot := QueryPlanGraph(roots, GraphDOT)
+	assert(t, "DOT digraph expected", strings.HasPrefix(dot, "digraph plan {"))
+	assert(t, "DOT root edge expected", strings.Contains(dot, "n0 -> n"))
+	assert(t, "DOT detail expected", strings.Contains(dot, "SEARCH test"))
+	mermaid := QueryPlanGraph(roots, GraphMermaid)
+	assert(t, "Mermaid flowchart expected", strings.HasPrefix(mermaid, "flowchart TD\n"))
+	assert(t, "Mermaid root edge expected", strings.Contains(mermaid, "n0 --> n"))
+
+	_, err = db.QueryPlan("SELECT * FROM missing")
+	assert(t, "error expected", err != nil)
+}
+
+func TestOpcodesGraph(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	ops, err := db.Explain("SELECT * FROM test")
+	checkNoError(t, err, "explain error: %s")
+	assertEquals(t, "expected %q but got %q", "Init", ops[0].Opcode)
+	dot := OpcodesGraph(ops, GraphDOT)
+	assert(t, "DOT jump expected", strings.Contains(dot, `[label="jump"]`))
+	mermaid := OpcodesGraph(ops, GraphMermaid)
+	assert(t, "Mermaid jump expected", strings.Contains(mermaid, "-->|jump|"))
+	assert(t, "Mermaid Rewind expected", strings.Contains(mermaid, "Rewind"))
+}
@@ -12,6 +12,8 @@ import "C"
 import (
 	"fmt"
 	"io"
+	"os"
+	"sync"
 )
 
 // IntegrityCheck checks database integrity.
@@ -135,6 +137,308 @@ func (c *Conn) SetSynchronous(dbName string, mode int) error {
 	return c.exec(pragma(dbName, fmt.Sprintf("synchronous=%d", mode)))
 }
 
+// MmapSize queries the maximum number of bytes of the database file accessed with memory-mapped I/O.
+// Database name is optional (default is 'main').
+// (See http://sqlite.org/pragma.html#pragma_mmap_size)
+func (c *Conn) MmapSize(dbName string) (int64, error) {
+	var size int64
+	err := c.oneValue(pragma(dbName, "mmap_size"), &size)
+	if err != nil {
+		return -1, err
+	}
+	return size, nil
+}
+
+// SetMmapSize changes the maximum number of bytes of the database file accessed with memory-mapped I/O
+// and returns the actual limit (which may be capped at compile-time).
+// Database name is optional (default is 'main').
+// (See http://sqlite.org/pragma.html#pragma_mmap_size)
+func (c *Conn) SetMmapSize(dbName string, size int64) (int64, error) {
+	var newSize int64
+	err := c.oneValue(pragma(dbName, fmt.Sprintf("mmap_size=%d", size)), &newSize)
+	if err != nil {
+		return -1, err
+	}
+	return newSize, nil
+}
+
+// SecureDelete tells how the deleted content is overwritten (see Conn.SetSecureDelete).
+type SecureDelete int
+
+// Secure delete modes
+const (
+	SecureDeleteOff  SecureDelete = iota // deleted content is left in place
+	SecureDeleteOn                       // deleted content is overwritten with zeros
+	SecureDeleteFast                     // deleted content is overwritten only when it does not increase the I/O
+)
+
+func (d SecureDelete) String() string {
+	switch d {
+	case SecureDeleteOff:
+		return "OFF"
+	case SecureDeleteOn:
+		return "ON"
+	case SecureDeleteFast:
+		return "FAST"
+	}
+	return fmt.Sprintf("SecureDelete(%d)", int(d))
+}
+
+// SecureDelete queries the secure-delete mode.
+// Database name is optional (default is 'main').
+// (See http://sqlite.org/pragma.html#pragma_secure_delete)
+func (c *Conn) SecureDelete(dbName string) (SecureDelete, error) {
+	var mode int
+	err := c.oneValue(pragma(dbName, "secure_delete"), &mode)
+	if err != nil {
+		return -1, err
+	}
+	return SecureDelete(mode), nil
+}
+
+// SetSecureDelete changes the secure-delete mode and returns the actual mode.
+// If the database name is empty, the mode of all attached databases is changed
+// (and of the ones attached later).
+// (See http://sqlite.org/pragma.html#pragma_secure_delete)
+func (c *Conn) SetSecureDelete(dbName string, mode SecureDelete) (SecureDelete, error) {
+	if mode < SecureDeleteOff || mode > SecureDeleteFast {
+		return -1, c.specificError("invalid secure_delete mode: %d", mode)
+	}
+	var newMode int
+	err := c.oneValue(pragma(dbName, "secure_delete="+mode.String()), &newMode)
+	if err != nil {
+		return -1, err
+	}
+	return SecureDelete(newMode), nil
+}
+
+// JournalSizeLimit queries the maximum size (in bytes) of the rollback journal or WAL
+// left in the file-system after a transaction or checkpoint (-1 means no limit).
+// Database name is optional (default is 'main').
+// (See http://sqlite.org/pragma.html#pragma_journal_size_limit)
+func (c *Conn) JournalSizeLimit(dbName string) (int64, error) {
+	var limit int64
+	err := c.oneValue(pragma(dbName, "journal_size_limit"), &limit)
+	if err != nil {
+		return -1, err
+	}
+	return limit, nil
+}
+
+// SetJournalSizeLimit changes the maximum size (in bytes) of the rollback journal or WAL
+// left in the file-system after a transaction or checkpoint (-1 means no limit)
+// and returns the actual limit.
+// Database name is optional (default is 'main').
+// (See http://sqlite.org/pragma.html#pragma_journal_size_limit)
+func (c *Conn) SetJournalSizeLimit(dbName string, limit int64) (int64, error) {
+	var newLimit int64
+	err := c.oneValue(pragma(dbName, fmt.Sprintf("journal_size_limit=%d", limit)), &newLimit)
+	if err != nil {
+		return -1, err
+	}
+	return newLimit, nil
+}
+
+// WipeFreeSpace removes the deleted content still present in the specified database file:
+// the database is rebuilt (see http://sqlite.org/lang_vacuum.html) so that the free pages
+// and the unused parts of the pages are discarded, then the WAL (if any) is checkpointed and truncated.
+// The rollback journal written during the rebuild is deleted (or truncated) but not overwritten.
+// Secure delete (see Conn.SetSecureDelete) should be enabled to wipe the content deleted afterwards.
+// Database name is optional (default is 'main').
+func (c *Conn) WipeFreeSpace(dbName string) error {
+	mode, err := c.JournalMode(dbName)
+	if err != nil {
+		return err
+	}
+	vacuum := "VACUUM"
+	if len(dbName) > 0 {
+		vacuum = Mprintf("VACUUM %Q", dbName)
+	}
+	if err = c.exec(vacuum); err != nil {
+		return err
+	}
+	if mode == "wal" {
+		_, _, err = c.WalCheckpoint(dbName, CheckpointTruncate)
+	}
+	return err
+}
+
+// PageCacheSize is the suggested maximum size of the page cache of a database:
+// a number of pages when positive or an amount of memory in KiB when negative
+// (see CachePages and CacheKiB).
+type PageCacheSize int64
+
+// CachePages returns a page cache size of n pages.
+func CachePages(n int64) PageCacheSize {
+	return PageCacheSize(n)
+}
+
+// CacheKiB returns a page cache size of n KiB.
+func CacheKiB(n int64) PageCacheSize {
+	return PageCacheSize(-n)
+}
+
+// Pages returns the number of pages (false if the size is specified in KiB).
+func (s PageCacheSize) Pages() (int64, bool) {
+	return int64(s), s >= 0
+}
+
+// KiB returns the amount of memory in KiB (false if the size is specified in pages).
+func (s PageCacheSize) KiB() (int64, bool) {
+	return -int64(s), s < 0
+}
+
+func (s PageCacheSize) String() string {
+	if s < 0 {
+		return fmt.Sprintf("%d KiB", -s)
+	}
+	return fmt.Sprintf("%d pages", int64(s))
+}
+
+// PageCacheSize queries the suggested maximum size of the page cache
+// (not to be confused with the prepared statements cache, see Conn.CacheSize).
+// Database name is optional (default is 'main').
+// (See http://sqlite.org/pragma.html#pragma_cache_size)
+func (c *Conn) PageCacheSize(dbName string) (PageCacheSize, error) {
+	var size int64
+	err := c.oneValue(pragma(dbName, "cache_size"), &size)
+	if err != nil {
+		return 0, err
+	}
+	return PageCacheSize(size), nil
+}
+
+// SetPageCacheSize changes the suggested maximum size of the page cache.
+// Database name is optional (default is 'main').
+// (See http://sqlite.org/pragma.html#pragma_cache_size)
+func (c *Conn) SetPageCacheSize(dbName string, size PageCacheSize) error {
+	return c.exec(pragma(dbName, fmt.Sprintf("cache_size=%d", int64(size))))
+}
+
+// CacheSpill queries the number of dirty pages above which the page cache is spilled
+// to the database file before the commit (0 when spilling is disabled).
+// Database name is optional (default is 'main').
+// (See http://sqlite.org/pragma.html#pragma_cache_spill)
+func (c *Conn) CacheSpill(dbName string) (int, error) {
+	var pages int
+	err := c.oneValue(pragma(dbName, "cache_spill"), &pages)
+	if err != nil {
+		return -1, err
+	}
+	return pages, nil
+}
+
+// SetCacheSpill changes the threshold of the page cache spilling:
+// pages > 0 sets the minimum number of dirty pages before spilling (the page cache size is used if greater),
+// 0 disables spilling (a big transaction may then use a lot of memory) and -1 restores the default.
+// The threshold applies to all the databases of the connection.
+// (See http://sqlite.org/pragma.html#pragma_cache_spill)
+func (c *Conn) SetCacheSpill(pages int) error {
+	switch {
+	case pages == 0:
+		return c.exec("PRAGMA cache_spill=OFF")
+	case pages < 0:
+		return c.exec("PRAGMA cache_spill=ON")
+	}
+	return c.exec(fmt.Sprintf("PRAGMA cache_spill=%d", pages))
+}
+
+// RecommendCacheSize recommends a page cache size for the specified database:
+// big enough to hold the whole database (as reported by PRAGMA page_count) but not more than maxKiB
+// and not less than the default cache size (2000 KiB).
+// Database name is optional (default is 'main').
+func (c *Conn) RecommendCacheSize(dbName string, maxKiB int64) (PageCacheSize, error) {
+	var pageCount, pageSize int64
+	if err := c.oneValue(pragma(dbName, "page_count"), &pageCount); err != nil {
+		return 0, err
+	}
+	if err := c.oneValue(pragma(dbName, "page_size"), &pageSize); err != nil {
+		return 0, err
+	}
+	kib := (pageCount*pageSize + 1023) / 1024
+	kib += kib / 10 // room for growth
+	if maxKiB > 0 && kib > maxKiB {
+		kib = maxKiB
+	}
+	if kib < 2000 {
+		kib = 2000
+	}
+	return CacheKiB(kib), nil
+}
+
+// TempStore queries the temporary storage location:
+// 0 (default, as set at compile-time), 1 (file) or 2 (memory).
+// (See http://sqlite.org/pragma.html#pragma_temp_store)
+func (c *Conn) TempStore() (int, error) {
+	var mode int
+	err := c.oneValue("PRAGMA temp_store", &mode)
+	if err != nil {
+		return -1, err
+	}
+	return mode, nil
+}
+
+// SetTempStore changes the temporary storage location:
+// 0 (default, as set at compile-time), 1 (file) or 2 (memory).
+// All existing temporary tables, indices, triggers, and views are deleted.
+// (See http://sqlite.org/pragma.html#pragma_temp_store)
+func (c *Conn) SetTempStore(mode int) error {
+	if mode < 0 || mode > 2 {
+		return c.specificError("invalid temp_store mode: %d", mode)
+	}
+	return c.exec(fmt.Sprintf("PRAGMA temp_store=%d", mode))
+}
+
+// sqlite3_temp_directory is a process-wide global variable.
+var tempDirMutex sync.Mutex
+
+// TempDirectory returns the directory where temporary files are stored
+// ("" means the default location chosen by the VFS).
+// (See http://sqlite.org/pragma.html#pragma_temp_store_directory)
+func (c *Conn) TempDirectory() (string, error) {
+	tempDirMutex.Lock()
+	defer tempDirMutex.Unlock()
+	var dir string
+	err := c.oneValue("PRAGMA temp_store_directory", &dir)
+	if err == io.EOF {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// SetTempDirectory changes the directory where temporary files are stored
+// ("" resets to the default location chosen by the VFS).
+// The directory must exist and be writable.
+// The setting is global to the process (sqlite3_temp_directory) and impacts all connections:
+// it should be called once, before other connections are opened.
+// An error is returned if the setting is not taken into account (SQLITE_OMIT_DEPRECATED).
+// (See http://sqlite.org/pragma.html#pragma_temp_store_directory and http://sqlite.org/c3ref/temp_directory.html)
+func (c *Conn) SetTempDirectory(dir string) error {
+	if len(dir) > 0 {
+		fi, err := os.Stat(dir)
+		if err != nil {
+			return err
+		} else if !fi.IsDir() {
+			return c.specificError("%q is not a directory", dir)
+		}
+	}
+	tempDirMutex.Lock()
+	defer tempDirMutex.Unlock()
+	if err := c.exec(Mprintf("PRAGMA temp_store_directory=%Q", dir)); err != nil {
+		return err
+	}
+	var newDir string
+	if err := c.oneValue("PRAGMA temp_store_directory", &newDir); err != nil && err != io.EOF {
+		return err
+	}
+	if newDir != dir {
+		return c.specificError("temp_store_directory not supported (got %q)", newDir)
+	}
+	return nil
+}
+
 // FkViolation is the description of one foreign key constraint violation.
 type FkViolation struct {
 	Table  string
@@ -192,12 +496,14 @@ func pragma(dbName, pragmaName string) string {
 }
 
 func (c *Conn) oneValue(query string, value interface{}) error { // no cache
+	c.enter()
+	defer c.leave()
 	s, err := c.prepare(query)
 	if err != nil {
 		return err
 	}
 	defer s.finalize()
-	rv := C.sqlite3_step(s.stmt)
+	rv := s.step()
 	err = Errno(rv)
 	if err == Row {
 		return s.Scan(value)
@@ -5,6 +5,11 @@
 package sqlite_test
 
 import (
+	"bytes"
+	. "github.com/gwenn/gosqlite"
+	"github.com/gwenn/gosqlite/sqlitetest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -79,3 +84,104 @@ func TestSetSynchronous(t *testing.T) {
 	checkNoError(t, err, "Error reading synchronous flag of database: %s")
 	assertEquals(t, "expecting %d but got %d", 0, mode)
 }
+
+func TestSecureDelete(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	mode, err := db.SetSecureDelete("", SecureDeleteFast)
+	checkNoError(t, err, "Error setting secure_delete of database: %s")
+	assertEquals(t, "expecting %s but got %s", SecureDeleteFast, mode)
+	mode, err = db.SecureDelete("main")
+	checkNoError(t, err, "Error reading secure_delete of database: %s")
+	assertEquals(t, "expecting %s but got %s", SecureDeleteFast, mode)
+	_, err = db.SetSecureDelete("", SecureDelete(3))
+	assert(t, "error expected", err != nil)
+}
+
+func TestJournalSizeLimit(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	limit, err := db.SetJournalSizeLimit("", 1<<20)
+	checkNoError(t, err, "Error setting journal_size_limit of database: %s")
+	assertEquals(t, "expecting %d but got %d", int64(1<<20), limit)
+	limit, err = db.JournalSizeLimit("main")
+	checkNoError(t, err, "Error reading journal_size_limit of database: %s")
+	assertEquals(t, "expecting %d but got %d", int64(1<<20), limit)
+}
+
+func TestWipeFreeSpace(t *testing.T) {
+	db := sqlitetest.Open(t, sqlitetest.TempFile())
+	filename := db.Filename("main")
+	_, err := db.SetSecureDelete("", SecureDeleteOff) // may be enabled at compile-time
+	checkNoError(t, err, "Error setting secure_delete of database: %s")
+	secret := []byte("correct horse battery staple")
+	checkNoError(t, db.Exec("CREATE TABLE test (data TEXT)"), "%s")
+	checkNoError(t, db.Exec("INSERT INTO test VALUES (?)", string(secret)), "%s")
+	checkNoError(t, db.Exec("DELETE FROM test"), "%s")
+	content, err := os.ReadFile(filename)
+	checkNoError(t, err, "%s")
+	assert(t, "deleted content expected to be left in place", bytes.Contains(content, secret))
+
+	checkNoError(t, db.WipeFreeSpace(""), "Error wiping free space: %s")
+	content, err = os.ReadFile(filename)
+	checkNoError(t, err, "%s")
+	assert(t, "deleted content expected to be wiped", !bytes.Contains(content, secret))
+}
+
+func TestSetTempStore(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	err := db.SetTempStore(2)
+	checkNoError(t, err, "Error setting temp_store of database: %s")
+	mode, err := db.TempStore()
+	checkNoError(t, err, "Error reading temp_store of database: %s")
+	assertEquals(t, "expecting %d but got %d", 2, mode)
+	err = db.SetTempStore(3)
+	assert(t, "error expected", err != nil)
+}
+
+func TestSetTempDirectory(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	dir := os.TempDir()
+	err := db.SetTempDirectory(dir)
+	checkNoError(t, err, "Error setting temp_store_directory: %s")
+	defer db.SetTempDirectory("")
+	current, err := db.TempDirectory()
+	checkNoError(t, err, "Error reading temp_store_directory: %s")
+	assertEquals(t, "expecting %q but got %q", dir, current)
+	err = db.SetTempDirectory(filepath.Join(dir, "gosqlite-does-not-exist"))
+	assert(t, "error expected", err != nil)
+}
+
+func TestPageCacheSize(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	size, err := db.PageCacheSize("")
+	checkNoError(t, err, "cache_size error: %s")
+	kib, ok := size.KiB()
+	assert(t, "default cache size in KiB expected", ok && kib > 0)
+
+	checkNoError(t, db.SetPageCacheSize("main", CachePages(100)), "cache_size error: %s")
+	size, err = db.PageCacheSize("main")
+	checkNoError(t, err, "cache_size error: %s")
+	assertEquals(t, "expected %s but got %s", CachePages(100), size)
+	checkNoError(t, db.SetPageCacheSize("", CacheKiB(4096)), "cache_size error: %s")
+	size, err = db.PageCacheSize("")
+	checkNoError(t, err, "cache_size error: %s")
+	assertEquals(t, "expected %s but got %s", "4096 KiB", size.String())
+
+	checkNoError(t, db.SetCacheSpill(0), "cache_spill error: %s")
+	spill, err := db.CacheSpill("")
+	checkNoError(t, err, "cache_spill error: %s")
+	assertEquals(t, "expected %d but got %d", 0, spill)
+	checkNoError(t, db.SetCacheSpill(500), "cache_spill error: %s")
+	spill, err = db.CacheSpill("")
+	checkNoError(t, err, "cache_spill error: %s")
+	assert(t, "spill threshold expected", spill >= 500) // or the page cache size if greater
+
+	createTable(db, t)
+	recommended, err := db.RecommendCacheSize("", 10000)
+	checkNoError(t, err, "recommend error: %s")
+	assertEquals(t, "expected %s but got %s", CacheKiB(2000), recommended)
+}
@@ -0,0 +1,14 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_preupdate
+// +build sqlite_preupdate
+
+#include <sqlite3.h>
+
+extern void goXPreUpdateHook(void *udp, sqlite3 *db, int op, char const *dbName, char const *tableName, sqlite3_int64 oldRowId, sqlite3_int64 newRowId);
+
+void* goSqlite3PreUpdateHook(sqlite3 *db, void *udp) {
+	return sqlite3_preupdate_hook(db, goXPreUpdateHook, udp);
+}
@@ -0,0 +1,115 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_preupdate
+// +build sqlite_preupdate
+
+package sqlite
+
+/*
+#cgo CFLAGS: -DSQLITE_ENABLE_PREUPDATE_HOOK
+#include <sqlite3.h>
+
+void* goSqlite3PreUpdateHook(sqlite3 *db, void *udp);
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+// PreUpdateData gives access to the row being inserted, updated or deleted.
+// It must not be used after the PreUpdateHook returns.
+// (See http://sqlite.org/c3ref/preupdate_count.html)
+type PreUpdateData struct {
+	c         *Conn
+	Op        Action // Insert, Update or Delete
+	DbName    string
+	TableName string
+	OldRowId  int64 // undefined for Insert
+	NewRowId  int64 // undefined for Delete
+}
+
+// PreUpdateHook is the callback function signature.
+type PreUpdateHook func(udp interface{}, d *PreUpdateData)
+
+type sqlitePreUpdateHook struct {
+	f   PreUpdateHook
+	udp interface{}
+	c   *Conn
+}
+
+//export goXPreUpdateHook
+func goXPreUpdateHook(udp, db unsafe.Pointer, op int, dbName, tableName *C.char, oldRowId, newRowId C.sqlite3_int64) {
+	arg := (*sqlitePreUpdateHook)(udp)
+	arg.f(arg.udp, &PreUpdateData{arg.c, Action(op), C.GoString(dbName), C.GoString(tableName), int64(oldRowId), int64(newRowId)})
+}
+
+// PreUpdateHook registers a callback to be invoked before each row is updated,
+// inserted or deleted using this database connection.
+// Only available with the sqlite_preupdate build tag
+// (the SQLite library must be compiled with SQLITE_ENABLE_PREUPDATE_HOOK).
+// (See http://sqlite.org/c3ref/preupdate_count.html)
+func (c *Conn) PreUpdateHook(f PreUpdateHook, udp interface{}) {
+	if f == nil {
+		c.preUpdateHook = nil
+		C.sqlite3_preupdate_hook(c.db, nil, nil)
+		return
+	}
+	// To make sure it is not gced, keep a reference in the connection.
+	hook := &sqlitePreUpdateHook{f, udp, c}
+	c.preUpdateHook = hook
+	C.goSqlite3PreUpdateHook(c.db, unsafe.Pointer(hook))
+}
+
+// Count returns the number of columns in the row being changed.
+func (d *PreUpdateData) Count() int {
+	return int(C.sqlite3_preupdate_count(d.c.db))
+}
+
+// Depth returns 0 for a direct change and the trigger depth for changes made by triggers.
+func (d *PreUpdateData) Depth() int {
+	return int(C.sqlite3_preupdate_depth(d.c.db))
+}
+
+// Old returns the value of the specified column before the change (Update or Delete only).
+// The leftmost column is number 0.
+func (d *PreUpdateData) Old(i int) (interface{}, error) {
+	var v *C.sqlite3_value
+	if rv := C.sqlite3_preupdate_old(d.c.db, C.int(i), &v); rv != C.SQLITE_OK {
+		return nil, d.c.error(rv, "PreUpdateData.Old")
+	}
+	return valueOf(v), nil
+}
+
+// New returns the value of the specified column after the change (Insert or Update only).
+// The leftmost column is number 0.
+func (d *PreUpdateData) New(i int) (interface{}, error) {
+	var v *C.sqlite3_value
+	if rv := C.sqlite3_preupdate_new(d.c.db, C.int(i), &v); rv != C.SQLITE_OK {
+		return nil, d.c.error(rv, "PreUpdateData.New")
+	}
+	return valueOf(v), nil
+}
+
+// OldRow returns the values of all columns before the change.
+func (d *PreUpdateData) OldRow() ([]interface{}, error) {
+	return d.row(d.Old)
+}
+
+// NewRow returns the values of all columns after the change.
+func (d *PreUpdateData) NewRow() ([]interface{}, error) {
+	return d.row(d.New)
+}
+
+func (d *PreUpdateData) row(value func(i int) (interface{}, error)) ([]interface{}, error) {
+	row := make([]interface{}, d.Count())
+	for i := range row {
+		var err error
+		if row[i], err = value(i); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
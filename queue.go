@@ -0,0 +1,171 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrQueueEmpty is returned by Queue.Dequeue when there is no visible job.
+var ErrQueueEmpty = errors.New("queue empty")
+
+// ErrJobNotFound is returned by Queue.Ack, Queue.Nack and Queue.Requeue when there is no job with the specified id.
+var ErrJobNotFound = errors.New("job not found")
+
+// Job is a message of a Queue.
+type Job struct {
+	ID        int64
+	Payload   []byte
+	Attempts  int    // number of deliveries (including this one)
+	LastError string // reason of the last Nack
+}
+
+// Queue is a durable job queue stored in a table:
+//
+//	q, err := NewQueue(db, "jobs", 30*time.Second, 5)
+//	id, err := q.Enqueue(payload, 0)
+//	job, err := q.Dequeue() // ErrQueueEmpty
+//	err = q.Ack(job.ID)     // or q.Nack(job.ID, reason, delay)
+//
+// A dequeued job is hidden from other consumers until it is acknowledged, or until the visibility timeout expires
+// (the consumer is then presumed dead and the job is delivered again).
+// A job delivered maxAttempts times without being acknowledged is moved to the dead-letter list (see DeadLetters).
+//
+// Each operation is done in an IMMEDIATE transaction so that the write lock is taken upfront
+// (no SQLITE_BUSY when upgrading a read transaction); consumers should set a BusyTimeout
+// (or a RetryPolicy) on their connections.
+type Queue struct {
+	c           *Conn
+	table       string
+	visibility  time.Duration
+	maxAttempts int
+}
+
+// NewQueue creates the queue table in the main database (if it doesn't exist).
+// visibility is the time a dequeued job stays hidden (default is 30s).
+// maxAttempts is the number of deliveries before a job is dead-lettered (0 for no limit).
+func NewQueue(c *Conn, table string, visibility time.Duration, maxAttempts int) (*Queue, error) {
+	if visibility <= 0 {
+		visibility = 30 * time.Second
+	}
+	err := c.Exec(Mprintf(`CREATE TABLE IF NOT EXISTS "%w" (
+		id INTEGER PRIMARY KEY,
+		payload BLOB,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		visible_at INTEGER NOT NULL, -- unix time in milliseconds
+		dead INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT)`, table) + c.tableOptions() + ";" +
+		Mprintf2(`CREATE INDEX IF NOT EXISTS "%w" ON "%w" (dead, visible_at)`, table+"_visible", table))
+	if err != nil {
+		return nil, err
+	}
+	return &Queue{c: c, table: table, visibility: visibility, maxAttempts: maxAttempts}, nil
+}
+
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// Enqueue adds a job, visible after delay, and returns its id.
+func (q *Queue) Enqueue(payload []byte, delay time.Duration) (int64, error) {
+	var id int64
+	err := q.c.Transaction(Immediate, func(c *Conn) error {
+		err := c.Exec(Mprintf(`INSERT INTO "%w" (payload, visible_at) VALUES (?, ?)`, q.table),
+			payload, unixMilli(time.Now().Add(delay)))
+		id = c.LastInsertRowid()
+		return err
+	})
+	return id, err
+}
+
+// Dequeue delivers the oldest visible job (ErrQueueEmpty if there is none).
+// The job must be acknowledged (see Ack) before the visibility timeout expires.
+func (q *Queue) Dequeue() (*Job, error) {
+	var job *Job
+	err := q.c.Transaction(Immediate, func(c *Conn) error {
+		now := time.Now()
+		if q.maxAttempts > 0 {
+			err := c.Exec(Mprintf(`UPDATE "%w" SET dead = 1 WHERE dead = 0 AND visible_at <= ? AND attempts >= ?`, q.table),
+				unixMilli(now), q.maxAttempts)
+			if err != nil {
+				return err
+			}
+		}
+		s, err := c.Prepare(Mprintf2(`UPDATE "%w" SET attempts = attempts + 1, visible_at = ?
+			WHERE id = (SELECT id FROM "%w" WHERE dead = 0 AND visible_at <= ? ORDER BY visible_at, id LIMIT 1)
+			RETURNING id, payload, attempts, last_error`, q.table, q.table),
+			unixMilli(now.Add(q.visibility)), unixMilli(now))
+		if err != nil {
+			return err
+		}
+		defer s.Finalize()
+		return s.Select(func(s *Stmt) error {
+			job = &Job{}
+			return s.Scan(&job.ID, &job.Payload, &job.Attempts, &job.LastError)
+		})
+	})
+	if err != nil {
+		return nil, err
+	} else if job == nil {
+		return nil, ErrQueueEmpty
+	}
+	return job, nil
+}
+
+// Ack acknowledges (deletes) the job with the specified id.
+func (q *Queue) Ack(id int64) error {
+	return q.update(Mprintf(`DELETE FROM "%w" WHERE id = ? AND dead = 0`, q.table), id)
+}
+
+// Nack makes the job with the specified id visible again after delay
+// (or dead-letters it when maxAttempts is reached).
+func (q *Queue) Nack(id int64, reason string, delay time.Duration) error {
+	return q.update(Mprintf(`UPDATE "%w" SET visible_at = ?, last_error = ?, dead = (? > 0 AND attempts >= ?)
+		WHERE id = ? AND dead = 0`, q.table), unixMilli(time.Now().Add(delay)), reason, q.maxAttempts, q.maxAttempts, id)
+}
+
+// Requeue moves the dead-lettered job with the specified id back to the queue (its attempts are reset).
+func (q *Queue) Requeue(id int64) error {
+	return q.update(Mprintf(`UPDATE "%w" SET dead = 0, attempts = 0, visible_at = ? WHERE id = ? AND dead = 1`, q.table),
+		unixMilli(time.Now()), id)
+}
+
+func (q *Queue) update(sql string, args ...interface{}) error {
+	return q.c.Transaction(Immediate, func(c *Conn) error {
+		if err := c.Exec(sql, args...); err != nil {
+			return err
+		} else if c.Changes() == 0 {
+			return ErrJobNotFound
+		}
+		return nil
+	})
+}
+
+// DeadLetters returns the dead-lettered jobs.
+func (q *Queue) DeadLetters() ([]Job, error) {
+	s, err := q.c.Prepare(Mprintf(`SELECT id, payload, attempts, last_error FROM "%w" WHERE dead = 1 ORDER BY id`, q.table))
+	if err != nil {
+		return nil, err
+	}
+	defer s.Finalize()
+	var jobs []Job
+	err = s.Select(func(s *Stmt) error {
+		var job Job
+		if err := s.Scan(&job.ID, &job.Payload, &job.Attempts, &job.LastError); err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+		return nil
+	})
+	return jobs, err
+}
+
+// Len returns the number of pending (visible or not) jobs, dead-lettered jobs excluded.
+func (q *Queue) Len() (int, error) {
+	var n int
+	err := q.c.OneValue(Mprintf(`SELECT count(*) FROM "%w" WHERE dead = 0`, q.table), &n)
+	return n, err
+}
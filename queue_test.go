@@ -0,0 +1,65 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	q, err := NewQueue(db, "jobs", 20*time.Millisecond, 2)
+	checkNoError(t, err, "couldn't create queue: %s")
+
+	id1, err := q.Enqueue([]byte("first"), 0)
+	checkNoError(t, err, "enqueue error: %s")
+	id2, err := q.Enqueue([]byte("second"), 0)
+	checkNoError(t, err, "enqueue error: %s")
+	_, err = q.Enqueue([]byte("later"), time.Hour)
+	checkNoError(t, err, "enqueue error: %s")
+
+	job, err := q.Dequeue()
+	checkNoError(t, err, "dequeue error: %s")
+	assertEquals(t, "expected job %d but got %d", id1, job.ID)
+	assertEquals(t, "expected %q but got %q", "first", string(job.Payload))
+	assertEquals(t, "expected %d attempt but got %d", 1, job.Attempts)
+	checkNoError(t, q.Ack(job.ID), "ack error: %s")
+	assertEquals(t, "expected %v but got %v", ErrJobNotFound, q.Ack(job.ID))
+
+	job, err = q.Dequeue()
+	checkNoError(t, err, "dequeue error: %s")
+	assertEquals(t, "expected job %d but got %d", id2, job.ID)
+	_, err = q.Dequeue()
+	assertEquals(t, "expected %v but got %v", ErrQueueEmpty, err)
+
+	// visibility timeout expired: the job is delivered again
+	time.Sleep(30 * time.Millisecond)
+	job, err = q.Dequeue()
+	checkNoError(t, err, "dequeue error: %s")
+	assertEquals(t, "expected job %d but got %d", id2, job.ID)
+	assertEquals(t, "expected %d attempts but got %d", 2, job.Attempts)
+	// max attempts reached: the job is dead-lettered
+	checkNoError(t, q.Nack(job.ID, "boom", 0), "nack error: %s")
+	_, err = q.Dequeue()
+	assertEquals(t, "expected %v but got %v", ErrQueueEmpty, err)
+	dead, err := q.DeadLetters()
+	checkNoError(t, err, "dead letters error: %s")
+	assertEquals(t, "expected %d dead letter but got %d", 1, len(dead))
+	assertEquals(t, "expected %q but got %q", "boom", dead[0].LastError)
+	n, err := q.Len()
+	checkNoError(t, err, "len error: %s")
+	assertEquals(t, "expected %d jobs but got %d", 1, n)
+
+	checkNoError(t, q.Requeue(id2), "requeue error: %s")
+	job, err = q.Dequeue()
+	checkNoError(t, err, "dequeue error: %s")
+	assertEquals(t, "expected job %d but got %d", id2, job.ID)
+	assertEquals(t, "expected %d attempt but got %d", 1, job.Attempts)
+	assertEquals(t, "expected %q but got %q", "boom", job.LastError)
+	checkNoError(t, q.Ack(job.ID), "ack error: %s")
+}
@@ -0,0 +1,175 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// QuotaScope specifies which files share a quota (see RegisterQuotaVFS).
+type QuotaScope int
+
+// Quota scopes
+const (
+	QuotaPerFile      QuotaScope = iota // a database file with its rollback journal and WAL
+	QuotaPerDirectory                   // all the files in a directory
+)
+
+// QuotaVFS is a VFS shim which limits the size of the database files:
+// writes growing the files of a quota group beyond the limit fail with SQLITE_FULL.
+//
+//	q, err := RegisterQuotaVFS("quota", QuotaPerDirectory, 100<<20)
+//	db, err := OpenVfs(filepath.Join(tenantDir, "app.db"), "quota")
+//
+// The files of a group are measured the first time one of them is opened
+// then only the writes, truncates and deletes done through the VFS are tracked.
+type QuotaVFS struct {
+	name  string
+	scope QuotaScope
+	mu    sync.Mutex
+	limit int64
+	usage map[string]map[string]int64 // group -> file -> size
+}
+
+// RegisterQuotaVFS registers a quota-enforcing VFS shim named name on top of the default VFS.
+func RegisterQuotaVFS(name string, scope QuotaScope, limit int64) (*QuotaVFS, error) {
+	q := &QuotaVFS{name: name, scope: scope, limit: limit, usage: make(map[string]map[string]int64)}
+	if err := RegisterVFSShim(name, "", q.shim, false); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Name returns the name of the VFS (see OpenVfs).
+func (q *QuotaVFS) Name() string {
+	return q.name
+}
+
+// SetLimit changes the maximum size of the quota groups (current files are not truncated).
+func (q *QuotaVFS) SetLimit(limit int64) {
+	q.mu.Lock()
+	q.limit = limit
+	q.mu.Unlock()
+}
+
+// Usage returns the size of the quota group of the specified file
+// (or directory with QuotaPerDirectory).
+func (q *QuotaVFS) Usage(path string) int64 {
+	if q.scope == QuotaPerDirectory {
+		path = filepath.Join(path, "x")
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var total int64
+	for _, size := range q.files(path) {
+		total += size
+	}
+	return total
+}
+
+// Unregister unregisters the VFS. No connection must be using it.
+func (q *QuotaVFS) Unregister() error {
+	return UnregisterVFSShim(q.name)
+}
+
+// group returns the quota group of the file.
+func (q *QuotaVFS) group(file string) string {
+	if q.scope == QuotaPerDirectory {
+		return filepath.Dir(file)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(file, "-wal"), "-journal")
+}
+
+// files returns the sizes of the files in the quota group of file, measured on first use.
+func (q *QuotaVFS) files(file string) map[string]int64 {
+	group := q.group(file)
+	files, ok := q.usage[group]
+	if ok {
+		return files
+	}
+	files = make(map[string]int64)
+	if q.scope == QuotaPerDirectory {
+		entries, _ := os.ReadDir(group)
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil && info.Mode().IsRegular() && !strings.HasSuffix(e.Name(), "-shm") {
+				files[filepath.Join(group, e.Name())] = info.Size()
+			}
+		}
+	} else {
+		for _, suffix := range []string{"", "-journal", "-wal"} {
+			if info, err := os.Stat(group + suffix); err == nil {
+				files[group+suffix] = info.Size()
+			}
+		}
+	}
+	q.usage[group] = files
+	return files
+}
+
+func (q *QuotaVFS) shim(call *VFSCall, next func() error) error {
+	if len(call.File) == 0 { // temporary files are not limited
+		return next()
+	}
+	switch call.Op {
+	case VFSOpen:
+		if err := next(); err != nil {
+			return err
+		}
+		size, err := call.Size()
+		if err != nil {
+			return nil
+		}
+		q.mu.Lock()
+		q.files(call.File)[call.File] = size
+		q.mu.Unlock()
+		return nil
+	case VFSWrite:
+		end := call.Offset + int64(len(call.Data))
+		q.mu.Lock()
+		files := q.files(call.File)
+		if growth := end - files[call.File]; growth > 0 {
+			var total int64
+			for _, size := range files {
+				total += size
+			}
+			if total+growth > q.limit {
+				q.mu.Unlock()
+				return ErrFull
+			}
+			files[call.File] = end // reserved before the write
+		}
+		q.mu.Unlock()
+		err := next()
+		if err != nil {
+			q.resize(call)
+		}
+		return err
+	case VFSTruncate:
+		err := next()
+		q.resize(call)
+		return err
+	case VFSDelete:
+		err := next()
+		q.mu.Lock()
+		delete(q.files(call.File), call.File)
+		q.mu.Unlock()
+		return err
+	}
+	return next()
+}
+
+// resize records the actual size of the file.
+func (q *QuotaVFS) resize(call *VFSCall) {
+	size, err := call.Size()
+	if err != nil {
+		return
+	}
+	q.mu.Lock()
+	q.files(call.File)[call.File] = size
+	q.mu.Unlock()
+}
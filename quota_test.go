@@ -0,0 +1,43 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestQuotaVFS(t *testing.T) {
+	q, err := RegisterQuotaVFS("quota", QuotaPerDirectory, 64<<10)
+	checkNoError(t, err, "couldn't register quota VFS: %s")
+	defer q.Unregister()
+
+	dir, err := ioutil.TempDir("", "gosqlite-quota")
+	checkNoError(t, err, "couldn't create temp dir: %s")
+	defer os.RemoveAll(dir)
+	db, err := OpenVfs(filepath.Join(dir, "tenant.db"), q.Name())
+	checkNoError(t, err, "couldn't open database: %s")
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE data (v BLOB)"), "create error: %s")
+
+	var i int
+	for ; i < 100; i++ {
+		if err = db.Exec("INSERT INTO data VALUES (zeroblob(4096))"); err != nil {
+			break
+		}
+	}
+	if se, ok := err.(*StmtError); !ok || se.Code() != ErrFull {
+		t.Fatalf("expected database full error but got %#v", err)
+	}
+	assert(t, "some inserts should succeed", i > 0)
+	usage := q.Usage(dir)
+	assert(t, "usage should be within the quota", usage > 0 && usage <= 64<<10)
+
+	q.SetLimit(1 << 20)
+	checkNoError(t, db.Exec("INSERT INTO data VALUES (zeroblob(4096))"), "insert error: %s")
+}
@@ -0,0 +1,110 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+#include <string.h>
+
+static const void *goSeed;
+static int goSeedLen;
+
+static int seedRandomness(sqlite3_vfs *pVfs, int nByte, char *zOut) {
+	memset(zOut, 0, nByte);
+	if (goSeed != 0) {
+		memcpy(zOut, goSeed, goSeedLen < nByte ? goSeedLen : nByte);
+	}
+	return nByte;
+}
+
+// The PRNG is seeded by the xRandomness method of the default VFS
+// so it is replaced by a copy returning the specified seed, only for the reseeding call.
+// The copy is static because a connection opened concurrently may still use it after it is unregistered.
+static sqlite3_vfs seedVfs;
+
+static int my_seed_randomness(const void *seed, int n) {
+	sqlite3_vfs *pDefault = sqlite3_vfs_find(0);
+	unsigned char b;
+	int rc;
+	if (pDefault == 0) {
+		return SQLITE_ERROR;
+	}
+	seedVfs = *pDefault;
+	seedVfs.zName = "gosqlite-seed";
+	seedVfs.xRandomness = seedRandomness;
+	goSeed = seed;
+	goSeedLen = n;
+	rc = sqlite3_vfs_register(&seedVfs, 1);
+	if (rc == SQLITE_OK) {
+		sqlite3_randomness(0, 0);
+		sqlite3_randomness(1, &b);
+		sqlite3_vfs_register(pDefault, 1);
+		sqlite3_vfs_unregister(&seedVfs);
+	}
+	goSeed = 0;
+	goSeedLen = 0;
+	return rc;
+}
+*/
+import "C"
+
+import (
+	"crypto/rand"
+	"sync"
+	"unsafe"
+)
+
+// RandomSeedSize is the number of bytes used to seed the SQLite PRNG (see SeedRandomness).
+const RandomSeedSize = 44
+
+var seedMutex sync.Mutex
+
+// Randomness fills b with bytes from the SQLite PRNG,
+// the one used by the random() and randomblob() SQL functions and for the names of the temporary files.
+// (See http://sqlite.org/c3ref/randomness.html)
+func Randomness(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	C.sqlite3_randomness(C.int(len(b)), unsafe.Pointer(&b[0]))
+}
+
+// SeedRandomness reseeds the SQLite PRNG with the specified seed
+// (truncated or padded with zeros to RandomSeedSize bytes)
+// instead of the randomness provided by the default VFS.
+// The same seed makes the PRNG generate the same sequence, which may help to write deterministic tests.
+// It should be called at init, before any connection is opened:
+//
+//	func init() {
+//		if err := sqlite.SeedRandomnessFromCrypto(); err != nil {
+//			panic(err)
+//		}
+//	}
+func SeedRandomness(seed []byte) error {
+	if len(seed) == 0 {
+		seed = make([]byte, 1)
+	}
+	seedMutex.Lock()
+	defer seedMutex.Unlock()
+	if rv := C.my_seed_randomness(unsafe.Pointer(&seed[0]), C.int(len(seed))); rv != C.SQLITE_OK {
+		return Errno(rv)
+	}
+	return nil
+}
+
+// SeedRandomnessFromCrypto reseeds the SQLite PRNG with bytes read from crypto/rand
+// (see SeedRandomness).
+func SeedRandomnessFromCrypto() error {
+	seed := make([]byte, RandomSeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return err
+	}
+	return SeedRandomness(seed)
+}
+
+// ResetRandomness makes the SQLite PRNG reseed itself from the default VFS on next use.
+func ResetRandomness() {
+	C.sqlite3_randomness(0, nil)
+}
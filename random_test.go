@@ -0,0 +1,38 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	. "github.com/gwenn/gosqlite"
+	"strings"
+	"testing"
+)
+
+func TestSeedRandomness(t *testing.T) {
+	defer ResetRandomness()
+	db := open(t)
+	defer checkClose(db, t)
+
+	seed := []byte("deterministic seed")
+	checkNoError(t, SeedRandomness(seed), "couldn't seed PRNG: %s")
+	expected := make([]byte, 16)
+	Randomness(expected)
+
+	checkNoError(t, SeedRandomness(seed), "couldn't seed PRNG: %s")
+	actual := make([]byte, 16)
+	Randomness(actual)
+	assert(t, "same sequence expected with the same seed", bytes.Equal(expected, actual))
+
+	checkNoError(t, SeedRandomness(seed), "couldn't seed PRNG: %s")
+	var blob string
+	checkNoError(t, db.OneValue("SELECT hex(randomblob(16))", &blob), "%s")
+	assertEquals(t, "expected %q but got %q", strings.ToUpper(hex.EncodeToString(expected)), blob)
+
+	checkNoError(t, SeedRandomnessFromCrypto(), "couldn't seed PRNG: %s")
+	Randomness(actual)
+	assert(t, "different sequence expected with a random seed", !bytes.Equal(expected, actual))
+}
@@ -0,0 +1,135 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ReadPool owns several read-only connections to a database in WAL mode
+// so that independent queries can run concurrently (a single Conn is not safe for concurrent use).
+// In WAL mode, readers don't block each other nor the writer.
+type ReadPool struct {
+	conns chan *Conn
+	all   []*Conn
+}
+
+// ParallelQuery is one of the queries run by ReadPool.QueryParallel.
+type ParallelQuery struct {
+	SQL  string
+	Args []interface{}
+}
+
+// NewReadPool opens size read-only connections to the specified database (which must be in WAL mode).
+func NewReadPool(filename string, size int) (*ReadPool, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid read pool size: %d", size)
+	}
+	p := &ReadPool{conns: make(chan *Conn, size)}
+	for i := 0; i < size; i++ {
+		c, err := Open(filename, OpenReadOnly, OpenNoMutex)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.all = append(p.all, c)
+		p.conns <- c
+		if i > 0 {
+			continue
+		}
+		if mode, err := c.JournalMode("main"); err != nil || mode != "wal" {
+			p.Close()
+			if err == nil {
+				err = fmt.Errorf("database %q is not in WAL mode (%s)", filename, mode)
+			}
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Size returns the number of connections (and shards).
+func (p *ReadPool) Size() int {
+	return len(p.all)
+}
+
+// Do invokes f with a connection of the pool (waiting for one to be available).
+// The connection must not be used after f returns.
+func (p *ReadPool) Do(f func(c *Conn) error) error {
+	c := <-p.conns
+	defer func() {
+		p.conns <- c
+	}()
+	return f(c)
+}
+
+// ForEachShard invokes f concurrently on each connection of the pool and waits for all invocations to complete.
+// shard goes from 0 to shards-1 (= Size-1) and can be used to split a query, for example:
+//
+//	"SELECT ... WHERE rowid % ? = ?", shards, shard
+//
+// f must synchronize its access to shared state. The first error is returned.
+func (p *ReadPool) ForEachShard(f func(shard, shards int, c *Conn) error) error {
+	shards := p.Size()
+	errs := make(chan error, shards)
+	for shard := 0; shard < shards; shard++ {
+		go func(shard int) {
+			errs <- p.Do(func(c *Conn) error {
+				return f(shard, shards, c)
+			})
+		}(shard)
+	}
+	return firstError(errs, shards)
+}
+
+// QueryParallel runs the queries concurrently (at most Size at a time) and merges their results:
+// the rowCallbackHandler is invoked for each row with the index of the query that produced it (see Stmt.Select).
+// Invocations of rowCallbackHandler are serialized but rows of different queries are interleaved.
+// The first error is returned.
+func (p *ReadPool) QueryParallel(queries []ParallelQuery, rowCallbackHandler func(query int, s *Stmt) error) error {
+	var mu sync.Mutex
+	errs := make(chan error, len(queries))
+	for i, q := range queries {
+		go func(i int, q ParallelQuery) {
+			errs <- p.Do(func(c *Conn) error {
+				s, err := c.Prepare(q.SQL, q.Args...)
+				if err != nil {
+					return err
+				}
+				defer s.Finalize()
+				return s.Select(func(s *Stmt) error {
+					mu.Lock()
+					defer mu.Unlock()
+					return rowCallbackHandler(i, s)
+				})
+			})
+		}(i, q)
+	}
+	return firstError(errs, len(queries))
+}
+
+func firstError(errs <-chan error, n int) error {
+	var first error
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Close closes all connections of the pool.
+// It must not be called while the pool is in use.
+func (p *ReadPool) Close() error {
+	var first error
+	for _, c := range p.all {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	p.all = nil
+	return first
+}
@@ -0,0 +1,64 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestReadPool(t *testing.T) {
+	f, db := openWal(t)
+	defer os.Remove(f.Name())
+	defer os.Remove(db.WalFilename("main"))
+	defer checkClose(db, t)
+	for i := 1; i <= 100; i++ {
+		checkNoError(t, db.Exec("INSERT INTO test (int_num) VALUES (?)", i), "insert error: %s")
+	}
+
+	p, err := NewReadPool(f.Name(), 3)
+	checkNoError(t, err, "couldn't create read pool: %s")
+	defer p.Close()
+	assertEquals(t, "expected %d but got %d", 3, p.Size())
+
+	var mu sync.Mutex
+	var sum int64
+	err = p.ForEachShard(func(shard, shards int, c *Conn) error {
+		var partial int64
+		err := c.OneValue("SELECT sum(int_num) FROM test WHERE rowid % ? = ?", &partial, shards, shard)
+		mu.Lock()
+		sum += partial
+		mu.Unlock()
+		return err
+	})
+	checkNoError(t, err, "for each shard error: %s")
+	assertEquals(t, "expected %d but got %d", int64(5050), sum)
+
+	counts := make([]int, 4)
+	queries := []ParallelQuery{
+		{"SELECT int_num FROM test WHERE int_num <= ?", []interface{}{10}},
+		{"SELECT int_num FROM test WHERE int_num > ?", []interface{}{90}},
+		{"SELECT 1", nil},
+		{"SELECT int_num FROM test", nil},
+	}
+	err = p.QueryParallel(queries, func(query int, s *Stmt) error {
+		counts[query]++
+		return nil
+	})
+	checkNoError(t, err, "query parallel error: %s")
+	assert(t, "unexpected row counts", counts[0] == 10 && counts[1] == 10 && counts[2] == 1 && counts[3] == 100)
+
+	err = p.QueryParallel([]ParallelQuery{{SQL: "SELECT 1"}, {SQL: "INSERT INTO test (int_num) VALUES (0)"}}, func(query int, s *Stmt) error {
+		return nil
+	})
+	assert(t, "read-only error expected", err != nil)
+}
+
+func TestReadPoolWithoutWal(t *testing.T) {
+	_, err := NewReadPool(":memory:", 2)
+	assert(t, "WAL mode error expected", err != nil)
+}
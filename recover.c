@@ -0,0 +1,19 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_recover
+// +build sqlite_recover
+
+#include <sqlite3.h>
+#include "sqlite3recover.h"
+
+extern int goXRecoverSql(void *udp, char *sql);
+
+static int cXRecoverSql(void *udp, const char *sql) {
+	return goXRecoverSql(udp, (char *)sql);
+}
+
+sqlite3_recover *goSqlite3RecoverInitSql(sqlite3 *db, const char *zDb, void *udp) {
+	return sqlite3_recover_init_sql(db, zDb, cXRecoverSql, udp);
+}
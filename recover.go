@@ -0,0 +1,114 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_recover
+// +build sqlite_recover
+
+package sqlite
+
+/*
+#cgo LDFLAGS: -lsqlite3recover
+#include <sqlite3.h>
+#include <stdlib.h>
+#include "sqlite3recover.h"
+
+sqlite3_recover *goSqlite3RecoverInitSql(sqlite3 *db, const char *zDb, void *udp);
+*/
+import "C"
+
+import (
+	"io"
+	"unsafe"
+)
+
+// RecoverOptions configures Conn.RecoverTo and Conn.RecoverSQL.
+type RecoverOptions struct {
+	DbName          string // database recovered (default is 'main')
+	LostAndFound    string // name of the table where orphan rows are stored (none by default)
+	FreelistCorrupt bool   // ignore the freelist (when it is known to be corrupted)
+	NoRowids        bool   // do not preserve the rowids of tables without INTEGER PRIMARY KEY
+	SlowIndexes     bool   // create the indexes before inserting the rows
+}
+
+// RecoverTo salvages as much data as possible from a corrupted database into a new database file.
+// Only available with the sqlite_recover build tag
+// (the recover extension, ext/recover in the SQLite sources, must be built as the sqlite3recover library,
+// see the README for the build steps).
+// (See http://sqlite.org/recovery.html)
+func (c *Conn) RecoverTo(dstPath string, opts RecoverOptions) error {
+	zDb := C.CString(opts.dbName())
+	defer C.free(unsafe.Pointer(zDb))
+	zUri := C.CString(dstPath)
+	defer C.free(unsafe.Pointer(zUri))
+	return c.runRecover(C.sqlite3_recover_init(c.db, zDb, zUri), opts)
+}
+
+type sqliteRecoverSQL struct {
+	w   io.Writer
+	err error
+}
+
+// RecoverSQL salvages as much data as possible from a corrupted database
+// as SQL statements (one per line) written to w.
+// Only available with the sqlite_recover build tag.
+// (See http://sqlite.org/recovery.html)
+func (c *Conn) RecoverSQL(w io.Writer, opts RecoverOptions) error {
+	zDb := C.CString(opts.dbName())
+	defer C.free(unsafe.Pointer(zDb))
+	arg := &sqliteRecoverSQL{w: w}
+	c.recoverSQL = arg // keep a reference so that it is not gced
+	defer func() { c.recoverSQL = nil }()
+	err := c.runRecover(C.goSqlite3RecoverInitSql(c.db, zDb, unsafe.Pointer(arg)), opts)
+	if arg.err != nil {
+		return arg.err
+	}
+	return err
+}
+
+//export goXRecoverSql
+func goXRecoverSql(udp unsafe.Pointer, sql *C.char) C.int {
+	arg := (*sqliteRecoverSQL)(udp)
+	if _, arg.err = io.WriteString(arg.w, C.GoString(sql)+";\n"); arg.err != nil {
+		return C.SQLITE_ERROR
+	}
+	return C.SQLITE_OK
+}
+
+func (o RecoverOptions) dbName() string {
+	if len(o.DbName) == 0 {
+		return "main"
+	}
+	return o.DbName
+}
+
+func (c *Conn) runRecover(p *C.sqlite3_recover, opts RecoverOptions) error {
+	if p == nil {
+		return c.specificError("sqlite3_recover_init failed")
+	}
+	if len(opts.LostAndFound) > 0 {
+		zLost := C.CString(opts.LostAndFound)
+		defer C.free(unsafe.Pointer(zLost))
+		C.sqlite3_recover_config(p, C.SQLITE_RECOVER_LOST_AND_FOUND, unsafe.Pointer(zLost))
+	}
+	var on C.int = 1
+	var off C.int
+	if opts.FreelistCorrupt {
+		C.sqlite3_recover_config(p, C.SQLITE_RECOVER_FREELIST_CORRUPT, unsafe.Pointer(&on))
+	}
+	if opts.NoRowids {
+		C.sqlite3_recover_config(p, C.SQLITE_RECOVER_ROWIDS, unsafe.Pointer(&off))
+	}
+	if opts.SlowIndexes {
+		C.sqlite3_recover_config(p, C.SQLITE_RECOVER_SLOWINDEXES, unsafe.Pointer(&on))
+	}
+	c.enter()
+	defer c.leave()
+	C.sqlite3_recover_run(p)
+	var err error
+	if rv := C.sqlite3_recover_errcode(p); rv != C.SQLITE_OK {
+		err = &ConnError{c: c, code: Errno(rv), msg: C.GoString(C.sqlite3_recover_errmsg(p)), details: "Conn.Recover"}
+	}
+	C.sqlite3_recover_finish(p)
+	return err
+}
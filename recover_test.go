@@ -0,0 +1,45 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_recover
+// +build sqlite_recover
+
+package sqlite_test
+
+import (
+	"bytes"
+	. "github.com/gwenn/gosqlite"
+	"github.com/gwenn/gosqlite/sqlitetest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecoverSQL(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('recovered')"), "insert error: %s")
+
+	var buf bytes.Buffer
+	checkNoError(t, db.RecoverSQL(&buf, RecoverOptions{}), "recover error: %s")
+	sql := buf.String()
+	assert(t, "table creation expected", strings.Contains(sql, "CREATE TABLE"))
+	assert(t, "row insertion expected", strings.Contains(sql, "'recovered'"))
+}
+
+func TestRecoverTo(t *testing.T) {
+	db := sqlitetest.Open(t, sqlitetest.TempFile())
+	createTable(db, t)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('a'), ('b')"), "insert error: %s")
+
+	dst := filepath.Join(t.TempDir(), "recovered.db")
+	checkNoError(t, db.RecoverTo(dst, RecoverOptions{LostAndFound: "lost"}), "recover error: %s")
+	recovered, err := Open(dst)
+	checkNoError(t, err, "couldn't open recovered database: %s")
+	defer checkClose(recovered, t)
+	var count int
+	checkNoError(t, recovered.OneValue("SELECT count(*) FROM test", &count), "count error: %s")
+	assertEquals(t, "expected %d rows but got %d", 2, count)
+}
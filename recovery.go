@@ -0,0 +1,132 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// RecoveryHandler is called when a statement fails because the database is corrupted
+// (SQLITE_CORRUPT, SQLITE_NOTADB, SQLITE_IOERR_CORRUPTFS) or its file has been moved or deleted (SQLITE_READONLY_DBMOVED).
+// The connection is quarantined: it can only be used by the handler until the handler succeeds
+// (e.g. after restoring the database from a backup, see Conn.RestoreFrom) or Conn.Recover is called.
+// The handler is called when the failing call returns (after the statement has been reset),
+// not while the error is being built.
+// err is the error which triggered the recovery.
+type RecoveryHandler func(c *Conn, err error) error
+
+// QuarantinedError is returned by Conn.Prepare, Conn.Exec, Conn.Begin, Stmt.Exec, Stmt.Next, ...
+// while the connection is quarantined.
+type QuarantinedError struct {
+	Cause error // error which triggered the quarantine
+}
+
+func (e *QuarantinedError) Error() string {
+	return fmt.Sprintf("sqlite connection quarantined (%s)", e.Cause)
+}
+
+// Unwrap returns the error which triggered the quarantine.
+func (e *QuarantinedError) Unwrap() error {
+	return e.Cause
+}
+
+type recoveryState struct {
+	handler    RecoveryHandler
+	cause      error // not nil while quarantined
+	recovering bool
+	pending    error // corruption error whose handler call is deferred until the failing call returns
+	pendingExt int   // extended code of the pending error
+}
+
+// SetRecoveryHandler registers (or removes when f is nil) the handler called on corruption errors.
+// Without handler, these errors are only returned to the caller (no quarantine).
+func (c *Conn) SetRecoveryHandler(f RecoveryHandler) {
+	if f == nil {
+		c.recovery = nil
+		return
+	}
+	if c.recovery == nil {
+		c.recovery = &recoveryState{}
+	}
+	c.recovery.handler = f
+}
+
+// Quarantined returns the error which triggered the quarantine of the connection (nil if it is not quarantined).
+func (c *Conn) Quarantined() error {
+	if c.recovery == nil {
+		return nil
+	}
+	return c.recovery.cause
+}
+
+// Recover calls the recovery handler again and lifts the quarantine if it succeeds.
+func (c *Conn) Recover() error {
+	r := c.recovery
+	if r == nil || r.cause == nil {
+		return nil
+	}
+	return c.recover(r.cause)
+}
+
+func (c *Conn) recover(cause error) error {
+	r := c.recovery
+	r.cause = cause
+	r.recovering = true
+	err := r.handler(c, cause)
+	r.recovering = false
+	if err == nil {
+		r.cause = nil
+	}
+	return err
+}
+
+// quarantined returns a *QuarantinedError if the connection cannot be used.
+func (c *Conn) quarantined() error {
+	if r := c.recovery; r != nil && r.cause != nil && !r.recovering {
+		return &QuarantinedError{r.cause}
+	}
+	return nil
+}
+
+// checkCorruption quarantines the connection when err denotes a corruption.
+// The recovery handler is called when the outermost call returns (see Conn.leave)
+// or immediately if the error is built outside of a call.
+func (c *Conn) checkCorruption(err error, extCode int) {
+	r := c.recovery
+	if r == nil || r.recovering {
+		return
+	}
+	switch extCode & 0xff {
+	case C.SQLITE_CORRUPT, C.SQLITE_NOTADB:
+	default:
+		if extCode != C.SQLITE_IOERR_CORRUPTFS && extCode != C.SQLITE_READONLY_DBMOVED {
+			return
+		}
+	}
+	r.cause = err
+	r.pending, r.pendingExt = err, extCode
+	if atomic.LoadInt32(&c.depth) == 0 {
+		c.recoverPending()
+	}
+}
+
+// recoverPending calls the recovery handler for the pending corruption error (if any).
+func (c *Conn) recoverPending() {
+	r := c.recovery
+	if r == nil || r.pending == nil {
+		return
+	}
+	err, extCode := r.pending, r.pendingExt
+	r.pending = nil
+	if rerr := c.recover(err); rerr != nil {
+		Log(extCode, fmt.Sprintf("recovery failed: %s", rerr))
+	}
+}
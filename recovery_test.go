@@ -0,0 +1,66 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	"errors"
+	. "github.com/gwenn/gosqlite"
+	"github.com/gwenn/gosqlite/sqlitetest"
+	"io/ioutil"
+	"testing"
+)
+
+func TestRecoveryHandler(t *testing.T) {
+	db := sqlitetest.Open(t, sqlitetest.TempFile())
+	checkNoError(t, db.Exec("CREATE TABLE test (a INTEGER); INSERT INTO test VALUES (1)"), "create error: %s")
+	filename := db.Filename("main")
+	good, err := ioutil.ReadFile(filename)
+	checkNoError(t, err, "read error: %s")
+	garbage := append([]byte("this is not a database file...."), good[31:]...)
+	checkNoError(t, ioutil.WriteFile(filename, garbage, 0600), "write error: %s")
+
+	c, err := Open(filename, OpenReadWrite)
+	checkNoError(t, err, "couldn't open database: %s")
+	defer checkClose(c, t)
+	var causes []error
+	restore := false
+	c.SetRecoveryHandler(func(c *Conn, err error) error {
+		causes = append(causes, err)
+		if !restore {
+			return errors.New("no backup")
+		}
+		return ioutil.WriteFile(filename, good, 0600)
+	})
+
+	s, err := c.Prepare("SELECT 1")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+
+	var n int
+	err = c.OneValue("SELECT count(*) FROM test", &n)
+	assert(t, "error expected with corrupted file", err != nil)
+	assertEquals(t, "expected %d recovery but got %d", 1, len(causes))
+	assertEquals(t, "expected %v but got %v", ErrNotDB, causes[0].(*ConnError).Code())
+	assert(t, "quarantine expected", c.Quarantined() != nil)
+	err = c.OneValue("SELECT count(*) FROM test", &n)
+	if _, ok := err.(*QuarantinedError); !ok {
+		t.Errorf("expected QuarantinedError but got %#v", err)
+	}
+	_, err = s.Next()
+	if _, ok := err.(*QuarantinedError); !ok {
+		t.Errorf("expected QuarantinedError but got %#v", err)
+	}
+	err = c.Begin()
+	if _, ok := err.(*QuarantinedError); !ok {
+		t.Errorf("expected QuarantinedError but got %#v", err)
+	}
+	assertEquals(t, "expected %d recovery but got %d", 1, len(causes))
+
+	restore = true
+	checkNoError(t, c.Recover(), "recover error: %s")
+	assert(t, "no quarantine expected", c.Quarantined() == nil)
+	checkNoError(t, c.OneValue("SELECT count(*) FROM test", &n), "select error: %s")
+	assertEquals(t, "expected %d row but got %d", 1, n)
+}
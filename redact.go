@@ -0,0 +1,205 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unsafe"
+)
+
+// Redaction tells how the literal values are masked in the traced SQL (see Conn.SetRedaction).
+type Redaction int
+
+// Redaction modes
+const (
+	RedactNone        Redaction = iota // values are logged as is
+	RedactPlaceholder                  // values are replaced by ?
+	RedactHash                         // values are replaced by ?# followed by a hash of the value
+)
+
+// redactionKey makes the hashes of the values (see RedactHash) unpredictable:
+// they can only be correlated inside the same process.
+var redactionKey = func() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}()
+
+// SetRedaction sets how the values bound to the statements (and the literals) are masked
+// in the SQL passed to the tracer (see Conn.Trace) and returned by Stmt.ExpandedSQL,
+// so that SQL can be logged without leaking personal data.
+// The SQL passed to the profiler (see Conn.Profile) never contains the bound values.
+func (c *Conn) SetRedaction(r Redaction) {
+	c.redaction = r
+}
+
+// ExpandedSQL returns the SQL of the statement with the parameters replaced by their bound values,
+// masked according to the redaction mode of the connection (see Conn.SetRedaction).
+// (See http://sqlite.org/c3ref/expanded_sql.html)
+func (s *Stmt) ExpandedSQL() string {
+	zSQL := C.sqlite3_expanded_sql(s.stmt)
+	if zSQL == nil {
+		return ""
+	}
+	defer C.sqlite3_free(unsafe.Pointer(zSQL))
+	return RedactSQL(C.GoString(zSQL), s.c.redaction)
+}
+
+// RedactSQL masks the string, blob and numeric literals of sql:
+//
+//	RedactSQL("SELECT * FROM user WHERE email = 'bob@example.com'", RedactPlaceholder)
+//	// SELECT * FROM user WHERE email = ?
+//
+// Identifiers, comments, parameters and NULL are kept.
+func RedactSQL(sql string, r Redaction) string {
+	if r == RedactNone {
+		return sql
+	}
+	var b strings.Builder
+	b.Grow(len(sql))
+	scanSQL(sql, func(kind sqlToken, token string) {
+		if kind != literalToken {
+			b.WriteString(token)
+		} else if r == RedactHash {
+			h := hmac.New(sha256.New, redactionKey)
+			h.Write([]byte(token))
+			b.WriteString("?#")
+			b.WriteString(hex.EncodeToString(h.Sum(nil)[:4]))
+		} else {
+			b.WriteByte('?')
+		}
+	})
+	return b.String()
+}
+
+type sqlToken int
+
+const (
+	otherToken     sqlToken = iota // keywords, identifiers, comments, operators, spaces
+	literalToken                   // string, blob or numeric literal
+	parameterToken                 // ?, ?NNN, :AAA, @AAA or $AAA
+)
+
+// scanSQL splits sql into tokens, only distinguishing the literals and the parameters.
+// A unary minus is part of the numeric literal it precedes ("-3.14e-2" is one token).
+func scanSQL(sql string, f func(kind sqlToken, token string)) {
+	operand := false // whether the last significant token ends an operand (so that '-' is a binary operator)
+	for i := 0; i < len(sql); {
+		ch := sql[i]
+		var prev byte
+		if i > 0 {
+			prev = sql[i-1]
+		}
+		kind, j := otherToken, i+1
+		significant := true
+		switch {
+		case ch == '\'':
+			kind, j = literalToken, endOfQuoted(sql, i, '\'')
+		case (ch == 'x' || ch == 'X') && j < len(sql) && sql[j] == '\'' && !isIdentChar(prev):
+			kind, j = literalToken, endOfQuoted(sql, j, '\'')
+		case ch == '"' || ch == '`':
+			j = endOfQuoted(sql, i, ch)
+		case ch == '[':
+			if j = strings.IndexByte(sql[i:], ']'); j < 0 {
+				j = len(sql)
+			} else {
+				j += i + 1
+			}
+		case ch == '-' && strings.HasPrefix(sql[i:], "--"):
+			significant = false
+			if j = strings.IndexByte(sql[i:], '\n'); j < 0 {
+				j = len(sql)
+			} else {
+				j += i
+			}
+		case ch == '/' && strings.HasPrefix(sql[i:], "/*"):
+			significant = false
+			if j = strings.Index(sql[i+2:], "*/"); j < 0 {
+				j = len(sql)
+			} else {
+				j += i + 4
+			}
+		case ch == '?' || ch == ':' || ch == '@' || ch == '$':
+			kind = parameterToken
+			for j < len(sql) && isIdentChar(sql[j]) {
+				j++
+			}
+		case ch == '-' && !operand && isNumberStart(sql, j):
+			kind, j = literalToken, endOfNumber(sql, j)
+		case isNumberStart(sql, i) && !isIdentChar(prev):
+			kind, j = literalToken, endOfNumber(sql, i)
+		case isIdentChar(ch):
+			for j < len(sql) && isIdentChar(sql[j]) {
+				j++
+			}
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f':
+			significant = false
+		}
+		token := sql[i:j]
+		if significant {
+			operand = kind != otherToken || ch == ')' || ch == '"' || ch == '`' || ch == '[' ||
+				isIdentChar(ch) && !exprKeywords[strings.ToUpper(token)]
+		}
+		f(kind, token)
+		i = j
+	}
+}
+
+// exprKeywords are the keywords which may be followed by an expression (and so by a unary minus).
+var exprKeywords = map[string]bool{
+	"ALL": true, "AND": true, "BETWEEN": true, "BY": true, "CASE": true, "DEFAULT": true, "DISTINCT": true,
+	"ELSE": true, "GLOB": true, "HAVING": true, "IN": true, "IS": true, "LIKE": true, "LIMIT": true, "NOT": true,
+	"OFFSET": true, "ON": true, "OR": true, "RETURNING": true, "SELECT": true, "SET": true, "THEN": true,
+	"WHEN": true, "WHERE": true,
+}
+
+// isNumberStart reports whether a numeric literal starts at i.
+func isNumberStart(sql string, i int) bool {
+	return i < len(sql) && (isDigit(sql[i]) || sql[i] == '.' && i+1 < len(sql) && isDigit(sql[i+1]))
+}
+
+// endOfNumber returns the index following the numeric literal starting at i.
+func endOfNumber(sql string, i int) int {
+	j := i
+	hex := strings.HasPrefix(sql[i:], "0x") || strings.HasPrefix(sql[i:], "0X")
+	for j < len(sql) && (isIdentChar(sql[j]) || sql[j] == '.') {
+		if !hex && (sql[j] == 'e' || sql[j] == 'E') && j+1 < len(sql) && (sql[j+1] == '+' || sql[j+1] == '-') {
+			j++
+		}
+		j++
+	}
+	return j
+}
+
+// endOfQuoted returns the index following the quoted token starting at i (doubled quotes are escaped).
+func endOfQuoted(sql string, i int, quote byte) int {
+	for j := i + 1; j < len(sql); j++ {
+		if sql[j] == quote {
+			if j+1 < len(sql) && sql[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(sql)
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdentChar(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || isDigit(ch) || ch == '_' || ch == '$' || ch >= 0x80
+}
@@ -0,0 +1,134 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_session
+// +build sqlite_session
+
+package sqlite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Replicator records the changes made on a primary connection
+// and ships them, as length-prefixed changesets, to replicas (see ApplyChangesets).
+// The transport (file, pipe, network) is provided by the caller.
+// Only available with the sqlite_session build tag.
+type Replicator struct {
+	primary *Conn
+	tables  []string
+	session *Session
+}
+
+// NewReplicator starts recording the changes made through primary
+// to the specified tables of the "main" database (all the tables when none is specified).
+func NewReplicator(primary *Conn, tables ...string) (*Replicator, error) {
+	r := &Replicator{primary: primary, tables: tables}
+	if err := r.start(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Replicator) start() error {
+	s, err := r.primary.CreateSession("main")
+	if err != nil {
+		return err
+	}
+	if len(r.tables) == 0 {
+		err = s.Attach("")
+	}
+	for _, table := range r.tables {
+		if err = s.Attach(table); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		s.Delete()
+		return err
+	}
+	r.session = s
+	return nil
+}
+
+// Ship writes to w the changes recorded since the last call (nothing when there is none)
+// and returns the size of the changeset.
+// The recording restarts only once the changeset has been written:
+// when w fails, the changes are kept and shipped again by the next call.
+func (r *Replicator) Ship(w io.Writer) (int, error) {
+	if r.session.IsEmpty() {
+		return 0, nil
+	}
+	changeset, err := r.session.Changeset()
+	if err != nil {
+		return 0, err
+	}
+	frame := make([]byte, 4+len(changeset))
+	binary.BigEndian.PutUint32(frame, uint32(len(changeset)))
+	copy(frame[4:], changeset)
+	if _, err = w.Write(frame); err != nil {
+		return 0, err
+	}
+	r.session.Delete()
+	if err = r.start(); err != nil {
+		return 0, err
+	}
+	return len(changeset), nil
+}
+
+// Close stops recording.
+// It must be called before the primary connection is closed.
+func (r *Replicator) Close() {
+	if r.session != nil {
+		r.session.Delete()
+		r.session = nil
+	}
+}
+
+// ApplyChangesets applies on replica the changesets read from r (as written by Replicator.Ship)
+// until EOF and returns the number of changesets applied.
+// Each changeset is applied in its own transaction;
+// the handler resolves the conflicts (see PrimaryWins and ReplicaWins).
+func ApplyChangesets(replica *Conn, r io.Reader, handler ConflictHandler) (int, error) {
+	var n int
+	var size [4]byte
+	for {
+		if _, err := io.ReadFull(r, size[:]); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		changeset := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, changeset); err != nil {
+			return n, fmt.Errorf("truncated changeset: %s", err)
+		}
+		if err := replica.ApplyChangeset(changeset, handler); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// PrimaryWins is a ConflictHandler which overwrites the conflicting rows of the replica
+// and skips the changes to rows missing from the replica.
+func PrimaryWins(d *Conflict) ConflictAction {
+	switch d.Type {
+	case ChangesetData, ChangesetConflict:
+		return ChangesetReplace
+	case ChangesetNotFound:
+		return ChangesetOmit
+	}
+	return ChangesetAbort
+}
+
+// ReplicaWins is a ConflictHandler which keeps the rows of the replica.
+func ReplicaWins(d *Conflict) ConflictAction {
+	switch d.Type {
+	case ChangesetData, ChangesetConflict, ChangesetNotFound:
+		return ChangesetOmit
+	}
+	return ChangesetAbort
+}
@@ -0,0 +1,122 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_session
+// +build sqlite_session
+
+package sqlite_test
+
+import (
+	"bytes"
+	"errors"
+	. "github.com/gwenn/gosqlite"
+	"testing"
+)
+
+const replicatedTable = "CREATE TABLE item (id INTEGER PRIMARY KEY, name TEXT)"
+
+func TestReplicator(t *testing.T) {
+	primary := open(t)
+	defer checkClose(primary, t)
+	replica := open(t)
+	defer checkClose(replica, t)
+	checkNoError(t, primary.Exec(replicatedTable), "create error: %s")
+	checkNoError(t, replica.Exec(replicatedTable), "create error: %s")
+
+	r, err := NewReplicator(primary, "item")
+	checkNoError(t, err, "couldn't create replicator: %s")
+	defer r.Close()
+	var buf bytes.Buffer
+	n, err := r.Ship(&buf)
+	checkNoError(t, err, "ship error: %s")
+	assertEquals(t, "expected %d but got %d", 0, n)
+
+	checkNoError(t, primary.Exec("INSERT INTO item VALUES (1, 'a'), (2, 'b')"), "insert error: %s")
+	_, err = r.Ship(&buf)
+	checkNoError(t, err, "ship error: %s")
+	checkNoError(t, primary.Exec("UPDATE item SET name = 'B' WHERE id = 2; DELETE FROM item WHERE id = 1"), "update error: %s")
+	_, err = r.Ship(&buf)
+	checkNoError(t, err, "ship error: %s")
+
+	applied, err := ApplyChangesets(replica, &buf, nil)
+	checkNoError(t, err, "apply error: %s")
+	assertEquals(t, "expected %d changesets but got %d", 2, applied)
+	var name string
+	checkNoError(t, replica.OneValue("SELECT group_concat(name) FROM item", &name), "select error: %s")
+	assertEquals(t, "expected %q but got %q", "B", name)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestReplicatorShipFailure(t *testing.T) {
+	primary := open(t)
+	defer checkClose(primary, t)
+	replica := open(t)
+	defer checkClose(replica, t)
+	checkNoError(t, primary.Exec(replicatedTable), "create error: %s")
+	checkNoError(t, replica.Exec(replicatedTable), "create error: %s")
+
+	r, err := NewReplicator(primary)
+	checkNoError(t, err, "couldn't create replicator: %s")
+	defer r.Close()
+	checkNoError(t, primary.Exec("INSERT INTO item VALUES (1, 'a')"), "insert error: %s")
+	_, err = r.Ship(failingWriter{})
+	assert(t, "write error expected", err != nil)
+
+	var buf bytes.Buffer
+	n, err := r.Ship(&buf)
+	checkNoError(t, err, "ship error: %s")
+	assert(t, "changes expected to be shipped again", n > 0)
+	_, err = ApplyChangesets(replica, &buf, nil)
+	checkNoError(t, err, "apply error: %s")
+	var name string
+	checkNoError(t, replica.OneValue("SELECT name FROM item WHERE id = 1", &name), "select error: %s")
+	assertEquals(t, "expected %q but got %q", "a", name)
+}
+
+func TestReplicatorConflict(t *testing.T) {
+	primary := open(t)
+	defer checkClose(primary, t)
+	replica := open(t)
+	defer checkClose(replica, t)
+	checkNoError(t, primary.Exec(replicatedTable), "create error: %s")
+	checkNoError(t, replica.Exec(replicatedTable), "create error: %s")
+	checkNoError(t, replica.Exec("INSERT INTO item VALUES (1, 'replica')"), "insert error: %s")
+
+	r, err := NewReplicator(primary)
+	checkNoError(t, err, "couldn't create replicator: %s")
+	defer r.Close()
+	checkNoError(t, primary.Exec("INSERT INTO item VALUES (1, 'primary')"), "insert error: %s")
+	var buf bytes.Buffer
+	_, err = r.Ship(&buf)
+	checkNoError(t, err, "ship error: %s")
+	changeset := buf.Bytes()
+
+	_, err = ApplyChangesets(replica, bytes.NewReader(changeset), nil)
+	assert(t, "conflict expected", err != nil)
+
+	var conflicts []*Conflict
+	var current interface{}
+	_, err = ApplyChangesets(replica, bytes.NewReader(changeset), func(d *Conflict) ConflictAction {
+		conflicts = append(conflicts, d)
+		current, _ = d.Current(1)
+		return ReplicaWins(d)
+	})
+	checkNoError(t, err, "apply error: %s")
+	assertEquals(t, "expected %d conflicts but got %d", 1, len(conflicts))
+	assertEquals(t, "expected %v but got %v", ChangesetConflict, conflicts[0].Type)
+	assertEquals(t, "expected %q but got %q", "item", conflicts[0].Table)
+	assertEquals(t, "expected %v but got %v", Insert, conflicts[0].Op)
+	assertEquals(t, "expected %q but got %q", "replica", current)
+
+	_, err = ApplyChangesets(replica, bytes.NewReader(changeset), PrimaryWins)
+	checkNoError(t, err, "apply error: %s")
+	var name string
+	checkNoError(t, replica.OneValue("SELECT name FROM item WHERE id = 1", &name), "select error: %s")
+	assertEquals(t, "expected %q but got %q", "primary", name)
+}
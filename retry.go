@@ -0,0 +1,121 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy tells how statements failing with a transient error are retried (see Conn.SetRetryPolicy).
+// Unlike a busy handler, it also applies to SQLITE_LOCKED (shared cache or same connection conflicts).
+type RetryPolicy struct {
+	MaxAttempts    int           // total number of attempts (<= 1 disables retries)
+	BaseDelay      time.Duration // delay before the first retry, doubled for each following one (default 1ms)
+	Jitter         float64       // fraction of each delay randomly added or removed (between 0 and 1)
+	RetryableCodes []Errno       // primary result codes retried (default ErrBusy and ErrLocked)
+}
+
+func (p *RetryPolicy) retryable(rv C.int) bool {
+	code := Errno(rv & 0xff)
+	if len(p.RetryableCodes) == 0 {
+		return code == ErrBusy || code == ErrLocked
+	}
+	for _, c := range p.RetryableCodes {
+		if c&0xff == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) delay(retry int) time.Duration {
+	d := p.BaseDelay
+	if d <= 0 {
+		d = time.Millisecond
+	}
+	d <<= uint(retry)
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// SetRetryPolicy makes statements failing with a transient error (SQLITE_BUSY/SQLITE_LOCKED by default)
+// be retried transparently by Prepare, Exec, Next and the other step wrappers.
+// Only statements which have not yet returned a row are retried,
+// and only outside explicit transactions (except COMMIT) as advised by SQLite.
+// A policy with MaxAttempts <= 1 disables retries.
+// (See http://sqlite.org/c3ref/step.html)
+func (c *Conn) SetRetryPolicy(p RetryPolicy) {
+	if p.MaxAttempts <= 1 {
+		c.retryPolicy = nil
+		return
+	}
+	c.retryPolicy = &p
+}
+
+// RetryCount returns the number of statement retries done on this connection (see Conn.SetRetryPolicy).
+func (c *Conn) RetryCount() int64 {
+	return atomic.LoadInt64(&c.nRetries)
+}
+
+// step calls sqlite3_step, retrying transient errors according to the retry policy of the connection.
+func (s *Stmt) step() C.int {
+	return s.retry(func() C.int {
+		return C.sqlite3_step(s.stmt)
+	})
+}
+
+func (s *Stmt) retry(step func() C.int) (rv C.int) {
+	defer func() {
+		s.checkSchema(rv)
+		s.afterSavepoint(rv == C.SQLITE_DONE)
+		s.c.afterStep()
+	}()
+	busy := C.sqlite3_stmt_busy(s.stmt) != 0
+	if !busy && s.c.scanReport != nil {
+		s.executions++
+	}
+	if s.c.retryPolicy == nil || busy {
+		return step()
+	}
+	return s.c.retry(step, s.retryable)
+}
+
+// retry calls f until it succeeds or fails with a non-retryable error or the policy is exhausted.
+func (c *Conn) retry(f func() C.int, retryable func() bool) C.int {
+	p := c.retryPolicy
+	rv := f()
+	for retry := 0; p != nil && retry+1 < p.MaxAttempts && p.retryable(rv) && retryable(); retry++ {
+		time.Sleep(p.delay(retry))
+		atomic.AddInt64(&c.nRetries, 1)
+		rv = f()
+	}
+	return rv
+}
+
+// autocommit tells if the connection is outside of an explicit transaction
+// (where transient errors can be retried).
+func (c *Conn) autocommit() bool {
+	return C.sqlite3_get_autocommit(c.db) != 0
+}
+
+// retryable tells if the statement can be retried after SQLITE_BUSY:
+// outside of an explicit transaction or when it is a COMMIT.
+func (s *Stmt) retryable() bool {
+	if s.c.autocommit() {
+		return true
+	}
+	sql := strings.ToUpper(strings.TrimSpace(s.SQL()))
+	return strings.HasPrefix(sql, "COMMIT") || strings.HasPrefix(sql, "END")
+}
@@ -0,0 +1,162 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"strings"
+)
+
+// savepointOp is the kind of a savepoint statement.
+type savepointOp int
+
+const (
+	savepointBegin      savepointOp = iota + 1 // SAVEPOINT name
+	savepointRelease                           // RELEASE [SAVEPOINT] name
+	savepointRollbackTo                        // ROLLBACK [TRANSACTION] TO [SAVEPOINT] name
+)
+
+// sqliteSavepointHook is invoked after each savepoint statement successfully executed (see Conn.afterSavepoint).
+// There is no such hook in SQLite: ROLLBACK TO does not invoke the rollback hook.
+type sqliteSavepointHook struct {
+	f     func(op savepointOp, name string)
+	token HookToken
+}
+
+// addSavepointHook adds a callback to be invoked after each SAVEPOINT, RELEASE or ROLLBACK TO statement
+// executed through this connection (Conn.Savepoint, Conn.Exec("SAVEPOINT a"), ...).
+// Returns a token to be used with Conn.RemoveHook.
+func (c *Conn) addSavepointHook(f func(op savepointOp, name string)) HookToken {
+	chain := c.initHooks()
+	chain.lastToken++
+	hooks := make([]*sqliteSavepointHook, len(chain.savepoint), len(chain.savepoint)+1)
+	copy(hooks, chain.savepoint)
+	chain.savepoint = append(hooks, &sqliteSavepointHook{f, chain.lastToken})
+	return chain.lastToken
+}
+
+// afterSavepoint invokes the savepoint hooks if s is a savepoint statement which has just been executed.
+func (s *Stmt) afterSavepoint(done bool) {
+	chain := s.c.hooks
+	if !done || chain == nil || len(chain.savepoint) == 0 {
+		return
+	}
+	op, name := parseSavepoint(s.SQL())
+	if op == 0 {
+		return
+	}
+	for _, h := range chain.savepoint {
+		h.f(op, name)
+	}
+}
+
+// parseSavepoint returns the kind and the (unquoted) name of a savepoint statement, 0 otherwise.
+func parseSavepoint(sql string) (savepointOp, string) {
+	var words []string
+	for i := 0; i < len(sql) && len(words) < 5; {
+		ch := sql[i]
+		j := i + 1
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f':
+		case ch == '-' && strings.HasPrefix(sql[i:], "--"):
+			if j = strings.IndexByte(sql[i:], '\n'); j < 0 {
+				j = len(sql)
+			} else {
+				j += i
+			}
+		case ch == '/' && strings.HasPrefix(sql[i:], "/*"):
+			if j = strings.Index(sql[i+2:], "*/"); j < 0 {
+				j = len(sql)
+			} else {
+				j += i + 4
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			j = endOfQuoted(sql, i, ch)
+			words = append(words, strings.Replace(strings.Trim(sql[i:j], string(ch)), string([]byte{ch, ch}), string(ch), -1))
+		case ch == '[':
+			if j = strings.IndexByte(sql[i:], ']'); j < 0 {
+				j = len(sql)
+			} else {
+				j += i + 1
+			}
+			words = append(words, strings.TrimSuffix(sql[i+1:j], "]"))
+		case isIdentChar(ch):
+			for j < len(sql) && isIdentChar(sql[j]) {
+				j++
+			}
+			words = append(words, sql[i:j])
+		default:
+			words = append(words, sql[i:j]) // ';' ends the statement
+		}
+		i = j
+	}
+	keyword := func(i int, kw string) bool {
+		return i < len(words) && strings.EqualFold(words[i], kw)
+	}
+	var op savepointOp
+	i := 1
+	switch {
+	case keyword(0, "SAVEPOINT"):
+		op = savepointBegin
+	case keyword(0, "RELEASE"):
+		op = savepointRelease
+		if keyword(i, "SAVEPOINT") {
+			i++
+		}
+	case keyword(0, "ROLLBACK"):
+		if keyword(i, "TRANSACTION") {
+			i++
+		}
+		if !keyword(i, "TO") {
+			return 0, ""
+		}
+		op = savepointRollbackTo
+		if i++; keyword(i, "SAVEPOINT") {
+			i++
+		}
+	default:
+		return 0, ""
+	}
+	if i >= len(words) {
+		return 0, ""
+	}
+	return op, words[i]
+}
+
+// savepointMarks records the length of a buffer of changes at each savepoint of the current transaction
+// so that the changes undone by ROLLBACK TO can be dropped.
+type savepointMarks struct {
+	names []string
+	lens  []int
+}
+
+// apply updates the savepoints stack and returns the length of the buffer (n before) once op is applied.
+func (m *savepointMarks) apply(op savepointOp, name string, n int) int {
+	if op == savepointBegin {
+		m.names = append(m.names, name)
+		m.lens = append(m.lens, n)
+		return n
+	}
+	i := len(m.names) - 1
+	for i >= 0 && !strings.EqualFold(m.names[i], name) {
+		i--
+	}
+	if i < 0 {
+		return n
+	}
+	if op == savepointRelease {
+		m.names, m.lens = m.names[:i], m.lens[:i]
+		return n
+	}
+	m.names, m.lens = m.names[:i+1], m.lens[:i+1] // the savepoint itself is kept by ROLLBACK TO
+	if m.lens[i] < n {
+		return m.lens[i]
+	}
+	return n
+}
+
+// reset is called when the transaction ends.
+func (m *savepointMarks) reset() {
+	m.names, m.lens = nil, nil
+}
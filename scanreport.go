@@ -0,0 +1,98 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"sort"
+	"sync"
+)
+
+// ScanReportEntry reports the costly operations done by the executions of a statement
+// (see Conn.ScanReport).
+type ScanReportEntry struct {
+	SQL           string
+	Executions    int   // number of executions (first steps) of the statement
+	FullScanSteps int64 // see StmtStatusFullScanStep
+	Sorts         int64 // see StmtStatusSort
+	AutoIndexes   int64 // see StmtStatusAutoIndex
+}
+
+type scanReport struct {
+	mu      sync.Mutex
+	entries map[string]*ScanReportEntry
+}
+
+// SetScanReport enables or disables (and clears) the collection of the full scan, sort and automatic index
+// counters of the statements executed on c: they are collected when a statement
+// is finalized or returned to the statement cache (see Conn.SetCacheSize).
+func (c *Conn) SetScanReport(on bool) {
+	if !on {
+		c.scanReport = nil
+	} else if c.scanReport == nil {
+		c.scanReport = &scanReport{entries: make(map[string]*ScanReportEntry)}
+	}
+}
+
+// ScanReport returns the statements that full-scanned a table, sorted or built an automatic index
+// since the collection has been enabled (see SetScanReport), to guide index creation.
+// Statements which built automatic indexes come first, then the ones with the most full scan steps.
+func (c *Conn) ScanReport() []ScanReportEntry {
+	r := c.scanReport
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	entries := make([]ScanReportEntry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, *e)
+	}
+	r.mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].AutoIndexes != entries[j].AutoIndexes {
+			return entries[i].AutoIndexes > entries[j].AutoIndexes
+		} else if entries[i].FullScanSteps != entries[j].FullScanSteps {
+			return entries[i].FullScanSteps > entries[j].FullScanSteps
+		}
+		return entries[i].Sorts > entries[j].Sorts
+	})
+	return entries
+}
+
+// ResetScanReport clears the collected counters.
+func (c *Conn) ResetScanReport() {
+	if r := c.scanReport; r != nil {
+		r.mu.Lock()
+		r.entries = make(map[string]*ScanReportEntry)
+		r.mu.Unlock()
+	}
+}
+
+// collectScanStats adds (and resets) the status counters of s to the scan report (if enabled).
+func (s *Stmt) collectScanStats() {
+	r := s.c.scanReport
+	if r == nil || s.stmt == nil {
+		return
+	}
+	fullScanSteps := s.Status(StmtStatusFullScanStep, true)
+	sorts := s.Status(StmtStatusSort, true)
+	autoIndexes := s.Status(StmtStatusAutoIndex, true)
+	executions := s.executions
+	s.executions = 0
+	if fullScanSteps == 0 && sorts == 0 && autoIndexes == 0 {
+		return
+	}
+	sql := s.SQL()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[sql]
+	if !ok {
+		e = &ScanReportEntry{SQL: sql}
+		r.entries[sql] = e
+	}
+	e.Executions += executions
+	e.FullScanSteps += int64(fullScanSteps)
+	e.Sorts += int64(sorts)
+	e.AutoIndexes += int64(autoIndexes)
+}
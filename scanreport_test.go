@@ -0,0 +1,46 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"testing"
+)
+
+func TestScanReport(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE a (id INTEGER PRIMARY KEY, b_id INTEGER, name TEXT); CREATE TABLE b (id INTEGER, name TEXT)"), "create error: %s")
+	checkNoError(t, db.Exec("INSERT INTO a (b_id, name) VALUES (1, 'x'), (2, 'y'), (3, 'z'); INSERT INTO b VALUES (1, 'x'), (2, 'y'), (3, 'z')"), "insert error: %s")
+	assert(t, "no report expected when disabled", db.ScanReport() == nil)
+
+	db.SetScanReport(true)
+	var n int
+	for i := 0; i < 2; i++ {
+		checkNoError(t, db.OneValue("SELECT count(*) FROM a WHERE name = ?", &n, "x"), "select error: %s")
+	}
+	checkNoError(t, db.OneValue("SELECT name FROM a WHERE id = ?", new(string), 1), "select error: %s")
+	s, err := db.Prepare("SELECT a.name FROM a JOIN b ON a.b_id = b.id ORDER BY b.name")
+	checkNoError(t, err, "prepare error: %s")
+	for i := 0; i < 3; i++ {
+		checkNoError(t, s.Select(func(s *Stmt) error { return nil }), "select error: %s")
+	}
+	checkFinalize(s, t)
+
+	report := db.ScanReport()
+	assertEquals(t, "expected %d entries but got %d", 2, len(report))
+	assertEquals(t, "expected %q but got %q", "SELECT a.name FROM a JOIN b ON a.b_id = b.id ORDER BY b.name", report[0].SQL)
+	assert(t, "automatic index expected", report[0].AutoIndexes > 0)
+	assert(t, "sort expected", report[0].Sorts > 0)
+	assertEquals(t, "expected %d executions but got %d", 3, report[0].Executions)
+	assertEquals(t, "expected %q but got %q", "SELECT count(*) FROM a WHERE name = ?", report[1].SQL)
+	assertEquals(t, "expected %d executions but got %d", 2, report[1].Executions)
+	assertEquals(t, "expected %d full scan steps but got %d", int64(4), report[1].FullScanSteps)
+
+	db.ResetScanReport()
+	assertEquals(t, "expected %d entries but got %d", 0, len(db.ScanReport()))
+	db.SetScanReport(false)
+	assert(t, "no report expected when disabled", db.ScanReport() == nil)
+}
@@ -0,0 +1,51 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+// SetCacheSchemaCheck enables or disables the check of the main database schema version (PRAGMA schema_version)
+// before looking in the statement cache (see Conn.Prepare): the cache is flushed when the schema has changed
+// (after a migration run by another connection for example), so that cached statements are prepared again
+// against the new schema. The check costs one PRAGMA per Conn.Prepare.
+// Even without this check, the cache is flushed when a statement fails with SQLITE_SCHEMA
+// and the cached column metadata of a statement is refreshed when SQLite has automatically re-prepared it.
+func (c *Conn) SetCacheSchemaCheck(on bool) {
+	c.stmtCache.checkSchema = on
+	c.stmtCache.schemaVersion = -1
+}
+
+// checkSchemaVersion flushes the statement cache if the main database schema version has changed.
+func (c *Conn) checkSchemaVersion() {
+	var version int
+	if err := c.oneValue("PRAGMA main.schema_version", &version); err != nil {
+		return
+	}
+	if version != c.stmtCache.schemaVersion {
+		c.stmtCache.flush()
+		c.stmtCache.schemaVersion = version
+		// the schema is reloaded when a statement reading it is stepped
+		c.exec("SELECT 1 FROM main.sqlite_master LIMIT 0")
+	}
+}
+
+// checkSchema is called after each step: the cached column metadata are cleared
+// when the statement has been re-prepared by SQLite and the statement cache is flushed on SQLITE_SCHEMA.
+func (s *Stmt) checkSchema(rv C.int) {
+	if n := int(C.sqlite3_stmt_status(s.stmt, C.SQLITE_STMTSTATUS_REPREPARE, 0)); n != s.reprepares {
+		s.reprepares = n
+		s.columnCount = -1
+		s.cols = nil
+		s.declKinds = nil
+		s.row = nil
+		s.rowValues = nil
+	}
+	if rv&0xff == C.SQLITE_SCHEMA {
+		s.c.stmtCache.flush()
+	}
+}
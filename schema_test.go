@@ -0,0 +1,63 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"github.com/gwenn/gosqlite/sqlitetest"
+	"testing"
+)
+
+func TestStaleCachedStmt(t *testing.T) {
+	db := sqlitetest.Open(t, sqlitetest.TempFile())
+	checkNoError(t, db.Exec("CREATE TABLE migrated (a INTEGER); INSERT INTO migrated VALUES (1)"), "create error: %s")
+	other, err := Open(db.Filename("main"), OpenReadWrite)
+	checkNoError(t, err, "couldn't open database: %s")
+	defer checkClose(other, t)
+
+	s, err := db.Prepare("SELECT * FROM migrated")
+	checkNoError(t, err, "prepare error: %s")
+	assertEquals(t, "expected %d columns but got %d", 1, s.ColumnCount())
+	checkFinalize(s, t) // cached
+
+	checkNoError(t, other.Exec("ALTER TABLE migrated ADD COLUMN b INTEGER DEFAULT 2"), "alter error: %s")
+
+	// re-prepared by SQLite on the first step
+	s, err = db.Prepare("SELECT * FROM migrated")
+	checkNoError(t, err, "prepare error: %s")
+	row, err := s.NextRow()
+	checkNoError(t, err, "step error: %s")
+	assert(t, "row expected", row != nil)
+	assertEquals(t, "expected %d columns but got %d", 2, s.ColumnCount())
+	var a, b int
+	checkNoError(t, s.Scan(&a, &b), "scan error: %s")
+	assertEquals(t, "expected %d but got %d", 2, b)
+	checkFinalize(s, t)
+}
+
+func TestCacheSchemaCheck(t *testing.T) {
+	db := sqlitetest.Open(t, sqlitetest.TempFile())
+	checkNoError(t, db.Exec("CREATE TABLE migrated (a INTEGER); INSERT INTO migrated VALUES (1)"), "create error: %s")
+	other, err := Open(db.Filename("main"), OpenReadWrite)
+	checkNoError(t, err, "couldn't open database: %s")
+	defer checkClose(other, t)
+	db.SetCacheSchemaCheck(true)
+
+	for i := 0; i < 2; i++ {
+		s, err := db.Prepare("SELECT * FROM migrated")
+		checkNoError(t, err, "prepare error: %s")
+		checkFinalize(s, t)
+	}
+	hits, _ := db.CacheStats()
+	assertEquals(t, "expected %d hit but got %d", 1, hits)
+
+	checkNoError(t, other.Exec("ALTER TABLE migrated ADD COLUMN b INTEGER DEFAULT 2"), "alter error: %s")
+	s, err := db.Prepare("SELECT * FROM migrated")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	assertEquals(t, "expected %d columns but got %d", 2, s.ColumnCount())
+	hits, _ = db.CacheStats()
+	assertEquals(t, "expected %d hit but got %d", 1, hits)
+}
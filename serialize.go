@@ -0,0 +1,152 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"unsafe"
+)
+
+// Serialize returns a copy of the content of the specified database, as it would be stored on disk.
+// (See http://sqlite.org/c3ref/serialize.html)
+func (c *Conn) Serialize(dbName string) ([]byte, error) {
+	zSchema := C.CString(dbName)
+	defer C.free(unsafe.Pointer(zSchema))
+	var size C.sqlite3_int64
+	p := C.sqlite3_serialize(c.db, zSchema, &size, 0)
+	if p == nil {
+		if size == 0 {
+			return nil, c.specificError("cannot serialize %q", dbName)
+		}
+		return nil, ErrNoMem
+	}
+	defer C.sqlite3_free(unsafe.Pointer(p))
+	if int64(size) > math.MaxInt {
+		return nil, c.specificError("cannot serialize %q: %d bytes exceed the address space", dbName, int64(size))
+	}
+	// C.GoBytes would truncate the size to a C int (2 GiB).
+	data := make([]byte, int(size))
+	copy(data, unsafe.Slice((*byte)(unsafe.Pointer(p)), int(size)))
+	return data, nil
+}
+
+// Deserialize replaces the content of the specified database by data
+// (the database becomes an in-memory database, writable unless readOnly is true).
+// (See http://sqlite.org/c3ref/deserialize.html)
+func (c *Conn) Deserialize(dbName string, data []byte, readOnly bool) error {
+	p := (*C.uchar)(C.sqlite3_malloc64(C.sqlite3_uint64(len(data))))
+	if p == nil && len(data) > 0 {
+		return ErrNoMem
+	}
+	if len(data) > 0 {
+		copy(unsafe.Slice((*byte)(unsafe.Pointer(p)), len(data)), data)
+	}
+	return c.deserialize(dbName, p, int64(len(data)), readOnly)
+}
+
+// deserialize takes ownership of p (allocated with sqlite3_malloc64).
+func (c *Conn) deserialize(dbName string, p *C.uchar, n int64, readOnly bool) error {
+	zSchema := C.CString(dbName)
+	defer C.free(unsafe.Pointer(zSchema))
+	flags := C.uint(C.SQLITE_DESERIALIZE_FREEONCLOSE)
+	if readOnly {
+		flags |= C.SQLITE_DESERIALIZE_READONLY
+	} else {
+		flags |= C.SQLITE_DESERIALIZE_RESIZEABLE
+	}
+	rv := C.sqlite3_deserialize(c.db, zSchema, p, C.sqlite3_int64(n), C.sqlite3_int64(n), flags)
+	if rv != C.SQLITE_OK {
+		return c.error(rv, "Conn.Deserialize")
+	}
+	return nil
+}
+
+// BackupTo streams a consistent snapshot of the specified database to w, page by page,
+// without the need for a destination file (to upload a backup to a remote storage for example).
+// The snapshot is first copied into a temporary file with the online backup API
+// so that the source database is not locked while w is being written
+// (only one page is held in memory at a time).
+func (c *Conn) BackupTo(w io.Writer, dbName string) (int64, error) {
+	f, err := ioutil.TempFile("", "gosqlite-backup")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	snapshot, err := Open(f.Name(), OpenReadWrite, OpenNoMutex)
+	if err != nil {
+		return 0, err
+	}
+	bck, err := NewBackup(snapshot, "main", c, dbName)
+	if err != nil {
+		snapshot.Close()
+		return 0, err
+	}
+	if err = bck.Run(-1, 0, nil); err != nil {
+		snapshot.Close()
+		return 0, err
+	}
+	var pageSize int
+	if err = snapshot.OneValue("PRAGMA page_size", &pageSize); err != nil {
+		snapshot.Close()
+		return 0, err
+	}
+	if err = snapshot.Close(); err != nil {
+		return 0, err
+	}
+	page := make([]byte, pageSize)
+	var written int64
+	for {
+		n, err := io.ReadFull(f, page)
+		if n > 0 {
+			if _, werr := w.Write(page[:n]); werr != nil {
+				return written, werr
+			}
+			written += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return written, nil
+		} else if err != nil {
+			return written, err
+		}
+	}
+}
+
+// RestoreFrom replaces the content of the specified database by the database read from r
+// (as written by BackupTo), with the online backup API.
+// The database is first copied from r into a temporary file (not into memory).
+func (c *Conn) RestoreFrom(r io.Reader, dbName string) error {
+	f, err := ioutil.TempFile("", "gosqlite-restore")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	src, err := Open(f.Name(), OpenReadOnly, OpenNoMutex)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	bck, err := NewBackup(c, dbName, src, "main")
+	if err != nil {
+		return err
+	}
+	return bck.Run(-1, 0, nil)
+}
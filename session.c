@@ -0,0 +1,14 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_session
+// +build sqlite_session
+
+#include <sqlite3.h>
+
+extern int goXChangesetConflict(void *udp, int eConflict, sqlite3_changeset_iter *iter);
+
+int goSqlite3ChangesetApply(sqlite3 *db, int n, void *changeset, void *udp) {
+	return sqlite3changeset_apply(db, n, changeset, 0, goXChangesetConflict, udp);
+}
@@ -0,0 +1,200 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite_session
+// +build sqlite_session
+
+package sqlite
+
+/*
+#cgo CFLAGS: -DSQLITE_ENABLE_SESSION -DSQLITE_ENABLE_PREUPDATE_HOOK
+#include <sqlite3.h>
+#include <stdlib.h>
+
+int goSqlite3ChangesetApply(sqlite3 *db, int n, void *changeset, void *udp);
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+// Session records the changes made to the tables of a database
+// so that they can be packaged as a changeset.
+// Only available with the sqlite_session build tag
+// (the SQLite library must be compiled with SQLITE_ENABLE_SESSION).
+// (See http://sqlite.org/sessionintro.html)
+type Session struct {
+	c  *Conn
+	ss *C.sqlite3_session
+}
+
+// CreateSession starts recording the changes made to the specified database.
+// The session must be deleted before the connection is closed.
+// (See http://sqlite.org/session/sqlite3session_create.html)
+func (c *Conn) CreateSession(dbName string) (*Session, error) {
+	zDb := C.CString(dbName)
+	defer C.free(unsafe.Pointer(zDb))
+	var ss *C.sqlite3_session
+	rv := C.sqlite3session_create(c.db, zDb, &ss)
+	if rv != C.SQLITE_OK {
+		return nil, c.error(rv, "Conn.CreateSession")
+	}
+	return &Session{c, ss}, nil
+}
+
+// Attach records the changes made to the specified table
+// (all the tables when table is empty).
+// Only tables with an explicit PRIMARY KEY are recorded.
+// (See http://sqlite.org/session/sqlite3session_attach.html)
+func (s *Session) Attach(table string) error {
+	var zTab *C.char
+	if len(table) > 0 {
+		zTab = C.CString(table)
+		defer C.free(unsafe.Pointer(zTab))
+	}
+	rv := C.sqlite3session_attach(s.ss, zTab)
+	if rv != C.SQLITE_OK {
+		return s.c.error(rv, "Session.Attach")
+	}
+	return nil
+}
+
+// Changeset returns the changes recorded so far.
+// (See http://sqlite.org/session/sqlite3session_changeset.html)
+func (s *Session) Changeset() ([]byte, error) {
+	var n C.int
+	var p unsafe.Pointer
+	rv := C.sqlite3session_changeset(s.ss, &n, &p)
+	if rv != C.SQLITE_OK {
+		return nil, s.c.error(rv, "Session.Changeset")
+	}
+	defer C.sqlite3_free(p)
+	return C.GoBytes(p, n), nil
+}
+
+// IsEmpty tells whether no change has been recorded.
+// (See http://sqlite.org/session/sqlite3session_isempty.html)
+func (s *Session) IsEmpty() bool {
+	return C.sqlite3session_isempty(s.ss) != 0
+}
+
+// Delete stops recording and frees the session.
+// (See http://sqlite.org/session/sqlite3session_delete.html)
+func (s *Session) Delete() {
+	if s.ss == nil {
+		return
+	}
+	C.sqlite3session_delete(s.ss)
+	s.ss = nil
+}
+
+// ConflictType is the reason why a change cannot be applied.
+// (See http://sqlite.org/session/c_changeset_conflict.html)
+type ConflictType int
+
+// Types of conflict
+const (
+	ChangesetData       ConflictType = C.SQLITE_CHANGESET_DATA       // the row exists but its values differ from the expected ones
+	ChangesetNotFound   ConflictType = C.SQLITE_CHANGESET_NOTFOUND   // the row to update or delete does not exist
+	ChangesetConflict   ConflictType = C.SQLITE_CHANGESET_CONFLICT   // the row to insert already exists
+	ChangesetConstraint ConflictType = C.SQLITE_CHANGESET_CONSTRAINT // a constraint is violated
+	ChangesetForeignKey ConflictType = C.SQLITE_CHANGESET_FOREIGN_KEY
+)
+
+// ConflictAction tells how a conflict is resolved.
+// (See http://sqlite.org/session/c_changeset_abort.html)
+type ConflictAction int
+
+// Conflict resolutions
+const (
+	ChangesetOmit    ConflictAction = C.SQLITE_CHANGESET_OMIT    // skip the change
+	ChangesetReplace ConflictAction = C.SQLITE_CHANGESET_REPLACE // overwrite the conflicting row (Data and Conflict only)
+	ChangesetAbort   ConflictAction = C.SQLITE_CHANGESET_ABORT   // roll back all the changes
+)
+
+// Conflict gives access to the change that cannot be applied.
+// It must not be used after the ConflictHandler returns.
+type Conflict struct {
+	Type  ConflictType
+	Table string
+	Op    Action // Insert, Update or Delete
+	it    *C.sqlite3_changeset_iter
+	c     *Conn
+}
+
+// Old returns the value of the specified column before the change (Update or Delete only).
+// For Update, nil is returned when the column is not modified.
+func (d *Conflict) Old(i int) (interface{}, error) {
+	var v *C.sqlite3_value
+	if rv := C.sqlite3changeset_old(d.it, C.int(i), &v); rv != C.SQLITE_OK {
+		return nil, d.c.error(rv, "Conflict.Old")
+	}
+	return valueOf(v), nil
+}
+
+// New returns the value of the specified column after the change (Insert or Update only).
+// For Update, nil is returned when the column is not modified.
+func (d *Conflict) New(i int) (interface{}, error) {
+	var v *C.sqlite3_value
+	if rv := C.sqlite3changeset_new(d.it, C.int(i), &v); rv != C.SQLITE_OK {
+		return nil, d.c.error(rv, "Conflict.New")
+	}
+	return valueOf(v), nil
+}
+
+// Current returns the value of the specified column of the conflicting row
+// (ChangesetData or ChangesetConflict only).
+func (d *Conflict) Current(i int) (interface{}, error) {
+	var v *C.sqlite3_value
+	if rv := C.sqlite3changeset_conflict(d.it, C.int(i), &v); rv != C.SQLITE_OK {
+		return nil, d.c.error(rv, "Conflict.Current")
+	}
+	return valueOf(v), nil
+}
+
+// ConflictHandler is the callback function signature.
+type ConflictHandler func(d *Conflict) ConflictAction
+
+type sqliteConflictHandler struct {
+	f ConflictHandler
+	c *Conn
+}
+
+//export goXChangesetConflict
+func goXChangesetConflict(udp unsafe.Pointer, eConflict C.int, it *C.sqlite3_changeset_iter) C.int {
+	arg := (*sqliteConflictHandler)(udp)
+	d := &Conflict{Type: ConflictType(eConflict), it: it, c: arg.c}
+	var zTab *C.char
+	var nCol, op, indirect C.int
+	if C.sqlite3changeset_op(it, &zTab, &nCol, &op, &indirect) == C.SQLITE_OK {
+		d.Table = C.GoString(zTab)
+		d.Op = Action(op)
+	}
+	if arg.f == nil {
+		return C.SQLITE_CHANGESET_ABORT
+	}
+	return C.int(arg.f(d))
+}
+
+// ApplyChangeset applies the changeset to the "main" database, in one transaction.
+// The handler is called for each change that cannot be applied
+// (when nil, the first conflict aborts the whole changeset).
+// (See http://sqlite.org/session/sqlite3changeset_apply.html)
+func (c *Conn) ApplyChangeset(changeset []byte, handler ConflictHandler) error {
+	if len(changeset) == 0 {
+		return nil
+	}
+	// the changeset must not be moved/collected while applied
+	p := C.CBytes(changeset)
+	defer C.free(p)
+	h := &sqliteConflictHandler{handler, c}
+	c.conflictHandler = h // keep a reference so that it is not gced
+	defer func() { c.conflictHandler = nil }()
+	rv := C.goSqlite3ChangesetApply(c.db, C.int(len(changeset)), p, unsafe.Pointer(h))
+	if rv != C.SQLITE_OK {
+		return c.error(rv, "Conn.ApplyChangeset")
+	}
+	return nil
+}
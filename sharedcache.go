@@ -0,0 +1,84 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrNoSharedCache is returned when a shared-cache only setting is used on a connection
+// not opened in shared-cache mode.
+var ErrNoSharedCache = errors.New("connection not opened in shared-cache mode (see OpenSharedCache)")
+
+// sharedCacheEnabled tracks EnableSharedCache (accessed atomically).
+var sharedCacheEnabled int32
+
+// sharedCacheMode tells if a connection opened with filename and flags uses a shared cache.
+func sharedCacheMode(filename string, flags int) bool {
+	// the cache URI parameter overrides the flags
+	if flags&C.SQLITE_OPEN_URI != 0 && strings.HasPrefix(filename, "file:") {
+		if i := strings.IndexByte(filename, '?'); i >= 0 {
+			if query, err := url.ParseQuery(filename[i+1:]); err == nil {
+				switch query.Get("cache") {
+				case "shared":
+					return true
+				case "private":
+					return false
+				}
+			}
+		}
+	}
+	if flags&C.SQLITE_OPEN_SHAREDCACHE != 0 {
+		return true
+	} else if flags&C.SQLITE_OPEN_PRIVATECACHE != 0 {
+		return false
+	}
+	return atomic.LoadInt32(&sharedCacheEnabled) != 0
+}
+
+// SharedCache reports if the connection has been opened in shared-cache mode
+// (with OpenSharedCache, a "cache=shared" URI or after EnableSharedCache(true)).
+// Connections to the same database in the same process then share the page cache and the schema,
+// with table-level locking instead of file locking.
+// Shared-cache mode is discouraged (WAL mode is usually a better fit for multiple connections)
+// except for several connections to the same in-memory database.
+// (See http://sqlite.org/sharedcache.html)
+func (c *Conn) SharedCache() bool {
+	return c.sharedCache
+}
+
+// ReadUncommitted queries the read-uncommitted isolation mode.
+// (See http://sqlite.org/pragma.html#pragma_read_uncommitted)
+func (c *Conn) ReadUncommitted() (bool, error) {
+	var b bool
+	err := c.oneValue("PRAGMA read_uncommitted", &b)
+	if err != nil {
+		return false, err
+	}
+	return b, nil
+}
+
+// SetReadUncommitted enables or disables the read-uncommitted isolation mode:
+// the connection does not take read locks on the tables it reads,
+// so it may see the uncommitted changes of the other connections sharing its cache
+// (and it does not block them nor is blocked by them).
+// It has no effect without shared cache, so ErrNoSharedCache is returned
+// when enabling it on a connection not opened in shared-cache mode (see Conn.SharedCache).
+// (See http://sqlite.org/sharedcache.html#dirty_reads)
+func (c *Conn) SetReadUncommitted(b bool) error {
+	if b && !c.sharedCache {
+		return ErrNoSharedCache
+	}
+	return c.exec(fmt.Sprintf("PRAGMA read_uncommitted=%t", b))
+}
@@ -0,0 +1,53 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"testing"
+)
+
+func TestReadUncommitted(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	assert(t, "private cache expected", !db.SharedCache())
+	assertEquals(t, "expected %v but got %v", ErrNoSharedCache, db.SetReadUncommitted(true))
+	checkNoError(t, db.SetReadUncommitted(false), "read_uncommitted error: %s")
+
+	const uri = "file:readuncommitted?mode=memory&cache=shared"
+	writer, err := Open(uri, OpenUri, OpenReadWrite, OpenCreate, OpenFullMutex)
+	checkNoError(t, err, "open error: %s")
+	defer checkClose(writer, t)
+	reader, err := Open(uri, OpenUri, OpenReadWrite, OpenCreate, OpenFullMutex)
+	checkNoError(t, err, "open error: %s")
+	defer checkClose(reader, t)
+	assert(t, "shared cache expected", writer.SharedCache() && reader.SharedCache())
+	checkNoError(t, writer.Exec("CREATE TABLE test (data TEXT)"), "create error: %s")
+
+	checkNoError(t, writer.Begin(), "begin error: %s")
+	checkNoError(t, writer.Exec("INSERT INTO test VALUES ('dirty')"), "insert error: %s")
+	var n int
+	err = reader.OneValue("SELECT count(*) FROM test", &n)
+	assert(t, "table lock error expected", err != nil)
+
+	checkNoError(t, reader.SetReadUncommitted(true), "read_uncommitted error: %s")
+	b, err := reader.ReadUncommitted()
+	checkNoError(t, err, "read_uncommitted error: %s")
+	assert(t, "read_uncommitted expected", b)
+	checkNoError(t, reader.OneValue("SELECT count(*) FROM test", &n), "dirty read error: %s")
+	assertEquals(t, "expected %d but got %d", 1, n)
+	checkNoError(t, writer.Rollback(), "rollback error: %s")
+
+	private, err := Open("file:readuncommitted?mode=memory", OpenUri, OpenReadWrite, OpenPrivateCache, OpenFullMutex)
+	checkNoError(t, err, "open error: %s")
+	defer checkClose(private, t)
+	assert(t, "private cache expected", !private.SharedCache())
+	shared, err := Open("file:readuncommitted?mode=memory&cache=shared", OpenUri, OpenReadWrite, OpenPrivateCache, OpenFullMutex)
+	checkNoError(t, err, "open error: %s")
+	defer checkClose(shared, t)
+	assert(t, "shared cache expected (URI parameter overrides flags)", shared.SharedCache())
+	_, err = shared.Exists("SELECT 1 FROM test")
+	checkNoError(t, err, "exists error: %s")
+}
@@ -24,38 +24,89 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode/utf16"
 	"unsafe"
 )
 
 type ConnError struct {
-	c       *Conn
-	code    Errno
-	msg     string
-	details string
+	c        *Conn
+	code     Errno
+	msg      string
+	details  string
+	sysErrno int // OS error code of the failed I/O
+	extCode  int // extended result code when the error occurred
 }
 
 func (e *ConnError) Code() Errno {
 	return e.code
 }
 
-// FIXME it might be the case that a second error occurs on a separate thread in between the time of the first error and the call to this method.
+// ErrInterrupted is matched (see errors.Is) by the errors of the operations interrupted by Conn.Interrupt
+// (SQLITE_INTERRUPT).
+var ErrInterrupted = errors.New("sqlite operation interrupted")
+
+// ErrBusySnapshot is matched (see errors.Is) by the SQLITE_BUSY_SNAPSHOT errors:
+// in WAL mode, a read transaction cannot be upgraded to a write transaction
+// because the database has been modified by another connection since it started.
+// Retrying the statement is useless: the transaction must be rolled back and restarted.
+// (See http://sqlite.org/rescode.html#busy_snapshot)
+var ErrBusySnapshot = errors.New("sqlite snapshot is stale")
+
+// Is makes errors.Is(err, ErrInterrupted) and errors.Is(err, ErrBusySnapshot) work
+// without pattern-matching the error codes.
+func (e *ConnError) Is(target error) bool {
+	switch target {
+	case ErrInterrupted:
+		return e.code&0xff == ErrInterrupt
+	case ErrBusySnapshot:
+		return e.code&0xff == ErrBusy && e.ExtendedCode() == C.SQLITE_BUSY_SNAPSHOT
+	}
+	return false
+}
+
+// ExtendedCode returns the extended result code captured when the error occurred.
+// (See http://sqlite.org/c3ref/errcode.html)
 func (e *ConnError) ExtendedCode() int {
+	if e.extCode != 0 {
+		return e.extCode
+	}
 	return int(C.sqlite3_extended_errcode(e.c.db))
 }
 
-// Filename returns database file name from which the error comes from.
+// Filename returns database file name from which the error comes from
+// (empty when the connection could not be opened).
 func (e *ConnError) Filename() string {
+	if e.c == nil {
+		return ""
+	}
 	return e.c.Filename("main")
 }
 
+// SystemErrno returns the OS error code (errno) of the failed I/O operation
+// when the error is an I/O error (SQLITE_IOERR, SQLITE_CANTOPEN or SQLITE_FULL), 0 otherwise.
+// (See http://sqlite.org/c3ref/system_errno.html)
+func (e *ConnError) SystemErrno() int {
+	return e.sysErrno
+}
+
 func (e *ConnError) Error() string { // FIXME code.Error() & e.msg are often redundant...
+	var s string
 	if len(e.details) > 0 {
-		return fmt.Sprintf("%s; %s (%s)", e.code.Error(), e.msg, e.details)
+		s = fmt.Sprintf("%s; %s (%s)", e.code.Error(), e.msg, e.details)
 	} else if len(e.msg) > 0 {
-		return fmt.Sprintf("%s; %s", e.code.Error(), e.msg)
+		s = fmt.Sprintf("%s; %s", e.code.Error(), e.msg)
+	} else {
+		s = e.code.Error()
 	}
-	return e.code.Error()
+	if e.sysErrno != 0 {
+		s = fmt.Sprintf("%s; errno %d: %s", s, e.sysErrno, syscall.Errno(e.sysErrno).Error())
+	}
+	return s
 }
 
 // Result codes
@@ -74,6 +125,12 @@ func (e Errno) Error() string {
 	return s
 }
 
+// String returns the English-language description of the result code
+// (See http://sqlite.org/c3ref/errcode.html)
+func (e Errno) String() string {
+	return e.Error()
+}
+
 const (
 	ErrError      = Errno(C.SQLITE_ERROR)      /* SQL error or missing database */
 	ErrInternal   = Errno(C.SQLITE_INTERNAL)   /* Internal logic error in SQLite */
@@ -115,10 +172,37 @@ func (c *Conn) error(rv C.int, details ...string) error {
 	if rv == C.SQLITE_OK {
 		return nil
 	}
-	err := &ConnError{c: c, code: Errno(rv), msg: C.GoString(C.sqlite3_errmsg(c.db))}
+	c.countBusy(rv)
+	err := &ConnError{c: c, code: Errno(rv), msg: C.GoString(C.sqlite3_errmsg(c.db)), sysErrno: c.systemErrno(rv),
+		extCode: int(C.sqlite3_extended_errcode(c.db))}
 	if len(details) > 0 {
 		err.details = details[0]
 	}
+	c.checkCorruption(err, err.extCode)
+	return c.commitAborted(err, err.extCode)
+}
+
+// systemErrno returns the errno of the last failed I/O (only meaningful for I/O errors).
+func (c *Conn) systemErrno(rv C.int) int {
+	return systemErrno(c.db, rv)
+}
+
+func systemErrno(db *C.sqlite3, rv C.int) int {
+	switch rv & 0xff {
+	case C.SQLITE_IOERR, C.SQLITE_CANTOPEN, C.SQLITE_FULL:
+		return int(C.sqlite3_system_errno(db))
+	}
+	return 0
+}
+
+// openError returns the error of a failed open (with the OS error code) and closes db.
+func openError(db *C.sqlite3, rv C.int) error {
+	if db == nil {
+		return Errno(rv)
+	}
+	err := &ConnError{code: Errno(rv), msg: C.GoString(C.sqlite3_errmsg(db)), sysErrno: systemErrno(db, rv),
+		extCode: int(C.sqlite3_extended_errcode(db))}
+	C.sqlite3_close(db)
 	return err
 }
 
@@ -126,6 +210,17 @@ func (c *Conn) specificError(msg string, a ...interface{}) error {
 	return &ConnError{c: c, code: ErrSpecific, msg: fmt.Sprintf(msg, a...)}
 }
 
+func (c *Conn) countBusy(rv C.int) {
+	if rv&0xff == C.SQLITE_BUSY || rv&0xff == C.SQLITE_LOCKED {
+		atomic.AddInt64(&c.nBusy, 1)
+	}
+}
+
+// BusyCount returns the number of SQLITE_BUSY or SQLITE_LOCKED errors reported on this connection.
+func (c *Conn) BusyCount() int64 {
+	return atomic.LoadInt64(&c.nBusy)
+}
+
 // LastError returns the error for the most recent failed sqlite3_* API call associated with a database connection.
 // (See http://sqlite.org/c3ref/errcode.html)
 func (c *Conn) LastError() error {
@@ -136,12 +231,17 @@ func (c *Conn) LastError() error {
 	if errorCode == C.SQLITE_OK {
 		return nil
 	}
-	return &ConnError{c: c, code: Errno(errorCode), msg: C.GoString(C.sqlite3_errmsg(c.db))}
+	return &ConnError{c: c, code: Errno(errorCode), msg: C.GoString(C.sqlite3_errmsg(c.db)), sysErrno: c.systemErrno(errorCode),
+		extCode: int(C.sqlite3_extended_errcode(c.db))}
 }
 
 // Database connection handle
 // (See http://sqlite.org/c3ref/sqlite3.html)
 type Conn struct {
+	nBusy           int64 // accessed atomically (first field for 64-bit alignment)
+	nRetries        int64 // accessed atomically
+	nBusyCalls      int64 // accessed atomically
+	busyBlocked     int64 // accessed atomically (nanoseconds)
 	db              *C.sqlite3
 	stmtCache       *cache
 	authorizer      *sqliteAuthorizer
@@ -149,13 +249,37 @@ type Conn struct {
 	profile         *sqliteProfile
 	progressHandler *sqliteProgressHandler
 	trace           *sqliteTrace
-	commitHook      *sqliteCommitHook
-	rollbackHook    *sqliteRollbackHook
-	updateHook      *sqliteUpdateHook
+	hooks           *hookChain  // commit, rollback and update hooks
+	preUpdateHook   interface{} // *sqlitePreUpdateHook (only with the sqlite_preupdate build tag)
+	conflictHandler interface{} // *sqliteConflictHandler (only with the sqlite_session build tag)
+	recoverSQL      interface{} // *sqliteRecoverSQL (only with the sqlite_recover build tag)
+	walHook         *sqliteWalHook
 	udfs            map[string]*sqliteFunction
 	modules         map[string]*sqliteModule
 	timeUsed        time.Time
 	nTransaction    uint8
+	noMutex         bool
+	guard           *connGuard
+	readOnly        *sqliteAuthorizer // previous authorizer when read-only mode is enforced
+	typeAffinity    bool
+	typeMismatch    TypeMismatchPolicy
+	strictTables    bool
+	retryPolicy     *RetryPolicy
+	singleStatement bool
+	blobs           map[*blobHandle]bool // BLOB handles still open
+	leakPolicy      LeakPolicy
+	optimizeOnClose bool
+	sharedCache     bool // opened in shared-cache mode
+	scanReport      *scanReport
+	recovery        *recoveryState // see SetRecoveryHandler
+	depth           int32          // calls in progress, accessed atomically (see enter/leave)
+	redaction       Redaction      // see SetRedaction
+	dqs             bool           // double-quoted string literals explicitly enabled (see SetDoubleQuotedStrings)
+	strictInsertIds bool           // see SetStrictInsertIds
+	inserted        bool           // the last Exec executed an INSERT (only tracked with strictInsertIds)
+	insertProbe     *insertProbe   // not nil while a statement is inspected (see Stmt.inserts)
+	zombie          bool           // closed with CloseV2 but not yet freed
+	closing         sync.RWMutex   // write-locked while the handle is closed (see Collector.Sample)
 }
 
 // Version returns the run-time library version number
@@ -165,6 +289,25 @@ func Version() string {
 	return C.GoString(p)
 }
 
+// VersionNumber returns the run-time library version number (X*1000000 + Y*1000 + Z for X.Y.Z)
+// (See http://sqlite.org/c3ref/libversion.html)
+func VersionNumber() int {
+	return int(C.sqlite3_libversion_number())
+}
+
+// SourceID returns the check-in identifier (date, time and SHA3 hash) of the run-time library source code
+// (See http://sqlite.org/c3ref/libversion.html)
+func SourceID() string {
+	return C.GoString(C.sqlite3_sourceid())
+}
+
+// Threadsafe returns the threading mode the library was compiled with:
+// 0 (single-thread), 1 (serialized) or 2 (multi-thread).
+// (See http://sqlite.org/c3ref/threadsafe.html and http://sqlite.org/compile.html#threadsafe)
+func Threadsafe() int {
+	return int(C.sqlite3_threadsafe())
+}
+
 // Flags for file open operations
 type OpenFlag int
 
@@ -177,8 +320,27 @@ const (
 	OpenFullMutex    OpenFlag = C.SQLITE_OPEN_FULLMUTEX
 	OpenSharedCache  OpenFlag = C.SQLITE_OPEN_SHAREDCACHE
 	OpenPrivateCache OpenFlag = C.SQLITE_OPEN_PRIVATECACHE
+	OpenMemory       OpenFlag = C.SQLITE_OPEN_MEMORY    // database is held in memory, the filename is only used for shared cache naming
+	OpenNoFollow     OpenFlag = C.SQLITE_OPEN_NOFOLLOW  // the database filename is not allowed to be a symbolic link
+	OpenExResCode    OpenFlag = C.SQLITE_OPEN_EXRESCODE // extended result codes are enabled (see Conn.EnableExtendedResultCodes)
 )
 
+// checkOpenFlags rejects combinations of flags that SQLite does not support (or silently ignores).
+func checkOpenFlags(flags int) error {
+	switch flags & (C.SQLITE_OPEN_READONLY | C.SQLITE_OPEN_READWRITE | C.SQLITE_OPEN_CREATE) {
+	case C.SQLITE_OPEN_READONLY, C.SQLITE_OPEN_READWRITE, C.SQLITE_OPEN_READWRITE | C.SQLITE_OPEN_CREATE:
+	default:
+		return errors.New("invalid open flags: exactly one of OpenReadOnly, OpenReadWrite or OpenReadWrite|OpenCreate expected")
+	}
+	if flags&C.SQLITE_OPEN_NOMUTEX != 0 && flags&C.SQLITE_OPEN_FULLMUTEX != 0 {
+		return errors.New("invalid open flags: OpenNoMutex and OpenFullMutex are exclusive")
+	}
+	if flags&C.SQLITE_OPEN_SHAREDCACHE != 0 && flags&C.SQLITE_OPEN_PRIVATECACHE != 0 {
+		return errors.New("invalid open flags: OpenSharedCache and OpenPrivateCache are exclusive")
+	}
+	return nil
+}
+
 // Open opens a new database connection.
 // ":memory:" for memory db,
 // "" for temp file db
@@ -201,6 +363,9 @@ func OpenVfs(filename string, vfsname string, flags ...OpenFlag) (*Conn, error)
 	} else {
 		openFlags = C.SQLITE_OPEN_FULLMUTEX | C.SQLITE_OPEN_READWRITE | C.SQLITE_OPEN_CREATE
 	}
+	if err := checkOpenFlags(openFlags); err != nil {
+		return nil, err
+	}
 
 	var db *C.sqlite3
 	cname := C.CString(filename)
@@ -212,21 +377,45 @@ func OpenVfs(filename string, vfsname string, flags ...OpenFlag) (*Conn, error)
 	}
 	rv := C.sqlite3_open_v2(cname, &db, C.int(openFlags), vfs)
 	if rv != C.SQLITE_OK {
-		if db != nil {
-			C.sqlite3_close(db)
-		}
-		return nil, Errno(rv)
+		return nil, openError(db, rv)
 	}
 	if db == nil {
 		return nil, errors.New("sqlite succeeded without returning a database")
 	}
-	c := &Conn{db: db, stmtCache: newCache()}
+	return newConn(db, filename, openFlags), nil
+}
+
+// Open16 opens a new database connection with a filename converted to UTF-16 (native byte order),
+// like the wide-character Windows APIs, in read/write/create mode.
+// URI filenames are not interpreted unless URI handling is globally enabled.
+// (See http://sqlite.org/c3ref/open.html)
+func Open16(filename string) (*Conn, error) {
+	if C.sqlite3_threadsafe() == 0 {
+		return nil, errors.New("sqlite library was not compiled for thread-safe operation")
+	}
+	name := utf16.Encode([]rune(filename + "\x00"))
+	var db *C.sqlite3
+	rv := C.sqlite3_open16(unsafe.Pointer(&name[0]), &db)
+	if rv != C.SQLITE_OK {
+		return nil, openError(db, rv)
+	}
+	if db == nil {
+		return nil, errors.New("sqlite succeeded without returning a database")
+	}
+	return newConn(db, filename, C.SQLITE_OPEN_READWRITE|C.SQLITE_OPEN_CREATE), nil
+}
+
+func newConn(db *C.sqlite3, filename string, openFlags int) *Conn {
+	c := &Conn{db: db, stmtCache: newCache(), noMutex: openFlags&C.SQLITE_OPEN_NOMUTEX != 0,
+		sharedCache: sharedCacheMode(filename, openFlags)}
+	if debugGuard && c.noMutex {
+		c.guard = newConnGuard(false)
+	}
 	if os.Getenv("SQLITE_DEBUG") != "" {
 		c.SetAuthorizer(authorizer, c.db)
 		c.SetCacheSize(0)
 	}
-
-	return c, nil
+	return c
 }
 
 func authorizer(d interface{}, action Action, arg1, arg2, dbName, triggerName string) Auth {
@@ -235,6 +424,8 @@ func authorizer(d interface{}, action Action, arg1, arg2, dbName, triggerName st
 }
 
 // BusyTimeout sets a busy timeout.
+// The built-in handler is not instrumented: only Errors are reported by Conn.BusyStats
+// (see Conn.InstrumentedBusyTimeout).
 // (See http://sqlite.org/c3ref/busy_timeout.html)
 func (c *Conn) BusyTimeout(d time.Duration) error {
 	c.busyHandler = nil
@@ -275,9 +466,58 @@ func (c *Conn) AreTriggersEnabled() (bool, error) {
 	return c.queryOrSetEnableDbConfig(C.SQLITE_DBCONFIG_ENABLE_TRIGGER, -1)
 }
 
+// SetDoubleQuotedStrings enables or disables the double-quoted string literals
+// in both DML and DDL statements: when disabled, a misspelled "identifier" is an error
+// instead of silently becoming a string literal.
+// Conn.ExecScript disables them while it runs, unless they have been explicitly enabled with this method.
+// Calls sqlite3_db_config(db, SQLITE_DBCONFIG_DQS_DML/SQLITE_DBCONFIG_DQS_DDL, b).
+//
+// (See http://sqlite.org/quirks.html#dblquote)
+func (c *Conn) SetDoubleQuotedStrings(b bool) error {
+	if _, err := c.queryOrSetEnableDbConfig(C.SQLITE_DBCONFIG_DQS_DML, btocint(b)); err != nil {
+		return err
+	}
+	if _, err := c.queryOrSetEnableDbConfig(C.SQLITE_DBCONFIG_DQS_DDL, btocint(b)); err != nil {
+		return err
+	}
+	c.dqs = b
+	return nil
+}
+
+// DoubleQuotedStrings reports if the double-quoted string literals are accepted
+// in DML statements and in DDL statements.
+//
+// (See http://sqlite.org/c3ref/c_dbconfig_defensive.html#sqlitedbconfigdqsdml)
+func (c *Conn) DoubleQuotedStrings() (dml, ddl bool, err error) {
+	if dml, err = c.queryOrSetEnableDbConfig(C.SQLITE_DBCONFIG_DQS_DML, -1); err != nil {
+		return
+	}
+	ddl, err = c.queryOrSetEnableDbConfig(C.SQLITE_DBCONFIG_DQS_DDL, -1)
+	return
+}
+
+// disableDoubleQuotedStrings disables the double-quoted string literals (unless they have been explicitly enabled)
+// and returns a function restoring the previous settings.
+func (c *Conn) disableDoubleQuotedStrings() (func(), error) {
+	if c.dqs {
+		return func() {}, nil
+	}
+	dml, ddl, err := c.DoubleQuotedStrings()
+	if err != nil || !dml && !ddl {
+		return func() {}, err
+	}
+	if err = c.SetDoubleQuotedStrings(false); err != nil {
+		return func() {}, err
+	}
+	return func() {
+		c.queryOrSetEnableDbConfig(C.SQLITE_DBCONFIG_DQS_DML, btocint(dml))
+		c.queryOrSetEnableDbConfig(C.SQLITE_DBCONFIG_DQS_DDL, btocint(ddl))
+	}, nil
+}
+
 func (c *Conn) queryOrSetEnableDbConfig(key, i C.int) (bool, error) {
 	var ok C.int
-	rv := C.my_db_config(c.db, C.SQLITE_DBCONFIG_ENABLE_FKEY, i, &ok)
+	rv := C.my_db_config(c.db, key, i, &ok)
 	if rv == C.SQLITE_OK {
 		return (ok == 1), nil
 	}
@@ -290,6 +530,39 @@ func (c *Conn) EnableExtendedResultCodes(b bool) error {
 	return c.error(C.sqlite3_extended_result_codes(c.db, btocint(b)), "Conn.EnableExtendedResultCodes")
 }
 
+// SetTypeAffinity enables or disables declared-type-directed scanning:
+// when enabled, values scanned into *interface{} (and by the database/sql driver)
+// are converted to time.Time for columns declared as DATE/DATETIME/TIMESTAMP
+// and to bool for columns declared as BOOLEAN (see Stmt.ColumnDeclaredType).
+// Expressions (without declared type) are not converted.
+func (c *Conn) SetTypeAffinity(b bool) {
+	c.typeAffinity = b
+}
+
+// TypeAffinity reports if declared-type-directed scanning is enabled or not.
+func (c *Conn) TypeAffinity() bool {
+	return c.typeAffinity
+}
+
+// SetStrictTables makes the tables created by the DDL helpers of this package (like NewAudit) STRICT.
+// (See http://sqlite.org/stricttables.html)
+func (c *Conn) SetStrictTables(b bool) {
+	c.strictTables = b
+}
+
+// StrictTables reports if the tables created by the DDL helpers are STRICT or not.
+func (c *Conn) StrictTables() bool {
+	return c.strictTables
+}
+
+// tableOptions returns the table options to append to the CREATE TABLE statements generated by this package.
+func (c *Conn) tableOptions() string {
+	if c.strictTables {
+		return " STRICT"
+	}
+	return ""
+}
+
 // Readonly determines if a database is read-only.
 // (See http://sqlite.org/c3ref/db_readonly.html)
 func (c *Conn) Readonly(dbName string) (bool, error) {
@@ -302,6 +575,143 @@ func (c *Conn) Readonly(dbName string) (bool, error) {
 	return rv == 1, nil
 }
 
+// ProbeWritable checks that the specified database (default is 'main') can actually be written
+// by rewriting its user version inside a savepoint which is rolled back,
+// so that a read-only mount, an immutable URI or a non-writable journal directory can be detected at startup
+// (Conn.Readonly only reports the way the database has been opened).
+// Returns nil if the database is writable.
+func (c *Conn) ProbeWritable(dbName string) error {
+	var version int
+	if err := c.oneValue(pragma(dbName, "user_version"), &version); err != nil {
+		return err
+	}
+	const savepoint = "probe_writable"
+	if err := c.Savepoint(savepoint); err != nil {
+		return err
+	}
+	err := c.exec(pragma(dbName, fmt.Sprintf("user_version = %d", version)))
+	if rerr := c.RollbackSavepoint(savepoint); err == nil {
+		err = rerr
+	}
+	if rerr := c.ReleaseSavepoint(savepoint); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// SetSingleStatement enables or disables the single-statement mode:
+// when enabled, Conn.Exec and Conn.Prepare reject SQL containing more than one statement
+// (comments and white-spaces after the statement are allowed),
+// so that a statement cannot be smuggled in after the expected one.
+// Scripts must then be executed with Conn.ExecScript or Conn.PrepareAll.
+func (c *Conn) SetSingleStatement(b bool) {
+	c.singleStatement = b
+}
+
+// SingleStatement reports if the single-statement mode is enabled (see Conn.SetSingleStatement).
+func (c *Conn) SingleStatement() bool {
+	return c.singleStatement
+}
+
+// checkSingleStatement returns an error when the tail of cmd contains another statement.
+func (c *Conn) checkSingleStatement(cmd, tail string) error {
+	for len(tail) > 0 {
+		s, err := c.prepare(tail)
+		if err == nil && s.stmt == nil { // comment or white-space
+			tail = s.tail
+			continue
+		}
+		if err == nil {
+			s.finalize()
+		}
+		return c.specificError("multiple statements are not allowed in single-statement mode: %q", cmd)
+	}
+	return nil
+}
+
+// SetReadOnly enables or disables a defense-in-depth read-only mode for report/analytics connections:
+//   - PRAGMA query_only is set,
+//   - an authorizer denies any write (the previous authorizer, if any, is still consulted for other actions and restored on disable),
+//   - Conn.Exec refuses statements that are not read-only (see Stmt.ReadOnly).
+//
+// Opening the connection with OpenReadOnly remains the strongest guarantee and should be preferred when possible.
+// (See http://sqlite.org/pragma.html#pragma_query_only)
+func (c *Conn) SetReadOnly(b bool) error {
+	if b == (c.readOnly != nil) {
+		return nil
+	}
+	if !b {
+		prev := c.readOnly
+		c.readOnly = nil
+		var err error
+		if prev.f == nil {
+			err = c.SetAuthorizer(nil, nil)
+		} else {
+			err = c.SetAuthorizer(prev.f, prev.udp)
+		}
+		if err != nil {
+			return err
+		}
+		return c.exec("PRAGMA query_only = 0")
+	}
+	if err := c.exec("PRAGMA query_only = 1"); err != nil {
+		return err
+	}
+	c.stmtCache.flush() // cached statements were authorized before
+	prev := &sqliteAuthorizer{}
+	if c.authorizer != nil {
+		*prev = *c.authorizer
+	}
+	err := c.SetAuthorizer(func(udp interface{}, action Action, arg1, arg2, dbName, triggerName string) Auth {
+		if isWriteAction(action, arg1, arg2) {
+			return AuthDeny
+		}
+		if prev.f != nil {
+			return prev.f(prev.udp, action, arg1, arg2, dbName, triggerName)
+		}
+		return AuthOk
+	}, nil)
+	if err != nil {
+		c.exec("PRAGMA query_only = 0")
+		return err
+	}
+	c.readOnly = prev
+	return nil
+}
+
+// IsReadOnlyEnforced tells if the read-only mode is enabled (see Conn.SetReadOnly).
+func (c *Conn) IsReadOnlyEnforced() bool {
+	return c.readOnly != nil
+}
+
+// readOnlyPragmas are the pragmas whose argument is not a new value
+// (the authorizer cannot tell PRAGMA name(arg) from PRAGMA name = value).
+var readOnlyPragmas = map[string]bool{
+	"foreign_key_check": true,
+	"foreign_key_list":  true,
+	"index_info":        true,
+	"index_list":        true,
+	"index_xinfo":       true,
+	"integrity_check":   true,
+	"quick_check":       true,
+	"table_info":        true,
+	"table_list":        true,
+	"table_xinfo":       true,
+}
+
+func isWriteAction(action Action, arg1, arg2 string) bool {
+	switch action {
+	case CreateIndex, CreateTable, CreateTempIndex, CreateTempTable, CreateTempTrigger, CreateTempView,
+		CreateTrigger, CreateView, Delete, DropIndex, DropTable, DropTempIndex, DropTempTable, DropTempTrigger,
+		DropTempView, DropTrigger, DropView, Insert, Update, AlterTable, Reindex, Analyze, CreateVTable, DropVTable:
+		return true
+	case Pragma:
+		// PRAGMA name = value, except for the pragmas taking an argument to query
+		return len(arg2) > 0 && !readOnlyPragmas[strings.ToLower(arg1)]
+	}
+	return false
+}
+
 // Filename returns the filename for a database connection.
 // (See http://sqlite.org/c3ref/db_filename.html)
 func (c *Conn) Filename(dbName string) string {
@@ -310,9 +720,90 @@ func (c *Conn) Filename(dbName string) string {
 	return C.GoString(C.sqlite3_db_filename(c.db, cname))
 }
 
+// dbFilename returns the filename object of the specified database
+// or nil for temporary or in-memory database.
+func (c *Conn) dbFilename(dbName string) *C.char {
+	cname := C.CString(dbName)
+	defer C.free(unsafe.Pointer(cname))
+	zFilename := C.sqlite3_db_filename(c.db, cname)
+	if zFilename == nil || *zFilename == 0 {
+		return nil
+	}
+	return zFilename
+}
+
+// JournalFilename returns the rollback journal filename for the specified database.
+// Returns "" for temporary or in-memory database.
+// (See http://sqlite.org/c3ref/filename_database.html)
+func (c *Conn) JournalFilename(dbName string) string {
+	zFilename := c.dbFilename(dbName)
+	if zFilename == nil {
+		return ""
+	}
+	return C.GoString(C.sqlite3_filename_journal(zFilename))
+}
+
+// WalFilename returns the write-ahead log filename for the specified database.
+// The shared-memory file is the same path with a "-shm" suffix instead of "-wal".
+// Returns "" for temporary or in-memory database.
+// (See http://sqlite.org/c3ref/filename_database.html)
+func (c *Conn) WalFilename(dbName string) string {
+	zFilename := c.dbFilename(dbName)
+	if zFilename == nil {
+		return ""
+	}
+	return C.GoString(C.sqlite3_filename_wal(zFilename))
+}
+
+// UriParameter returns the value of a query parameter of the URI used to open the specified database.
+// ok is false when the parameter is not present.
+// (See http://sqlite.org/c3ref/uri_boolean.html)
+func (c *Conn) UriParameter(dbName, param string) (value string, ok bool) {
+	zFilename := c.dbFilename(dbName)
+	if zFilename == nil {
+		return "", false
+	}
+	cparam := C.CString(param)
+	defer C.free(unsafe.Pointer(cparam))
+	zValue := C.sqlite3_uri_parameter(zFilename, cparam)
+	if zValue == nil {
+		return "", false
+	}
+	return C.GoString(zValue), true
+}
+
+// UriBoolean returns the boolean value of a query parameter of the URI used to open the specified database.
+// Returns def when the parameter is not present.
+// (See http://sqlite.org/c3ref/uri_boolean.html)
+func (c *Conn) UriBoolean(dbName, param string, def bool) bool {
+	zFilename := c.dbFilename(dbName)
+	if zFilename == nil {
+		return def
+	}
+	cparam := C.CString(param)
+	defer C.free(unsafe.Pointer(cparam))
+	return C.sqlite3_uri_boolean(zFilename, cparam, btocint(def)) == 1
+}
+
+// UriInt64 returns the integer value of a query parameter of the URI used to open the specified database.
+// Returns def when the parameter is not present or not an integer.
+// (See http://sqlite.org/c3ref/uri_boolean.html)
+func (c *Conn) UriInt64(dbName, param string, def int64) int64 {
+	zFilename := c.dbFilename(dbName)
+	if zFilename == nil {
+		return def
+	}
+	cparam := C.CString(param)
+	defer C.free(unsafe.Pointer(cparam))
+	return int64(C.sqlite3_uri_int64(zFilename, cparam, C.sqlite3_int64(def)))
+}
+
 // Exec prepares and executes one parameterized statement or many statements (separated by semi-colon).
 // Don't use it with SELECT or anything that returns data.
 func (c *Conn) Exec(cmd string, args ...interface{}) error {
+	if c.strictInsertIds {
+		c.inserted = false
+	}
 	for len(cmd) > 0 {
 		s, err := c.prepare(cmd)
 		if err != nil {
@@ -322,6 +813,19 @@ func (c *Conn) Exec(cmd string, args ...interface{}) error {
 			cmd = s.tail
 			continue
 		}
+		if c.singleStatement && len(s.tail) > 0 {
+			if err = c.checkSingleStatement(cmd, s.tail); err != nil {
+				s.finalize()
+				return err
+			}
+		}
+		if c.readOnly != nil && !s.ReadOnly() {
+			s.finalize()
+			return c.specificError("cannot execute a write statement on a read-only connection: %q", cmd)
+		}
+		if c.strictInsertIds && !c.inserted { // inspected before execution (DDL could not be explained afterwards)
+			c.inserted = s.inserts()
+		}
 		err = s.Exec(args...)
 		if err != nil {
 			s.finalize()
@@ -341,7 +845,80 @@ func (c *Conn) Exec(cmd string, args ...interface{}) error {
 	return nil
 }
 
+// ExecScript executes all the statements of the specified script (separated by semi-colon),
+// even in single-statement mode (see Conn.SetSingleStatement),
+// with the double-quoted string literals disabled (see Conn.SetDoubleQuotedStrings).
+// Don't use it with SELECT or anything that returns data.
+func (c *Conn) ExecScript(sql string) error {
+	restore, err := c.disableDoubleQuotedStrings()
+	if err != nil {
+		return err
+	}
+	defer restore()
+	script := c.PrepareAll(sql)
+	defer script.Close()
+	for script.Next() {
+		s := script.Stmt()
+		if c.readOnly != nil && !s.ReadOnly() {
+			return c.specificError("cannot execute a write statement on a read-only connection: %q", s.SQL())
+		}
+		if err := s.Exec(); err != nil {
+			return err
+		}
+	}
+	return script.Err()
+}
+
+// ScriptError tells which statement of a script failed (see Conn.ExecMulti).
+type ScriptError struct {
+	Index int    // index of the failed statement (0 for the first one)
+	SQL   string // the failed statement (followed by the rest of the script when it cannot be prepared)
+	Err   error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("statement #%d failed: %s (%q)", e.Index, e.Err, e.SQL)
+}
+
+// Unwrap returns the error of the failed statement.
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// ExecMulti executes all the statements of the specified script (separated by semi-colon),
+// binding argsPerStmt[i] to the i-th statement (statements without argument set are executed without binding).
+// When a statement fails, the following ones are not executed and a *ScriptError is returned.
+func (c *Conn) ExecMulti(script string, argsPerStmt ...[]interface{}) error {
+	sc := c.PrepareAll(script)
+	defer sc.Close()
+	i := 0
+	for ; sc.Next(); i++ {
+		s := sc.Stmt()
+		var err error
+		if c.readOnly != nil && !s.ReadOnly() {
+			err = c.specificError("cannot execute a write statement on a read-only connection")
+		} else if i < len(argsPerStmt) {
+			err = s.Exec(argsPerStmt[i]...)
+		} else {
+			err = s.Exec()
+		}
+		if err != nil {
+			return &ScriptError{Index: i, SQL: s.SQL(), Err: err}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return &ScriptError{Index: i, SQL: sc.Tail(), Err: err}
+	}
+	if i < len(argsPerStmt) {
+		return c.specificError("%d argument sets specified for %d statements", len(argsPerStmt), i)
+	}
+	return nil
+}
+
 // Exists returns true if the specified query returns at least one row.
+// The query is prepared (cached) and stepped only once, so it should not be a count:
+//
+//	found, err := db.Exists("SELECT 1 FROM user WHERE name = ?", name)
 func (c *Conn) Exists(query string, args ...interface{}) (bool, error) {
 	s, err := c.Prepare(query, args...)
 	if err != nil {
@@ -351,6 +928,38 @@ func (c *Conn) Exists(query string, args ...interface{}) (bool, error) {
 	return s.Next()
 }
 
+// Count returns the number of rows of the specified table matching the optional where clause
+// (SELECT count(*) FROM table WHERE where, prepared and cached):
+//
+//	n, err := db.Count("user", "age >= ?", 18)
+func (c *Conn) Count(table, where string, args ...interface{}) (int64, error) {
+	query := Mprintf(`SELECT count(*) FROM "%w"`, table)
+	if len(where) > 0 {
+		query += " WHERE " + where
+	}
+	var n int64
+	err := c.OneValue(query, &n, args...)
+	return n, err
+}
+
+// FastCount returns an estimate of the number of rows of the specified table without scanning it:
+// the greatest rowid, which is exact only when rows have never been deleted and rowids have been assigned
+// automatically (otherwise, it is an upper bound, usually).
+// Count is used for WITHOUT ROWID tables.
+func (c *Conn) FastCount(table string) (int64, error) {
+	for _, dbName := range []string{"temp", "main"} { // unqualified table names are resolved in this order
+		if typ, def, err := c.schemaObject(dbName, table); err == nil {
+			if typ == "table" && isWithoutRowid(def) {
+				return c.Count(table, "")
+			}
+			break
+		}
+	}
+	var n int64
+	err := c.OneValue(Mprintf(`SELECT coalesce(max(_rowid_), 0) FROM "%w"`, table), &n)
+	return n, err
+}
+
 // OneValue is used with SELECT that returns only one row with only one column.
 // Returns io.EOF when there is no row.
 // No check is performed to ensure that there is no more than one row.
@@ -369,6 +978,26 @@ func (c *Conn) OneValue(query string, value interface{}, args ...interface{}) er
 	return s.Scan(value)
 }
 
+// OneValue is the generic version of Conn.OneValue (Go methods cannot have type parameters):
+// the query is prepared (cached), bound with args, stepped once
+// and its first column is scanned into a T.
+// found is false (with no error) when there is no row.
+// No check is performed to ensure that there is no more than one row.
+//
+//	n, _, err := sqlite.OneValue[int64](db, "SELECT count(*) FROM test")
+func OneValue[T any](c *Conn, query string, args ...interface{}) (value T, found bool, err error) {
+	s, err := c.Prepare(query, args...)
+	if err != nil {
+		return
+	}
+	defer s.Finalize()
+	if found, err = s.Next(); err != nil || !found {
+		return
+	}
+	_, err = s.ScanByIndex(0, &value)
+	return
+}
+
 // Changes returns the number of database rows that were changed or inserted or deleted by the most recently completed SQL statement on the database connection.
 // If a separate thread makes changes on the same database connection while Changes() is running then the value returned is unpredictable and not meaningful.
 // (See http://sqlite.org/c3ref/changes.html)
@@ -462,8 +1091,8 @@ func (c *Conn) Transaction(t TransactionType, f func(c *Conn) error) (err error)
 	defer func() {
 		c.nTransaction--
 		if err != nil {
-			_, ko := err.(*ConnError)
-			if c.nTransaction == 0 || ko {
+			var cerr *ConnError
+			if c.nTransaction == 0 || errors.As(err, &cerr) {
 				c.Rollback()
 			} else {
 				if rerr := c.RollbackSavepoint(strconv.Itoa(int(c.nTransaction))); rerr != nil {
@@ -506,53 +1135,100 @@ func (c *Conn) RollbackSavepoint(name string) error {
 }
 
 func (c *Conn) exec(cmd string) error {
+	c.enter()
+	defer c.leave()
 	s, err := c.prepare(cmd)
 	if err != nil {
 		return err
 	}
 	defer s.finalize()
-	rv := C.sqlite3_step(s.stmt)
+	rv := s.step()
 	if Errno(rv) != Done {
 		return s.error(rv, "Conn.exec(%q)", cmd)
 	}
 	return nil
 }
 
+// SetOptimizeOnClose makes Close and CloseV2 run PRAGMA optimize
+// so that the query planner statistics are refreshed when needed
+// (errors are only logged, see ConfigLog).
+// (See http://sqlite.org/pragma.html#pragma_optimize)
+func (c *Conn) SetOptimizeOnClose(b bool) {
+	c.optimizeOnClose = b
+}
+
+func (c *Conn) optimize() {
+	if !c.optimizeOnClose {
+		return
+	}
+	if err := c.exec("PRAGMA optimize"); err != nil {
+		Log(C.SQLITE_WARNING, fmt.Sprintf("PRAGMA optimize failed while closing Conn: %s", err))
+	}
+}
+
 // Close closes a database connection and any dangling statements.
 // (See http://sqlite.org/c3ref/close.html)
 func (c *Conn) Close() error {
 	if c == nil {
 		return errors.New("nil sqlite database")
 	}
-	if c.db == nil {
+	if c.db == nil || c.zombie {
 		return nil
 	}
+	c.optimize()
+	c.enter()
+	defer c.leave()
 
 	c.stmtCache.flush()
 
-	// Dangling statements
-	stmt := C.sqlite3_next_stmt(c.db, nil)
-	for stmt != nil {
-		if C.sqlite3_stmt_busy(stmt) != 0 {
-			Log(C.SQLITE_MISUSE, "Dangling statement (not reset): \""+C.GoString(C.sqlite3_sql(stmt))+"\"")
-		} else {
-			Log(C.SQLITE_MISUSE, "Dangling statement (not finalize): \""+C.GoString(C.sqlite3_sql(stmt))+"\"")
-		}
-		C.sqlite3_finalize(stmt)
-		stmt = C.sqlite3_next_stmt(c.db, nil)
-	}
+	// Dangling statements and blobs
+	leaks := c.closeLeaks()
 
+	c.closing.Lock()
+	defer c.closing.Unlock()
 	rv := C.sqlite3_close(c.db)
 	if rv != C.SQLITE_OK {
 		Log(int(rv), "error while closing Conn")
 		return c.error(rv, "Conn.Close")
 	}
 	c.db = nil
+	if leaks != nil && c.leakPolicy == LeakReport {
+		return leaks
+	}
+	return nil
+}
+
+// CloseV2 closes the connection gracefully: the statements, BLOB handles and backups still open
+// remain usable and the connection is actually freed when the last one is finalized/closed.
+// The connection must not be used otherwise after this call.
+// (See http://sqlite.org/c3ref/close.html)
+func (c *Conn) CloseV2() error {
+	if c == nil {
+		return errors.New("nil sqlite database")
+	}
+	if c.db == nil || c.zombie {
+		return nil
+	}
+	c.optimize()
+	c.enter()
+	defer c.leave()
+
+	c.stmtCache.flush()
+
+	c.closing.Lock()
+	defer c.closing.Unlock()
+	rv := C.sqlite3_close_v2(c.db)
+	if rv != C.SQLITE_OK {
+		Log(int(rv), "error while closing Conn")
+		return c.error(rv, "Conn.CloseV2")
+	}
+	// c.db is kept to finalize the remaining statements
+	c.zombie = true
 	return nil
 }
 
 func (c *Conn) IsClosed() bool {
-	return c == nil || c.db == nil
+	return c == nil || c.db == nil || c.zombie
 }
 
 // EnableLoadExtension enables or disables extension loading.
@@ -585,10 +1261,16 @@ func (c *Conn) LoadExtension(file string, proc ...string) error {
 }
 
 // EnableSharedCache enables or disables shared pager cache
+// for the connections opened afterwards (see Conn.SharedCache)
 // (See http://sqlite.org/c3ref/enable_shared_cache.html)
 func EnableSharedCache(b bool) error {
 	rv := C.sqlite3_enable_shared_cache(btocint(b))
 	if rv == C.SQLITE_OK {
+		var enabled int32
+		if b {
+			enabled = 1
+		}
+		atomic.StoreInt32(&sharedCacheEnabled, enabled)
 		return nil
 	}
 	return Errno(rv)
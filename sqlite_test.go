@@ -5,9 +5,15 @@
 package sqlite_test
 
 import (
+	"fmt"
 	. "github.com/gwenn/gosqlite"
+	"github.com/gwenn/gosqlite/sqlitetest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -18,11 +24,7 @@ func checkNoError(t *testing.T, err error, format string) {
 }
 
 func open(t *testing.T) *Conn {
-	db, err := Open(":memory:", OpenReadWrite, OpenCreate, OpenFullMutex /*OpenNoMutex*/)
-	checkNoError(t, err, "couldn't open database file: %s")
-	if db == nil {
-		t.Fatal("opened database is nil")
-	}
+	db := sqlitetest.Open(t)
 	//db.SetLockingMode("", "exclusive")
 	//db.SetSynchronous("", 0)
 	//db.Profile(profile, t)
@@ -49,6 +51,10 @@ func TestVersion(t *testing.T) {
 	if !strings.HasPrefix(v, "3") {
 		t.Fatalf("unexpected library version: %s", v)
 	}
+	n := VersionNumber()
+	assertEquals(t, "expected %q but got %q", v, fmt.Sprintf("%d.%d.%d", n/1000000, n/1000%1000, n%1000))
+	assert(t, "source id expected", len(SourceID()) > 0)
+	assert(t, "thread-safe library expected", Threadsafe() != 0)
 }
 
 func TestOpen(t *testing.T) {
@@ -60,6 +66,91 @@ func TestOpenFailure(t *testing.T) {
 	db, err := Open("doesnotexist.sqlite", OpenReadOnly)
 	assert(t, "open failure expected", db == nil && err != nil)
 	//println(err.Error())
+	cerr, ok := err.(*ConnError)
+	if !ok {
+		t.Fatalf("ConnError expected but got %T", err)
+	}
+	assertEquals(t, "expected %s but got %s", ErrCantOpen, cerr.Code())
+	assertEquals(t, "expected %d but got %d", int(syscall.ENOENT), cerr.SystemErrno())
+	assertEquals(t, "expected %q but got %q", "", cerr.Filename())
+}
+
+func TestConstraintError(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	_, err := db.EnableFKey(true)
+	checkNoError(t, err, "couldn't enable foreign keys: %s")
+	err = db.Exec("CREATE TABLE parent (id INTEGER PRIMARY KEY, a TEXT NOT NULL, b TEXT, UNIQUE (a, b));" +
+		"CREATE TABLE child (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parent(id), n INTEGER CONSTRAINT positive CHECK (n > 0));" +
+		"CREATE TABLE typed (id INTEGER PRIMARY KEY, n INTEGER) STRICT;" +
+		"INSERT INTO parent VALUES (1, 'x', 'y')")
+	checkNoError(t, err, "couldn't create tables: %s")
+
+	for _, c := range []struct {
+		sql     string
+		kind    ConstraintKind
+		table   string
+		columns string
+		name    string
+	}{
+		{"INSERT INTO parent VALUES (2, 'x', 'y')", ConstraintUnique, "parent", "[a b]", ""},
+		{"INSERT INTO parent VALUES (1, 'z', 'y')", ConstraintPrimaryKey, "parent", "[id]", ""},
+		{"INSERT INTO parent (id, b) VALUES (3, 'y')", ConstraintNotNull, "parent", "[a]", ""},
+		{"INSERT INTO child VALUES (1, 1, 0)", ConstraintCheck, "", "[]", "positive"},
+		{"INSERT INTO child VALUES (1, 2, 1)", ConstraintForeignKey, "", "[]", ""},
+		{"INSERT INTO typed VALUES (1, 'x')", ConstraintDatatype, "typed", "[n]", ""},
+	} {
+		err = db.Exec(c.sql)
+		ce, ok := AsConstraintError(err)
+		if !ok {
+			t.Fatalf("constraint error expected for %q but got %v", c.sql, err)
+		}
+		assertEquals(t, "expected %s but got %s", c.kind, ce.Kind)
+		assertEquals(t, "expected %q but got %q", c.table, ce.Table)
+		assertEquals(t, "expected %q but got %q", c.columns, fmt.Sprint(ce.Columns))
+		assertEquals(t, "expected %q but got %q", c.name, ce.Name)
+		assertEquals(t, "expected %q but got %q", err.Error(), ce.Error())
+	}
+	_, ok := AsConstraintError(db.Exec("INSERT INTO unknown VALUES (1)"))
+	assert(t, "no constraint error expected", !ok)
+}
+
+func TestSystemErrno(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	err := db.Attach(filepath.Join(os.TempDir(), "gosqlite-does-not-exist", "test.db"), "other")
+	assert(t, "attach failure expected", err != nil)
+	cerr, ok := err.(interface {
+		Code() Errno
+		SystemErrno() int
+	})
+	if !ok {
+		t.Fatalf("ConnError or StmtError expected but got %T", err)
+	}
+	assertEquals(t, "expected %s but got %s", ErrCantOpen, cerr.Code())
+	assertEquals(t, "expected %d but got %d", int(syscall.ENOENT), cerr.SystemErrno())
+	assert(t, "errno expected in message", strings.Contains(err.Error(), syscall.ENOENT.Error()))
+	assertEquals(t, "expected %q but got %q", "database is locked", ErrBusy.String())
+}
+
+func TestOpenFlags(t *testing.T) {
+	invalids := [][]OpenFlag{
+		{OpenReadOnly, OpenReadWrite},
+		{OpenCreate},
+		{OpenReadOnly, OpenCreate},
+		{OpenReadWrite, OpenNoMutex, OpenFullMutex},
+		{OpenReadWrite, OpenSharedCache, OpenPrivateCache},
+	}
+	for _, flags := range invalids {
+		db, err := Open(":memory:", flags...)
+		assert(t, "invalid flags expected", db == nil && err != nil)
+	}
+
+	db, err := Open("dummy", OpenReadWrite, OpenCreate, OpenMemory, OpenNoFollow, OpenExResCode, OpenPrivateCache)
+	checkNoError(t, err, "couldn't open in-memory database: %s")
+	defer checkClose(db, t)
+	assertEquals(t, "expected %q but got %q", "", db.Filename("main"))
+	createTable(db, t)
 }
 
 func TestEnableFKey(t *testing.T) {
@@ -82,6 +173,33 @@ func TestEnableTriggers(t *testing.T) {
 	}
 }
 
+func TestDoubleQuotedStrings(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	dml, ddl, err := db.DoubleQuotedStrings()
+	checkNoError(t, err, "couldn't read DQS settings: %s")
+
+	err = db.ExecScript(`INSERT INTO test (a_string) VALUES ("misquoted")`)
+	assert(t, "no such column error expected", err != nil)
+	dml2, ddl2, err := db.DoubleQuotedStrings()
+	checkNoError(t, err, "couldn't read DQS settings: %s")
+	assert(t, "DQS settings should be restored", dml == dml2 && ddl == ddl2)
+
+	checkNoError(t, db.SetDoubleQuotedStrings(false), "couldn't disable DQS: %s")
+	dml, ddl, err = db.DoubleQuotedStrings()
+	checkNoError(t, err, "couldn't read DQS settings: %s")
+	assert(t, "DQS should be disabled", !dml && !ddl)
+	err = db.Exec(`INSERT INTO test (a_string) VALUES ("misquoted")`)
+	assert(t, "no such column error expected", err != nil)
+
+	checkNoError(t, db.SetDoubleQuotedStrings(true), "couldn't enable DQS: %s")
+	checkNoError(t, db.ExecScript(`INSERT INTO test (a_string) VALUES ("legacy")`), "script error: %s")
+	var s string
+	checkNoError(t, db.OneValue("SELECT a_string FROM test", &s), "select error: %s")
+	assertEquals(t, "expected %q but got %q", "legacy", s)
+}
+
 func TestEnableExtendedResultCodes(t *testing.T) {
 	db := open(t)
 	defer checkClose(db, t)
@@ -126,6 +244,49 @@ func TestExists(t *testing.T) {
 	assert(t, "One row expected", b)
 }
 
+func TestCount(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE counted (a INTEGER); CREATE TABLE norowid (a INTEGER PRIMARY KEY) WITHOUT ROWID"), "create error: %s")
+	n, err := db.FastCount("counted")
+	checkNoError(t, err, "count error: %s")
+	assertEquals(t, "expected %d but got %d", int64(0), n)
+	checkNoError(t, db.Exec("INSERT INTO counted VALUES (1), (2), (3); INSERT INTO norowid VALUES (1), (2)"), "insert error: %s")
+
+	n, err = db.Count("counted", "")
+	checkNoError(t, err, "count error: %s")
+	assertEquals(t, "expected %d but got %d", int64(3), n)
+	n, err = db.Count("counted", "a >= ?", 2)
+	checkNoError(t, err, "count error: %s")
+	assertEquals(t, "expected %d but got %d", int64(2), n)
+	n, err = db.FastCount("counted")
+	checkNoError(t, err, "count error: %s")
+	assertEquals(t, "expected %d but got %d", int64(3), n)
+	n, err = db.FastCount("norowid")
+	checkNoError(t, err, "count error: %s")
+	assertEquals(t, "expected %d but got %d", int64(2), n)
+	// a column named _rowid_ must not be mistaken for the rowid
+	checkNoError(t, db.Exec("CREATE TEMP TABLE aliased (_rowid_ INTEGER PRIMARY KEY, a) WITHOUT ROWID; INSERT INTO aliased VALUES (10, 'a')"), "create error: %s")
+	n, err = db.FastCount("aliased")
+	checkNoError(t, err, "count error: %s")
+	assertEquals(t, "expected %d but got %d", int64(1), n)
+	_, err = db.FastCount("missing")
+	assert(t, "error expected with missing table", err != nil)
+}
+
+func TestGenericOneValue(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	n, found, err := OneValue[int64](db, "SELECT count(*) FROM (SELECT 1 UNION SELECT 2)")
+	checkNoError(t, err, "couldn't query one value: %s")
+	assert(t, "one row expected", found)
+	assertEquals(t, "expected %d but got %d", int64(2), n)
+	s, found, err := OneValue[string](db, "SELECT 'a' WHERE 1 = ?", 0)
+	checkNoError(t, err, "couldn't query one value: %s")
+	assert(t, "no row expected", !found)
+	assertEquals(t, "expected %q but got %q", "", s)
+}
+
 func TestInsert(t *testing.T) {
 	db := open(t)
 	defer checkClose(db, t)
@@ -213,6 +374,115 @@ func TestConnInitialState(t *testing.T) {
 	readonly, err := db.Readonly("main")
 	checkNoError(t, err, "Readonly status error: %s")
 	assert(t, "readonly expected to be unset by default", !readonly)
+	checkNoError(t, db.ProbeWritable(""), "writable database expected: %s")
+	assert(t, "autocommit expected after probe", db.GetAutocommit())
+}
+
+func TestSetReadOnly(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	checkNoError(t, db.SetReadOnly(true), "couldn't enable read-only mode: %s")
+	assert(t, "read-only mode expected", db.IsReadOnlyEnforced())
+	err := db.Exec("INSERT INTO test (a_string) VALUES ('denied')")
+	assert(t, "write error expected", err != nil)
+	s, err := db.Prepare("DELETE FROM test")
+	assert(t, "write error expected", err != nil && s == nil)
+	b, err := db.Exists("SELECT 1 FROM test")
+	checkNoError(t, err, "read error: %s")
+	assert(t, "no row expected", !b)
+	columns, err := db.Columns("", "test")
+	checkNoError(t, err, "couldn't read columns: %s")
+	assertEquals(t, "expected %d columns but got %d", 4, len(columns))
+	_, err = db.Indexes("", "test")
+	checkNoError(t, err, "couldn't read indexes: %s")
+	checkNoError(t, db.IntegrityCheck("", 1, true), "couldn't check integrity: %s")
+	err = db.SetSynchronous("", 0)
+	assert(t, "pragma assignment error expected", err != nil)
+	checkNoError(t, db.SetReadOnly(false), "couldn't disable read-only mode: %s")
+	assert(t, "read-only mode not expected", !db.IsReadOnlyEnforced())
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('allowed')"), "insert error: %s")
+}
+
+func TestSingleStatement(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	db.SetSingleStatement(true)
+	assert(t, "single-statement mode expected", db.SingleStatement())
+	err := db.Exec("INSERT INTO test (a_string) VALUES ('a'); DROP TABLE test")
+	assert(t, "multiple statements error expected", err != nil)
+	s, err := db.Prepare("SELECT 1; DELETE FROM test")
+	assert(t, "multiple statements error expected", err != nil && s == nil)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('b'); -- comment"), "insert error: %s")
+	checkNoError(t, db.ExecScript("INSERT INTO test (a_string) VALUES ('c'); INSERT INTO test (a_string) VALUES ('d')"), "script error: %s")
+	var count int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM test", &count), "count error: %s")
+	assertEquals(t, "expected %d but got %d", 3, count)
+	db.SetSingleStatement(false)
+	checkNoError(t, db.Exec("DELETE FROM test; DROP TABLE test"), "exec error: %s")
+}
+
+func TestExecMulti(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	err := db.ExecMulti("INSERT INTO test (a_string) VALUES (?); -- comment\n"+
+		"INSERT INTO test (a_string, float_num) VALUES (?, ?); UPDATE test SET int_num = 1",
+		[]interface{}{"a"}, []interface{}{"b", 3.14})
+	checkNoError(t, err, "exec error: %s")
+	var count int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM test WHERE int_num = 1", &count), "count error: %s")
+	assertEquals(t, "expected %d but got %d", 2, count)
+
+	err = db.ExecMulti("DELETE FROM test WHERE a_string = ?; INSERT INTO test (id) VALUES (?); DELETE FROM test",
+		[]interface{}{"a"}, []interface{}{"x"})
+	se, ok := err.(*ScriptError)
+	assert(t, "script error expected", ok)
+	assertEquals(t, "expected statement #%d but got #%d", 1, se.Index)
+	assert(t, "failed statement expected", strings.Contains(se.SQL, "INSERT INTO test (id) VALUES (?)"))
+	checkNoError(t, db.OneValue("SELECT count(*) FROM test", &count), "count error: %s")
+	assertEquals(t, "expected %d but got %d", 1, count)
+
+	err = db.ExecMulti("SELECT 1", nil, []interface{}{1})
+	assert(t, "too many argument sets error expected", err != nil)
+}
+
+func TestCloseLeaks(t *testing.T) {
+	db := open(t)
+	createTable(db, t)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES (zeroblob(10))"), "insert error: %s")
+	db.SetLeakPolicy(LeakReport)
+	s, err := db.Prepare("SELECT * FROM test")
+	checkNoError(t, err, "prepare error: %s")
+	s.Cacheable = false
+	_, err = db.NewBlobReader("main", "test", "a_string", db.LastInsertRowid())
+	checkNoError(t, err, "blob open error: %s")
+
+	err = db.Close()
+	leaks, ok := err.(*LeakError)
+	assert(t, "leak error expected", ok)
+	assertEquals(t, "expected %v but got %v", "[SELECT * FROM test]", fmt.Sprint(leaks.Statements))
+	assertEquals(t, "expected %d but got %d", 1, leaks.Blobs)
+	assert(t, "closed connection expected", db.IsClosed())
+}
+
+func TestCloseV2(t *testing.T) {
+	db := open(t)
+	createTable(db, t)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('a'), ('b')"), "insert error: %s")
+	s, err := db.Prepare("SELECT a_string FROM test ORDER BY 1")
+	checkNoError(t, err, "prepare error: %s")
+	assert(t, "row expected", Must(s.Next()))
+
+	checkNoError(t, db.CloseV2(), "close error: %s")
+	assert(t, "closed connection expected", db.IsClosed())
+	var value string
+	assert(t, "row expected", Must(s.Next()))
+	checkNoError(t, s.Scan(&value), "scan error: %s")
+	assertEquals(t, "expected %q but got %q", "b", value)
+	checkFinalize(s, t)
+	checkNoError(t, db.Close(), "close error: %s")
 }
 
 func TestReadonlyMisuse(t *testing.T) {
@@ -224,6 +494,34 @@ func TestReadonlyMisuse(t *testing.T) {
 	//println(err.Error())
 }
 
+func TestFilenames(t *testing.T) {
+	f, err := ioutil.TempFile("", "gosqlite-filenames")
+	checkNoError(t, err, "couldn't create temp file: %s")
+	checkNoError(t, f.Close(), "couldn't close temp file: %s")
+	defer os.Remove(f.Name())
+
+	db, err := Open("file:"+f.Name()+"?foo=bar&size=12&flag=on", OpenUri, OpenReadWrite, OpenCreate, OpenFullMutex)
+	checkNoError(t, err, "couldn't open database file: %s")
+	defer checkClose(db, t)
+
+	assertEquals(t, "expected %q but got %q", f.Name()+"-journal", db.JournalFilename("main"))
+	assertEquals(t, "expected %q but got %q", f.Name()+"-wal", db.WalFilename("main"))
+	value, ok := db.UriParameter("main", "foo")
+	assert(t, "foo parameter expected", ok)
+	assertEquals(t, "expected %q but got %q", "bar", value)
+	_, ok = db.UriParameter("main", "missing")
+	assert(t, "no parameter expected", !ok)
+	assertEquals(t, "expected %d but got %d", int64(12), db.UriInt64("main", "size", 0))
+	assert(t, "flag expected", db.UriBoolean("main", "flag", false))
+	assert(t, "default expected", db.UriBoolean("main", "missing", true))
+
+	mem := open(t)
+	defer checkClose(mem, t)
+	assertEquals(t, "expected %q but got %q", "", mem.WalFilename("main"))
+	_, ok = mem.UriParameter("main", "foo")
+	assert(t, "no parameter expected", !ok)
+}
+
 func TestConnSettings(t *testing.T) {
 	db := open(t)
 	defer checkClose(db, t)
@@ -237,6 +535,16 @@ func TestComplete(t *testing.T) {
 	assert(t, "expected complete statement", Complete("SELECT 1;"))
 }
 
+func TestStrGlobLike(t *testing.T) {
+	assert(t, "glob match expected", StrGlob("a*c?", "abbcd"))
+	assert(t, "glob is case sensitive", !StrGlob("A*", "abc"))
+	assert(t, "glob character class expected", StrGlob("[a-c]x", "bx"))
+	assert(t, "like match expected", StrLike("A%c_", "abbcd", 0))
+	assert(t, "like mismatch expected", !StrLike("a_", "abc", 0))
+	assert(t, "escaped like match expected", StrLike("100\\%", "100%", '\\'))
+	assert(t, "escaped like mismatch expected", !StrLike("100\\%", "1000", '\\'))
+}
+
 func TestExecMisuse(t *testing.T) {
 	db := open(t)
 	defer checkClose(db, t)
@@ -259,6 +567,24 @@ func TestTransaction(t *testing.T) {
 	checkNoError(t, err, "error: %s")
 }
 
+func TestTransactionWrappedError(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	err := db.Transaction(Immediate, func(_ *Conn) error {
+		err := db.Transaction(Immediate, func(_ *Conn) error {
+			if err := db.Exec("INSERT INTO unknown VALUES (1)"); err != nil {
+				return fmt.Errorf("insert: %w", err)
+			}
+			return nil
+		})
+		assert(t, "error expected", err != nil)
+		assert(t, "rollback of the whole transaction expected", db.GetAutocommit())
+		return err
+	})
+	assert(t, "error expected", err != nil)
+}
+
 func assertEquals(t *testing.T, format string, expected, actual interface{}) {
 	if expected != actual {
 		t.Errorf(format, expected, actual)
@@ -0,0 +1,253 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlitetest
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gwenn/gosqlite"
+)
+
+// FaultKind is the kind of fault injected by a FaultVFS.
+type FaultKind int
+
+// Fault kinds
+const (
+	WriteError FaultKind = iota // the write fails with SQLITE_IOERR (nothing is written)
+	SyncError                   // the sync fails with SQLITE_IOERR
+	TornWrite                   // the system crashes while only the first half of the data is written
+	Crash                       // the system crashes before the write
+)
+
+// Fault is injected by a FaultVFS at the (After+1)th write (or sync for SyncError)
+// on the files whose name ends with File.
+type Fault struct {
+	Kind  FaultKind
+	After int    // number of matching writes (or syncs) let through before the fault
+	File  string // suffix of the affected files (like "-journal" or "-wal"), empty for all files
+}
+
+type undo struct {
+	offset int64
+	data   []byte
+}
+
+// unsynced records how to revert the writes done on a file since its last sync.
+type unsynced struct {
+	size  int64 // size at the last sync
+	undos []undo
+}
+
+// FaultVFS is a VFS shim (see sqlite.RegisterVFSShim) injecting I/O faults
+// to test the recovery paths of an application:
+//
+//	fv := sqlitetest.NewFaultVFS(t)
+//	db := sqlitetest.Open(t, sqlitetest.TempFile(), sqlitetest.VFS(fv.Name()))
+//	fv.Inject(sqlitetest.Fault{Kind: sqlitetest.TornWrite, File: "-journal"})
+//	err := db.Exec(...) // fails
+//	db = sqlitetest.Reopen(t, db)
+//	sqlitetest.AssertIntegrity(t, db)
+//
+// A simulated crash reverts the writes not synced yet (but not the truncates and deletes)
+// and makes all the following file operations fail until Reset.
+type FaultVFS struct {
+	name     string
+	mu       sync.Mutex
+	faults   []Fault
+	counts   []int // matching operations seen by each fault
+	crashed  bool
+	unsynced map[string]*unsynced
+}
+
+var faultVFSCount int32
+
+// NewFaultVFS registers a new FaultVFS on top of the default VFS.
+// It is unregistered when the test completes.
+func NewFaultVFS(t testing.TB) *FaultVFS {
+	t.Helper()
+	f := &FaultVFS{
+		name:     fmt.Sprintf("sqlitetest-fault-%d", atomic.AddInt32(&faultVFSCount, 1)),
+		unsynced: make(map[string]*unsynced),
+	}
+	if err := sqlite.RegisterVFSShim(f.name, "", f.shim, false); err != nil {
+		t.Fatalf("couldn't register fault VFS: %s", err)
+	}
+	t.Cleanup(func() {
+		sqlite.UnregisterVFSShim(f.name)
+	})
+	return f
+}
+
+// Name returns the name of the VFS (see VFS and sqlite.OpenVfs).
+func (f *FaultVFS) Name() string {
+	return f.name
+}
+
+// Inject schedules a fault.
+func (f *FaultVFS) Inject(fault Fault) {
+	f.mu.Lock()
+	f.faults = append(f.faults, fault)
+	f.counts = append(f.counts, 0)
+	f.mu.Unlock()
+}
+
+// Crash simulates a system crash now.
+func (f *FaultVFS) Crash() {
+	f.mu.Lock()
+	f.crash()
+	f.mu.Unlock()
+}
+
+// Crashed tells if a crash has been simulated (since the last Reset).
+func (f *FaultVFS) Crashed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.crashed
+}
+
+// Reset removes the pending faults and lets the file operations succeed again after a crash.
+func (f *FaultVFS) Reset() {
+	f.mu.Lock()
+	f.faults, f.counts = nil, nil
+	f.crashed = false
+	f.unsynced = make(map[string]*unsynced)
+	f.mu.Unlock()
+}
+
+func (f *FaultVFS) shim(call *sqlite.VFSCall, next func() error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.crashed {
+		if call.Op == sqlite.VFSClose {
+			return next()
+		}
+		return sqlite.ErrIOErr
+	}
+	switch call.Op {
+	case sqlite.VFSWrite:
+		if fault, ok := f.trigger(call.File, false); ok {
+			switch fault.Kind {
+			case WriteError:
+				return sqlite.ErrIOErr
+			case TornWrite:
+				f.record(call)
+				f.crash()
+				if len(call.File) > 0 {
+					if file, err := os.OpenFile(call.File, os.O_WRONLY, 0); err == nil {
+						file.WriteAt(call.Data[:len(call.Data)/2], call.Offset)
+						file.Close()
+					}
+				}
+				return sqlite.ErrIOErr
+			case Crash:
+				f.crash()
+				return sqlite.ErrIOErr
+			}
+		}
+		f.record(call)
+	case sqlite.VFSSync:
+		if _, ok := f.trigger(call.File, true); ok {
+			return sqlite.ErrIOErr
+		}
+		if err := next(); err != nil {
+			return err
+		}
+		delete(f.unsynced, call.File)
+		return nil
+	}
+	return next()
+}
+
+// trigger returns the fault to inject (if any) for the current write or sync on file.
+func (f *FaultVFS) trigger(file string, sync bool) (Fault, bool) {
+	for i, fault := range f.faults {
+		if (fault.Kind == SyncError) != sync || !strings.HasSuffix(file, fault.File) {
+			continue
+		}
+		if f.counts[i] < fault.After {
+			f.counts[i]++
+			continue
+		}
+		f.faults = append(f.faults[:i], f.faults[i+1:]...)
+		f.counts = append(f.counts[:i], f.counts[i+1:]...)
+		return fault, true
+	}
+	return Fault{}, false
+}
+
+// record saves the data overwritten by the write (temporary files are ignored).
+func (f *FaultVFS) record(call *sqlite.VFSCall) {
+	if len(call.File) == 0 {
+		return
+	}
+	u, ok := f.unsynced[call.File]
+	if !ok {
+		size, err := call.Size()
+		if err != nil {
+			return
+		}
+		u = &unsynced{size: size}
+		f.unsynced[call.File] = u
+	}
+	old := make([]byte, len(call.Data))
+	if file, err := os.Open(call.File); err == nil {
+		n, _ := file.ReadAt(old, call.Offset)
+		old = old[:n]
+		file.Close()
+	} else {
+		old = old[:0]
+	}
+	u.undos = append(u.undos, undo{call.Offset, old})
+}
+
+// crash reverts the unsynced writes.
+func (f *FaultVFS) crash() {
+	f.crashed = true
+	for name, u := range f.unsynced {
+		file, err := os.OpenFile(name, os.O_WRONLY, 0)
+		if err != nil {
+			continue
+		}
+		for i := len(u.undos) - 1; i >= 0; i-- {
+			file.WriteAt(u.undos[i].data, u.undos[i].offset)
+		}
+		file.Truncate(u.size)
+		file.Close()
+	}
+	f.unsynced = make(map[string]*unsynced)
+}
+
+// Reopen closes db (ignoring the errors, as after a crash) and returns a new connection
+// to its main database file, with the default VFS:
+// a hot journal left by a crash is rolled back on first access.
+// The new connection is closed when the test completes.
+func Reopen(t testing.TB, db *sqlite.Conn) *sqlite.Conn {
+	t.Helper()
+	filename := db.Filename("main")
+	db.Close()
+	db, err := sqlite.Open(filename, sqlite.OpenReadWrite, sqlite.OpenFullMutex)
+	if err != nil {
+		t.Fatalf("couldn't reopen database file: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("error closing database: %s", err)
+		}
+	})
+	return db
+}
+
+// AssertIntegrity checks the integrity of the main database (see Conn.IntegrityCheck).
+func AssertIntegrity(t testing.TB, db *sqlite.Conn) {
+	t.Helper()
+	if err := db.IntegrityCheck("main", 10, false); err != nil {
+		t.Errorf("integrity check failed: %s", err)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlitetest_test
+
+import (
+	"testing"
+
+	. "github.com/gwenn/gosqlite/sqlitetest"
+)
+
+func TestFaultVFS(t *testing.T) {
+	for _, fault := range []Fault{
+		{Kind: WriteError, File: "-journal"},
+		{Kind: SyncError, File: "-journal"},
+		{Kind: TornWrite, After: 2},
+		{Kind: Crash, After: 5},
+	} {
+		fv := NewFaultVFS(t)
+		db := Open(t, TempFile(), VFS(fv.Name()))
+		MustExec(t, db, "CREATE TABLE t (v TEXT); WITH RECURSIVE s(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM s WHERE i < 100) INSERT INTO t SELECT printf('%0500d', i) FROM s")
+
+		fv.Inject(fault)
+		err := db.Exec("UPDATE t SET v = printf('%0600d', rowid); INSERT INTO t SELECT v FROM t")
+		if err == nil {
+			t.Fatalf("%#v: error expected", fault)
+		}
+		if crashed := fault.Kind == TornWrite || fault.Kind == Crash; crashed != fv.Crashed() {
+			t.Errorf("%#v: expected crashed %t", fault, crashed)
+		}
+		db = Reopen(t, db)
+		AssertIntegrity(t, db)
+		AssertRowCount(t, db, "t", 100)
+	}
+}
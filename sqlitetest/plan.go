@@ -0,0 +1,60 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlitetest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gwenn/gosqlite"
+)
+
+// PlanDetails returns the details of the query plan nodes of stmt (depth-first),
+// failing the test on error (see Conn.QueryPlan).
+func PlanDetails(t testing.TB, stmt *sqlite.Stmt) []string {
+	t.Helper()
+	roots, err := stmt.Conn().QueryPlan(stmt.SQL())
+	if err != nil {
+		t.Fatalf("error explaining %q: %s", stmt.SQL(), err)
+	}
+	var details []string
+	var walk func(nodes []*sqlite.QueryPlanNode)
+	walk = func(nodes []*sqlite.QueryPlanNode) {
+		for _, n := range nodes {
+			details = append(details, n.Detail)
+			walk(n.Children)
+		}
+	}
+	walk(roots)
+	return details
+}
+
+// AssertUsesIndex checks that the query plan of stmt uses the specified index.
+func AssertUsesIndex(t testing.TB, stmt *sqlite.Stmt, index string) {
+	t.Helper()
+	details := PlanDetails(t, stmt)
+	for _, detail := range details {
+		fields := strings.Fields(detail)
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] == "INDEX" && fields[i+1] == index {
+				return
+			}
+		}
+	}
+	t.Errorf("expected %q to use index %s but the plan is:\n%s", stmt.SQL(), index, strings.Join(details, "\n"))
+}
+
+// AssertNoFullScan checks that the query plan of stmt does not scan a whole table
+// (a full scan of an index, "SCAN t USING INDEX i", is reported too).
+func AssertNoFullScan(t testing.TB, stmt *sqlite.Stmt) {
+	t.Helper()
+	details := PlanDetails(t, stmt)
+	for _, detail := range details {
+		if strings.HasPrefix(detail, "SCAN ") && detail != "SCAN CONSTANT ROW" {
+			t.Errorf("expected no full scan in %q but the plan is:\n%s", stmt.SQL(), strings.Join(details, "\n"))
+			return
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sqlitetest provides helpers for tests using SQLite databases:
+// isolated databases closed (and removed) automatically, fixtures loading and assertions.
+package sqlitetest
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gwenn/gosqlite"
+)
+
+type config struct {
+	file     bool
+	wal      bool
+	vfs      string
+	fixtures []func(t testing.TB, db *sqlite.Conn)
+}
+
+// Option customizes the database returned by Open.
+type Option func(*config)
+
+// TempFile makes Open use a temporary file instead of an in-memory database
+// (needed to open other connections to the same database, see Conn.Filename).
+func TempFile() Option {
+	return func(c *config) {
+		c.file = true
+	}
+}
+
+// Wal makes Open use a temporary file in WAL journal mode.
+func Wal() Option {
+	return func(c *config) {
+		c.file = true
+		c.wal = true
+	}
+}
+
+// VFS makes Open use the specified VFS (see FaultVFS).
+func VFS(name string) Option {
+	return func(c *config) {
+		c.vfs = name
+	}
+}
+
+// SQLFixture makes Open execute the SQL statements of the specified file.
+func SQLFixture(path string) Option {
+	return func(c *config) {
+		c.fixtures = append(c.fixtures, func(t testing.TB, db *sqlite.Conn) {
+			LoadSQL(t, db, path)
+		})
+	}
+}
+
+// CSVFixture makes Open load the specified CSV file into table (see LoadCSV).
+func CSVFixture(table, path string) Option {
+	return func(c *config) {
+		c.fixtures = append(c.fixtures, func(t testing.TB, db *sqlite.Conn) {
+			LoadCSV(t, db, table, path)
+		})
+	}
+}
+
+// Open returns a new in-memory database (or a temporary file with TempFile or Wal),
+// with fixtures loaded in the order of the options.
+// The connection is closed and the files are removed when the test completes (see testing.TB.Cleanup).
+func Open(t testing.TB, opts ...Option) *sqlite.Conn {
+	t.Helper()
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	filename := ":memory:"
+	if cfg.file {
+		f, err := ioutil.TempFile("", "gosqlite-test")
+		if err != nil {
+			t.Fatalf("couldn't create temp file: %s", err)
+		}
+		filename = f.Name()
+		f.Close()
+		t.Cleanup(func() {
+			for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+				os.Remove(filename + suffix)
+			}
+		})
+	}
+	db, err := sqlite.OpenVfs(filename, cfg.vfs, sqlite.OpenReadWrite, sqlite.OpenCreate, sqlite.OpenFullMutex)
+	if err != nil {
+		t.Fatalf("couldn't open database file: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("error closing database: %s", err)
+		}
+	})
+	if cfg.wal {
+		if mode, err := db.SetJournalMode("", "wal"); err != nil {
+			t.Fatalf("couldn't set journal mode: %s", err)
+		} else if mode != "wal" {
+			t.Fatalf("expected wal journal mode but got %q", mode)
+		}
+	}
+	for _, fixture := range cfg.fixtures {
+		fixture(t, db)
+	}
+	return db
+}
+
+// LoadSQL executes the SQL statements of the specified file.
+func LoadSQL(t testing.TB, db *sqlite.Conn, path string) {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("couldn't read fixture: %s", err)
+	}
+	if err = db.ExecScript(string(b)); err != nil {
+		t.Fatalf("error loading %s: %s", path, err)
+	}
+}
+
+// LoadCSV inserts the records of the specified CSV file into table, in one transaction.
+// The first record gives the column names.
+// The table is created (without declared types) if it doesn't exist.
+// Empty fields are inserted as NULL.
+func LoadCSV(t testing.TB, db *sqlite.Conn, table, path string) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("couldn't open fixture: %s", err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("error reading %s: %s", path, err)
+	}
+	if len(records) == 0 {
+		t.Fatalf("no header in %s", path)
+	}
+	columns := make([]string, len(records[0]))
+	for i, name := range records[0] {
+		columns[i] = sqlite.Mprintf(`"%w"`, strings.TrimSpace(name))
+	}
+	err = db.Transaction(sqlite.Immediate, func(c *sqlite.Conn) error {
+		if err := c.Exec(sqlite.Mprintf(`CREATE TABLE IF NOT EXISTS "%w" (`, table) + strings.Join(columns, ", ") + ")"); err != nil {
+			return err
+		}
+		s, err := c.Prepare(sqlite.Mprintf(`INSERT INTO "%w" (`, table) + strings.Join(columns, ", ") +
+			") VALUES (" + strings.Repeat("?, ", len(columns)-1) + "?)")
+		if err != nil {
+			return err
+		}
+		defer s.Finalize()
+		args := make([]interface{}, len(columns))
+		for _, record := range records[1:] {
+			for i, field := range record {
+				if len(field) == 0 {
+					args[i] = nil
+				} else {
+					args[i] = field
+				}
+			}
+			if err := s.Exec(args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error loading %s: %s", path, err)
+	}
+}
+
+// MustExec executes the SQL statement(s), failing the test on error.
+func MustExec(t testing.TB, db *sqlite.Conn, cmd string, args ...interface{}) {
+	t.Helper()
+	if err := db.Exec(cmd, args...); err != nil {
+		t.Fatalf("error executing %q: %s", cmd, err)
+	}
+}
+
+// RowCount returns the number of rows in table, failing the test on error.
+func RowCount(t testing.TB, db *sqlite.Conn, table string) int {
+	t.Helper()
+	var n int
+	if err := db.OneValue(sqlite.Mprintf(`SELECT count(*) FROM "%w"`, table), &n); err != nil {
+		t.Fatalf("error counting rows of %s: %s", table, err)
+	}
+	return n
+}
+
+// AssertRowCount checks that table contains the expected number of rows.
+func AssertRowCount(t testing.TB, db *sqlite.Conn, table string, expected int) {
+	t.Helper()
+	if n := RowCount(t, db, table); n != expected {
+		t.Errorf("expected %d rows in %s but got %d", expected, table, n)
+	}
+}
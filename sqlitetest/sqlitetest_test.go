@@ -0,0 +1,89 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlitetest_test
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/gwenn/gosqlite/sqlitetest"
+)
+
+func TestOpenFixtures(t *testing.T) {
+	db := Open(t, SQLFixture("testdata/person.sql"), CSVFixture("person", "testdata/person.csv"))
+	AssertRowCount(t, db, "person", 4)
+	var name string
+	var age interface{}
+	if err := db.OneValue("SELECT name FROM person WHERE id = 4", &name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "dave, jr" {
+		t.Errorf("expected %q but got %q", "dave, jr", name)
+	}
+	if err := db.OneValue("SELECT age FROM person WHERE id = 4", &age); err != nil {
+		t.Fatal(err)
+	}
+	if age != nil {
+		t.Errorf("expected NULL but got %v", age)
+	}
+	MustExec(t, db, "DELETE FROM person WHERE age IS NULL")
+	AssertRowCount(t, db, "person", 2)
+}
+
+func TestOpenWal(t *testing.T) {
+	var filename string
+	t.Run("wal", func(t *testing.T) {
+		db := Open(t, Wal())
+		filename = db.Filename("main")
+		MustExec(t, db, "CREATE TABLE test (x); INSERT INTO test VALUES (1)")
+		if _, err := os.Stat(filename + "-wal"); err != nil {
+			t.Errorf("wal file expected: %s", err)
+		}
+	})
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("temp file %q not removed", filename)
+	}
+}
+
+// recorder records the failures of an assertion expected to fail.
+type recorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recorder) Helper() {
+}
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failed = true
+}
+
+func TestPlanAssertions(t *testing.T) {
+	db := Open(t)
+	MustExec(t, db, "CREATE TABLE item (id INTEGER PRIMARY KEY, name TEXT, price REAL); CREATE INDEX idx_item_name ON item (name)")
+	byName, err := db.Prepare("SELECT id FROM item WHERE name = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer byName.Finalize()
+	byPrice, err := db.Prepare("SELECT id FROM item WHERE price > ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer byPrice.Finalize()
+
+	AssertUsesIndex(t, byName, "idx_item_name")
+	AssertNoFullScan(t, byName)
+
+	r := &recorder{TB: t}
+	AssertUsesIndex(r, byPrice, "idx_item_name")
+	if !r.failed {
+		t.Error("expected AssertUsesIndex to fail")
+	}
+	r = &recorder{TB: t}
+	AssertNoFullScan(r, byPrice)
+	if !r.failed {
+		t.Error("expected AssertNoFullScan to fail")
+	}
+}
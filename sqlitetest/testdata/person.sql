@@ -0,0 +1,2 @@
+CREATE TABLE person (id INTEGER PRIMARY KEY, name TEXT NOT NULL, age INTEGER);
+INSERT INTO person (name, age) VALUES ('alice', 42), ('bob', NULL);
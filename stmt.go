@@ -28,6 +28,53 @@ static int my_bind_blob(sqlite3_stmt *stmt, int n, void *p, int np) {
 static int my_prepare_v2(sqlite3 *db, const char *zSql, int nByte, sqlite3_stmt **ppStmt, char **pzTail) {
 	return sqlite3_prepare_v2(db, zSql, nByte, ppStmt, (const char**)pzTail);
 }
+
+// Column value retrieved by my_step_row
+typedef struct {
+	int type;
+	int n;
+	sqlite3_int64 i;
+	double d;
+	const void *p;
+} my_column;
+
+// Returns the type of the column value and its size for a text or blob (no conversion).
+static int my_column_peek(sqlite3_stmt *stmt, int i, int *n) {
+	int type = sqlite3_column_type(stmt, i);
+	*n = (type == SQLITE_TEXT || type == SQLITE_BLOB) ? sqlite3_column_bytes(stmt, i) : 0;
+	return type;
+}
+
+// Steps and retrieves all column values of the new row in one cgo call (see Stmt.NextRow).
+static int my_step_row(sqlite3_stmt *stmt, my_column *cols, int ncol) {
+	int i;
+	int rv = sqlite3_step(stmt);
+	if (rv != SQLITE_ROW) {
+		sqlite3_reset(stmt);
+		return rv;
+	}
+	for (i = 0; i < ncol; i++) {
+		my_column *col = &cols[i];
+		col->type = sqlite3_column_type(stmt, i);
+		switch (col->type) {
+		case SQLITE_INTEGER:
+			col->i = sqlite3_column_int64(stmt, i);
+			break;
+		case SQLITE_FLOAT:
+			col->d = sqlite3_column_double(stmt, i);
+			break;
+		case SQLITE_TEXT:
+			col->p = sqlite3_column_text(stmt, i);
+			col->n = sqlite3_column_bytes(stmt, i);
+			break;
+		case SQLITE_BLOB:
+			col->p = sqlite3_column_blob(stmt, i);
+			col->n = sqlite3_column_bytes(stmt, i);
+			break;
+		}
+	}
+	return rv;
+}
 */
 import "C"
 
@@ -38,6 +85,10 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 	"unsafe"
 )
@@ -59,17 +110,42 @@ func (s *Stmt) error(rv C.int, details ...string) error {
 	if rv == C.SQLITE_OK {
 		return nil
 	}
-	err := ConnError{c: s.c, code: Errno(rv), msg: C.GoString(C.sqlite3_errmsg(s.c.db))}
+	s.c.countBusy(rv)
+	err := ConnError{c: s.c, code: Errno(rv), msg: C.GoString(C.sqlite3_errmsg(s.c.db)), sysErrno: s.c.systemErrno(rv),
+		extCode: int(C.sqlite3_extended_errcode(s.c.db))}
 	if len(details) > 0 {
 		err.details = details[0]
 	}
-	return &StmtError{err, s}
+	serr := &StmtError{err, s}
+	s.c.checkCorruption(serr, err.extCode)
+	return s.c.commitAborted(serr, err.extCode)
 }
 
 func (s *Stmt) specificError(msg string, a ...interface{}) error {
 	return &StmtError{ConnError{c: s.c, code: ErrSpecific, msg: fmt.Sprintf(msg, a...)}, s}
 }
 
+// ErrNullColumn is wrapped by the *NullColumnError returned when a NULL value is scanned
+// into a non-nullable destination (see Stmt.StrictNull).
+var ErrNullColumn = errors.New("sqlite NULL column scanned into a non-nullable destination")
+
+// NullColumnError tells which NULL column has been scanned into a non-nullable destination.
+type NullColumnError struct {
+	SQL    string
+	Index  int
+	Column string
+	Type   string // destination type
+}
+
+func (e *NullColumnError) Error() string {
+	return fmt.Sprintf("%s: column #%d %q scanned into %s (%q)", ErrNullColumn, e.Index, e.Column, e.Type, e.SQL)
+}
+
+// Unwrap returns ErrNullColumn.
+func (e *NullColumnError) Unwrap() error {
+	return ErrNullColumn
+}
+
 // SQL statement
 // (See http://sqlite.org/c3ref/stmt.html)
 type Stmt struct {
@@ -78,11 +154,23 @@ type Stmt struct {
 	sql                string
 	tail               string
 	columnCount        int
+	reprepares         int            // SQLITE_STMTSTATUS_REPREPARE when the cached metadata were computed
 	cols               map[string]int // cached columns index by name
 	bindParameterCount int
-	params             map[string]int // cached parameter index by name
-	// Enable type check in Scan methods (default true)
-	CheckTypeMismatch bool
+	params             map[string]int     // cached parameter index by name
+	paramIndices       []int              // cached indexes of the parameters actually used
+	bound              []bool             // parameters bound since the last ClearBindings (see CheckBindings)
+	declKinds          []declKind         // cached kinds of declared types (see Conn.SetTypeAffinity)
+	row                []C.my_column      // column buffer filled by my_step_row
+	rowValues          []interface{}      // values returned by NextRow
+	limits             *stmtLimits        // see SetMaxRows and SetMaxResultBytes
+	typeMismatch       TypeMismatchPolicy // see SetTypeMismatchPolicy
+	insert             int8               // 1 if the statement updates the last inserted rowid, -1 if not, 0 if unknown (see inserts)
+	withoutRowid       string             // WITHOUT ROWID table written by the statement when it doesn't update the last inserted rowid
+	executions         int                // executions started since the scan counters were collected (see collectScanStats)
+	// Make Scan methods fail with a *NullColumnError when a NULL value is scanned
+	// into a destination that cannot represent it (*string, *int, ...) instead of writing the zero value (default false)
+	StrictNull bool
 	// Tell if the stmt should be cached (default true)
 	Cacheable bool
 }
@@ -91,12 +179,22 @@ func (c *Conn) prepare(cmd string, args ...interface{}) (*Stmt, error) {
 	if c == nil {
 		return nil, errors.New("nil sqlite database")
 	}
+	if err := c.quarantined(); err != nil {
+		return nil, err
+	}
+	c.enter()
+	defer c.leave()
 	cmdstr := C.CString(cmd)
 	defer C.free(unsafe.Pointer(cmdstr))
 	var stmt *C.sqlite3_stmt
 	var tail *C.char
 	// If the caller knows that the supplied string is nul-terminated, then there is a small performance advantage to be gained by passing an nByte parameter that is equal to the number of bytes in the input string including the nul-terminator bytes as this saves SQLite from having to make a copy of the input string.
 	rv := C.my_prepare_v2(c.db, cmdstr, C.int(len(cmd)+1), &stmt, &tail)
+	if rv != C.SQLITE_OK && c.retryPolicy != nil { // the schema may be locked
+		rv = c.retry(func() C.int {
+			return C.my_prepare_v2(c.db, cmdstr, C.int(len(cmd)+1), &stmt, &tail)
+		}, c.autocommit)
+	}
 	if rv != C.SQLITE_OK {
 		return nil, c.error(rv, cmd)
 	}
@@ -104,7 +202,10 @@ func (c *Conn) prepare(cmd string, args ...interface{}) (*Stmt, error) {
 	if tail != nil && C.strlen(tail) > 0 {
 		t = C.GoString(tail)
 	}
-	s := &Stmt{c: c, stmt: stmt, tail: t, columnCount: -1, bindParameterCount: -1, CheckTypeMismatch: true}
+	s := &Stmt{c: c, stmt: stmt, tail: t, columnCount: -1, bindParameterCount: -1, typeMismatch: c.typeMismatch}
+	if debugLeaks && stmt != nil {
+		setLeakFinalizer(s, fmt.Sprintf("statement %q", cmd[:len(cmd)-len(t)]))
+	}
 	if len(args) > 0 {
 		err := s.Bind(args...)
 		if err != nil {
@@ -119,6 +220,12 @@ func (c *Conn) prepare(cmd string, args ...interface{}) (*Stmt, error) {
 // And optionally bind values.
 // (See sqlite3_prepare_v2: http://sqlite.org/c3ref/prepare.html)
 func (c *Conn) Prepare(cmd string, args ...interface{}) (*Stmt, error) {
+	if err := c.quarantined(); err != nil {
+		return nil, err
+	}
+	if c.stmtCache.checkSchema && c.stmtCache.maxSize > 0 {
+		c.checkSchemaVersion()
+	}
 	s := c.stmtCache.find(cmd)
 	if s != nil {
 		if len(args) > 0 {
@@ -132,17 +239,104 @@ func (c *Conn) Prepare(cmd string, args ...interface{}) (*Stmt, error) {
 	}
 	s, err := c.prepare(cmd, args...)
 	if s != nil {
+		if c.singleStatement && len(s.tail) > 0 {
+			if err = c.checkSingleStatement(cmd, s.tail); err != nil {
+				s.finalize()
+				return nil, err
+			}
+		}
 		s.Cacheable = true
 	}
 	return s, err
 }
 
+// Script iterates over the statements of a SQL script (statements separated by semi-colon).
+// Each statement is prepared lazily, when the previous one has been consumed,
+// so that it can depend on schema changes made by the previous ones.
+//
+//	script := db.PrepareAll(sql)
+//	defer script.Close()
+//	for script.Next() {
+//		err = script.Stmt().Exec(args...)
+//	}
+//	err = script.Err()
+type Script struct {
+	c    *Conn
+	tail string
+	s    *Stmt
+	err  error
+}
+
+// PrepareAll returns an iterator over all the statements of the specified script.
+// Statements are not cached.
+// Comments and white-spaces between statements are skipped.
+func (c *Conn) PrepareAll(sql string) *Script {
+	return &Script{c: c, tail: sql}
+}
+
+// Next finalizes the current statement and prepares the next one.
+// Returns false when there is no more statement or when an error occurred (see Script.Err).
+func (sc *Script) Next() bool {
+	if sc.err != nil {
+		return false
+	}
+	if sc.s != nil {
+		sc.err = sc.s.finalize()
+		sc.s = nil
+		if sc.err != nil {
+			return false
+		}
+	}
+	for len(sc.tail) > 0 {
+		s, err := sc.c.prepare(sc.tail)
+		if err != nil {
+			sc.err = err
+			return false
+		}
+		sc.tail = s.tail
+		if s.stmt == nil { // comment or white-space
+			continue
+		}
+		sc.s = s
+		return true
+	}
+	return false
+}
+
+// Stmt returns the current statement (valid until the next call to Script.Next or Script.Close).
+func (sc *Script) Stmt() *Stmt {
+	return sc.s
+}
+
+// Tail returns the part of the script not yet prepared.
+func (sc *Script) Tail() string {
+	return sc.tail
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (sc *Script) Err() error {
+	return sc.err
+}
+
+// Close finalizes the current statement (if any) and stops the iteration.
+func (sc *Script) Close() error {
+	sc.tail = ""
+	if sc.s == nil {
+		return nil
+	}
+	err := sc.s.finalize()
+	sc.s = nil
+	return err
+}
+
 // Exec is a one-step statement execution.
 // Don't use it with SELECT or anything that returns data.
 // The Stmt is reset at each call.
 // (See http://sqlite.org/c3ref/bind_blob.html, http://sqlite.org/c3ref/step.html)
 func (s *Stmt) Exec(args ...interface{}) error {
 	// TODO Check column count == 0
+	s.c.enter()
+	defer s.c.leave()
 	err := s.Bind(args...)
 	if err != nil {
 		return err
@@ -150,7 +344,12 @@ func (s *Stmt) Exec(args ...interface{}) error {
 	return s.exec()
 }
 func (s *Stmt) exec() error {
-	rv := C.sqlite3_step(s.stmt)
+	s.c.enter()
+	defer s.c.leave()
+	if err := s.c.quarantined(); err != nil {
+		return err
+	}
+	rv := s.step()
 	C.sqlite3_reset(s.stmt)
 	if Errno(rv) != Done {
 		return s.error(rv, "Stmt.exec")
@@ -158,26 +357,105 @@ func (s *Stmt) exec() error {
 	return nil
 }
 
+// execResult is like exec but also captures the number of rows modified by this statement
+// and the last inserted rowid before another statement can change them.
+func (s *Stmt) execResult() (changes, lastInsertRowid int64, err error) {
+	s.c.enter()
+	defer s.c.leave()
+	if err := s.c.quarantined(); err != nil {
+		return 0, 0, err
+	}
+	total := C.sqlite3_total_changes(s.c.db)
+	rv := s.step()
+	C.sqlite3_reset(s.stmt)
+	if Errno(rv) != Done {
+		return 0, 0, s.error(rv, "Stmt.exec")
+	}
+	if C.sqlite3_total_changes(s.c.db) != total { // sqlite3_changes is not reset by statements other than INSERT/UPDATE/DELETE
+		changes = int64(C.sqlite3_changes(s.c.db))
+	}
+	return changes, int64(C.sqlite3_last_insert_rowid(s.c.db)), nil
+}
+
 // ExecDml is like Exec but returns the number of rows that were changed or inserted or deleted.
-// Don't use it with SELECT or anything that returns data.
+// Don't use it with SELECT or anything that returns data
+// except INSERT/UPDATE/DELETE ... RETURNING (rows are then discarded, see Stmt.ExecReturning).
 // The Stmt is reset at each call.
 func (s *Stmt) ExecDml(args ...interface{}) (int, error) {
-	err := s.Exec(args...)
+	var err error
+	if s.ColumnCount() > 0 {
+		err = s.ExecReturning(func(s *Stmt) error { return nil }, args...)
+	} else {
+		err = s.Exec(args...)
+	}
 	if err != nil {
 		return -1, err
 	}
 	return s.c.Changes(), nil
 }
 
+// ExecReturning executes an INSERT/UPDATE/DELETE ... RETURNING statement:
+// it binds the specified args and invokes scanFn for each row returned.
+// All changes are applied even if scanFn returns an error.
+// The Stmt is reset at each call.
+// (See http://sqlite.org/lang_returning.html)
+//
+//	s, err := db.Prepare("UPDATE account SET balance = balance - ? WHERE id = ? RETURNING balance")
+//	err = s.ExecReturning(func(s *Stmt) error {
+//		return s.Scan(&balance)
+//	}, amount, id)
+func (s *Stmt) ExecReturning(scanFn func(s *Stmt) error, args ...interface{}) error {
+	s.c.enter()
+	defer s.c.leave()
+	if err := s.Bind(args...); err != nil {
+		return err
+	}
+	for {
+		if ok, err := s.Next(); err != nil {
+			return err
+		} else if !ok {
+			return nil
+		}
+		if err := scanFn(s); err != nil {
+			C.sqlite3_reset(s.stmt) // completes the statement
+			return err
+		}
+	}
+}
+
 // Insert is like ExecDml but returns the autoincremented rowid.
+// If the statement has a RETURNING clause, the first column of the first row returned is used instead
+// (for example "INSERT INTO t ... RETURNING pk" with WITHOUT ROWID tables where there is no rowid).
+// Without RETURNING clause, an error is returned for WITHOUT ROWID tables
+// (the statement is inspected once with EXPLAIN before its first execution).
 // Don't use it with SELECT or anything that returns data.
 // The Stmt is reset at each call.
 func (s *Stmt) Insert(args ...interface{}) (int64, error) {
+	if s.ColumnCount() > 0 {
+		id := int64(-1)
+		first := true
+		err := s.ExecReturning(func(s *Stmt) (err error) {
+			if first {
+				first = false
+				id, _, err = s.ScanInt64(0)
+			}
+			return
+		}, args...)
+		if err != nil {
+			return -1, err
+		}
+		return id, nil
+	}
+	inserts := s.inserts()
+	if !inserts && len(s.withoutRowid) > 0 {
+		return -1, s.specificError("no rowid for the WITHOUT ROWID table %q (use a RETURNING clause)", s.withoutRowid)
+	}
+	noInsert := s.c.strictInsertIds && !inserts // UPDATE or DELETE
 	n, err := s.ExecDml(args...)
 	if err != nil {
 		return -1, err
 	}
-	if n == 0 { // No change => no insert...
+	if n == 0 || noInsert { // No change => no insert...
 		return -1, nil
 	}
 	return s.c.LastInsertRowid(), nil
@@ -230,8 +508,8 @@ func (s *Stmt) SelectOneRow(args ...interface{}) (bool, error) {
 	return true, s.Scan(args...)
 }
 
-// BindParameterCount returns the number of SQL parameters.
-// FIXME If parameters of the ?NNN form are used, there may be gaps in the list.
+// BindParameterCount returns the index of the largest (rightmost) SQL parameter.
+// If parameters of the ?NNN form are used, there may be gaps in the list (see Stmt.BindParameterIndices).
 // (See http://sqlite.org/c3ref/bind_parameter_count.html)
 func (s *Stmt) BindParameterCount() int {
 	if s.bindParameterCount == -1 {
@@ -240,7 +518,49 @@ func (s *Stmt) BindParameterCount() int {
 	return s.bindParameterCount
 }
 
+// BindParameterIndices returns the indexes of the SQL parameters actually used (cached).
+// Without parameter of the ?NNN form, they are 1..BindParameterCount.
+// Otherwise, the SQL is scanned to tell the anonymous "?" parameters from the gaps.
+func (s *Stmt) BindParameterIndices() []int {
+	if s.paramIndices != nil {
+		return s.paramIndices
+	}
+	n := s.BindParameterCount()
+	indices := make([]int, 0, n)
+	var numbered bool
+	for i := 1; i <= n; i++ {
+		name := C.sqlite3_bind_parameter_name(s.stmt, C.int(i))
+		if name != nil && *name == '?' {
+			numbered = true
+			break
+		}
+	}
+	if numbered {
+		s.parameters(func(index int, _ string) {
+			indices = append(indices, index)
+		})
+		sort.Ints(indices)
+	} else {
+		for i := 1; i <= n; i++ {
+			indices = append(indices, i)
+		}
+	}
+	s.paramIndices = indices
+	return indices
+}
+
+// isParameterIndex tells if the specified index is used by a SQL parameter.
+func (s *Stmt) isParameterIndex(index int) bool {
+	for _, i := range s.BindParameterIndices() {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
 // BindParameterIndex returns the index of a parameter with a given name (cached).
+// The name may be specified without prefix ("id"): it is then resolved against ":id", "@id" or "$id".
 // The first host parameter has an index of 1, not 0.
 // (See http://sqlite.org/c3ref/bind_parameter_index.html)
 func (s *Stmt) BindParameterIndex(name string) (int, error) {
@@ -252,9 +572,14 @@ func (s *Stmt) BindParameterIndex(name string) (int, error) {
 	if ok {
 		return index, nil
 	}
-	cname := C.CString(name)
-	defer C.free(unsafe.Pointer(cname))
-	index = int(C.sqlite3_bind_parameter_index(s.stmt, cname))
+	index = s.bindParameterIndex(name)
+	if index == 0 && len(name) > 0 && strings.IndexByte(":@$?", name[0]) < 0 {
+		for _, prefix := range []string{":", "@", "$"} {
+			if index = s.prefixedParameterIndex(prefix, name); index > 0 {
+				break
+			}
+		}
+	}
 	if index == 0 {
 		return index, s.specificError("invalid parameter name: %q", name)
 	}
@@ -262,6 +587,28 @@ func (s *Stmt) BindParameterIndex(name string) (int, error) {
 	return index, nil
 }
 
+// nameBuffers pools the buffers used to pass NUL-terminated parameter names to SQLite.
+var nameBuffers = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 32)
+		return &b
+	},
+}
+
+func (s *Stmt) bindParameterIndex(name string) int {
+	return s.prefixedParameterIndex("", name)
+}
+
+// prefixedParameterIndex looks up prefix + name without allocating a C string.
+func (s *Stmt) prefixedParameterIndex(prefix, name string) int {
+	bp := nameBuffers.Get().(*[]byte)
+	b := append(append(append((*bp)[:0], prefix...), name...), 0)
+	index := int(C.sqlite3_bind_parameter_index(s.stmt, (*C.char)(unsafe.Pointer(&b[0]))))
+	*bp = b
+	nameBuffers.Put(bp)
+	return index
+}
+
 // BindParameterName returns the name of a wildcard parameter (not cached).
 // Returns "" if the index is out of range or if the wildcard is unnamed.
 // The first host parameter has an index of 1, not 0.
@@ -276,6 +623,8 @@ func (s *Stmt) BindParameterName(i int) (string, error) {
 
 // NamedBind binds parameters by their name (name1, value1, ...)
 func (s *Stmt) NamedBind(args ...interface{}) error {
+	s.c.enter()
+	defer s.c.leave()
 	if len(args)%2 != 0 {
 		return s.specificError("expected an even number of arguments: %d", len(args))
 	}
@@ -296,17 +645,78 @@ func (s *Stmt) NamedBind(args ...interface{}) error {
 	return nil
 }
 
+// BindMap binds the named parameters present in the map.
+// Keys may be specified with or without prefix ("id" or ":id").
+// Parameters without a matching key are left unchanged and keys without a matching parameter are ignored.
+// (See Stmt.BindMapStrict)
+func (s *Stmt) BindMap(args map[string]interface{}) error {
+	return s.bindMap(args, false)
+}
+
+// BindMapStrict is like BindMap but returns an error
+// if a parameter has no matching key or if a key has no matching parameter.
+func (s *Stmt) BindMapStrict(args map[string]interface{}) error {
+	return s.bindMap(args, true)
+}
+
+func (s *Stmt) bindMap(args map[string]interface{}, strict bool) error {
+	s.c.enter()
+	defer s.c.leave()
+	var used int
+	for i := 1; i <= s.BindParameterCount(); i++ {
+		name := C.sqlite3_bind_parameter_name(s.stmt, C.int(i))
+		if name == nil {
+			if strict {
+				return s.specificError("unnamed parameter at %d", i)
+			}
+			continue
+		}
+		pname := C.GoString(name)
+		value, ok := args[pname]
+		if !ok {
+			value, ok = args[pname[1:]]
+		}
+		if !ok {
+			if strict {
+				return s.specificError("missing value for parameter %q", pname)
+			}
+			continue
+		}
+		used++
+		if err := s.BindByIndex(i, value); err != nil {
+			return err
+		}
+	}
+	if strict && used != len(args) {
+		return s.specificError("%d key(s) without matching parameter", len(args)-used)
+	}
+	return nil
+}
+
 // Bind binds parameters by their index.
 // Calls sqlite3_bind_parameter_count and sqlite3_bind_(blob|double|int|int64|null|text) depending on args type/kind.
+// If there are gaps in the parameter indexes (?NNN form), args may either cover all indexes up to
+// BindParameterCount (values at gaps are ignored) or only the indexes actually used (see Stmt.BindParameterIndices).
 // (See http://sqlite.org/c3ref/bind_blob.html)
 func (s *Stmt) Bind(args ...interface{}) error {
+	s.c.enter()
+	defer s.c.leave()
 	n := s.BindParameterCount()
-	if n != len(args) {
-		return s.specificError("incorrect argument count for Stmt.Bind: have %d want %d", len(args), n)
+	if n == len(args) {
+		for i, v := range args {
+			err := s.BindByIndex(i+1, v)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	indices := s.BindParameterIndices()
+	if len(indices) != len(args) {
+		return s.specificError("incorrect argument count for Stmt.Bind: have %d want %d", len(args), len(indices))
 	}
-
 	for i, v := range args {
-		err := s.BindByIndex(i+1, v)
+		err := s.BindByIndex(indices[i], v)
 		if err != nil {
 			return err
 		}
@@ -314,6 +724,26 @@ func (s *Stmt) Bind(args ...interface{}) error {
 	return nil
 }
 
+// BindNumbered binds only the specified parameters by their index (sparse binding).
+// Other parameters keep their current value.
+// Each index must be used by a SQL parameter (see Stmt.BindParameterIndices).
+//
+//	stmt, err := db.Prepare("SELECT ?1, ?3")
+//	err = stmt.BindNumbered(map[int]interface{}{3: "c"})
+func (s *Stmt) BindNumbered(args map[int]interface{}) error {
+	s.c.enter()
+	defer s.c.leave()
+	for index, v := range args {
+		if !s.isParameterIndex(index) {
+			return s.specificError("invalid parameter index: %d", index)
+		}
+		if err := s.BindByIndex(index, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // NullIfEmpty transforms empty string to null when true (true by default)
 var NullIfEmptyString = true
 
@@ -322,8 +752,11 @@ var NullIfZeroTime = true
 
 // BindByIndex binds value to the specified host parameter of the prepared statement.
 // Value's type/kind is used to find the storage class.
+// Common types are bound without reflection nor allocation (text and blobs are not copied on the Go side).
 // The leftmost SQL parameter has an index of 1.
 func (s *Stmt) BindByIndex(index int, value interface{}) error {
+	s.c.enter()
+	defer s.c.leave()
 	i := C.int(index)
 	var rv C.int
 	switch value := value.(type) {
@@ -337,11 +770,31 @@ func (s *Stmt) BindByIndex(index int, value interface{}) error {
 			rv = C.my_bind_text(s.stmt, i, cs, l)
 		}
 	case int:
-		rv = C.sqlite3_bind_int(s.stmt, i, C.int(value))
+		rv = C.sqlite3_bind_int64(s.stmt, i, C.sqlite3_int64(value))
 	case int64:
 		rv = C.sqlite3_bind_int64(s.stmt, i, C.sqlite3_int64(value))
+	case int32:
+		rv = C.sqlite3_bind_int(s.stmt, i, C.int(value))
+	case int16:
+		rv = C.sqlite3_bind_int(s.stmt, i, C.int(value))
+	case int8:
+		rv = C.sqlite3_bind_int(s.stmt, i, C.int(value))
 	case byte:
 		rv = C.sqlite3_bind_int(s.stmt, i, C.int(value))
+	case uint16:
+		rv = C.sqlite3_bind_int(s.stmt, i, C.int(value))
+	case uint32:
+		rv = C.sqlite3_bind_int64(s.stmt, i, C.sqlite3_int64(value))
+	case uint:
+		if uint64(value) > math.MaxInt64 {
+			return s.specificError("int overflow")
+		}
+		rv = C.sqlite3_bind_int64(s.stmt, i, C.sqlite3_int64(value))
+	case uint64:
+		if value > math.MaxInt64 {
+			return s.specificError("int overflow")
+		}
+		rv = C.sqlite3_bind_int64(s.stmt, i, C.sqlite3_int64(value))
 	case bool:
 		rv = C.sqlite3_bind_int(s.stmt, i, btocint(value))
 	case float32:
@@ -371,6 +824,9 @@ func (s *Stmt) BindByIndex(index int, value interface{}) error {
 	default:
 		return s.BindReflect(index, value)
 	}
+	if rv == C.SQLITE_OK {
+		s.setBound(index)
+	}
 	return s.error(rv, "Stmt.Bind")
 }
 
@@ -378,6 +834,8 @@ func (s *Stmt) BindByIndex(index int, value interface{}) error {
 // Value's (reflect) Kind is used to find the storage class.
 // The leftmost SQL parameter has an index of 1.
 func (s *Stmt) BindReflect(index int, value interface{}) error {
+	s.c.enter()
+	defer s.c.leave()
 	i := C.int(index)
 	var rv C.int
 	v := reflect.ValueOf(value)
@@ -397,10 +855,24 @@ func (s *Stmt) BindReflect(index int, value interface{}) error {
 		rv = C.sqlite3_bind_int(s.stmt, i, btocint(v.Bool()))
 	case reflect.Float32, reflect.Float64:
 		rv = C.sqlite3_bind_double(s.stmt, i, C.double(v.Float()))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			name, _ := s.BindParameterName(index)
+			return s.specificError("unsupported type in Bind: %T (index: %d, name: %q)", value, index, name)
+		}
+		b := v.Bytes()
+		var p *byte
+		if len(b) > 0 {
+			p = &b[0]
+		}
+		rv = C.my_bind_blob(s.stmt, i, unsafe.Pointer(p), C.int(len(b)))
 	default:
 		name, _ := s.BindParameterName(index)
 		return s.specificError("unsupported type in Bind: %T (index: %d, name: %q)", value, index, name)
 	}
+	if rv == C.SQLITE_OK {
+		s.setBound(index)
+	}
 	return s.error(rv, "Stmt.Bind")
 }
 
@@ -420,11 +892,25 @@ func (s *Stmt) BindReflect(index int, value interface{}) error {
 //		err := s.Scan(&fnum, &inum, &sstr)
 //	}
 //
+// On error, the statement is reset so that it can be executed again.
+// An interrupted statement returns an error matching ErrInterrupted
+// and a stale WAL snapshot one matching ErrBusySnapshot (see errors.Is).
+// Transient errors are retried according to the retry policy of the connection
+// before the first row is returned (see Conn.SetRetryPolicy, ErrInterrupt may be added to its RetryableCodes).
 // (See http://sqlite.org/c3ref/step.html)
 func (s *Stmt) Next() (bool, error) {
-	rv := C.sqlite3_step(s.stmt)
+	s.c.enter()
+	defer s.c.leave()
+	if err := s.c.quarantined(); err != nil {
+		return false, err
+	}
+	s.startStep()
+	rv := s.step()
 	err := Errno(rv)
 	if err == Row {
+		if err := s.checkLimits(); err != nil {
+			return false, err
+		}
 		return true, nil
 	}
 	C.sqlite3_reset(s.stmt) // Release implicit lock as soon as possible (see dbEvalStep in tclsqlite3.c)
@@ -435,16 +921,100 @@ func (s *Stmt) Next() (bool, error) {
 	return false, nil
 }
 
+// NextRow is like Next but also retrieves all the column values of the new row in a single cgo call.
+// Values are converted like with ScanValue(i, false).
+// Returns nil when there is no more row.
+// The returned slice is reused by the next call.
+//
+//	for {
+//		row, err := s.NextRow()
+//		if err != nil {
+//			return err
+//		} else if row == nil {
+//			break
+//		}
+//		// process row
+//	}
+func (s *Stmt) NextRow() ([]interface{}, error) {
+	if s.rowValues == nil {
+		s.rowValues = make([]interface{}, s.ColumnCount())
+	}
+	values := s.rowValues // cleared if the statement is re-prepared
+	ok, err := s.nextRow(values, false)
+	if !ok {
+		return nil, err
+	}
+	return values, nil
+}
+
+// nextRow steps and stores the values of the first len(dest) columns into dest.
+// With blob, text values are returned as []byte (see ScanValue).
+func (s *Stmt) nextRow(dest []interface{}, blob bool) (bool, error) {
+	s.c.enter()
+	defer s.c.leave()
+	if err := s.c.quarantined(); err != nil {
+		return false, err
+	}
+	if s.row == nil {
+		s.row = make([]C.my_column, s.ColumnCount()+1) // +1 to always have a valid pointer
+	}
+	row := s.row // cleared if the statement is re-prepared
+	n := len(dest)
+	if n > len(row)-1 {
+		n = len(row) - 1
+	}
+	s.startStep()
+	rv := s.retry(func() C.int {
+		return C.my_step_row(s.stmt, &row[0], C.int(n))
+	})
+	if rv != C.SQLITE_ROW {
+		if rv != C.SQLITE_DONE {
+			return false, s.error(rv, "Stmt.NextRow")
+		}
+		return false, nil
+	}
+	if err := s.checkLimits(); err != nil {
+		return false, err
+	}
+	for i := 0; i < n; i++ {
+		col := &row[i]
+		switch col._type {
+		case C.SQLITE_NULL:
+			dest[i] = nil
+		case C.SQLITE_INTEGER:
+			dest[i] = int64(col.i)
+		case C.SQLITE_FLOAT:
+			dest[i] = float64(col.d)
+		case C.SQLITE_TEXT:
+			if blob {
+				dest[i] = C.GoBytes(col.p, col.n)
+			} else {
+				dest[i] = C.GoStringN((*C.char)(col.p), col.n)
+			}
+		case C.SQLITE_BLOB:
+			dest[i] = C.GoBytes(col.p, col.n)
+		}
+	}
+	return true, nil
+}
+
 // Reset terminates the current execution of an SQL statement
 // and reset it back to its starting state so that it can be reused.
 // (See http://sqlite.org/c3ref/reset.html)
 func (s *Stmt) Reset() error {
+	s.c.enter()
+	defer s.c.leave()
 	return s.error(C.sqlite3_reset(s.stmt), "Stmt.Reset")
 }
 
 // ClearBindings resets all bindings on a prepared statement.
 // (See http://sqlite.org/c3ref/clear_bindings.html)
 func (s *Stmt) ClearBindings() error {
+	s.c.enter()
+	defer s.c.leave()
+	for i := range s.bound {
+		s.bound[i] = false
+	}
 	return s.error(C.sqlite3_clear_bindings(s.stmt), "Stmt.ClearBindings")
 }
 
@@ -461,6 +1031,8 @@ func (s *Stmt) ColumnCount() int {
 // Same as ColumnCount() except when there is no (more) row, it returns 0.
 // (See http://sqlite.org/c3ref/data_count.html)
 func (s *Stmt) DataCount() int {
+	s.c.enter()
+	defer s.c.leave()
 	return int(C.sqlite3_data_count(s.stmt))
 }
 
@@ -468,12 +1040,16 @@ func (s *Stmt) DataCount() int {
 // The leftmost column is number 0.
 // (See http://sqlite.org/c3ref/column_name.html)
 func (s *Stmt) ColumnName(index int) string {
+	s.c.enter()
+	defer s.c.leave()
 	// If there is no AS clause then the name of the column is unspecified and may change from one release of SQLite to the next.
 	return C.GoString(C.sqlite3_column_name(s.stmt, C.int(index)))
 }
 
 // ColumnNames returns the name of the columns of the result set returned by the SQL statement. (not cached)
 func (s *Stmt) ColumnNames() []string {
+	s.c.enter()
+	defer s.c.leave()
 	count := s.ColumnCount()
 	names := make([]string, count)
 	for i := 0; i < count; i++ {
@@ -512,9 +1088,38 @@ var typeText = map[Type]string{
 // After a type conversion, the value returned by sqlite3_column_type() is undefined.
 // (See sqlite3_column_type: http://sqlite.org/c3ref/column_blob.html)
 func (s *Stmt) ColumnType(index int) Type {
+	s.c.enter()
+	defer s.c.leave()
 	return Type(C.sqlite3_column_type(s.stmt, C.int(index)))
 }
 
+// PeekType returns the datatype code of the value of the result column
+// and its size in bytes for a text or blob (0 otherwise),
+// so that a buffer can be pre-sized or a scan strategy chosen.
+// The leftmost column is number 0.
+// It must be called before any conversion of the value of this column in the current row
+// (by a Scan or Column* method): the value itself is never converted.
+// (See http://sqlite.org/c3ref/column_blob.html)
+func (s *Stmt) PeekType(index int) (Type, int) {
+	s.c.enter()
+	defer s.c.leave()
+	var n C.int
+	t := C.my_column_peek(s.stmt, C.int(index), &n)
+	return Type(t), int(n)
+}
+
+// ColumnBytes returns the size in bytes of the text (UTF-8) or blob value of the result column
+// (0 for NULL and numeric values, which are not converted).
+// The leftmost column is number 0.
+// Like PeekType, it must be called before any conversion of the value.
+// (See http://sqlite.org/c3ref/column_blob.html)
+func (s *Stmt) ColumnBytes(index int) int {
+	s.c.enter()
+	defer s.c.leave()
+	_, n := s.PeekType(index)
+	return n
+}
+
 // NamedScan scans result values from a query by name (name1, value1, ...).
 //
 // NULL value is converted to 0 if arg type is *int,*int64,*float,*float64, to "" for *string, to []byte{} for *[]byte and to false for *bool.
@@ -550,6 +1155,8 @@ func (s *Stmt) NamedScan(args ...interface{}) error {
 // Calls sqlite3_column_(blob|double|int|int64|text) depending on args type/kind.
 // (See http://sqlite.org/c3ref/column_blob.html)
 func (s *Stmt) Scan(args ...interface{}) error {
+	s.c.enter()
+	defer s.c.leave()
 	n := s.ColumnCount()
 	if n != len(args) { // What happens when the number of arguments is less than the number of columns?
 		return s.specificError("incorrect argument count for Stmt.Scan: have %d want %d", len(args), n)
@@ -597,6 +1204,8 @@ func (s *Stmt) ColumnIndex(name string) (int, error) {
 // Calls sqlite3_column_(blob|double|int|int64|text) depending on arg type/kind.
 // (See http://sqlite.org/c3ref/column_blob.html)
 func (s *Stmt) ScanByName(name string, value interface{}) (bool, error) {
+	s.c.enter()
+	defer s.c.leave()
 	index, err := s.ColumnIndex(name)
 	if err != nil {
 		return false, err
@@ -619,10 +1228,13 @@ func (s *Stmt) ScanByName(name string, value interface{}) (bool, error) {
 //    sql.Scanner
 //    *interface{}
 //
-// Returns true when column is null.
+// Returns true when column is null
+// (with a *NullColumnError when Stmt.StrictNull is set and value cannot represent NULL).
 // Calls sqlite3_column_(blob|double|int|int64|text) depending on arg type/kind.
 // (See http://sqlite.org/c3ref/column_blob.html)
 func (s *Stmt) ScanByIndex(index int, value interface{}) (bool, error) {
+	s.c.enter()
+	defer s.c.leave()
 	var isNull bool
 	var err error
 	switch value := value.(type) {
@@ -714,13 +1326,29 @@ func (s *Stmt) ScanByIndex(index int, value interface{}) (bool, error) {
 		v, isNull = s.ScanValue(index, false)
 		err = value.Scan(v)
 	case *interface{}:
-		*value, isNull = s.ScanValue(index, false)
+		if s.c.typeAffinity {
+			*value, isNull, err = s.scanAffinity(index, false)
+		} else {
+			*value, isNull = s.ScanValue(index, false)
+		}
 	default:
-		return s.ScanReflect(index, value)
+		isNull, err = s.ScanReflect(index, value)
+	}
+	if isNull && err == nil && s.StrictNull && !nullable(value) {
+		err = &NullColumnError{SQL: s.SQL(), Index: index, Column: s.ColumnName(index), Type: fmt.Sprintf("%T", value)}
 	}
 	return isNull, err
 }
 
+// nullable tells if the scan destination can represent NULL.
+func nullable(value interface{}) bool {
+	switch value.(type) {
+	case nil, **string, **int, **int64, **byte, **bool, **float64, *[]byte, **[]byte, sql.Scanner, *interface{}:
+		return true
+	}
+	return false
+}
+
 // ScanReflect scans result value from a query.
 // The leftmost column/index is number 0.
 //
@@ -734,6 +1362,8 @@ func (s *Stmt) ScanByIndex(index int, value interface{}) (bool, error) {
 //
 // Returns true when column is null.
 func (s *Stmt) ScanReflect(index int, v interface{}) (bool, error) {
+	s.c.enter()
+	defer s.c.leave()
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return false, s.specificError("ScanReflect unsupported type %T", v)
@@ -794,6 +1424,8 @@ func (s *Stmt) ScanReflect(index int, v interface{}) (bool, error) {
 // Calls sqlite3_column_(blob|double|int|int64|text) depending on columns type.
 // (See http://sqlite.org/c3ref/column_blob.html)
 func (s *Stmt) ScanValue(index int, blob bool) (interface{}, bool) {
+	s.c.enter()
+	defer s.c.leave()
 	switch s.ColumnType(index) {
 	case Null:
 		return nil, true
@@ -818,8 +1450,43 @@ func (s *Stmt) ScanValue(index int, blob bool) (interface{}, bool) {
 	panic("The column type is not one of SQLITE_INTEGER, SQLITE_FLOAT, SQLITE_TEXT, SQLITE_BLOB, or SQLITE_NULL")
 }
 
+type declKind uint8
+
+const (
+	declOther declKind = iota
+	declTime
+	declBool
+)
+
+// scanAffinity is like ScanValue but converts the value depending on the column declared type.
+func (s *Stmt) scanAffinity(index int, blob bool) (interface{}, bool, error) {
+	if s.declKinds == nil {
+		s.declKinds = make([]declKind, s.ColumnCount())
+		for i := range s.declKinds {
+			declType := strings.ToUpper(s.ColumnDeclaredType(i))
+			if strings.Contains(declType, "DATE") || strings.Contains(declType, "TIME") {
+				s.declKinds[i] = declTime
+			} else if strings.Contains(declType, "BOOL") {
+				s.declKinds[i] = declBool
+			}
+		}
+	}
+	if index >= 0 && index < len(s.declKinds) && s.ColumnType(index) != Null {
+		switch s.declKinds[index] {
+		case declTime:
+			return s.ScanTime(index)
+		case declBool:
+			return s.ScanBool(index)
+		}
+	}
+	value, isNull := s.ScanValue(index, blob)
+	return value, isNull, nil
+}
+
 // ScanValues is like ScanValue on several columns.
 func (s *Stmt) ScanValues(values []interface{}) {
+	s.c.enter()
+	defer s.c.leave()
 	for i := range values {
 		values[i], _ = s.ScanValue(i, false)
 	}
@@ -830,6 +1497,8 @@ func (s *Stmt) ScanValues(values []interface{}) {
 // Returns true when column is null.
 // (See sqlite3_column_text: http://sqlite.org/c3ref/column_blob.html)
 func (s *Stmt) ScanText(index int) (value string, isNull bool) {
+	s.c.enter()
+	defer s.c.leave()
 	p := C.sqlite3_column_text(s.stmt, C.int(index))
 	if p == nil {
 		isNull = true
@@ -839,19 +1508,52 @@ func (s *Stmt) ScanText(index int) (value string, isNull bool) {
 	return
 }
 
+// ScanTextInto copies the text of the specified column into buf (from buf[0], growing it only when it is too small)
+// and returns the filled slice, avoiding the string allocation of ScanText in hot loops:
+//
+//	var buf []byte
+//	err = s.Select(func(s *Stmt) (err error) {
+//		buf, _, err = s.ScanTextInto(0, buf)
+//		return
+//	})
+//
+// The leftmost column/index is number 0.
+// Returns true when column is null (buf[:0] is then returned).
+// (See sqlite3_column_text: http://sqlite.org/c3ref/column_blob.html)
+func (s *Stmt) ScanTextInto(index int, buf []byte) ([]byte, bool, error) {
+	s.c.enter()
+	defer s.c.leave()
+	if index < 0 || index >= s.ColumnCount() {
+		return buf[:0], false, s.specificError("column index %d out of range [0,%d[", index, s.ColumnCount())
+	}
+	p := C.sqlite3_column_text(s.stmt, C.int(index))
+	if p == nil {
+		return buf[:0], true, nil
+	}
+	n := int(C.sqlite3_column_bytes(s.stmt, C.int(index)))
+	if cap(buf) < n {
+		buf = make([]byte, n)
+	}
+	buf = buf[:n]
+	if n > 0 {
+		copy(buf, (*[1 << 30]byte)(unsafe.Pointer(p))[:n:n])
+	}
+	return buf, false, nil
+}
+
 // ScanInt scans result value from a query.
 // The leftmost column/index is number 0.
 // Returns true when column is null.
 // (See sqlite3_column_int: http://sqlite.org/c3ref/column_blob.html)
 // TODO Factorize with ScanByte, ScanBool
 func (s *Stmt) ScanInt(index int) (value int, isNull bool, err error) {
+	s.c.enter()
+	defer s.c.leave()
 	ctype := s.ColumnType(index)
 	if ctype == Null {
 		isNull = true
 	} else {
-		if s.CheckTypeMismatch {
-			err = s.checkTypeMismatch(ctype, Integer)
-		}
+		err = s.checkTypeMismatch(index, ctype, Integer)
 		value = int(C.sqlite3_column_int(s.stmt, C.int(index)))
 	}
 	return
@@ -862,13 +1564,13 @@ func (s *Stmt) ScanInt(index int) (value int, isNull bool, err error) {
 // Returns true when column is null.
 // (See sqlite3_column_int64: http://sqlite.org/c3ref/column_blob.html)
 func (s *Stmt) ScanInt64(index int) (value int64, isNull bool, err error) {
+	s.c.enter()
+	defer s.c.leave()
 	ctype := s.ColumnType(index)
 	if ctype == Null {
 		isNull = true
 	} else {
-		if s.CheckTypeMismatch {
-			err = s.checkTypeMismatch(ctype, Integer)
-		}
+		err = s.checkTypeMismatch(index, ctype, Integer)
 		value = int64(C.sqlite3_column_int64(s.stmt, C.int(index)))
 	}
 	return
@@ -879,13 +1581,13 @@ func (s *Stmt) ScanInt64(index int) (value int64, isNull bool, err error) {
 // Returns true when column is null.
 // (See sqlite3_column_int: http://sqlite.org/c3ref/column_blob.html)
 func (s *Stmt) ScanByte(index int) (value byte, isNull bool, err error) {
+	s.c.enter()
+	defer s.c.leave()
 	ctype := s.ColumnType(index)
 	if ctype == Null {
 		isNull = true
 	} else {
-		if s.CheckTypeMismatch {
-			err = s.checkTypeMismatch(ctype, Integer)
-		}
+		err = s.checkTypeMismatch(index, ctype, Integer)
 		value = byte(C.sqlite3_column_int(s.stmt, C.int(index)))
 	}
 	return
@@ -896,13 +1598,13 @@ func (s *Stmt) ScanByte(index int) (value byte, isNull bool, err error) {
 // Returns true when column is null.
 // (See sqlite3_column_int: http://sqlite.org/c3ref/column_blob.html)
 func (s *Stmt) ScanBool(index int) (value bool, isNull bool, err error) {
+	s.c.enter()
+	defer s.c.leave()
 	ctype := s.ColumnType(index)
 	if ctype == Null {
 		isNull = true
 	} else {
-		if s.CheckTypeMismatch {
-			err = s.checkTypeMismatch(ctype, Integer)
-		}
+		err = s.checkTypeMismatch(index, ctype, Integer)
 		value = C.sqlite3_column_int(s.stmt, C.int(index)) == 1
 	}
 	return
@@ -913,13 +1615,13 @@ func (s *Stmt) ScanBool(index int) (value bool, isNull bool, err error) {
 // Returns true when column is null.
 // (See sqlite3_column_double: http://sqlite.org/c3ref/column_blob.html)
 func (s *Stmt) ScanDouble(index int) (value float64, isNull bool, err error) {
+	s.c.enter()
+	defer s.c.leave()
 	ctype := s.ColumnType(index)
 	if ctype == Null {
 		isNull = true
 	} else {
-		if s.CheckTypeMismatch {
-			err = s.checkTypeMismatch(ctype, Float)
-		}
+		err = s.checkTypeMismatch(index, ctype, Float)
 		value = float64(C.sqlite3_column_double(s.stmt, C.int(index)))
 	}
 	return
@@ -930,6 +1632,8 @@ func (s *Stmt) ScanDouble(index int) (value float64, isNull bool, err error) {
 // Returns true when column is null.
 // (See sqlite3_column_blob: http://sqlite.org/c3ref/column_blob.html)
 func (s *Stmt) ScanBlob(index int) (value []byte, isNull bool) {
+	s.c.enter()
+	defer s.c.leave()
 	p := C.sqlite3_column_blob(s.stmt, C.int(index))
 	if p == nil {
 		isNull = true
@@ -947,48 +1651,15 @@ func (s *Stmt) ScanBlob(index int) (value []byte, isNull bool) {
 // The leftmost column/index is number 0.
 // Returns true when column is null.
 func (s *Stmt) ScanTime(index int) (value time.Time, isNull bool, err error) {
+	s.c.enter()
+	defer s.c.leave()
 	switch s.ColumnType(index) {
 	case Null:
 		isNull = true
 	case Text:
 		p := C.sqlite3_column_text(s.stmt, C.int(index))
 		txt := C.GoString((*C.char)(unsafe.Pointer(p)))
-		var layout string
-		switch len(txt) {
-		case 5: // HH:MM
-			layout = "15:04"
-		case 8: // HH:MM:SS
-			layout = "15:04:05"
-		case 10: // YYYY-MM-DD
-			layout = "2006-01-02"
-		case 12: // HH:MM:SS.SSS
-			layout = "15:04:05.000"
-		case 16: // YYYY-MM-DDTHH:MM
-			if txt[10] == 'T' {
-				layout = "2006-01-02T15:04"
-			} else {
-				layout = "2006-01-02 15:04"
-			}
-		case 19: // YYYY-MM-DDTHH:MM:SS
-			if txt[10] == 'T' {
-				layout = "2006-01-02T15:04:05"
-			} else {
-				layout = "2006-01-02 15:04:05"
-			}
-		case 23: // YYYY-MM-DDTHH:MM:SS.SSS
-			if txt[10] == 'T' {
-				layout = "2006-01-02T15:04:05.999"
-			} else {
-				layout = "2006-01-02 15:04:05.999"
-			}
-		default: // YYYY-MM-DDTHH:MM:SS.SSSZhh:mm or parse error
-			if len(txt) > 10 && txt[10] == 'T' {
-				layout = "2006-01-02T15:04:05.999Z07:00"
-			} else {
-				layout = "2006-01-02 15:04:05.999Z07:00"
-			}
-		}
-		value, err = time.Parse(layout, txt) // UTC except when timezone is specified
+		value, err = time.Parse(timeLayout(txt), txt) // UTC except when timezone is specified
 	case Integer:
 		unixepoch := int64(C.sqlite3_column_int64(s.stmt, C.int(index)))
 		value = time.Unix(unixepoch, 0) // local time
@@ -1001,27 +1672,107 @@ func (s *Stmt) ScanTime(index int) (value time.Time, isNull bool, err error) {
 	return
 }
 
-// Only lossy conversion is reported as error.
-func (s *Stmt) checkTypeMismatch(source, target Type) error {
+// timeLayout returns the layout matching the SQLite time value txt (see ScanTime).
+func timeLayout(txt string) string {
+	var layout string
+	switch len(txt) {
+	case 5: // HH:MM
+		layout = "15:04"
+	case 8: // HH:MM:SS
+		layout = "15:04:05"
+	case 10: // YYYY-MM-DD
+		layout = "2006-01-02"
+	case 12: // HH:MM:SS.SSS
+		layout = "15:04:05.000"
+	case 16: // YYYY-MM-DDTHH:MM
+		if txt[10] == 'T' {
+			layout = "2006-01-02T15:04"
+		} else {
+			layout = "2006-01-02 15:04"
+		}
+	case 19: // YYYY-MM-DDTHH:MM:SS
+		if txt[10] == 'T' {
+			layout = "2006-01-02T15:04:05"
+		} else {
+			layout = "2006-01-02 15:04:05"
+		}
+	case 23: // YYYY-MM-DDTHH:MM:SS.SSS
+		if txt[10] == 'T' {
+			layout = "2006-01-02T15:04:05.999"
+		} else {
+			layout = "2006-01-02 15:04:05.999"
+		}
+	default: // YYYY-MM-DDTHH:MM:SS.SSSZhh:mm or parse error
+		if len(txt) > 10 && txt[10] == 'T' {
+			layout = "2006-01-02T15:04:05.999Z07:00"
+		} else {
+			layout = "2006-01-02 15:04:05.999Z07:00"
+		}
+	}
+	return layout
+}
+
+// TypeMismatchPolicy tells what the Scan methods do when the value of a column
+// cannot be converted to the destination type without loss (text 'M' or float 3.5 scanned into an int for example).
+type TypeMismatchPolicy int
+
+// Type mismatch policies
+const (
+	MismatchError   TypeMismatchPolicy = iota // return an error (with the converted value) (default)
+	MismatchWarn                              // log a warning (see ConfigLog)
+	MismatchConvert                           // convert silently (like sqlite3_column_x)
+)
+
+// SetTypeMismatchPolicy sets the type mismatch policy of the statements prepared afterward.
+func (c *Conn) SetTypeMismatchPolicy(p TypeMismatchPolicy) {
+	c.typeMismatch = p
+}
+
+// SetTypeMismatchPolicy sets the type mismatch policy of the statement
+// (the connection policy is restored when the statement is put back in the cache).
+func (s *Stmt) SetTypeMismatchPolicy(p TypeMismatchPolicy) {
+	s.typeMismatch = p
+}
+
+// checkTypeMismatch applies the type mismatch policy when the value of the specified column
+// cannot be converted to target without loss.
+// Non-lossy conversions (text '12' or float 12.0 to integer, text '1.5' to float) are accepted.
+func (s *Stmt) checkTypeMismatch(index int, source, target Type) error {
+	if s.typeMismatch == MismatchConvert || !s.lossy(index, source, target) {
+		return nil
+	}
+	if s.typeMismatch == MismatchWarn {
+		Log(C.SQLITE_WARNING, fmt.Sprintf("type mismatch, source %s vs target %s (column #%d of %q)", source, target, index, s.SQL()))
+		return nil
+	}
+	return s.specificError("type mismatch, source %s vs target %s", source, target)
+}
+
+func (s *Stmt) lossy(index int, source, target Type) bool {
 	switch target {
 	case Integer:
 		switch source {
 		case Float:
-			fallthrough
+			f := float64(C.sqlite3_column_double(s.stmt, C.int(index)))
+			return f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64
 		case Text:
-			fallthrough
+			text, _ := s.ScanText(index)
+			_, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+			return err != nil
 		case Blob:
-			return s.specificError("type mismatch, source %s vs target %s", source, target)
+			return true
 		}
 	case Float:
 		switch source {
 		case Text:
-			fallthrough
+			text, _ := s.ScanText(index)
+			_, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+			return err != nil
 		case Blob:
-			return s.specificError("type mismatch, source %s vs target %s", source, target)
+			return true
 		}
 	}
-	return nil
+	return false
 }
 
 // Busy returns true if the prepared statement is in need of being reset.
@@ -1036,7 +1787,7 @@ func (s *Stmt) Finalize() error {
 	if s == nil {
 		return errors.New("nil sqlite statement")
 	}
-	if s.Cacheable && s.c != nil && s.c.db != nil {
+	if s.Cacheable && s.c != nil && s.c.db != nil && !s.c.zombie {
 		return s.c.stmtCache.release(s)
 	}
 	return s.finalize()
@@ -1052,6 +1803,9 @@ func (s *Stmt) finalize() error {
 		Log(C.SQLITE_MISUSE, "sqlite statement with already closed database connection")
 		return errors.New("sqlite statement with already closed database connection")
 	}
+	s.collectScanStats()
+	s.c.enter()
+	defer s.c.leave()
 	rv := C.sqlite3_finalize(s.stmt)
 	if rv != C.SQLITE_OK {
 		Log(int(rv), "error while finalizing Stmt")
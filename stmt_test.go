@@ -5,8 +5,11 @@
 package sqlite_test
 
 import (
+	"errors"
+	"fmt"
 	. "github.com/gwenn/gosqlite"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -289,6 +292,266 @@ func TestStmtSelectWithInsert(t *testing.T) {
 	assert(t, "no row expected", !exists)
 }
 
+func TestBareParameterName(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+
+	for _, sql := range []string{"SELECT :id, :name", "SELECT @id, @name", "SELECT $id, $name"} {
+		s, err := db.Prepare(sql)
+		checkNoError(t, err, "prepare error: %s")
+		index, err := s.BindParameterIndex("name")
+		checkNoError(t, err, "bind parameter index error: %s")
+		assertEquals(t, "expected %d but got %d", 2, index)
+		checkNoError(t, s.NamedBind("id", 1, "name", "bart"), "named bind error: %s")
+		var id int
+		var name string
+		checkNoError(t, s.Select(func(s *Stmt) error {
+			return s.Scan(&id, &name)
+		}), "select error: %s")
+		assertEquals(t, "expected %d but got %d", 1, id)
+		assertEquals(t, "expected %q but got %q", "bart", name)
+		_, err = s.BindParameterIndex("invalid")
+		assert(t, "invalid param name expected", err != nil)
+		checkFinalize(s, t)
+	}
+}
+
+func TestNumberedParameters(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+
+	s, err := db.Prepare("SELECT ?1, ?3, ?1")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	assertEquals(t, "expected %d but got %d", 3, s.BindParameterCount())
+	indices := s.BindParameterIndices()
+	assert(t, "unexpected parameter indices", len(indices) == 2 && indices[0] == 1 && indices[1] == 3)
+
+	var a, b, c string
+	checkNoError(t, s.Bind("a", "c"), "sparse bind error: %s")
+	checkNoError(t, s.Select(func(s *Stmt) error {
+		return s.Scan(&a, &b, &c)
+	}), "select error: %s")
+	assert(t, "unexpected values", a == "a" && b == "c" && c == "a")
+
+	checkNoError(t, s.Bind("x", nil, "z"), "dense bind error: %s")
+	checkNoError(t, s.Select(func(s *Stmt) error {
+		return s.Scan(&a, &b, &c)
+	}), "select error: %s")
+	assert(t, "unexpected values", a == "x" && b == "z")
+
+	checkNoError(t, s.BindNumbered(map[int]interface{}{3: "y"}), "numbered bind error: %s")
+	checkNoError(t, s.Select(func(s *Stmt) error {
+		return s.Scan(&a, &b, &c)
+	}), "select error: %s")
+	assert(t, "unexpected values", a == "x" && b == "y")
+
+	err = s.BindNumbered(map[int]interface{}{2: "gap"})
+	assert(t, "gap index error expected", err != nil)
+	err = s.Bind("a")
+	assert(t, "argument count error expected", err != nil)
+}
+
+func TestNextRow(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+
+	s, err := db.Prepare("SELECT 1, 3.14, 'text', x'00ff', NULL UNION ALL SELECT 2, 0.5, '', x'', 'a'")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+
+	row, err := s.NextRow()
+	checkNoError(t, err, "next row error: %s")
+	assertEquals(t, "expected %d columns but got %d", 5, len(row))
+	assertEquals(t, "expected %v but got %v", int64(1), row[0])
+	assertEquals(t, "expected %v but got %v", 3.14, row[1])
+	assertEquals(t, "expected %v but got %v", "text", row[2])
+	assert(t, "unexpected blob", reflect.DeepEqual([]byte{0, 0xff}, row[3]))
+	assert(t, "nil expected", row[4] == nil)
+	row, err = s.NextRow()
+	checkNoError(t, err, "next row error: %s")
+	assertEquals(t, "expected %v but got %v", int64(2), row[0])
+	assertEquals(t, "expected %v but got %v", "", row[2])
+	assertEquals(t, "expected %v but got %v", "a", row[4])
+	row, err = s.NextRow()
+	checkNoError(t, err, "next row error: %s")
+	assert(t, "no more row expected", row == nil)
+}
+
+func TestReturning(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE kv (k INTEGER PRIMARY KEY, v TEXT) WITHOUT ROWID"), "create error: %s")
+
+	s, err := db.Prepare("INSERT INTO kv (k, v) VALUES (?, ?) RETURNING k")
+	checkNoError(t, err, "prepare error: %s")
+	id, err := s.Insert(42, "a")
+	checkNoError(t, err, "insert error: %s")
+	assertEquals(t, "expected %d but got %d", int64(42), id)
+	checkFinalize(s, t)
+
+	s, err = db.Prepare("INSERT INTO test (int_num) VALUES (1), (2), (3) RETURNING id, int_num * 10")
+	checkNoError(t, err, "prepare error: %s")
+	var values []int
+	err = s.ExecReturning(func(s *Stmt) error {
+		var id, v int
+		if err := s.Scan(&id, &v); err != nil {
+			return err
+		}
+		values = append(values, v)
+		return nil
+	})
+	checkNoError(t, err, "exec returning error: %s")
+	assertEquals(t, "expected %v but got %v", "[10 20 30]", fmt.Sprint(values))
+	checkFinalize(s, t)
+
+	s, err = db.Prepare("DELETE FROM test WHERE int_num > ? RETURNING id")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	n, err := s.ExecDml(1)
+	checkNoError(t, err, "exec dml error: %s")
+	assertEquals(t, "expected %d but got %d", 2, n)
+	var count int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM test", &count), "count error: %s")
+	assertEquals(t, "expected %d but got %d", 1, count)
+}
+
+func TestStmtLimits(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	s, err := db.Prepare("WITH RECURSIVE cnt(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM cnt LIMIT 10) SELECT x, 'abcd' FROM cnt")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+
+	count := func() (int, error) {
+		var n int
+		for {
+			ok, err := s.Next()
+			if err != nil || !ok {
+				return n, err
+			}
+			n++
+		}
+	}
+	s.SetMaxRows(3)
+	n, err := count()
+	assertEquals(t, "expected %d rows but got %d", 3, n)
+	assert(t, "limit error expected", errors.Is(err, ErrLimitExceeded))
+	_, ok := err.(*LimitError)
+	assert(t, "*LimitError expected", ok)
+	assert(t, "statement reset expected", !s.Busy())
+
+	s.SetMaxRows(0)
+	s.SetMaxResultBytes(12 * 5) // 8 + 4 bytes per row
+	n, err = count()
+	assertEquals(t, "expected %d rows but got %d", 5, n)
+	assert(t, "limit error expected", errors.Is(err, ErrLimitExceeded))
+
+	s.SetMaxResultBytes(0)
+	n, err = count()
+	checkNoError(t, err, "next error: %s")
+	assertEquals(t, "expected %d rows but got %d", 10, n)
+}
+
+func TestStrictNull(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	s, err := db.Prepare("SELECT NULL AS n, 1 AS i")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	ok, err := s.Next()
+	checkNoError(t, err, "next error: %s")
+	assert(t, "one row expected", ok)
+
+	var i int
+	checkNoError(t, s.Scan(&i, &i), "scan error: %s")
+	s.StrictNull = true
+	var pi *int
+	var v interface{}
+	var b []byte
+	checkNoError(t, s.Scan(&pi, &i), "scan error: %s")
+	checkNoError(t, s.Scan(&v, &i), "scan error: %s")
+	checkNoError(t, s.Scan(&b, &i), "scan error: %s")
+	var str string
+	err = s.Scan(&str, &i)
+	assert(t, "NULL column error expected", errors.Is(err, ErrNullColumn))
+	nerr, ok := err.(*NullColumnError)
+	assert(t, "*NullColumnError expected", ok)
+	assertEquals(t, "expected %q but got %q", "n", nerr.Column)
+	var i8 int8
+	_, err = s.ScanByIndex(0, &i8)
+	assert(t, "NULL column error expected", errors.Is(err, ErrNullColumn))
+}
+
+func TestTypeMismatchPolicy(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	s, err := db.Prepare("SELECT '12', 'M', 3.0, 3.5, '1.5'")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	ok, err := s.Next()
+	checkNoError(t, err, "next error: %s")
+	assert(t, "one row expected", ok)
+
+	var i int
+	var f float64
+	_, err = s.ScanByIndex(0, &i)
+	checkNoError(t, err, "non-lossy conversion expected: %s")
+	assertEquals(t, "expected %d but got %d", 12, i)
+	_, err = s.ScanByIndex(1, &i)
+	assert(t, "type mismatch error expected", err != nil)
+	_, err = s.ScanByIndex(2, &i)
+	checkNoError(t, err, "non-lossy conversion expected: %s")
+	_, err = s.ScanByIndex(3, &i)
+	assert(t, "type mismatch error expected", err != nil)
+	_, err = s.ScanByIndex(4, &f)
+	checkNoError(t, err, "non-lossy conversion expected: %s")
+
+	var warnings int
+	logHook.Store(func(err error, msg string) {
+		if strings.Contains(msg, "type mismatch") {
+			warnings++
+		}
+	})
+	defer logHook.Store(func(error, string) {})
+	s.SetTypeMismatchPolicy(MismatchWarn)
+	_, err = s.ScanByIndex(1, &i)
+	checkNoError(t, err, "warning expected: %s")
+	assertEquals(t, "expected %d warning but got %d", 1, warnings)
+	s.SetTypeMismatchPolicy(MismatchConvert)
+	_, err = s.ScanByIndex(3, &i)
+	checkNoError(t, err, "silent conversion expected: %s")
+	assertEquals(t, "expected %d but got %d", 3, i)
+	assertEquals(t, "expected %d warning but got %d", 1, warnings)
+
+	db.SetTypeMismatchPolicy(MismatchConvert)
+	checkNoError(t, db.OneValue("SELECT 'M'", &i), "silent conversion expected: %s")
+}
+
+func TestBindMap(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+
+	s, err := db.Prepare("SELECT :id, @name")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	checkNoError(t, s.BindMap(map[string]interface{}{":id": 1, "name": "bart", "extra": true}), "bind map error: %s")
+	var id int
+	var name string
+	checkNoError(t, s.Select(func(s *Stmt) error {
+		return s.Scan(&id, &name)
+	}), "select error: %s")
+	assertEquals(t, "expected %d but got %d", 1, id)
+	assertEquals(t, "expected %q but got %q", "bart", name)
+
+	checkNoError(t, s.BindMapStrict(map[string]interface{}{"id": 2, "@name": "lisa"}), "bind map error: %s")
+	err = s.BindMapStrict(map[string]interface{}{"id": 2})
+	assert(t, "missing key error expected", err != nil)
+	err = s.BindMapStrict(map[string]interface{}{"id": 2, "name": "lisa", "extra": true})
+	assert(t, "extra key error expected", err != nil)
+}
+
 func TestNamedBind(t *testing.T) {
 	db := open(t)
 	defer checkClose(db, t)
@@ -338,6 +601,29 @@ func TestNamedBind(t *testing.T) {
 	assert(t, "invalid param name", err != nil)
 }
 
+func TestCheckBindings(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+
+	s, err := db.Prepare("SELECT ?, ?3, :name, ?, @x, ?3 -- :comment")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	assertEquals(t, "expected %d but got %d", 6, s.BindParameterCount())
+	assertEquals(t, "expected %q but got %q", "[1 3 4 5 6]", fmt.Sprint(s.BindParameterIndices()))
+	assertEquals(t, "expected %q but got %q", "[?1 ?3 :name ?5 @x]", fmt.Sprint(s.UnboundParameters()))
+
+	checkNoError(t, s.BindByIndex(1, "a"), "bind error: %s")
+	checkNoError(t, s.NamedBind(":name", "b", "?3", nil), "named bind error: %s")
+	assertEquals(t, "expected %q but got %q", "[?5 @x]", fmt.Sprint(s.UnboundParameters()))
+	err = s.CheckBindings()
+	assert(t, "unbound parameters error expected", err != nil && strings.Contains(err.Error(), "?5, @x"))
+
+	checkNoError(t, s.BindNumbered(map[int]interface{}{5: 1, 6: 2}), "bind error: %s")
+	checkNoError(t, s.CheckBindings(), "check bindings error: %s")
+	checkNoError(t, s.ClearBindings(), "clear bindings error: %s")
+	assertEquals(t, "expected %d unbound parameters but got %d", 5, len(s.UnboundParameters()))
+}
+
 func TestBind(t *testing.T) {
 	db := open(t)
 	defer checkClose(db, t)
@@ -356,6 +642,125 @@ func TestBind(t *testing.T) {
 	assert(t, "unsupported type error expected", err != nil)
 }
 
+func TestStrictInsertIds(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	db.SetLastInsertRowid(42)
+	assertEquals(t, "expected %d but got %d", int64(42), db.LastInsertRowid())
+
+	is, err := db.Prepare("INSERT INTO test (a_string) VALUES (?)")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(is, t)
+	us, err := db.Prepare("UPDATE test SET a_string = ?")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(us, t)
+
+	id, err := is.Insert("insert")
+	checkNoError(t, err, "insert error: %s")
+	id, err = us.Insert("update")
+	checkNoError(t, err, "update error: %s")
+	assertEquals(t, "expected %d but got %d", int64(1), id) // misleading
+
+	db.SetStrictInsertIds(true)
+	id, err = us.Insert("update")
+	checkNoError(t, err, "update error: %s")
+	assertEquals(t, "expected %d but got %d", int64(-1), id)
+	id, err = is.Insert("insert")
+	checkNoError(t, err, "insert error: %s")
+	assertEquals(t, "expected %d but got %d", int64(2), id)
+}
+
+func TestInsertWithoutRowid(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE kv (k INTEGER PRIMARY KEY, v TEXT) WITHOUT ROWID; CREATE INDEX kv_v ON kv (v)"), "create error: %s")
+	db.SetLastInsertRowid(42)
+
+	s, err := db.Prepare("INSERT INTO kv VALUES (?, ?)")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	id, err := s.Insert(1, "a")
+	assert(t, "WITHOUT ROWID error expected", err != nil)
+	assertEquals(t, "expected %d but got %d", int64(-1), id)
+	assert(t, "table name expected in error", strings.Contains(err.Error(), `"kv"`))
+
+	rs, err := db.Prepare("INSERT INTO kv VALUES (?, ?) RETURNING k")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(rs, t)
+	id, err = rs.Insert(7, "b")
+	checkNoError(t, err, "insert error: %s")
+	assertEquals(t, "expected %d but got %d", int64(7), id)
+
+	checkNoError(t, db.Exec("CREATE TABLE r (v TEXT); CREATE INDEX r_v ON r (v)"), "create error: %s")
+	is, err := db.Prepare("INSERT INTO r VALUES (?)")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(is, t)
+	id, err = is.Insert("c")
+	checkNoError(t, err, "insert error: %s")
+	assertEquals(t, "expected %d but got %d", int64(1), id)
+}
+
+func TestStrictInsertIdsWithTriggers(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE audit (msg TEXT); CREATE TRIGGER test_update AFTER UPDATE ON test BEGIN INSERT INTO audit VALUES (new.a_string); END"), "create error: %s")
+	checkNoError(t, db.Exec("CREATE VIEW vtest AS SELECT a_string FROM test; CREATE TRIGGER vtest_insert INSTEAD OF INSERT ON vtest BEGIN INSERT INTO test (a_string) VALUES (new.a_string); END"), "create error: %s")
+	var actions []Action
+	checkNoError(t, db.SetAuthorizer(func(_ interface{}, action Action, _, _, _, _ string) Auth {
+		actions = append(actions, action)
+		return AuthOk
+	}, nil), "authorizer error: %s")
+	db.SetStrictInsertIds(true)
+
+	is, err := db.Prepare("INSERT INTO test (a_string) VALUES (?)")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(is, t)
+	id, err := is.Insert("insert")
+	checkNoError(t, err, "insert error: %s")
+	assertEquals(t, "expected %d but got %d", int64(1), id)
+
+	us, err := db.Prepare("UPDATE test SET a_string = ?")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(us, t)
+	id, err = us.Insert("update") // the trigger inserts into audit
+	checkNoError(t, err, "update error: %s")
+	assertEquals(t, "expected %d but got %d", int64(-1), id)
+	actions = actions[:0]
+	checkNoError(t, db.Exec("DELETE FROM audit"), "delete error: %s")
+	assert(t, "authorizer expected to be kept", len(actions) > 0)
+
+	vs, err := db.Prepare("INSERT INTO vtest VALUES (?)")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(vs, t)
+	id, err = vs.Insert("view")
+	checkNoError(t, err, "insert error: %s")
+	assertEquals(t, "expected %d but got %d", int64(-1), id)
+}
+
+func TestPeekType(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	s, err := db.Prepare("SELECT NULL, 42, 3.14, 'héllo', x'00010203'")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	assert(t, "row expected", Must(s.Next()))
+
+	expected := []Type{Null, Integer, Float, Text, Blob}
+	sizes := []int{0, 0, 0, 6, 4}
+	for i := range expected {
+		typ, n := s.PeekType(i)
+		assertEquals(t, "expected %s but got %s", expected[i], typ)
+		assertEquals(t, "expected %d bytes but got %d", sizes[i], n)
+		assertEquals(t, "expected %d bytes but got %d", sizes[i], s.ColumnBytes(i))
+	}
+	// numeric values are not converted
+	typ, _ := s.PeekType(1)
+	assertEquals(t, "expected %s but got %s", Integer, typ)
+	assertEquals(t, "expected %s but got %s", Integer, s.ColumnType(1))
+}
+
 func TestInsertMisuse(t *testing.T) {
 	db := open(t)
 	defer checkClose(db, t)
@@ -401,6 +806,42 @@ func TestScanBytes(t *testing.T) {
 	assertEquals(t, "expected %v but got %v", "test", string(blob))
 }
 
+func TestScanTextInto(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+
+	s, err := db.Prepare("SELECT 'short', 'a longer text', NULL, ''")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	if !Must(s.Next()) {
+		t.Fatal("no result")
+	}
+	buf := make([]byte, 0, 8)
+	buf, null, err := s.ScanTextInto(0, buf)
+	checkNoError(t, err, "scan error: %s")
+	assert(t, "not null expected", !null)
+	assertEquals(t, "expected %q but got %q", "short", string(buf))
+	assertEquals(t, "expected capacity %d but got %d", 8, cap(buf))
+	buf, _, err = s.ScanTextInto(1, buf)
+	checkNoError(t, err, "scan error: %s")
+	assertEquals(t, "expected %q but got %q", "a longer text", string(buf))
+	buf, null, err = s.ScanTextInto(2, buf)
+	checkNoError(t, err, "scan error: %s")
+	assert(t, "null expected", null)
+	assertEquals(t, "expected %d bytes but got %d", 0, len(buf))
+	buf, null, err = s.ScanTextInto(3, buf)
+	checkNoError(t, err, "scan error: %s")
+	assert(t, "not null expected", !null)
+	assertEquals(t, "expected %d bytes but got %d", 0, len(buf))
+	_, _, err = s.ScanTextInto(4, buf)
+	assert(t, "error expected with index out of range", err != nil)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _, _ = s.ScanTextInto(1, buf)
+	})
+	assertEquals(t, "expected %v allocations but got %v", 0.0, allocs)
+}
+
 func TestBindEmptyZero(t *testing.T) {
 	db := open(t)
 	defer checkClose(db, t)
@@ -453,3 +894,61 @@ func TestBindEmptyZeroNotTransformedToNull(t *testing.T) {
 	_, null = s.ScanValue(1, false)
 	assert(t, "Zero time expected", !null)
 }
+
+func TestPrepareAll(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+
+	script := db.PrepareAll(`CREATE TABLE script (name TEXT); -- comment
+		INSERT INTO script VALUES (:name);
+		/* comment */ INSERT INTO script VALUES (:name);
+		`)
+	defer script.Close()
+	var n int
+	for script.Next() {
+		s := script.Stmt()
+		if s.BindParameterCount() > 0 {
+			checkNoError(t, s.Exec("test"), "exec error: %s")
+		} else {
+			checkNoError(t, s.Exec(), "exec error: %s")
+		}
+		n++
+	}
+	checkNoError(t, script.Err(), "script error: %s")
+	assertEquals(t, "expected %d statements but got %d", 3, n)
+	var count int
+	err := db.OneValue("SELECT count(*) FROM script", &count)
+	checkNoError(t, err, "count error: %s")
+	assertEquals(t, "expected %d rows but got %d", 2, count)
+
+	script = db.PrepareAll("SELECT 1; SELECT * FROM doesnotexist; SELECT 2")
+	defer script.Close()
+	assert(t, "first statement expected", script.Next())
+	assert(t, "prepare error expected", !script.Next() && script.Err() != nil)
+}
+
+func TestTypeAffinity(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE typed (d DATETIME, b BOOLEAN, s TEXT);"+
+		"INSERT INTO typed VALUES ('2012-01-02 03:04:05', 1, 'x'), (NULL, NULL, NULL)"), "exec error: %s")
+
+	var d, b, str interface{}
+	checkNoError(t, db.OneValue("SELECT d FROM typed", &d), "select error: %s")
+	_, ok := d.(string)
+	assert(t, "string expected when type affinity is disabled", ok)
+
+	db.SetTypeAffinity(true)
+	assert(t, "type affinity expected", db.TypeAffinity())
+	s, err := db.Prepare("SELECT d, b, s FROM typed ORDER BY rowid")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	assert(t, "row expected", Must(s.Next()))
+	checkNoError(t, s.Scan(&d, &b, &str), "scan error: %s")
+	assertEquals(t, "expected %v but got %v", time.Date(2012, 1, 2, 3, 4, 5, 0, time.UTC), d)
+	assertEquals(t, "expected %v but got %v", true, b)
+	assertEquals(t, "expected %v but got %v", "x", str)
+	assert(t, "row expected", Must(s.Next()))
+	checkNoError(t, s.Scan(&d, &b, &str), "scan error: %s")
+	assert(t, "nil expected", d == nil && b == nil && str == nil)
+}
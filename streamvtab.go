@@ -0,0 +1,364 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrStreamConsumed is returned when a stream virtual table is scanned more than once.
+var ErrStreamConsumed = errors.New("stream already consumed (a stream virtual table can be scanned only once)")
+
+type streamFormat int
+
+const (
+	streamCSV streamFormat = iota
+	streamNDJSON
+	streamChan
+)
+
+// StreamModule is a virtual table module exposing a stream of CSV records or JSON lines (NDJSON),
+// read from an io.Reader, or of rows received from a channel (see NewChanModule),
+// as a one-pass virtual table (without temporary file):
+//
+//	db.CreateModule("orders", NewCSVModule(resp.Body, true))
+//	db.Exec("CREATE VIRTUAL TABLE temp.orders USING orders")
+//	SELECT c.name, o.amount FROM orders o JOIN customer c ON c.id = o.customer_id
+//
+// The column names are the module arguments when specified,
+// otherwise the CSV header (or c1...cN without header) or the keys of the first JSON object.
+// CSV fields are returned as TEXT. JSON values are returned as INTEGER, REAL, TEXT or NULL
+// (booleans as 0/1, objects and arrays as JSON text).
+// Records are read while the table is scanned, so it can be scanned only once (ErrStreamConsumed):
+// in a join, the stream should be the outer loop (or be materialized first with CREATE TABLE ... AS SELECT).
+// A module can back only one virtual table (the stream cannot be rewound).
+type StreamModule struct {
+	format streamFormat
+	r      io.Reader
+	header bool
+	ch     <-chan []interface{}
+	names  []string
+	vTab   *streamVTab
+}
+
+// NewCSVModule creates a module reading CSV records from r.
+// If header is true, the first record contains the column names.
+func NewCSVModule(r io.Reader, header bool) *StreamModule {
+	return &StreamModule{format: streamCSV, r: r, header: header}
+}
+
+// NewNDJSONModule creates a module reading JSON objects from r (one per line).
+func NewNDJSONModule(r io.Reader) *StreamModule {
+	return &StreamModule{format: streamNDJSON, r: r}
+}
+
+// NewChanModule creates a module reading the rows sent to ch until it is closed:
+//
+//	ch := make(chan []interface{})
+//	db.CreateModule("ingest", NewChanModule(ch, "ts", "value"))
+//	db.Exec("CREATE VIRTUAL TABLE temp.ingest USING ingest")
+//	go produce(ch) // sends []interface{}{ts, value} rows and closes ch
+//	db.Exec("INSERT INTO measure SELECT * FROM ingest")
+//
+// The reading query blocks while waiting for the next row.
+// Row values can be nil, string, []byte, int, int64, float64 or bool.
+// The column names may be overridden by the module arguments.
+func NewChanModule(ch <-chan []interface{}, columns ...string) *StreamModule {
+	return &StreamModule{format: streamChan, ch: ch, names: columns}
+}
+
+// Create declares the virtual table, reading the first record to infer the columns if needed.
+func (m *StreamModule) Create(c *Conn, args []string) (VTab, error) {
+	if m.vTab != nil {
+		return nil, errors.New("stream module already used by a virtual table")
+	}
+	var names []string
+	for _, arg := range args[3:] {
+		names = append(names, strings.Trim(strings.TrimSpace(arg), `'"[]`+"`"))
+	}
+	v := &streamVTab{m: m}
+	var err error
+	switch m.format {
+	case streamCSV:
+		v.csv = csv.NewReader(m.r)
+		v.csv.FieldsPerRecord = -1
+		names, err = v.csvColumns(names)
+	case streamChan:
+		if len(names) == 0 {
+			names = m.names
+		}
+	default:
+		v.lines = bufio.NewReader(m.r)
+		names, err = v.jsonColumns(names)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no column in stream")
+	}
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = Mprintf(`"%w"`, name)
+	}
+	if err = c.DeclareVTab(fmt.Sprintf("CREATE TABLE x(%s)", strings.Join(quoted, ", "))); err != nil {
+		return nil, err
+	}
+	v.names = names
+	m.vTab = v
+	return v, nil
+}
+
+// Connect is like Create.
+func (m *StreamModule) Connect(c *Conn, args []string) (VTab, error) {
+	return m.Create(c, args)
+}
+
+// Destroy does nothing.
+func (m *StreamModule) Destroy() {
+}
+
+type streamVTab struct {
+	m       *StreamModule
+	names   []string
+	csv     *csv.Reader
+	lines   *bufio.Reader
+	pending []interface{} // first record read to infer the columns
+	scanned bool
+}
+
+func (v *streamVTab) csvColumns(names []string) ([]string, error) {
+	record, err := v.csv.Read()
+	if err == io.EOF {
+		return names, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if v.m.header {
+		if len(names) == 0 {
+			names = record
+		}
+		return names, nil
+	}
+	v.pending = csvValues(record)
+	for i := len(names); i < len(record); i++ {
+		names = append(names, fmt.Sprintf("c%d", i+1))
+	}
+	return names, nil
+}
+
+func csvValues(record []string) []interface{} {
+	values := make([]interface{}, len(record))
+	for i, field := range record {
+		values[i] = field
+	}
+	return values
+}
+
+// jsonColumns reads the first object: its keys (in document order) are the default column names.
+func (v *streamVTab) jsonColumns(names []string) ([]string, error) {
+	line, err := v.nextLine()
+	if err == io.EOF {
+		return names, nil
+	} else if err != nil {
+		return nil, err
+	}
+	keys, err := jsonKeys(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		names = keys
+	}
+	if v.pending, err = jsonValues(line, names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// nextLine returns the next non-blank line.
+func (v *streamVTab) nextLine() ([]byte, error) {
+	for {
+		line, err := v.lines.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			return line, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func jsonKeys(line []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	if t, err := dec.Token(); err != nil {
+		return nil, err
+	} else if t != json.Delim('{') {
+		return nil, fmt.Errorf("JSON object expected: %s", line)
+	}
+	var keys []string
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, t.(string))
+		var skip json.RawMessage
+		if err = dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+func jsonValues(line []byte, names []string) ([]interface{}, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(line, &obj); err != nil {
+		return nil, fmt.Errorf("invalid JSON line: %s", err)
+	}
+	values := make([]interface{}, len(names))
+	for i, name := range names {
+		raw, ok := obj[name]
+		if !ok {
+			continue
+		}
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		switch x := v.(type) {
+		case json.Number:
+			if n, err := strconv.ParseInt(string(x), 10, 64); err == nil {
+				values[i] = n
+			} else if f, err := x.Float64(); err == nil {
+				values[i] = f
+			} else {
+				values[i] = string(x)
+			}
+		case bool:
+			if x {
+				values[i] = int64(1)
+			} else {
+				values[i] = int64(0)
+			}
+		case string, nil:
+			values[i] = x
+		default: // object or array
+			values[i] = string(raw)
+		}
+	}
+	return values, nil
+}
+
+// next reads the next record (io.EOF at the end of the stream).
+func (v *streamVTab) next() ([]interface{}, error) {
+	if v.pending != nil {
+		record := v.pending
+		v.pending = nil
+		return record, nil
+	}
+	if v.m.ch != nil {
+		record, ok := <-v.m.ch
+		if !ok {
+			return nil, io.EOF
+		}
+		return record, nil
+	}
+	if v.csv != nil {
+		record, err := v.csv.Read()
+		if err != nil {
+			return nil, err
+		}
+		return csvValues(record), nil
+	}
+	line, err := v.nextLine()
+	if err != nil {
+		return nil, err
+	}
+	return jsonValues(line, v.names)
+}
+
+func (v *streamVTab) BestIndex() error {
+	return nil
+}
+func (v *streamVTab) Disconnect() error {
+	return nil
+}
+func (v *streamVTab) Destroy() error {
+	return nil
+}
+func (v *streamVTab) Open() (VTabCursor, error) {
+	return &streamVTabCursor{vTab: v}, nil
+}
+
+type streamVTabCursor struct {
+	vTab   *streamVTab
+	record []interface{}
+	rowid  int64
+	eof    bool
+}
+
+func (vc *streamVTabCursor) Close() error {
+	return nil
+}
+func (vc *streamVTabCursor) Filter() error {
+	if vc.vTab.scanned {
+		return ErrStreamConsumed
+	}
+	vc.vTab.scanned = true
+	return vc.Next()
+}
+func (vc *streamVTabCursor) Next() error {
+	record, err := vc.vTab.next()
+	if err == io.EOF {
+		vc.eof = true
+		return nil
+	} else if err != nil {
+		return err
+	}
+	vc.record = record
+	vc.rowid++
+	return nil
+}
+func (vc *streamVTabCursor) Eof() bool {
+	return vc.eof
+}
+func (vc *streamVTabCursor) Column(c *Context, col int) error {
+	if col >= len(vc.record) {
+		c.ResultNull()
+		return nil
+	}
+	switch v := vc.record[col].(type) {
+	case nil:
+		c.ResultNull()
+	case string:
+		c.ResultText(v)
+	case []byte:
+		c.ResultBlob(v)
+	case int:
+		c.ResultInt64(int64(v))
+	case int64:
+		c.ResultInt64(v)
+	case float64:
+		c.ResultDouble(v)
+	case bool:
+		c.ResultBool(v)
+	default:
+		return fmt.Errorf("unexpected value type: %T", v)
+	}
+	return nil
+}
+func (vc *streamVTabCursor) Rowid() (int64, error) {
+	return vc.rowid, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"strings"
+	"testing"
+)
+
+func TestCSVModule(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE customer (id INTEGER PRIMARY KEY, name TEXT)"), "create error: %s")
+	checkNoError(t, db.Exec("INSERT INTO customer VALUES (1, 'alice'), (2, 'bob')"), "insert error: %s")
+
+	r := strings.NewReader("customer_id,amount\n1,10\n2,20\n1,5\n")
+	checkNoError(t, db.CreateModule("orders", NewCSVModule(r, true)), "couldn't create module: %s")
+	checkNoError(t, db.Exec("CREATE VIRTUAL TABLE temp.orders USING orders"), "couldn't create virtual table: %s")
+	var total int
+	checkNoError(t, db.OneValue("SELECT sum(o.amount) FROM orders o JOIN customer c ON c.id = o.customer_id WHERE c.name = 'alice'",
+		&total), "select error: %s")
+	assertEquals(t, "expected %d but got %d", 15, total)
+
+	var n int
+	err := db.OneValue("SELECT count(*) FROM orders", &n)
+	assert(t, "consumed stream error expected", err != nil)
+	err = db.Exec("CREATE VIRTUAL TABLE temp.orders2 USING orders")
+	assert(t, "module reuse error expected", err != nil)
+}
+
+func TestCSVModuleNoHeader(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	r := strings.NewReader("a,1\nb,2\n")
+	checkNoError(t, db.CreateModule("csv", NewCSVModule(r, false)), "couldn't create module: %s")
+	checkNoError(t, db.Exec("CREATE VIRTUAL TABLE temp.t USING csv(name)"), "couldn't create virtual table: %s")
+	var names string
+	checkNoError(t, db.OneValue("SELECT group_concat(name || c2) FROM t", &names), "select error: %s")
+	assertEquals(t, "expected %q but got %q", "a1,b2", names)
+}
+
+func TestNDJSONModule(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	r := strings.NewReader(`{"id": 1, "name": "a", "score": 1.5, "ok": true, "tags": ["x"]}
+
+{"id": 2, "name": null, "score": 2}
+`)
+	checkNoError(t, db.CreateModule("events", NewNDJSONModule(r)), "couldn't create module: %s")
+	checkNoError(t, db.Exec("CREATE VIRTUAL TABLE temp.events USING events"), "couldn't create virtual table: %s")
+	s, err := db.Prepare("SELECT id, name, typeof(score), ok, tags FROM events")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	var rows []string
+	err = s.Select(func(s *Stmt) error {
+		var id int
+		var name, typ, tags string
+		var ok bool
+		if err := s.Scan(&id, &name, &typ, &ok, &tags); err != nil {
+			return err
+		}
+		rows = append(rows, strings.Join([]string{name, typ, tags}, "|"))
+		return nil
+	})
+	checkNoError(t, err, "select error: %s")
+	assertEquals(t, "expected %q but got %q", `a|real|["x"];|integer|`, strings.Join(rows, ";"))
+}
+
+func TestChanModule(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE measure (name TEXT, value REAL)"), "create error: %s")
+	ch := make(chan []interface{})
+	checkNoError(t, db.CreateModule("ingest", NewChanModule(ch, "name", "value")), "couldn't create module: %s")
+	checkNoError(t, db.Exec("CREATE VIRTUAL TABLE temp.ingest USING ingest"), "couldn't create virtual table: %s")
+	go func() {
+		for i := 0; i < 100; i++ {
+			ch <- []interface{}{"m", float64(i)}
+		}
+		close(ch)
+	}()
+	checkNoError(t, db.Exec("INSERT INTO measure SELECT * FROM ingest"), "insert error: %s")
+	assertEquals(t, "expected %d rows but got %d", 100, db.Changes())
+	var sum float64
+	checkNoError(t, db.OneValue("SELECT sum(value) FROM measure", &sum), "select error: %s")
+	assertEquals(t, "expected %f but got %f", float64(4950), sum)
+
+	err := db.Exec("INSERT INTO measure SELECT * FROM ingest")
+	assert(t, "consumed stream error expected", err != nil)
+	checkNoError(t, db.CreateModule("nocol", NewChanModule(ch)), "couldn't create module: %s")
+	err = db.Exec("CREATE VIRTUAL TABLE temp.nocol USING nocol")
+	assert(t, "no column error expected", err != nil)
+}
@@ -0,0 +1,138 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// structFields maps lower-cased column names to field index paths (cached by struct type).
+var structFields sync.Map // map[reflect.Type]map[string][]int
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// fieldsOf returns the columns bound to the exported fields of the specified struct type.
+// The column name is given by the `sql:"name"` tag or defaults to the field name.
+// Fields tagged with `sql:"-"` are ignored and fields of embedded structs are promoted.
+func fieldsOf(t reflect.Type) map[string][]int {
+	if fields, ok := structFields.Load(t); ok {
+		return fields.(map[string][]int)
+	}
+	fields := make(map[string][]int)
+	collectFields(t, nil, fields)
+	structFields.Store(t, fields)
+	return fields
+}
+
+func collectFields(t reflect.Type, parent []int, fields map[string][]int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("sql")
+		if name == "-" {
+			continue
+		}
+		index := make([]int, len(parent)+1)
+		copy(index, parent)
+		index[len(parent)] = i
+		if f.Anonymous && len(name) == 0 && f.Type.Kind() == reflect.Struct && f.Type != timeType {
+			collectFields(f.Type, index, fields)
+			continue
+		}
+		if len(f.PkgPath) > 0 { // unexported
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+		name = strings.ToLower(name)
+		if _, ok := fields[name]; ok && len(index) > 1 { // outer fields win
+			continue
+		}
+		fields[name] = index
+	}
+}
+
+// ScanStruct scans the current row into the fields of the struct pointed to by dest.
+// Columns are matched (case-insensitively) against the `sql:"name"` tag or the field name.
+// Columns without matching field are ignored.
+// NULL values are converted like in Stmt.Scan unless the field is a pointer (which is then set to nil).
+func (s *Stmt) ScanStruct(dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return s.specificError("ScanStruct expects a pointer to a struct but got %T", dest)
+	}
+	return s.scanStruct(rv.Elem())
+}
+
+func (s *Stmt) scanStruct(v reflect.Value) error {
+	fields := fieldsOf(v.Type())
+	for i, name := range s.ColumnNames() {
+		index, ok := fields[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+		f := v.FieldByIndex(index)
+		if f.Kind() == reflect.Ptr && f.IsNil() {
+			f.Set(reflect.New(f.Type().Elem()))
+		}
+		if _, err := s.ScanByIndex(i, f.Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SelectAll prepares (cached) and executes the query
+// and appends each row to the slice pointed to by dest.
+// If dest is a *[]T or *[]*T where T is a struct, each row is scanned with Stmt.ScanStruct.
+// Otherwise, the first column of each row is scanned with Stmt.ScanByIndex.
+//
+//	var people []Person
+//	err := db.SelectAll(&people, "SELECT name, age FROM person WHERE age > ?", 18)
+func (c *Conn) SelectAll(dest interface{}, query string, args ...interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return c.specificError("SelectAll expects a pointer to a slice but got %T", dest)
+	}
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+	ptr := elemType.Kind() == reflect.Ptr && elemType.Elem().Kind() == reflect.Struct
+	isStruct := ptr || (elemType.Kind() == reflect.Struct && elemType != timeType)
+
+	s, err := c.Prepare(query, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Finalize()
+	return s.Select(func(s *Stmt) error {
+		var elem reflect.Value
+		if ptr {
+			elem = reflect.New(elemType.Elem())
+		} else {
+			elem = reflect.New(elemType)
+		}
+		if isStruct {
+			if err := s.scanStruct(elem.Elem()); err != nil {
+				return err
+			}
+		} else {
+			if elemType.Kind() == reflect.Ptr {
+				elem.Elem().Set(reflect.New(elemType.Elem()))
+			}
+			if _, err := s.ScanByIndex(0, elem.Interface()); err != nil {
+				return err
+			}
+		}
+		if ptr {
+			slice.Set(reflect.Append(slice, elem))
+		} else {
+			slice.Set(reflect.Append(slice, elem.Elem()))
+		}
+		return nil
+	})
+}
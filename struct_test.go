@@ -0,0 +1,64 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"testing"
+)
+
+type person struct {
+	Id       int64
+	Name     string   `sql:"a_string"`
+	Score    *float64 `sql:"float_num"`
+	Ignored  string   `sql:"-"`
+	internal int
+}
+
+func TestScanStruct(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string, float_num) VALUES ('bart', NULL)"), "insert error: %s")
+
+	s, err := db.Prepare("SELECT id, a_string, float_num, 1 AS ignored, 2 AS unknown FROM test")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	assert(t, "row expected", Must(s.Next()))
+	var p person
+	checkNoError(t, s.ScanStruct(&p), "scan error: %s")
+	assertEquals(t, "expected %d but got %d", int64(1), p.Id)
+	assertEquals(t, "expected %q but got %q", "bart", p.Name)
+	assert(t, "nil expected", p.Score == nil)
+	assertEquals(t, "expected %q but got %q", "", p.Ignored)
+	err = s.ScanStruct(p)
+	assert(t, "error expected", err != nil)
+}
+
+func TestSelectAll(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string, float_num) VALUES ('bart', 1.5), ('lisa', 2.5)"), "insert error: %s")
+
+	var people []person
+	checkNoError(t, db.SelectAll(&people, "SELECT * FROM test WHERE float_num > ? ORDER BY id", 0), "select error: %s")
+	assertEquals(t, "expected %d rows but got %d", 2, len(people))
+	assertEquals(t, "expected %q but got %q", "lisa", people[1].Name)
+	assertEquals(t, "expected %f but got %f", 2.5, *people[1].Score)
+
+	var ptrs []*person
+	checkNoError(t, db.SelectAll(&ptrs, "SELECT * FROM test ORDER BY id"), "select error: %s")
+	assertEquals(t, "expected %d rows but got %d", 2, len(ptrs))
+	assertEquals(t, "expected %q but got %q", "bart", ptrs[0].Name)
+
+	var names []string
+	checkNoError(t, db.SelectAll(&names, "SELECT a_string FROM test ORDER BY id DESC"), "select error: %s")
+	assertEquals(t, "expected %d rows but got %d", 2, len(names))
+	assertEquals(t, "expected %q but got %q", "lisa", names[0])
+
+	err := db.SelectAll(names, "SELECT a_string FROM test")
+	assert(t, "error expected", err != nil)
+}
@@ -0,0 +1,238 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RowIterator returns the next row of a table-valued function (io.EOF when there is no more row).
+// Row values can be nil, string, []byte, int, int64, float64 or bool.
+type RowIterator func() ([]interface{}, error)
+
+// TableFunction is the expected signature of a table-valued function implemented in Go.
+// args contains one value per parameter (nil when the argument is not specified).
+type TableFunction func(args []interface{}) (RowIterator, error)
+
+// CreateTableFunction registers a table-valued function (as an eponymous-only virtual table)
+// returning the specified columns, with the specified parameters (as HIDDEN columns):
+//
+//	db.CreateTableFunction("split", []string{"part"}, []string{"str", "sep"}, split)
+//	SELECT part FROM split('a,b,c', ',')
+//
+// Arguments can be omitted from the right (nil is then passed to f).
+func (c *Conn) CreateTableFunction(name string, columns, params []string, f TableFunction) error {
+	if len(columns) == 0 {
+		return errors.New("no column in table function")
+	}
+	return c.CreateEponymousModule(name, &tableFunctionModule{name, columns, params, f})
+}
+
+type tableFunctionModule struct {
+	name            string
+	columns, params []string
+	f               TableFunction
+}
+
+func (m *tableFunctionModule) Create(c *Conn, args []string) (VTab, error) {
+	return nil, fmt.Errorf("%s is an eponymous-only virtual table", m.name)
+}
+func (m *tableFunctionModule) Connect(c *Conn, args []string) (VTab, error) {
+	defs := make([]string, 0, len(m.columns)+len(m.params))
+	for _, column := range m.columns {
+		defs = append(defs, Mprintf(`"%w"`, column))
+	}
+	for _, param := range m.params {
+		defs = append(defs, Mprintf(`"%w" HIDDEN`, param))
+	}
+	if err := c.DeclareVTab(fmt.Sprintf("CREATE TABLE x(%s)", strings.Join(defs, ", "))); err != nil {
+		return nil, err
+	}
+	return &tableFunctionVTab{m}, nil
+}
+func (m *tableFunctionModule) Destroy() {
+}
+
+type tableFunctionVTab struct {
+	m *tableFunctionModule
+}
+
+func (v *tableFunctionVTab) BestIndex() error {
+	return nil
+}
+
+// BestIndexInfo passes the arguments to FilterArgs: idxNum is the bitmask of the specified parameters.
+func (v *tableFunctionVTab) BestIndexInfo(info *IndexInfo) error {
+	nColumns := len(v.m.columns)
+	argvIndex := 0
+	for p := range v.m.params {
+		for i, c := range info.Constraints {
+			if c.Usable && c.Op == IndexConstraintEq && c.Column == nColumns+p {
+				argvIndex++
+				info.Constraints[i].ArgvIndex = argvIndex
+				info.Constraints[i].Omit = true
+				info.IdxNum |= 1 << uint(p)
+				break
+			}
+		}
+	}
+	// the more arguments, the better (unusable arguments are expected in joins)
+	info.EstimatedCost = float64(int64(1) << uint(len(v.m.params)-argvIndex) * 1000)
+	return nil
+}
+func (v *tableFunctionVTab) Disconnect() error {
+	return nil
+}
+func (v *tableFunctionVTab) Destroy() error {
+	return nil
+}
+func (v *tableFunctionVTab) Open() (VTabCursor, error) {
+	return &tableFunctionVTabCursor{vTab: v}, nil
+}
+
+type tableFunctionVTabCursor struct {
+	vTab  *tableFunctionVTab
+	args  []interface{}
+	next  RowIterator
+	row   []interface{}
+	rowid int64
+	eof   bool
+}
+
+func (vc *tableFunctionVTabCursor) Close() error {
+	return nil
+}
+func (vc *tableFunctionVTabCursor) Filter() error {
+	return vc.FilterArgs(0, nil)
+}
+func (vc *tableFunctionVTabCursor) FilterArgs(idxNum int, args []interface{}) error {
+	vc.args = make([]interface{}, len(vc.vTab.m.params))
+	for p := range vc.args {
+		if idxNum&(1<<uint(p)) != 0 && len(args) > 0 {
+			vc.args[p] = args[0]
+			args = args[1:]
+		}
+	}
+	next, err := vc.vTab.m.f(vc.args)
+	if err != nil {
+		return err
+	}
+	vc.next, vc.rowid, vc.eof = next, 0, false
+	return vc.Next()
+}
+func (vc *tableFunctionVTabCursor) Next() error {
+	row, err := vc.next()
+	if err == io.EOF {
+		vc.eof = true
+		return nil
+	} else if err != nil {
+		return err
+	}
+	vc.row = row
+	vc.rowid++
+	return nil
+}
+func (vc *tableFunctionVTabCursor) Eof() bool {
+	return vc.eof
+}
+func (vc *tableFunctionVTabCursor) Column(c *Context, col int) error {
+	var v interface{}
+	if n := len(vc.vTab.m.columns); col >= n {
+		v = vc.args[col-n]
+	} else if col < len(vc.row) {
+		v = vc.row[col]
+	}
+	switch v := v.(type) {
+	case nil:
+		c.ResultNull()
+	case string:
+		c.ResultText(v)
+	case []byte:
+		c.ResultBlob(v)
+	case int:
+		c.ResultInt64(int64(v))
+	case int64:
+		c.ResultInt64(v)
+	case float64:
+		c.ResultDouble(v)
+	case bool:
+		c.ResultBool(v)
+	default:
+		return fmt.Errorf("unsupported type in table function row: %T", v)
+	}
+	return nil
+}
+func (vc *tableFunctionVTabCursor) Rowid() (int64, error) {
+	return vc.rowid, nil
+}
+
+// CreateGenerateSeries registers the generate_series(start, stop, step) table-valued function,
+// like the series extension of SQLite (when it is not compiled in):
+// the integers from start to stop (default 4294967295) by step (default 1).
+// When step is negative, the same values are returned in descending order.
+// (See http://sqlite.org/series.html)
+func (c *Conn) CreateGenerateSeries() error {
+	return c.CreateTableFunction("generate_series", []string{"value"}, []string{"start", "stop", "step"}, generateSeries)
+}
+
+func generateSeries(args []interface{}) (RowIterator, error) {
+	if args[0] == nil {
+		return nil, errors.New("first argument to \"generate_series()\" missing or unusable")
+	}
+	var start, stop, step int64 = 0, 0xffffffff, 1
+	for i, p := range []*int64{&start, &stop, &step} {
+		switch v := args[i].(type) {
+		case nil:
+		case int64:
+			*p = v
+		case float64:
+			*p = int64(v)
+		case string:
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("generate_series: integer expected but got %q", v)
+			}
+			*p = n
+		default:
+			return nil, fmt.Errorf("generate_series: integer expected but got %v", v)
+		}
+	}
+	desc := step < 0
+	if step == 0 {
+		step = 1
+	} else if desc {
+		if step == math.MinInt64 {
+			step = math.MaxInt64
+		} else {
+			step = -step
+		}
+	}
+	if start > stop {
+		return func() ([]interface{}, error) { return nil, io.EOF }, nil
+	}
+	// number of values minus one
+	last := uint64(stop-start) / uint64(step)
+	var i uint64
+	done := false
+	return func() ([]interface{}, error) {
+		if done {
+			return nil, io.EOF
+		}
+		n := i
+		if desc {
+			n = last - i
+		}
+		if i == last {
+			done = true
+		}
+		i++
+		return []interface{}{start + int64(n*uint64(step))}, nil
+	}, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSeries(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	checkNoError(t, db.CreateGenerateSeries(), "couldn't create generate_series: %s")
+	for _, tc := range []struct {
+		sql      string
+		expected string
+	}{
+		{"SELECT group_concat(value) FROM generate_series(1, 5)", "1,2,3,4,5"},
+		{"SELECT group_concat(value) FROM generate_series(0, 10, 3)", "0,3,6,9"},
+		{"SELECT group_concat(value) FROM generate_series(1, 10, -2)", "9,7,5,3,1"},
+		{"SELECT group_concat(value) FROM generate_series(5, 1)", ""},
+		{"SELECT group_concat(value) FROM (SELECT value FROM generate_series(7) LIMIT 2)", "7,8"},
+		{"SELECT group_concat(value) FROM generate_series WHERE start = 1 AND stop = 3", "1,2,3"},
+		{"SELECT group_concat(start || stop || step) FROM generate_series(1, 1, 1)", "111"},
+	} {
+		var s string
+		checkNoError(t, db.OneValue(tc.sql, &s), "select error: %s")
+		assertEquals(t, "expected %q but got %q", tc.expected, s)
+	}
+	var n int
+	checkNoError(t, db.OneValue("SELECT count(*) FROM generate_series(1, 3) a, generate_series(a.value, 3) b", &n), "select error: %s")
+	assertEquals(t, "expected %d but got %d", 6, n)
+	err := db.OneValue("SELECT count(*) FROM generate_series", &n)
+	assert(t, "missing argument error expected", err != nil)
+}
+
+func TestCreateTableFunction(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	split := func(args []interface{}) (RowIterator, error) {
+		str, _ := args[0].(string)
+		sep, ok := args[1].(string)
+		if !ok {
+			sep = ","
+		}
+		parts := strings.Split(str, sep)
+		i := 0
+		return func() ([]interface{}, error) {
+			if i >= len(parts) {
+				return nil, io.EOF
+			}
+			i++
+			return []interface{}{parts[i-1], i}, nil
+		}, nil
+	}
+	err := db.CreateTableFunction("split", []string{"part", "pos"}, []string{"str", "sep"}, split)
+	checkNoError(t, err, "couldn't create table function: %s")
+	var s string
+	checkNoError(t, db.OneValue("SELECT group_concat(pos || part, ' ') FROM split('a;b;c', ';')", &s), "select error: %s")
+	assertEquals(t, "expected %q but got %q", "1a 2b 3c", s)
+	checkNoError(t, db.OneValue("SELECT group_concat(part, ' ') FROM split('x,y')", &s), "select error: %s")
+	assertEquals(t, "expected %q but got %q", "x y", s)
+}
@@ -0,0 +1,132 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"fmt"
+	"time"
+)
+
+// CreateTimeFunctions registers SQL date/time functions implemented with the Go time package
+// (and its time zone database):
+//
+//	go_now()                      current time
+//	go_format(ts, layout[, tz])   ts formatted with the Go layout, in tz (default UTC)
+//	go_parse(txt, layout[, tz])   txt parsed with the Go layout, in tz when txt has no zone (default UTC)
+//	go_tz(ts, tz)                 ts converted to the time zone tz ("Europe/Paris" for example)
+//
+// The time values (ts) are either texts like the ones returned by the SQLite date functions,
+// unix epochs (integers) or julian days (floats); go_now, go_parse and go_tz return RFC 3339 texts
+// which are understood by the SQLite date functions.
+// NULL arguments give NULL results.
+func (c *Conn) CreateTimeFunctions() error {
+	err := c.CreateScalarFunction("go_now", 0, nil, func(ctx *ScalarContext, nArg int) {
+		ctx.ResultText(time.Now().UTC().Format(time.RFC3339Nano))
+	}, nil)
+	if err != nil {
+		return err
+	}
+	err = c.CreateScalarFunction("go_format", -1, nil, func(ctx *ScalarContext, nArg int) {
+		if checkTimeArgs(ctx, "go_format", nArg, 2, 3) {
+			return
+		}
+		t, loc, ok := timeArgs(ctx, nArg)
+		if ok {
+			ctx.ResultText(t.In(loc).Format(ctx.Text(1)))
+		}
+	}, nil)
+	if err != nil {
+		return err
+	}
+	err = c.CreateScalarFunction("go_parse", -1, nil, func(ctx *ScalarContext, nArg int) {
+		if checkTimeArgs(ctx, "go_parse", nArg, 2, 3) {
+			return
+		}
+		loc := time.UTC
+		if nArg > 2 {
+			var ok bool
+			if loc, ok = locationArg(ctx, 2); !ok {
+				return
+			}
+		}
+		t, err := time.ParseInLocation(ctx.Text(1), ctx.Text(0), loc)
+		if err != nil {
+			ctx.ResultError(err.Error())
+			return
+		}
+		ctx.ResultText(t.UTC().Format(time.RFC3339Nano))
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return c.CreateScalarFunction("go_tz", 2, nil, func(ctx *ScalarContext, nArg int) {
+		if checkTimeArgs(ctx, "go_tz", nArg, 2, 2) {
+			return
+		}
+		t, err := timeArg(ctx, 0)
+		if err != nil {
+			ctx.ResultError(err.Error())
+			return
+		}
+		if loc, ok := locationArg(ctx, 1); ok {
+			ctx.ResultText(t.In(loc).Format(time.RFC3339Nano))
+		}
+	}, nil)
+}
+
+// checkTimeArgs returns true when the result has been set (NULL argument or error).
+func checkTimeArgs(ctx *ScalarContext, name string, nArg, min, max int) bool {
+	if nArg < min || nArg > max {
+		ctx.ResultError(fmt.Sprintf("wrong number of arguments to function %s()", name))
+		return true
+	}
+	for i := 0; i < nArg; i++ {
+		if ctx.Type(i) == Null {
+			ctx.ResultNull()
+			return true
+		}
+	}
+	return false
+}
+
+// timeArgs returns the time (first argument) and the optional location (third argument) of go_format.
+func timeArgs(ctx *ScalarContext, nArg int) (time.Time, *time.Location, bool) {
+	t, err := timeArg(ctx, 0)
+	if err != nil {
+		ctx.ResultError(err.Error())
+		return t, nil, false
+	}
+	if nArg < 3 {
+		return t, time.UTC, true
+	}
+	loc, ok := locationArg(ctx, 2)
+	return t, loc, ok
+}
+
+// timeArg converts a text (see ScanTime), a unix epoch or a julian day (in UTC) into a time.
+func timeArg(ctx *ScalarContext, i int) (time.Time, error) {
+	switch ctx.Type(i) {
+	case Integer:
+		return time.Unix(ctx.Int64(i), 0).UTC(), nil
+	case Float:
+		return JulianDayToUTC(ctx.Double(i)), nil
+	}
+	txt := ctx.Text(i)
+	return time.Parse(timeLayout(txt), txt)
+}
+
+// locationArg loads the time zone named by the argument i (cached as auxiliary data).
+func locationArg(ctx *ScalarContext, i int) (*time.Location, bool) {
+	if loc, ok := ctx.GetAuxData(i).(*time.Location); ok {
+		return loc, true
+	}
+	loc, err := time.LoadLocation(ctx.Text(i))
+	if err != nil {
+		ctx.ResultError(err.Error())
+		return nil, false
+	}
+	ctx.SetAuxData(i, loc)
+	return loc, true
+}
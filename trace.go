@@ -27,6 +27,9 @@ import "C"
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -34,6 +37,7 @@ import (
 type Tracer func(udp interface{}, sql string)
 
 type sqliteTrace struct {
+	c   *Conn
 	f   Tracer
 	udp interface{}
 }
@@ -41,11 +45,12 @@ type sqliteTrace struct {
 //export goXTrace
 func goXTrace(udp unsafe.Pointer, sql *C.char) {
 	arg := (*sqliteTrace)(udp)
-	arg.f(arg.udp, C.GoString(sql))
+	arg.f(arg.udp, RedactSQL(C.GoString(sql), arg.c.redaction))
 }
 
 // Trace registers or clears a trace function.
-// Prepared statement placeholders are replaced/logged with their assigned values.
+// Prepared statement placeholders are replaced/logged with their assigned values
+// (unless they are redacted, see Conn.SetRedaction).
 // (See sqlite3_trace, http://sqlite.org/c3ref/profile.html)
 func (c *Conn) Trace(f Tracer, udp interface{}) {
 	if f == nil {
@@ -54,7 +59,7 @@ func (c *Conn) Trace(f Tracer, udp interface{}) {
 		return
 	}
 	// To make sure it is not gced, keep a reference in the connection.
-	c.trace = &sqliteTrace{f, udp}
+	c.trace = &sqliteTrace{c, f, udp}
 	C.goSqlite3Trace(c.db, unsafe.Pointer(c.trace))
 }
 
@@ -132,6 +137,7 @@ const (
 	Function          Action = C.SQLITE_FUNCTION
 	Savepoint         Action = C.SQLITE_SAVEPOINT
 	Copy              Action = C.SQLITE_COPY
+	Recursive         Action = C.SQLITE_RECURSIVE
 )
 
 func (a Action) String() string {
@@ -202,6 +208,8 @@ func (a Action) String() string {
 		return "Savepoint"
 	case Copy:
 		return "Copy"
+	case Recursive:
+		return "Recursive"
 	}
 	return fmt.Sprintf("Unknown Action: %d", a)
 }
@@ -210,13 +218,21 @@ func (a Action) String() string {
 type Authorizer func(udp interface{}, action Action, arg1, arg2, dbName, triggerName string) Auth
 
 type sqliteAuthorizer struct {
-	f   Authorizer
+	f   Authorizer // nil when only registered while a statement is probed (see Conn.probeInserts)
 	udp interface{}
+	c   *Conn
 }
 
 //export goXAuth
 func goXAuth(udp unsafe.Pointer, action int, arg1, arg2, dbName, triggerName *C.char) C.int {
 	arg := (*sqliteAuthorizer)(udp)
+	if probe := arg.c.insertProbe; probe != nil { // the probed statement is never executed
+		probe.record(Action(action), C.GoString(arg1), C.GoString(dbName), triggerName != nil)
+		return C.int(AuthOk)
+	}
+	if arg.f == nil {
+		return C.int(AuthOk)
+	}
 	result := arg.f(arg.udp, Action(action), C.GoString(arg1), C.GoString(arg2), C.GoString(dbName), C.GoString(triggerName))
 	return C.int(result)
 }
@@ -229,7 +245,7 @@ func (c *Conn) SetAuthorizer(f Authorizer, udp interface{}) error {
 		return c.error(C.sqlite3_set_authorizer(c.db, nil, nil), "<Conn.SetAuthorizer")
 	}
 	// To make sure it is not gced, keep a reference in the connection.
-	c.authorizer = &sqliteAuthorizer{f, udp}
+	c.authorizer = &sqliteAuthorizer{f, udp, c}
 	return c.error(C.goSqlite3SetAuthorizer(c.db, unsafe.Pointer(c.authorizer)), "Conn.SetAuthorizer")
 }
 
@@ -238,6 +254,7 @@ func (c *Conn) SetAuthorizer(f Authorizer, udp interface{}) error {
 type BusyHandler func(udp interface{}, count int) bool
 
 type sqliteBusyHandler struct {
+	c   *Conn
 	f   BusyHandler
 	udp interface{}
 }
@@ -245,11 +262,15 @@ type sqliteBusyHandler struct {
 //export goXBusy
 func goXBusy(udp unsafe.Pointer, count int) C.int {
 	arg := (*sqliteBusyHandler)(udp)
+	start := time.Now()
 	result := arg.f(arg.udp, count)
+	atomic.AddInt64(&arg.c.nBusyCalls, 1)
+	atomic.AddInt64(&arg.c.busyBlocked, int64(time.Since(start)))
 	return btocint(result)
 }
 
 // BusyHandler registers a callback to handle SQLITE_BUSY errors.
+// Its invocations and the time spent in it are reported by Conn.BusyStats.
 // (See http://sqlite.org/c3ref/busy_handler.html)
 func (c *Conn) BusyHandler(f BusyHandler, udp interface{}) error {
 	if f == nil {
@@ -257,7 +278,7 @@ func (c *Conn) BusyHandler(f BusyHandler, udp interface{}) error {
 		return c.error(C.sqlite3_busy_handler(c.db, nil, nil), "<Conn.BusyHandler")
 	}
 	// To make sure it is not gced, keep a reference in the connection.
-	c.busyHandler = &sqliteBusyHandler{f, udp}
+	c.busyHandler = &sqliteBusyHandler{c, f, udp}
 	return c.error(C.goSqlite3BusyHandler(c.db, unsafe.Pointer(c.busyHandler)), "Conn.BusyHandler")
 }
 
@@ -291,6 +312,57 @@ func (c *Conn) ProgressHandler(f ProgressHandler, numOps int, udp interface{}) {
 	C.goSqlite3ProgressHandler(c.db, C.int(numOps), unsafe.Pointer(c.progressHandler))
 }
 
+var (
+	opsPerSecondOnce sync.Once
+	opsPerSecond     float64
+)
+
+// calibrateProgress estimates the number of virtual machine instructions executed per second
+// by running a small recursive query on a private in-memory database.
+func calibrateProgress() float64 {
+	opsPerSecondOnce.Do(func() {
+		opsPerSecond = 1e8 // fallback
+		db, err := Open(":memory:")
+		if err != nil {
+			return
+		}
+		defer db.Close()
+		const numOps = 1000
+		var calls int
+		db.ProgressHandler(func(udp interface{}) bool {
+			calls++
+			return false
+		}, numOps, nil)
+		var n int
+		start := time.Now()
+		err = db.oneValue("WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM c LIMIT 200000) SELECT count(*) FROM c", &n)
+		elapsed := time.Since(start)
+		if err != nil || calls == 0 || elapsed <= 0 {
+			return
+		}
+		opsPerSecond = float64(calls*numOps) / elapsed.Seconds()
+	})
+	return opsPerSecond
+}
+
+// ProgressHandlerEvery registers or clears a query progress callback invoked approximately every d of query execution.
+// The wall-clock interval is converted into a number of opcodes by a quick benchmark (done once per process).
+// Returns true to interrupt.
+// (See Conn.ProgressHandler)
+func (c *Conn) ProgressHandlerEvery(d time.Duration, f func() bool) {
+	if f == nil {
+		c.ProgressHandler(nil, 0, nil)
+		return
+	}
+	numOps := int(calibrateProgress() * d.Seconds())
+	if numOps < 1 {
+		numOps = 1
+	}
+	c.ProgressHandler(func(udp interface{}) bool {
+		return f()
+	}, numOps, nil)
+}
+
 // Status parameters for prepared statements
 type StmtStatus int
 
@@ -306,6 +378,34 @@ func (s *Stmt) Status(op StmtStatus, reset bool) int {
 	return int(C.sqlite3_stmt_status(s.stmt, C.int(op), btocint(reset)))
 }
 
+// Status parameters for database connections
+type DbStatus int
+
+const (
+	DbStatusLookasideUsed     DbStatus = C.SQLITE_DBSTATUS_LOOKASIDE_USED
+	DbStatusCacheUsed         DbStatus = C.SQLITE_DBSTATUS_CACHE_USED
+	DbStatusSchemaUsed        DbStatus = C.SQLITE_DBSTATUS_SCHEMA_USED
+	DbStatusStmtUsed          DbStatus = C.SQLITE_DBSTATUS_STMT_USED
+	DbStatusLookasideHit      DbStatus = C.SQLITE_DBSTATUS_LOOKASIDE_HIT
+	DbStatusLookasideMissSize DbStatus = C.SQLITE_DBSTATUS_LOOKASIDE_MISS_SIZE
+	DbStatusLookasideMissFull DbStatus = C.SQLITE_DBSTATUS_LOOKASIDE_MISS_FULL
+	DbStatusCacheHit          DbStatus = C.SQLITE_DBSTATUS_CACHE_HIT
+	DbStatusCacheMiss         DbStatus = C.SQLITE_DBSTATUS_CACHE_MISS
+	DbStatusCacheWrite        DbStatus = C.SQLITE_DBSTATUS_CACHE_WRITE
+	DbStatusDeferredFKs       DbStatus = C.SQLITE_DBSTATUS_DEFERRED_FKS
+)
+
+// Status returns the current and highwater values of a status counter for a database connection.
+// (See http://sqlite.org/c3ref/db_status.html)
+func (c *Conn) Status(op DbStatus, reset bool) (current, highwater int, err error) {
+	var cur, hiwtr C.int
+	rv := C.sqlite3_db_status(c.db, C.int(op), &cur, &hiwtr, btocint(reset))
+	if rv != C.SQLITE_OK {
+		return 0, 0, c.error(rv, "Conn.Status")
+	}
+	return int(cur), int(hiwtr), nil
+}
+
 // MemoryUsed returns the number of bytes of memory currently outstanding (malloced but not freed).
 // (See sqlite3_memory_used: http://sqlite.org/c3ref/memory_highwater.html)
 func MemoryUsed() int64 {
@@ -325,11 +425,26 @@ func SoftHeapLimit() int64 {
 }
 
 // SetSoftHeapLimit imposes a limit on heap size.
+// The limit applies to the whole process (not to a single connection).
 // (See http://sqlite.org/c3ref/soft_heap_limit64.html)
 func SetSoftHeapLimit(n int64) int64 {
 	return int64(C.sqlite3_soft_heap_limit64(C.sqlite3_int64(n)))
 }
 
+// HardHeapLimit returns the hard limit on heap size.
+// (See http://sqlite.org/c3ref/hard_heap_limit64.html)
+func HardHeapLimit() int64 {
+	return SetHardHeapLimit(-1)
+}
+
+// SetHardHeapLimit imposes a hard limit on heap size (memory allocations fail beyond it)
+// and returns the previous limit (0 for no limit).
+// The limit applies to the whole process.
+// (See http://sqlite.org/c3ref/hard_heap_limit64.html)
+func SetHardHeapLimit(n int64) int64 {
+	return int64(C.sqlite3_hard_heap_limit64(C.sqlite3_int64(n)))
+}
+
 // Complete determines if an SQL statement is complete.
 // (See http://sqlite.org/c3ref/complete.html)
 func Complete(sql string) bool {
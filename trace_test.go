@@ -5,9 +5,13 @@
 package sqlite_test
 
 import (
+	"errors"
 	"fmt"
 	. "github.com/gwenn/gosqlite"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -33,12 +37,23 @@ func init() {
 	if err != nil {
 		panic(fmt.Sprintf("couldn't unset logger: '%s'", err))
 	}
+	err = ConfigLog(func(d interface{}, err error, msg string) {
+		if f, ok := logHook.Load().(func(error, string)); ok && f != nil {
+			f(err, msg)
+		}
+	}, nil)
+	if err != nil {
+		panic(fmt.Sprintf("couldn't config log: '%s'", err))
+	}
 	err = EnableSharedCache(false)
 	if err != nil {
 		panic(fmt.Sprintf("couldn't disable shared cache: '%s'", err))
 	}
 }
 
+// logHook receives the messages logged by SQLite when set with a func(error, string) (see ConfigLog).
+var logHook atomic.Value
+
 func trace(d interface{}, sql string) {
 	if t, ok := d.(*testing.T); ok {
 		t.Logf("TRACE: %s\n", sql)
@@ -125,6 +140,133 @@ func TestTrace(t *testing.T) {
 	db.Exists("SELECT 1 WHERE 1 = ?", 1)
 }
 
+func TestRedactSQL(t *testing.T) {
+	for _, c := range []struct {
+		sql, expected string
+	}{
+		{"SELECT * FROM user WHERE email = 'bob@example.com'", "SELECT * FROM user WHERE email = ?"},
+		{"INSERT INTO t1 (a, b, c) VALUES (42, -3.14e-2, x'CAFE')", "INSERT INTO t1 (a, b, c) VALUES (?, ?, ?)"},
+		{"SELECT a-1, a - -2, 3-4, -.5 FROM t WHERE b = -1 OR c IN (-1) LIMIT -1", "SELECT a-?, a - ?, ?-?, ? FROM t WHERE b = ? OR c IN (?) LIMIT ?"},
+		{`SELECT "col1", [col 2], 'it''s' -- 'comment'`, `SELECT "col1", [col 2], ? -- 'comment'`},
+		{"SELECT x1 FROM t2 WHERE y = ?1 AND z = :z /* 3 */ AND w IS NULL", "SELECT x1 FROM t2 WHERE y = ?1 AND z = :z /* 3 */ AND w IS NULL"},
+		{"SELECT 0x1F, .5", "SELECT ?, ?"},
+	} {
+		assertEquals(t, "expected %q but got %q", c.expected, RedactSQL(c.sql, RedactPlaceholder))
+		assertEquals(t, "expected %q but got %q", c.sql, RedactSQL(c.sql, RedactNone))
+	}
+	h1 := RedactSQL("SELECT 'secret'", RedactHash)
+	assert(t, "hashed placeholder expected", strings.HasPrefix(h1, "SELECT ?#") && !strings.Contains(h1, "secret"))
+	assertEquals(t, "expected %q but got %q", h1, RedactSQL("SELECT 'secret'", RedactHash))
+	assert(t, "distinct hashes expected", h1 != RedactSQL("SELECT 'public'", RedactHash))
+}
+
+func TestRedaction(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	var traced []string
+	db.Trace(func(udp interface{}, sql string) {
+		traced = append(traced, sql)
+	}, nil)
+	db.SetRedaction(RedactPlaceholder)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string, int_num) VALUES (?, ?)", "bob@example.com", 42), "insert error: %s")
+	assertEquals(t, "expected %d traced statement but got %d", 1, len(traced))
+	assertEquals(t, "expected %q but got %q", "INSERT INTO test (a_string, int_num) VALUES (?, ?)", traced[0])
+
+	s, err := db.Prepare("SELECT ?", "bob@example.com")
+	checkNoError(t, err, "prepare error: %s")
+	defer checkFinalize(s, t)
+	assertEquals(t, "expected %q but got %q", "SELECT ?", s.ExpandedSQL())
+	db.SetRedaction(RedactNone)
+	assertEquals(t, "expected %q but got %q", "SELECT 'bob@example.com'", s.ExpandedSQL())
+}
+
+func TestHookMultiplexer(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+
+	var commits, rollbacks, updates []string
+	db.CommitHook(func(udp interface{}) bool {
+		commits = append(commits, "primary")
+		return false
+	}, nil)
+	t1 := db.AddCommitHook(func(udp interface{}) bool {
+		commits = append(commits, udp.(string))
+		return false
+	}, "lib")
+	t2 := db.AddRollbackHook(func(udp interface{}) {
+		rollbacks = append(rollbacks, udp.(string))
+	}, "lib")
+	t3 := db.AddUpdateHook(func(udp interface{}, a Action, dbName, tableName string, rowId int64) {
+		updates = append(updates, udp.(string))
+	}, "lib")
+	db.UpdateHook(func(udp interface{}, a Action, dbName, tableName string, rowId int64) {
+		updates = append(updates, "primary")
+	}, nil)
+	assert(t, "distinct tokens expected", t1 != t2 && t2 != t3 && t1 != t3)
+
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('hook')"), "insert error: %s")
+	assertEquals(t, "expected %q but got %q", "[primary lib]", fmt.Sprint(commits))
+	assertEquals(t, "expected %q but got %q", "[primary lib]", fmt.Sprint(updates))
+
+	// a veto from any commit hook converts the commit into a rollback
+	veto := db.AddCommitHook(func(udp interface{}) bool {
+		return true
+	}, nil)
+	err := db.Exec("INSERT INTO test (a_string) VALUES ('veto')")
+	assert(t, "commit hook veto expected", err != nil)
+	assertEquals(t, "expected %q but got %q", "[lib]", fmt.Sprint(rollbacks))
+	assert(t, "hook removed", db.RemoveHook(veto))
+	assert(t, "hook already removed", !db.RemoveHook(veto))
+
+	// replacing the primary hook doesn't remove the added ones
+	db.CommitHook(nil, nil)
+	assert(t, "hook removed", db.RemoveHook(t3))
+	commits, updates = nil, nil
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('hook')"), "insert error: %s")
+	assertEquals(t, "expected %q but got %q", "[lib]", fmt.Sprint(commits))
+	assertEquals(t, "expected %q but got %q", "[primary]", fmt.Sprint(updates))
+}
+
+func TestCommitErrorHook(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+
+	invariant := errors.New("invariant violated")
+	var fail bool
+	token := db.AddCommitErrorHook(func(udp interface{}) error {
+		if fail {
+			return invariant
+		}
+		return nil
+	}, nil)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('ok')"), "insert error: %s")
+
+	fail = true
+	err := db.Exec("INSERT INTO test (a_string) VALUES ('autocommit')")
+	assert(t, "commit hook error expected", errors.Is(err, invariant))
+	checkNoError(t, db.Begin(), "%s")
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('transaction')"), "insert error: %s")
+	err = db.Commit()
+	var aborted *CommitAbortedError
+	assert(t, "commit aborted error expected", errors.As(err, &aborted))
+	assert(t, "hook error expected", aborted.Err == invariant)
+	assert(t, "original error expected", aborted.Cause != nil)
+	var cerr interface{ Code() Errno }
+	assert(t, "original error expected to be matched", errors.As(err, &cerr))
+	assertEquals(t, "expected %s but got %s", ErrConstraint, cerr.Code())
+	assert(t, "autocommit expected after rollback", db.GetAutocommit())
+
+	var count int
+	checkNoError(t, db.OneValue("SELECT count(1) FROM test", &count), "%s")
+	assertEquals(t, "expected %d rows but got %d", 1, count)
+
+	assert(t, "hook removed", db.RemoveHook(token))
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('ok')"), "insert error: %s")
+}
+
 func TestLog(t *testing.T) {
 	Log(0, "One message")
 }
@@ -136,4 +278,28 @@ func TestMemory(t *testing.T) {
 	assert(t, "memory highwater", highwater >= 0)
 	limit := SoftHeapLimit()
 	assert(t, "soft heap limit positive", limit >= 0)
+	assert(t, "hard heap limit positive", HardHeapLimit() >= 0)
+}
+
+func TestDbStatus(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	used, _, err := db.Status(DbStatusCacheUsed, false)
+	checkNoError(t, err, "couldn't get connection status: %s")
+	assert(t, "cache used", used >= 0)
+}
+
+func TestProgressHandlerEvery(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	var calls int
+	db.ProgressHandlerEvery(time.Microsecond, func() bool {
+		calls++
+		return calls >= 3
+	})
+	var n int
+	err := db.OneValue("WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM c LIMIT 1000000) SELECT count(*) FROM c", &n)
+	assert(t, "interrupt expected", err != nil)
+	assertEquals(t, "expected %d calls but got %d", 3, calls)
+	db.ProgressHandlerEvery(0, nil)
 }
@@ -54,6 +54,29 @@ func Mprintf2(format string, arg1, arg2 string) string {
 	return C.GoString(zSQL)
 }
 
+// StrGlob returns true if the string matches the GLOB pattern (case sensitive, Unix file globbing syntax),
+// with the same semantics as the GLOB operator of SQLite.
+// (See http://sqlite.org/c3ref/strglob.html)
+func StrGlob(pattern, str string) bool {
+	cp := C.CString(pattern)
+	defer C.free(unsafe.Pointer(cp))
+	cs := C.CString(str)
+	defer C.free(unsafe.Pointer(cs))
+	return C.sqlite3_strglob(cp, cs) == 0
+}
+
+// StrLike returns true if the string matches the LIKE pattern (case insensitive for ASCII characters),
+// with the same semantics as the LIKE operator of SQLite.
+// escape is the ESCAPE character (0 for none).
+// (See http://sqlite.org/c3ref/strlike.html)
+func StrLike(pattern, str string, escape rune) bool {
+	cp := C.CString(pattern)
+	defer C.free(unsafe.Pointer(cp))
+	cs := C.CString(str)
+	defer C.free(unsafe.Pointer(cs))
+	return C.sqlite3_strlike(cp, cs, C.uint(escape)) == 0
+}
+
 // Must is a helper that wraps a call to a function returning (bool, os.Error)
 // and panics if the error is non-nil.
 func Must(b bool, err error) bool {
@@ -69,7 +92,16 @@ func btocint(b bool) C.int {
 	}
 	return 0
 }
+
+// cEmpty is used for empty strings because a nil pointer would be bound/returned as NULL.
+var cEmpty = C.CString("")
+
+// cstring returns a pointer into (not a copy of) the Go string
+// so it must only be used with length-aware calls that copy the text (SQLITE_TRANSIENT).
 func cstring(s string) (*C.char, C.int) {
+	if len(s) == 0 {
+		return cEmpty, 0
+	}
 	cs := *(*reflect.StringHeader)(unsafe.Pointer(&s))
 	return (*C.char)(unsafe.Pointer(cs.Data)), C.int(cs.Len)
 }
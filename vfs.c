@@ -0,0 +1,294 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+#include <sqlite3.h>
+#include <stdlib.h>
+#include <string.h>
+
+// Shim VFS: the file operations (open, close, read, write, truncate, sync and delete)
+// are intercepted by a Go callback which performs them by calling goSqlite3VfsNext.
+
+#define GO_VFS_OPEN 0
+#define GO_VFS_CLOSE 1
+#define GO_VFS_READ 2
+#define GO_VFS_WRITE 3
+#define GO_VFS_TRUNCATE 4
+#define GO_VFS_SYNC 5
+#define GO_VFS_DELETE 6
+
+extern int goXVfsCall(void *udp, int op, const char *zName, int flags, void *pBuf, int iAmt, sqlite3_int64 iOfst, void *pCall);
+
+typedef struct shimVfs shimVfs;
+struct shimVfs {
+	sqlite3_vfs base;
+	sqlite3_vfs *pReal;
+	void *udp;
+};
+
+typedef struct shimFile shimFile;
+struct shimFile {
+	sqlite3_file base;
+	shimVfs *pVfs;
+	const char *zName;
+	int flags;
+	sqlite3_file *pReal; // allocated just after the shimFile
+};
+
+typedef struct shimCall shimCall;
+struct shimCall {
+	int op;
+	shimVfs *pVfs;
+	shimFile *pFile;
+	const char *zName;
+	int flags;
+	int *pOutFlags;
+	void *pBuf;
+	int iAmt;
+	sqlite3_int64 iOfst;
+};
+
+static const sqlite3_io_methods shimIoMethods;
+
+int goSqlite3VfsNext(void *p) {
+	shimCall *c = (shimCall *)p;
+	sqlite3_vfs *pReal = c->pVfs->pReal;
+	sqlite3_file *pRealFile = c->pFile ? c->pFile->pReal : 0;
+	switch (c->op) {
+	case GO_VFS_OPEN: {
+		int rc = pReal->xOpen(pReal, c->zName, pRealFile, c->flags, c->pOutFlags);
+		if (pRealFile->pMethods) {
+			c->pFile->base.pMethods = &shimIoMethods;
+		}
+		return rc;
+	}
+	case GO_VFS_CLOSE:
+		return pRealFile->pMethods->xClose(pRealFile);
+	case GO_VFS_READ:
+		return pRealFile->pMethods->xRead(pRealFile, c->pBuf, c->iAmt, c->iOfst);
+	case GO_VFS_WRITE:
+		return pRealFile->pMethods->xWrite(pRealFile, c->pBuf, c->iAmt, c->iOfst);
+	case GO_VFS_TRUNCATE:
+		return pRealFile->pMethods->xTruncate(pRealFile, c->iOfst);
+	case GO_VFS_SYNC:
+		return pRealFile->pMethods->xSync(pRealFile, c->flags);
+	case GO_VFS_DELETE:
+		return pReal->xDelete(pReal, c->zName, c->flags);
+	}
+	return SQLITE_MISUSE;
+}
+
+int goSqlite3VfsFileSize(void *p, sqlite3_int64 *pSize) {
+	shimCall *c = (shimCall *)p;
+	if (!c->pFile || !c->pFile->pReal->pMethods) {
+		return SQLITE_MISUSE;
+	}
+	return c->pFile->pReal->pMethods->xFileSize(c->pFile->pReal, pSize);
+}
+
+static int shimFileCall(sqlite3_file *pFile, int op, void *pBuf, int iAmt, sqlite3_int64 iOfst, int flags) {
+	shimFile *p = (shimFile *)pFile;
+	shimCall c = {op, p->pVfs, p, p->zName, flags, 0, pBuf, iAmt, iOfst};
+	return goXVfsCall(p->pVfs->udp, op, p->zName, flags, pBuf, iAmt, iOfst, &c);
+}
+
+static int shimClose(sqlite3_file *pFile) {
+	return shimFileCall(pFile, GO_VFS_CLOSE, 0, 0, 0, ((shimFile *)pFile)->flags);
+}
+static int shimRead(sqlite3_file *pFile, void *zBuf, int iAmt, sqlite3_int64 iOfst) {
+	return shimFileCall(pFile, GO_VFS_READ, zBuf, iAmt, iOfst, ((shimFile *)pFile)->flags);
+}
+static int shimWrite(sqlite3_file *pFile, const void *zBuf, int iAmt, sqlite3_int64 iOfst) {
+	return shimFileCall(pFile, GO_VFS_WRITE, (void *)zBuf, iAmt, iOfst, ((shimFile *)pFile)->flags);
+}
+static int shimTruncate(sqlite3_file *pFile, sqlite3_int64 size) {
+	return shimFileCall(pFile, GO_VFS_TRUNCATE, 0, 0, size, ((shimFile *)pFile)->flags);
+}
+static int shimSync(sqlite3_file *pFile, int flags) {
+	return shimFileCall(pFile, GO_VFS_SYNC, 0, 0, 0, flags);
+}
+
+#define REAL(pFile) (((shimFile *)pFile)->pReal)
+
+static int shimFileSize(sqlite3_file *pFile, sqlite3_int64 *pSize) {
+	return REAL(pFile)->pMethods->xFileSize(REAL(pFile), pSize);
+}
+static int shimLock(sqlite3_file *pFile, int eLock) {
+	return REAL(pFile)->pMethods->xLock(REAL(pFile), eLock);
+}
+static int shimUnlock(sqlite3_file *pFile, int eLock) {
+	return REAL(pFile)->pMethods->xUnlock(REAL(pFile), eLock);
+}
+static int shimCheckReservedLock(sqlite3_file *pFile, int *pResOut) {
+	return REAL(pFile)->pMethods->xCheckReservedLock(REAL(pFile), pResOut);
+}
+static int shimFileControl(sqlite3_file *pFile, int op, void *pArg) {
+	return REAL(pFile)->pMethods->xFileControl(REAL(pFile), op, pArg);
+}
+static int shimSectorSize(sqlite3_file *pFile) {
+	return REAL(pFile)->pMethods->xSectorSize(REAL(pFile));
+}
+static int shimDeviceCharacteristics(sqlite3_file *pFile) {
+	return REAL(pFile)->pMethods->xDeviceCharacteristics(REAL(pFile));
+}
+static int shimShmMap(sqlite3_file *pFile, int iPg, int pgsz, int bExtend, void volatile **pp) {
+	if (REAL(pFile)->pMethods->iVersion < 2) {
+		return SQLITE_IOERR_SHMMAP;
+	}
+	return REAL(pFile)->pMethods->xShmMap(REAL(pFile), iPg, pgsz, bExtend, pp);
+}
+static int shimShmLock(sqlite3_file *pFile, int offset, int n, int flags) {
+	if (REAL(pFile)->pMethods->iVersion < 2) {
+		return SQLITE_IOERR_SHMLOCK;
+	}
+	return REAL(pFile)->pMethods->xShmLock(REAL(pFile), offset, n, flags);
+}
+static void shimShmBarrier(sqlite3_file *pFile) {
+	if (REAL(pFile)->pMethods->iVersion >= 2) {
+		REAL(pFile)->pMethods->xShmBarrier(REAL(pFile));
+	}
+}
+static int shimShmUnmap(sqlite3_file *pFile, int deleteFlag) {
+	if (REAL(pFile)->pMethods->iVersion < 2) {
+		return SQLITE_OK;
+	}
+	return REAL(pFile)->pMethods->xShmUnmap(REAL(pFile), deleteFlag);
+}
+static int shimFetch(sqlite3_file *pFile, sqlite3_int64 iOfst, int iAmt, void **pp) {
+	if (REAL(pFile)->pMethods->iVersion < 3) {
+		*pp = 0;
+		return SQLITE_OK;
+	}
+	return REAL(pFile)->pMethods->xFetch(REAL(pFile), iOfst, iAmt, pp);
+}
+static int shimUnfetch(sqlite3_file *pFile, sqlite3_int64 iOfst, void *p) {
+	if (REAL(pFile)->pMethods->iVersion < 3) {
+		return SQLITE_OK;
+	}
+	return REAL(pFile)->pMethods->xUnfetch(REAL(pFile), iOfst, p);
+}
+
+static const sqlite3_io_methods shimIoMethods = {
+	3,
+	shimClose,
+	shimRead,
+	shimWrite,
+	shimTruncate,
+	shimSync,
+	shimFileSize,
+	shimLock,
+	shimUnlock,
+	shimCheckReservedLock,
+	shimFileControl,
+	shimSectorSize,
+	shimDeviceCharacteristics,
+	shimShmMap,
+	shimShmLock,
+	shimShmBarrier,
+	shimShmUnmap,
+	shimFetch,
+	shimUnfetch
+};
+
+#define REALVFS(pVfs) (((shimVfs *)pVfs)->pReal)
+
+static int shimOpen(sqlite3_vfs *pVfs, const char *zName, sqlite3_file *pFile, int flags, int *pOutFlags) {
+	shimFile *p = (shimFile *)pFile;
+	memset(p, 0, sizeof(shimFile));
+	p->pVfs = (shimVfs *)pVfs;
+	p->zName = zName;
+	p->flags = flags;
+	p->pReal = (sqlite3_file *)&p[1];
+	shimCall c = {GO_VFS_OPEN, p->pVfs, p, zName, flags, pOutFlags, 0, 0, 0};
+	return goXVfsCall(p->pVfs->udp, GO_VFS_OPEN, zName, flags, 0, 0, 0, &c);
+}
+static int shimDelete(sqlite3_vfs *pVfs, const char *zName, int syncDir) {
+	shimCall c = {GO_VFS_DELETE, (shimVfs *)pVfs, 0, zName, syncDir, 0, 0, 0, 0};
+	return goXVfsCall(((shimVfs *)pVfs)->udp, GO_VFS_DELETE, zName, syncDir, 0, 0, 0, &c);
+}
+static int shimAccess(sqlite3_vfs *pVfs, const char *zName, int flags, int *pResOut) {
+	return REALVFS(pVfs)->xAccess(REALVFS(pVfs), zName, flags, pResOut);
+}
+static int shimFullPathname(sqlite3_vfs *pVfs, const char *zName, int nOut, char *zOut) {
+	return REALVFS(pVfs)->xFullPathname(REALVFS(pVfs), zName, nOut, zOut);
+}
+static void *shimDlOpen(sqlite3_vfs *pVfs, const char *zFilename) {
+	return REALVFS(pVfs)->xDlOpen(REALVFS(pVfs), zFilename);
+}
+static void shimDlError(sqlite3_vfs *pVfs, int nByte, char *zErrMsg) {
+	REALVFS(pVfs)->xDlError(REALVFS(pVfs), nByte, zErrMsg);
+}
+static void (*shimDlSym(sqlite3_vfs *pVfs, void *p, const char *zSym))(void) {
+	return REALVFS(pVfs)->xDlSym(REALVFS(pVfs), p, zSym);
+}
+static void shimDlClose(sqlite3_vfs *pVfs, void *p) {
+	REALVFS(pVfs)->xDlClose(REALVFS(pVfs), p);
+}
+static int shimRandomness(sqlite3_vfs *pVfs, int nByte, char *zOut) {
+	return REALVFS(pVfs)->xRandomness(REALVFS(pVfs), nByte, zOut);
+}
+static int shimSleep(sqlite3_vfs *pVfs, int microseconds) {
+	return REALVFS(pVfs)->xSleep(REALVFS(pVfs), microseconds);
+}
+static int shimCurrentTime(sqlite3_vfs *pVfs, double *pTime) {
+	return REALVFS(pVfs)->xCurrentTime(REALVFS(pVfs), pTime);
+}
+static int shimGetLastError(sqlite3_vfs *pVfs, int n, char *z) {
+	if (!REALVFS(pVfs)->xGetLastError) {
+		return 0;
+	}
+	return REALVFS(pVfs)->xGetLastError(REALVFS(pVfs), n, z);
+}
+static int shimCurrentTimeInt64(sqlite3_vfs *pVfs, sqlite3_int64 *pTime) {
+	return REALVFS(pVfs)->xCurrentTimeInt64(REALVFS(pVfs), pTime);
+}
+static int shimSetSystemCall(sqlite3_vfs *pVfs, const char *zName, sqlite3_syscall_ptr pFunc) {
+	return REALVFS(pVfs)->xSetSystemCall(REALVFS(pVfs), zName, pFunc);
+}
+static sqlite3_syscall_ptr shimGetSystemCall(sqlite3_vfs *pVfs, const char *zName) {
+	return REALVFS(pVfs)->xGetSystemCall(REALVFS(pVfs), zName);
+}
+static const char *shimNextSystemCall(sqlite3_vfs *pVfs, const char *zName) {
+	return REALVFS(pVfs)->xNextSystemCall(REALVFS(pVfs), zName);
+}
+
+// goSqlite3ShimVfs creates a shim VFS named zName on top of the zParent VFS (the default one if zParent is null).
+// Returns null if the parent VFS is not found.
+void *goSqlite3ShimVfs(const char *zName, const char *zParent, void *udp) {
+	sqlite3_vfs *pReal = sqlite3_vfs_find(zParent);
+	if (!pReal) {
+		return 0;
+	}
+	shimVfs *p = (shimVfs *)sqlite3_malloc(sizeof(shimVfs));
+	if (!p) {
+		return 0;
+	}
+	memset(p, 0, sizeof(shimVfs));
+	p->pReal = pReal;
+	p->udp = udp;
+	p->base.iVersion = pReal->iVersion < 3 ? pReal->iVersion : 3;
+	p->base.szOsFile = sizeof(shimFile) + pReal->szOsFile;
+	p->base.mxPathname = pReal->mxPathname;
+	p->base.zName = zName;
+	p->base.xOpen = shimOpen;
+	p->base.xDelete = shimDelete;
+	p->base.xAccess = shimAccess;
+	p->base.xFullPathname = shimFullPathname;
+	p->base.xDlOpen = pReal->xDlOpen ? shimDlOpen : 0;
+	p->base.xDlError = pReal->xDlError ? shimDlError : 0;
+	p->base.xDlSym = pReal->xDlSym ? shimDlSym : 0;
+	p->base.xDlClose = pReal->xDlClose ? shimDlClose : 0;
+	p->base.xRandomness = shimRandomness;
+	p->base.xSleep = shimSleep;
+	p->base.xCurrentTime = shimCurrentTime;
+	p->base.xGetLastError = shimGetLastError;
+	if (p->base.iVersion >= 2) {
+		p->base.xCurrentTimeInt64 = shimCurrentTimeInt64;
+	}
+	if (p->base.iVersion >= 3) {
+		p->base.xSetSystemCall = pReal->xSetSystemCall ? shimSetSystemCall : 0;
+		p->base.xGetSystemCall = pReal->xGetSystemCall ? shimGetSystemCall : 0;
+		p->base.xNextSystemCall = pReal->xNextSystemCall ? shimNextSystemCall : 0;
+	}
+	return p;
+}
@@ -0,0 +1,161 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+#include <stdlib.h>
+
+void *goSqlite3ShimVfs(const char *zName, const char *zParent, void *udp);
+int goSqlite3VfsNext(void *pCall);
+int goSqlite3VfsFileSize(void *pCall, sqlite3_int64 *pSize);
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// VFSOp identifies a file operation intercepted by a VFS shim.
+type VFSOp int
+
+// File operations intercepted by a VFS shim
+const (
+	VFSOpen VFSOp = iota
+	VFSClose
+	VFSRead
+	VFSWrite
+	VFSTruncate
+	VFSSync
+	VFSDelete
+)
+
+var vfsOpNames = [...]string{"open", "close", "read", "write", "truncate", "sync", "delete"}
+
+func (op VFSOp) String() string {
+	if op >= 0 && int(op) < len(vfsOpNames) {
+		return vfsOpNames[op]
+	}
+	return fmt.Sprintf("VFSOp(%d)", int(op))
+}
+
+// VFSCall is a file operation intercepted by a VFS shim.
+type VFSCall struct {
+	Op     VFSOp
+	File   string // full path of the file ("" for temporary files)
+	Flags  int    // open flags (SQLITE_OPEN_*) or sync flags (SQLITE_SYNC_*)
+	Offset int64  // offset of the read/write or size of the truncate
+	Data   []byte // read buffer or written data (only valid during the call)
+	call   unsafe.Pointer
+}
+
+// Size returns the current size of the file (not available for VFSDelete and
+// before/after the real VFSOpen/VFSClose).
+func (c *VFSCall) Size() (int64, error) {
+	var size C.sqlite3_int64
+	if rv := C.goSqlite3VfsFileSize(c.call, &size); rv != C.SQLITE_OK {
+		return 0, Errno(rv)
+	}
+	return int64(size), nil
+}
+
+// VFSShim intercepts the file operations of a VFS (see RegisterVFSShim).
+// It must call next to perform the operation on the underlying VFS
+// (and must do so for VFSClose) and return its error, or an Errno
+// like ErrFull to make the operation fail (other errors are reported as ErrIOErr).
+// It may be called concurrently by different connections.
+type VFSShim func(call *VFSCall, next func() error) error
+
+type vfsShim struct {
+	f     VFSShim
+	zName *C.char
+	vfs   *C.sqlite3_vfs
+}
+
+var vfsShims = struct {
+	sync.Mutex
+	m map[string]*vfsShim
+}{m: make(map[string]*vfsShim)}
+
+//export goXVfsCall
+func goXVfsCall(udp unsafe.Pointer, op C.int, zName *C.char, flags C.int, pBuf unsafe.Pointer, iAmt C.int, iOfst C.sqlite3_int64, pCall unsafe.Pointer) C.int {
+	shim := (*vfsShim)(udp)
+	call := &VFSCall{Op: VFSOp(op), Flags: int(flags), Offset: int64(iOfst), call: pCall}
+	if zName != nil {
+		call.File = C.GoString(zName)
+	}
+	if pBuf != nil && iAmt > 0 {
+		call.Data = (*[1 << 30]byte)(pBuf)[:iAmt:iAmt]
+	}
+	err := shim.f(call, func() error {
+		if rv := C.goSqlite3VfsNext(pCall); rv != C.SQLITE_OK {
+			return Errno(rv)
+		}
+		return nil
+	})
+	if err == nil {
+		return C.SQLITE_OK
+	} else if errno, ok := err.(Errno); ok {
+		return C.int(errno)
+	}
+	return C.SQLITE_IOERR
+}
+
+// RegisterVFSShim registers a VFS named name which delegates to the parent VFS
+// (the default VFS if parent is empty) but lets f intercept the file operations
+// (to enforce quotas, inject faults or trace I/O for example).
+// The new VFS becomes the default one if makeDefault is true,
+// otherwise it must be specified when the connections are opened (see OpenVfs).
+// (See http://sqlite.org/c3ref/vfs_find.html)
+func RegisterVFSShim(name, parent string, f VFSShim, makeDefault bool) error {
+	if f == nil {
+		return errors.New("nil VFS shim")
+	}
+	vfsShims.Lock()
+	defer vfsShims.Unlock()
+	if _, ok := vfsShims.m[name]; ok {
+		return fmt.Errorf("VFS shim %q already registered", name)
+	}
+	var zParent *C.char
+	if len(parent) > 0 {
+		zParent = C.CString(parent)
+		defer C.free(unsafe.Pointer(zParent))
+	}
+	// To make sure it is not gced, keep a reference in the registry.
+	shim := &vfsShim{f: f, zName: C.CString(name)}
+	shim.vfs = (*C.sqlite3_vfs)(C.goSqlite3ShimVfs(shim.zName, zParent, unsafe.Pointer(shim)))
+	if shim.vfs == nil {
+		C.free(unsafe.Pointer(shim.zName))
+		return fmt.Errorf("no such VFS: %q", parent)
+	}
+	if rv := C.sqlite3_vfs_register(shim.vfs, btocint(makeDefault)); rv != C.SQLITE_OK {
+		C.sqlite3_free(unsafe.Pointer(shim.vfs))
+		C.free(unsafe.Pointer(shim.zName))
+		return Errno(rv)
+	}
+	vfsShims.m[name] = shim
+	return nil
+}
+
+// UnregisterVFSShim unregisters a VFS registered with RegisterVFSShim.
+// No connection must be using it.
+func UnregisterVFSShim(name string) error {
+	vfsShims.Lock()
+	defer vfsShims.Unlock()
+	shim, ok := vfsShims.m[name]
+	if !ok {
+		return fmt.Errorf("no such VFS shim: %q", name)
+	}
+	if rv := C.sqlite3_vfs_unregister(shim.vfs); rv != C.SQLITE_OK {
+		return Errno(rv)
+	}
+	delete(vfsShims.m, name)
+	C.sqlite3_free(unsafe.Pointer(shim.vfs))
+	C.free(unsafe.Pointer(shim.zName))
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestVFSShim(t *testing.T) {
+	var mu sync.Mutex
+	ops := make(map[VFSOp]int)
+	err := RegisterVFSShim("counting", "", func(call *VFSCall, next func() error) error {
+		mu.Lock()
+		ops[call.Op]++
+		mu.Unlock()
+		return next()
+	}, false)
+	checkNoError(t, err, "couldn't register VFS shim: %s")
+	defer UnregisterVFSShim("counting")
+	err = RegisterVFSShim("counting", "", func(call *VFSCall, next func() error) error { return next() }, false)
+	assert(t, "duplicate VFS shim error expected", err != nil)
+	err = RegisterVFSShim("orphan", "unknown", func(call *VFSCall, next func() error) error { return next() }, false)
+	assert(t, "unknown parent VFS error expected", err != nil)
+
+	dir, err := ioutil.TempDir("", "gosqlite-vfs")
+	checkNoError(t, err, "couldn't create temp dir: %s")
+	defer os.RemoveAll(dir)
+	db, err := OpenVfs(filepath.Join(dir, "test.db"), "counting")
+	checkNoError(t, err, "couldn't open database: %s")
+	createTable(db, t)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('a')"), "insert error: %s")
+	var s string
+	checkNoError(t, db.OneValue("SELECT a_string FROM test", &s), "select error: %s")
+	checkClose(db, t)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, op := range []VFSOp{VFSOpen, VFSClose, VFSRead, VFSWrite, VFSSync, VFSDelete} {
+		assert(t, op.String()+" expected", ops[op] > 0)
+	}
+	assertEquals(t, "expected %d close(s) but got %d", ops[VFSOpen], ops[VFSClose])
+}
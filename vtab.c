@@ -39,8 +39,15 @@ static int cXConnect(sqlite3 *db, void *pAux, int argc, const char *const*argv,
 }
 
 static int cXBestIndex(sqlite3_vtab *pVTab, sqlite3_index_info *info) {
-  // TODO
-	return SQLITE_OK;
+  int rc = SQLITE_OK;
+  char *pzErr = goVBestIndex(((goVTab*)pVTab)->vTab, info, &rc);
+  if (pzErr) {
+    if (pVTab->zErrMsg)
+      sqlite3_free(pVTab->zErrMsg);
+    pVTab->zErrMsg = pzErr;
+    return SQLITE_ERROR;
+  }
+	return rc;
 }
 
 static int cXRelease(sqlite3_vtab *pVTab, int isDestroy) {
@@ -99,8 +106,7 @@ static int cXClose(sqlite3_vtab_cursor *pCursor) {
 	return SQLITE_OK;
 }
 static int cXFilter(sqlite3_vtab_cursor *pCursor, int idxNum, const char *idxStr, int argc, sqlite3_value **argv) {
-  // TODO idxNum, idxStr, argc, argv are useless when cXBestIndex is empty
-  char *pzErr = goVFilter(((goVTabCursor*)pCursor)->vTabCursor);
+  char *pzErr = goVFilter(((goVTabCursor*)pCursor)->vTabCursor, idxNum, argc, argv);
   if (pzErr) {
     return setErrMsg(pCursor, pzErr);
   }
@@ -158,7 +164,37 @@ static sqlite3_module goModule = {
   0                        /* xRollbackTo */
 };
 
+static sqlite3_module goEponymousModule = {
+  0,                       /* iVersion */
+  0,                       /* xCreate - eponymous-only */
+  cXConnect,               /* xConnect - connect to an existing table */
+  cXBestIndex,             /* xBestIndex - Determine search strategy */
+  cXDisconnect,            /* xDisconnect - Disconnect from a table */
+  cXDestroy,               /* xDestroy - Drop a table */
+  cXOpen,                  /* xOpen - open a cursor */
+  cXClose,                 /* xClose - close a cursor */
+  cXFilter,                /* xFilter - configure scan constraints */
+  cXNext,                  /* xNext - advance a cursor */
+  cXEof,                   /* xEof */
+  cXColumn,                /* xColumn - read data */
+  cXRowid,                 /* xRowid - read data */
+// TODO
+  0,                       /* xUpdate - write data */
+  0,                       /* xBegin - begin transaction */
+  0,                       /* xSync - sync transaction */
+  0,                       /* xCommit - commit transaction */
+  0,                       /* xRollback - rollback transaction */
+  0,                       /* xFindFunction - function overloading */
+  0,                       /* xRename - rename the table */
+  0,                       /* xSavepoint */
+  0,                       /* xRelease */
+  0                        /* xRollbackTo */
+};
+
 
 int goSqlite3CreateModule(sqlite3 *db, const char *zName, void *pClientData) {
 	return sqlite3_create_module_v2(db, zName, &goModule, pClientData, goMDestroy);
 }
+int goSqlite3CreateEponymousModule(sqlite3 *db, const char *zName, void *pClientData) {
+	return sqlite3_create_module_v2(db, zName, &goEponymousModule, pClientData, goMDestroy);
+}
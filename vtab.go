@@ -9,6 +9,7 @@ package sqlite
 #include <stdlib.h>
 
 int goSqlite3CreateModule(sqlite3 *db, const char *zName, void *pClientData);
+int goSqlite3CreateEponymousModule(sqlite3 *db, const char *zName, void *pClientData);
 */
 import "C"
 
@@ -128,10 +129,60 @@ func goMDestroy(pClientData unsafe.Pointer) {
 	delete(m.c.modules, m.name)
 }
 
+//export goVBestIndex
+func goVBestIndex(pVTab unsafe.Pointer, pInfo unsafe.Pointer, pRc *C.int) *C.char {
+	vt := (*sqliteVTab)(pVTab)
+	ix, ok := vt.vTab.(VTabIndexer)
+	if !ok {
+		if err := vt.vTab.BestIndex(); err != nil {
+			return mPrintf("%s", err.Error())
+		}
+		return nil
+	}
+	info := (*C.sqlite3_index_info)(pInfo)
+	n := int(info.nConstraint)
+	ii := &IndexInfo{Constraints: make([]IndexConstraint, n), EstimatedCost: float64(info.estimatedCost)}
+	if n > 0 {
+		constraints := (*[1 << 20]C.struct_sqlite3_index_constraint)(unsafe.Pointer(info.aConstraint))[:n:n]
+		for i, c := range constraints {
+			ii.Constraints[i] = IndexConstraint{Column: int(c.iColumn), Op: IndexConstraintOp(c.op), Usable: c.usable != 0}
+		}
+	}
+	if err := ix.BestIndexInfo(ii); err == ErrConstraint {
+		*pRc = C.SQLITE_CONSTRAINT // unusable plan
+		return nil
+	} else if err != nil {
+		return mPrintf("%s", err.Error())
+	}
+	if n > 0 {
+		usages := (*[1 << 20]C.struct_sqlite3_index_constraint_usage)(unsafe.Pointer(info.aConstraintUsage))[:n:n]
+		for i, c := range ii.Constraints {
+			usages[i].argvIndex = C.int(c.ArgvIndex)
+			if c.Omit {
+				usages[i].omit = 1
+			}
+		}
+	}
+	info.idxNum = C.int(ii.IdxNum)
+	info.estimatedCost = C.double(ii.EstimatedCost)
+	return nil
+}
+
 //export goVFilter
-func goVFilter(pCursor unsafe.Pointer) *C.char {
+func goVFilter(pCursor unsafe.Pointer, idxNum, argc C.int, argv **C.sqlite3_value) *C.char {
 	vtc := (*sqliteVTabCursor)(pCursor)
-	err := vtc.vTabCursor.Filter()
+	var err error
+	if f, ok := vtc.vTabCursor.(VTabCursorFilterer); ok {
+		args := make([]interface{}, argc)
+		if argc > 0 {
+			for i, v := range (*[1 << 20]*C.sqlite3_value)(unsafe.Pointer(argv))[:argc:argc] {
+				args[i] = valueOf(v)
+			}
+		}
+		err = f.FilterArgs(int(idxNum), args)
+	} else {
+		err = vtc.vTabCursor.Filter()
+	}
 	if err != nil {
 		return mPrintf("%s", err.Error())
 	}
@@ -208,6 +259,55 @@ type VTabExtended interface {
 	RollbackTo(i int) error
 }
 
+// IndexConstraintOp is the operator of a constraint passed to VTabIndexer.BestIndexInfo.
+type IndexConstraintOp uint8
+
+// Constraint operators
+const (
+	IndexConstraintEq    IndexConstraintOp = C.SQLITE_INDEX_CONSTRAINT_EQ
+	IndexConstraintGt    IndexConstraintOp = C.SQLITE_INDEX_CONSTRAINT_GT
+	IndexConstraintLe    IndexConstraintOp = C.SQLITE_INDEX_CONSTRAINT_LE
+	IndexConstraintLt    IndexConstraintOp = C.SQLITE_INDEX_CONSTRAINT_LT
+	IndexConstraintGe    IndexConstraintOp = C.SQLITE_INDEX_CONSTRAINT_GE
+	IndexConstraintMatch IndexConstraintOp = C.SQLITE_INDEX_CONSTRAINT_MATCH
+)
+
+// IndexConstraint is a WHERE clause term (or a table-valued function argument) on a virtual table column.
+type IndexConstraint struct {
+	Column int // column index (-1 for rowid)
+	Op     IndexConstraintOp
+	Usable bool
+	// Outputs:
+	ArgvIndex int  // if > 0, the right-hand side value is passed to VTabCursorFilterer.FilterArgs as args[ArgvIndex-1]
+	Omit      bool // true when the constraint does not need to be double checked by SQLite
+}
+
+// IndexInfo is the query planner state passed to VTabIndexer.BestIndexInfo.
+// (See http://sqlite.org/vtab.html#xbestindex)
+type IndexInfo struct {
+	Constraints []IndexConstraint
+	// Outputs:
+	IdxNum        int // passed to VTabCursorFilterer.FilterArgs
+	EstimatedCost float64
+}
+
+// VTabIndexer is an optional interface of VTab: when implemented,
+// BestIndexInfo is called instead of BestIndex with the constraints on the virtual table.
+// BestIndexInfo may return ErrConstraint to reject a plan which cannot be used
+// (a required argument missing from the usable constraints for example).
+type VTabIndexer interface {
+	VTab
+	BestIndexInfo(info *IndexInfo) error
+}
+
+// VTabCursorFilterer is an optional interface of VTabCursor: when implemented,
+// FilterArgs is called instead of Filter with the index number and the constraint values
+// chosen by VTabIndexer.BestIndexInfo.
+type VTabCursorFilterer interface {
+	VTabCursor
+	FilterArgs(idxNum int, args []interface{}) error
+}
+
 // (See http://sqlite.org/c3ref/vtab_cursor.html)
 type VTabCursor interface {
 	Close() error                                                                // See http://sqlite.org/vtab.html#xclose
@@ -230,6 +330,19 @@ func (c *Conn) DeclareVTab(sql string) error {
 // CreateModule registers a virtual table implementation.
 // (See http://sqlite.org/c3ref/create_module.html)
 func (c *Conn) CreateModule(moduleName string, module Module) error {
+	return c.createModule(moduleName, module, false)
+}
+
+// CreateEponymousModule registers an eponymous-only virtual table implementation:
+// the virtual table cannot be created with CREATE VIRTUAL TABLE (Module.Create is never called)
+// but it exists in the "main" schema with the name of the module.
+// It can be used to implement table-valued functions (see VTabIndexer, VTabCursorFilterer and HIDDEN columns).
+// (See http://sqlite.org/vtab.html#eponymous_only_virtual_tables)
+func (c *Conn) CreateEponymousModule(moduleName string, module Module) error {
+	return c.createModule(moduleName, module, true)
+}
+
+func (c *Conn) createModule(moduleName string, module Module, eponymous bool) error {
 	mname := C.CString(moduleName)
 	defer C.free(unsafe.Pointer(mname))
 	// To make sure it is not gced, keep a reference in the connection.
@@ -238,6 +351,10 @@ func (c *Conn) CreateModule(moduleName string, module Module) error {
 		c.modules = make(map[string]*sqliteModule)
 	}
 	c.modules[moduleName] = udm // FIXME What happens if different modules are registered with the same name?
+	if eponymous {
+		return c.error(C.goSqlite3CreateEponymousModule(c.db, mname, unsafe.Pointer(udm)),
+			fmt.Sprintf("Conn.CreateEponymousModule(%q)", moduleName))
+	}
 	return c.error(C.goSqlite3CreateModule(c.db, mname, unsafe.Pointer(udm)),
 		fmt.Sprintf("Conn.CreateModule(%q)", moduleName))
 }
@@ -0,0 +1,258 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// CheckpointMode enumerates checkpoint modes
+// (See http://sqlite.org/c3ref/wal_checkpoint_v2.html)
+type CheckpointMode int
+
+const (
+	CheckpointPassive  CheckpointMode = C.SQLITE_CHECKPOINT_PASSIVE
+	CheckpointFull     CheckpointMode = C.SQLITE_CHECKPOINT_FULL
+	CheckpointRestart  CheckpointMode = C.SQLITE_CHECKPOINT_RESTART
+	CheckpointTruncate CheckpointMode = C.SQLITE_CHECKPOINT_TRUNCATE
+)
+
+func (m CheckpointMode) String() string {
+	switch m {
+	case CheckpointPassive:
+		return "PASSIVE"
+	case CheckpointFull:
+		return "FULL"
+	case CheckpointRestart:
+		return "RESTART"
+	case CheckpointTruncate:
+		return "TRUNCATE"
+	}
+	return fmt.Sprintf("CheckpointMode(%d)", int(m))
+}
+
+// WalCheckpoint checkpoints the specified database (or all attached databases if dbName is empty).
+// Returns the size of the WAL log in frames and the number of checkpointed frames.
+// (See http://sqlite.org/c3ref/wal_checkpoint_v2.html)
+func (c *Conn) WalCheckpoint(dbName string, mode CheckpointMode) (logSize, checkpointed int, err error) {
+	var zDb *C.char
+	if len(dbName) > 0 {
+		zDb = C.CString(dbName)
+		defer C.free(unsafe.Pointer(zDb))
+	}
+	var nLog, nCkpt C.int
+	rv := C.sqlite3_wal_checkpoint_v2(c.db, zDb, C.int(mode), &nLog, &nCkpt)
+	if rv != C.SQLITE_OK {
+		return int(nLog), int(nCkpt), c.error(rv, fmt.Sprintf("Conn.WalCheckpoint(%q, %s)", dbName, mode))
+	}
+	return int(nLog), int(nCkpt), nil
+}
+
+// WalAutoCheckpoint configures the auto-checkpoint threshold (in frames, <= 0 disables it).
+// It replaces any WAL hook (see Conn.WalHook).
+// (See http://sqlite.org/c3ref/wal_autocheckpoint.html)
+func (c *Conn) WalAutoCheckpoint(n int) error {
+	c.walHook = nil
+	return c.error(C.sqlite3_wal_autocheckpoint(c.db, C.int(n)), "Conn.WalAutoCheckpoint")
+}
+
+// Checkpointer runs WAL checkpoints in a background goroutine, on a dedicated connection,
+// when the WAL size (reported by the WAL hook of the watched connections) exceeds thresholds.
+// It replaces the default auto-checkpoint of the watched connections
+// which runs PASSIVE checkpoints (that cannot prevent unbounded WAL growth) on the writer goroutine.
+//
+// Checkpoints can be suspended, for example around a backup, with Pause/Resume.
+type Checkpointer struct {
+	checkpoints int64 // accessed atomically
+	failures    int64 // accessed atomically
+	frames      int64 // accessed atomically, last WAL size reported
+
+	dbName   string // database name on the watched connections
+	restart  int
+	truncate int
+	conn     *Conn // dedicated connection
+
+	mu      sync.Mutex // held while checkpointing
+	paused  int
+	pending bool
+	lastErr error
+	watched []*Conn
+
+	notify chan bool
+	done   chan bool
+	exited chan bool
+}
+
+// CheckpointerBusyTimeout is the time a RESTART/TRUNCATE checkpoint waits for readers/writers
+// (writers are blocked during that time).
+var CheckpointerBusyTimeout = 100 * time.Millisecond
+
+// NewCheckpointer starts a checkpointer for the specified database of c (which must be in WAL mode).
+// A RESTART checkpoint is run when the WAL holds more than restart frames
+// and a TRUNCATE checkpoint when it holds more than truncate frames (0 disables a threshold).
+// The WAL hook of c is replaced.
+func NewCheckpointer(c *Conn, dbName string, restart, truncate int) (*Checkpointer, error) {
+	if len(dbName) == 0 {
+		dbName = "main"
+	}
+	if restart <= 0 && truncate <= 0 {
+		return nil, errors.New("no checkpoint threshold specified")
+	}
+	filename := c.Filename(dbName)
+	if len(filename) == 0 {
+		return nil, fmt.Errorf("no WAL for temporary or in-memory database %q", dbName)
+	}
+	conn, err := Open(filename, OpenReadWrite, OpenFullMutex)
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.BusyTimeout(CheckpointerBusyTimeout); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// makes sure the dedicated connection has read the database header (checkpoints are no-op otherwise)
+	if mode, err := conn.JournalMode("main"); err != nil || mode != "wal" {
+		conn.Close()
+		if err == nil {
+			err = fmt.Errorf("database %q is not in WAL mode (%s)", dbName, mode)
+		}
+		return nil, err
+	}
+	cp := &Checkpointer{dbName: dbName, restart: restart, truncate: truncate, conn: conn,
+		notify: make(chan bool, 1), done: make(chan bool), exited: make(chan bool)}
+	cp.Watch(c)
+	go cp.loop()
+	return cp, nil
+}
+
+// Watch replaces the WAL hook of another connection to the same database
+// so that its commits are taken into account.
+func (cp *Checkpointer) Watch(c *Conn) {
+	c.WalHook(func(udp interface{}, c *Conn, dbName string, nEntry int) int {
+		if dbName == cp.dbName {
+			atomic.StoreInt64(&cp.frames, int64(nEntry))
+			cp.signal()
+		}
+		return C.SQLITE_OK
+	}, nil)
+	cp.mu.Lock()
+	cp.watched = append(cp.watched, c)
+	cp.mu.Unlock()
+}
+
+func (cp *Checkpointer) signal() {
+	select {
+	case cp.notify <- true:
+	default:
+	}
+}
+
+func (cp *Checkpointer) loop() {
+	defer close(cp.exited)
+	for {
+		select {
+		case <-cp.done:
+			return
+		case <-cp.notify:
+			cp.check()
+		}
+	}
+}
+
+func (cp *Checkpointer) check() {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if cp.paused > 0 {
+		cp.pending = true
+		return
+	}
+	frames := int(atomic.LoadInt64(&cp.frames))
+	var mode CheckpointMode
+	if cp.truncate > 0 && frames >= cp.truncate {
+		mode = CheckpointTruncate
+	} else if cp.restart > 0 && frames >= cp.restart {
+		mode = CheckpointRestart
+	} else {
+		return
+	}
+	_, _, err := cp.conn.WalCheckpoint("main", mode)
+	if err != nil {
+		atomic.AddInt64(&cp.failures, 1)
+		cp.lastErr = err
+		return
+	}
+	atomic.AddInt64(&cp.checkpoints, 1)
+	atomic.StoreInt64(&cp.frames, 0)
+}
+
+// Pause suspends checkpoints (waiting for the current one to complete).
+// Calls can be nested.
+func (cp *Checkpointer) Pause() {
+	cp.mu.Lock()
+	cp.paused++
+	cp.mu.Unlock()
+}
+
+// Resume resumes checkpoints suspended by Pause.
+func (cp *Checkpointer) Resume() {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if cp.paused > 0 {
+		cp.paused--
+	}
+	if cp.paused == 0 && cp.pending {
+		cp.pending = false
+		cp.signal()
+	}
+}
+
+// Checkpoints returns the number of successful checkpoints.
+func (cp *Checkpointer) Checkpoints() int64 {
+	return atomic.LoadInt64(&cp.checkpoints)
+}
+
+// Failures returns the number of failed (usually busy) checkpoints.
+func (cp *Checkpointer) Failures() int64 {
+	return atomic.LoadInt64(&cp.failures)
+}
+
+// LastError returns the error of the last failed checkpoint.
+func (cp *Checkpointer) LastError() error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.lastErr
+}
+
+// Close stops the checkpointer, restores the default auto-checkpoint of the watched connections
+// (it should be called from the goroutine using them) and closes the dedicated connection.
+func (cp *Checkpointer) Close() error {
+	select {
+	case <-cp.done:
+		return nil
+	default:
+		close(cp.done)
+	}
+	<-cp.exited
+	cp.mu.Lock()
+	for _, c := range cp.watched {
+		if !c.IsClosed() {
+			c.WalAutoCheckpoint(1000) // SQLITE_DEFAULT_WAL_AUTOCHECKPOINT
+		}
+	}
+	cp.watched = nil
+	cp.mu.Unlock()
+	return cp.conn.Close()
+}
@@ -0,0 +1,119 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	"bytes"
+	. "github.com/gwenn/gosqlite"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func openWal(t *testing.T) (*os.File, *Conn) {
+	f, err := ioutil.TempFile("", "gosqlite-wal")
+	checkNoError(t, err, "couldn't create temp file: %s")
+	checkNoError(t, f.Close(), "couldn't close temp file: %s")
+	db, err := Open(f.Name(), OpenReadWrite, OpenCreate, OpenFullMutex)
+	checkNoError(t, err, "couldn't open database file: %s")
+	mode, err := db.SetJournalMode("", "wal")
+	checkNoError(t, err, "couldn't set journal mode: %s")
+	assertEquals(t, "expected %q but got %q", "wal", mode)
+	createTable(db, t)
+	return f, db
+}
+
+func TestWalHook(t *testing.T) {
+	f, db := openWal(t)
+	defer os.Remove(f.Name())
+	defer os.Remove(db.WalFilename("main"))
+	defer checkClose(db, t)
+
+	var nEntry int
+	db.WalHook(func(udp interface{}, c *Conn, dbName string, n int) int {
+		assertEquals(t, "expected %q but got %q", "main", dbName)
+		assert(t, "connection expected", c == db)
+		nEntry = n
+		return 0
+	}, nil)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('wal')"), "insert error: %s")
+	assert(t, "WAL hook not called", nEntry > 0)
+
+	logSize, checkpointed, err := db.WalCheckpoint("", CheckpointTruncate)
+	checkNoError(t, err, "checkpoint error: %s")
+	assertEquals(t, "expected %d but got %d", 0, logSize)
+	assertEquals(t, "expected %d but got %d", 0, checkpointed)
+	checkNoError(t, db.WalAutoCheckpoint(1000), "couldn't reset auto-checkpoint: %s")
+}
+
+func TestCheckpointer(t *testing.T) {
+	f, db := openWal(t)
+	defer os.Remove(f.Name())
+	defer os.Remove(db.WalFilename("main"))
+	defer checkClose(db, t)
+
+	cp, err := NewCheckpointer(db, "", 0, 10)
+	checkNoError(t, err, "couldn't start checkpointer: %s")
+	defer cp.Close()
+
+	cp.Pause()
+	for i := 0; i < 20; i++ {
+		checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('wal')"), "insert error: %s")
+	}
+	time.Sleep(10 * time.Millisecond)
+	assertEquals(t, "expected %d checkpoints but got %d", int64(0), cp.Checkpoints())
+	cp.Resume()
+
+	for i := 0; i < 100 && cp.Checkpoints() == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert(t, "checkpoint expected", cp.Checkpoints() > 0)
+	fi, err := os.Stat(db.WalFilename("main"))
+	checkNoError(t, err, "couldn't stat WAL file: %s")
+	assertEquals(t, "expected %d bytes but got %d", int64(0), fi.Size())
+	checkNoError(t, cp.LastError(), "unexpected checkpoint error: %s")
+	checkNoError(t, cp.Close(), "couldn't close checkpointer: %s")
+}
+
+func TestWalShipper(t *testing.T) {
+	f, db := openWal(t)
+	defer os.Remove(f.Name())
+	defer os.Remove(db.WalFilename("main"))
+	defer checkClose(db, t)
+	checkNoError(t, db.WalAutoCheckpoint(0), "couldn't disable auto-checkpoint: %s")
+
+	ws, err := NewWalShipper(f.Name())
+	checkNoError(t, err, "couldn't create WAL shipper: %s")
+	defer ws.Close()
+
+	var shipped bytes.Buffer
+	seg, err := ws.Ship(&shipped)
+	checkNoError(t, err, "ship error: %s")
+	assert(t, "new generation expected", seg.NewGeneration)
+	assert(t, "frames expected", seg.Frames > 0)
+	assertEquals(t, "expected %d bytes but got %d", int64(32)+int64(seg.Frames)*seg.Header.FrameSize(), seg.Size)
+
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('a')"), "insert error: %s")
+	seg, err = ws.Ship(&shipped)
+	checkNoError(t, err, "ship error: %s")
+	assert(t, "same generation expected", !seg.NewGeneration)
+	assert(t, "frames expected", seg.Frames > 0)
+	seg, err = ws.Ship(&shipped)
+	checkNoError(t, err, "ship error: %s")
+	assertEquals(t, "expected %d frames but got %d", 0, seg.Frames)
+
+	wal, err := ioutil.ReadFile(db.WalFilename("main"))
+	checkNoError(t, err, "couldn't read WAL: %s")
+	assert(t, "shipped frames expected to match the WAL", bytes.Equal(shipped.Bytes(), wal))
+
+	_, err = ws.Checkpoint(&shipped, CheckpointTruncate)
+	checkNoError(t, err, "checkpoint error: %s")
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('b')"), "insert error: %s")
+	seg, err = ws.Ship(&shipped)
+	checkNoError(t, err, "ship error: %s")
+	assert(t, "new generation expected", seg.NewGeneration)
+	assertEquals(t, "expected %d frames but got %d", 1, seg.Frames)
+}
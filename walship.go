@@ -0,0 +1,229 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+)
+
+// WalHeader is the header of a WAL file.
+// (See http://sqlite.org/fileformat2.html#walformat)
+type WalHeader struct {
+	Magic         uint32
+	Version       uint32
+	PageSize      uint32
+	CheckpointSeq uint32
+	Salt1, Salt2  uint32 // changed each time the WAL is restarted
+	Checksum1     uint32
+	Checksum2     uint32
+}
+
+// ReadWalHeader reads and checks the header of a WAL file.
+func ReadWalHeader(r io.ReaderAt) (WalHeader, error) {
+	var buf [walHeaderSize]byte
+	var h WalHeader
+	if _, err := r.ReadAt(buf[:], 0); err != nil {
+		return h, err
+	}
+	h = WalHeader{
+		Magic:         binary.BigEndian.Uint32(buf[0:]),
+		Version:       binary.BigEndian.Uint32(buf[4:]),
+		PageSize:      binary.BigEndian.Uint32(buf[8:]),
+		CheckpointSeq: binary.BigEndian.Uint32(buf[12:]),
+		Salt1:         binary.BigEndian.Uint32(buf[16:]),
+		Salt2:         binary.BigEndian.Uint32(buf[20:]),
+		Checksum1:     binary.BigEndian.Uint32(buf[24:]),
+		Checksum2:     binary.BigEndian.Uint32(buf[28:]),
+	}
+	if h.Magic&^1 != 0x377f0682 {
+		return h, fmt.Errorf("invalid WAL magic number: %#x", h.Magic)
+	}
+	if s1, s2 := h.checksum(0, 0, buf[:24]); s1 != h.Checksum1 || s2 != h.Checksum2 {
+		return h, fmt.Errorf("invalid WAL header checksum")
+	}
+	if h.PageSize == 1 {
+		h.PageSize = 65536
+	}
+	return h, nil
+}
+
+// FrameSize returns the size of one frame (header and page).
+func (h WalHeader) FrameSize() int64 {
+	return walFrameHeaderSize + int64(h.PageSize)
+}
+
+// checksum continues the checksum (s1, s2) over b (whose length is a multiple of 8).
+func (h WalHeader) checksum(s1, s2 uint32, b []byte) (uint32, uint32) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if h.Magic&1 == 1 {
+		order = binary.BigEndian
+	}
+	for i := 0; i+8 <= len(b); i += 8 {
+		s1 += order.Uint32(b[i:]) + s2
+		s2 += order.Uint32(b[i+4:]) + s1
+	}
+	return s1, s2
+}
+
+// WalSegment describes the WAL bytes copied by WalShipper.Ship.
+type WalSegment struct {
+	Header        WalHeader // current WAL header (Salt1/Salt2 identify the generation)
+	NewGeneration bool      // the WAL has been restarted: the segment starts with the WAL header
+	Offset        int64     // offset of the segment in the WAL file
+	Size          int64     // number of bytes copied (0 when there is no new committed frame)
+	Frames        int       // number of frames copied
+}
+
+// WalShipper copies the frames appended to the WAL of a database, transaction by transaction,
+// to implement continuous WAL shipping (replication or point-in-time backups).
+// Only frames of committed transactions with valid salts and checksums are copied.
+//
+// While frames are copied, a read transaction is held on a dedicated connection
+// so that the WAL cannot be restarted (its read-mark pins the frames)
+// and the checkpointer (see WalShipper.SetCheckpointer) is paused.
+// Frames checkpointed and overwritten between two calls to Ship cannot be shipped:
+// auto-checkpoints should be disabled (see Conn.WalAutoCheckpoint)
+// and checkpoints run with WalShipper.Checkpoint.
+type WalShipper struct {
+	conn   *Conn // dedicated connection
+	wal    string
+	cp     *Checkpointer
+	header WalHeader
+	offset int64  // offset of the next frame to ship
+	s1, s2 uint32 // checksum of the last shipped frame
+}
+
+// NewWalShipper starts shipping the WAL of the database file filename (which must be in WAL mode).
+func NewWalShipper(filename string) (*WalShipper, error) {
+	conn, err := Open(filename, OpenReadWrite, OpenFullMutex)
+	if err != nil {
+		return nil, err
+	}
+	if mode, err := conn.JournalMode("main"); err != nil || mode != "wal" {
+		conn.Close()
+		if err == nil {
+			err = fmt.Errorf("database %q is not in WAL mode (%s)", filename, mode)
+		}
+		return nil, err
+	}
+	if err = conn.WalAutoCheckpoint(0); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &WalShipper{conn: conn, wal: conn.WalFilename("main")}, nil
+}
+
+// SetCheckpointer makes Ship pause cp while frames are copied.
+func (ws *WalShipper) SetCheckpointer(cp *Checkpointer) {
+	ws.cp = cp
+}
+
+// Ship copies to w the frames of the transactions committed since the last call.
+// When the WAL has been restarted, the new WAL header is copied first.
+func (ws *WalShipper) Ship(w io.Writer) (WalSegment, error) {
+	if ws.cp != nil {
+		ws.cp.Pause()
+		defer ws.cp.Resume()
+	}
+	// the read transaction holds a read-mark which prevents the WAL from being restarted
+	if err := ws.conn.Begin(); err != nil {
+		return WalSegment{}, err
+	}
+	defer ws.conn.Commit()
+	var n int
+	if err := ws.conn.OneValue("SELECT count(*) FROM sqlite_master", &n); err != nil {
+		return WalSegment{}, err
+	}
+	return ws.ship(w)
+}
+
+func (ws *WalShipper) ship(w io.Writer) (WalSegment, error) {
+	f, err := os.Open(ws.wal)
+	if os.IsNotExist(err) {
+		return WalSegment{Header: ws.header, Offset: ws.offset}, nil
+	} else if err != nil {
+		return WalSegment{}, err
+	}
+	defer f.Close()
+	h, err := ReadWalHeader(f)
+	if err == io.EOF { // empty WAL
+		return WalSegment{Header: ws.header, Offset: ws.offset}, nil
+	} else if err != nil {
+		return WalSegment{}, err
+	}
+	seg := WalSegment{Header: h, Offset: ws.offset}
+	offset, s1, s2 := ws.offset, ws.s1, ws.s2
+	var committed, pending bytes.Buffer
+	if offset == 0 || h.Salt1 != ws.header.Salt1 || h.Salt2 != ws.header.Salt2 {
+		seg.NewGeneration = true
+		seg.Offset = 0
+		offset, s1, s2 = walHeaderSize, h.Checksum1, h.Checksum2
+		var buf [walHeaderSize]byte
+		if _, err = f.ReadAt(buf[:], 0); err != nil {
+			return WalSegment{}, err
+		}
+		committed.Write(buf[:])
+	}
+	// state after the last commit frame
+	next, n1, n2 := offset, s1, s2
+	frame := make([]byte, h.FrameSize())
+	nFrames := 0
+	for ; ; offset += h.FrameSize() {
+		if _, err = f.ReadAt(frame, offset); err == io.EOF {
+			break
+		} else if err != nil {
+			return WalSegment{}, err
+		}
+		if binary.BigEndian.Uint32(frame[8:]) != h.Salt1 || binary.BigEndian.Uint32(frame[12:]) != h.Salt2 {
+			break // frame of a previous generation
+		}
+		s1, s2 = h.checksum(s1, s2, frame[:8])
+		s1, s2 = h.checksum(s1, s2, frame[walFrameHeaderSize:])
+		if s1 != binary.BigEndian.Uint32(frame[16:]) || s2 != binary.BigEndian.Uint32(frame[20:]) {
+			break // partially written frame
+		}
+		pending.Write(frame)
+		nFrames++
+		if binary.BigEndian.Uint32(frame[4:]) != 0 { // commit frame
+			pending.WriteTo(&committed)
+			seg.Frames += nFrames
+			nFrames = 0
+			next, n1, n2 = offset+h.FrameSize(), s1, s2
+		}
+	}
+	seg.Size = int64(committed.Len())
+	if _, err = committed.WriteTo(w); err != nil {
+		return WalSegment{}, err
+	}
+	ws.header, ws.offset, ws.s1, ws.s2 = h, next, n1, n2
+	return seg, nil
+}
+
+// Checkpoint ships the pending frames to w and then checkpoints the database
+// (a RESTART or TRUNCATE checkpoint starts a new generation).
+// Transactions committed by other connections between the two steps are checkpointed without being shipped
+// when the WAL is restarted: writes should be suspended by the application when it matters.
+func (ws *WalShipper) Checkpoint(w io.Writer, mode CheckpointMode) (WalSegment, error) {
+	seg, err := ws.Ship(w)
+	if err != nil {
+		return seg, err
+	}
+	_, _, err = ws.conn.WalCheckpoint("main", mode)
+	return seg, err
+}
+
+// Close closes the dedicated connection.
+func (ws *WalShipper) Close() error {
+	return ws.conn.Close()
+}
@@ -0,0 +1,113 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// WarmupOptions configures Conn.Warmup.
+type WarmupOptions struct {
+	DbName   string   // database name (default is 'main')
+	MmapSize int64    // PRAGMA mmap_size (0 leaves it unchanged)
+	Pages    int      // number of pages of the database file read in advance (-1 for the whole file)
+	Queries  []string // queries run (rows discarded) and kept in the statement cache
+}
+
+// WarmupTimings reports the time spent by each step of Conn.Warmup.
+type WarmupTimings struct {
+	Schema  time.Duration // schema loading
+	Mmap    time.Duration
+	Pages   time.Duration
+	Queries time.Duration
+	Total   time.Duration
+
+	PagesRead int
+}
+
+// Warmup primes a new connection so that the first real request does not pay the cold-cache latency:
+// the schema is loaded, the memory-mapped I/O limit is set, the first pages of the database file
+// are read (into the OS page cache, which is directly used with memory-mapped I/O)
+// and the queries are run (their statements being kept in the cache, see Conn.SetCacheSize).
+func (c *Conn) Warmup(opts WarmupOptions) (WarmupTimings, error) {
+	var timings WarmupTimings
+	dbName := opts.DbName
+	if len(dbName) == 0 {
+		dbName = "main"
+	}
+	start := time.Now()
+	step := start
+	lap := func(d *time.Duration) {
+		now := time.Now()
+		*d = now.Sub(step)
+		step = now
+	}
+	var n int
+	if err := c.oneValue(Mprintf(`SELECT count(*) FROM "%w".sqlite_master`, dbName), &n); err != nil {
+		return timings, err
+	}
+	lap(&timings.Schema)
+	if opts.MmapSize != 0 {
+		if _, err := c.SetMmapSize(dbName, opts.MmapSize); err != nil {
+			return timings, err
+		}
+	}
+	lap(&timings.Mmap)
+	if opts.Pages != 0 {
+		pages, err := c.readPages(dbName, opts.Pages)
+		if err != nil {
+			return timings, err
+		}
+		timings.PagesRead = pages
+	}
+	lap(&timings.Pages)
+	for _, query := range opts.Queries {
+		s, err := c.Prepare(query)
+		if err != nil {
+			return timings, err
+		}
+		err = s.Select(func(s *Stmt) error {
+			return nil
+		})
+		if ferr := s.Finalize(); err == nil {
+			err = ferr
+		}
+		if err != nil {
+			return timings, err
+		}
+	}
+	lap(&timings.Queries)
+	timings.Total = time.Since(start)
+	return timings, nil
+}
+
+// readPages reads the first pages of the database file (nothing for a temporary or in-memory database).
+func (c *Conn) readPages(dbName string, pages int) (int, error) {
+	filename := c.Filename(dbName)
+	if len(filename) == 0 {
+		return 0, nil
+	}
+	var pageSize int
+	if err := c.oneValue(pragma(dbName, "page_size"), &pageSize); err != nil {
+		return 0, err
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	buf := make([]byte, pageSize)
+	n := 0
+	for ; pages < 0 || n < pages; n++ {
+		if _, err = io.ReadFull(f, buf); err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
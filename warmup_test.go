@@ -0,0 +1,38 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"github.com/gwenn/gosqlite/sqlitetest"
+	"testing"
+)
+
+func TestWarmup(t *testing.T) {
+	db := sqlitetest.Open(t, sqlitetest.TempFile())
+	createTable(db, t)
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('a'), ('b')"), "insert error: %s")
+
+	c, err := Open(db.Filename("main"), OpenReadWrite)
+	checkNoError(t, err, "couldn't open database: %s")
+	defer checkClose(c, t)
+	query := "SELECT * FROM test WHERE a_string = 'a'"
+	timings, err := c.Warmup(WarmupOptions{MmapSize: 1 << 20, Pages: -1, Queries: []string{query}})
+	checkNoError(t, err, "warmup error: %s")
+	assert(t, "pages read expected", timings.PagesRead > 0)
+	assert(t, "total time expected", timings.Total >= timings.Schema+timings.Queries)
+	size, err := c.MmapSize("")
+	checkNoError(t, err, "mmap_size error: %s")
+	assertEquals(t, "expected %d but got %d", int64(1<<20), size)
+	cached, _ := c.CacheSize()
+	assertEquals(t, "expected %d cached statement but got %d", 1, cached)
+
+	timings, err = c.Warmup(WarmupOptions{Pages: 1})
+	checkNoError(t, err, "warmup error: %s")
+	assertEquals(t, "expected %d page but got %d", 1, timings.PagesRead)
+
+	_, err = c.Warmup(WarmupOptions{Queries: []string{"SELECT * FROM missing"}})
+	assert(t, "query error expected", err != nil)
+}
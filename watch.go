@@ -0,0 +1,142 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RowChange describes one row inserted, updated or deleted (see UpdateHook).
+type RowChange struct {
+	Action    Action
+	DbName    string
+	TableName string
+	RowId     int64
+}
+
+// ChangeEvent groups the changes made to the watched tables by one or more committed transactions.
+type ChangeEvent struct {
+	Tables  []string // distinct names of the changed tables (sorted)
+	Changes []RowChange
+}
+
+type watcher struct {
+	tables     map[string]bool // lower-cased names, nil means all tables
+	current    []RowChange     // changes of the current transaction
+	marks      savepointMarks  // length of current at each savepoint
+	committing []RowChange     // changes accepted by the commit hook, delivered once committed
+
+	mu      sync.Mutex
+	pending []RowChange // committed changes not yet delivered
+	notify  chan bool
+	done    chan bool
+}
+
+// Watch delivers the changes made to the specified tables (all tables if empty) through this connection.
+// Changes are buffered until the transaction is committed and dropped if it is rolled back
+// (even by another commit hook) or undone by ROLLBACK TO a savepoint.
+// If the receiver is slower than the writer, the changes of several transactions are merged into one event
+// so that the commit is never blocked.
+// The stop function unregisters the hooks (it must be called from the goroutine using the connection)
+// and closes the channel.
+//
+//	events, stop := db.Watch([]string{"person"})
+//	defer stop()
+//	go func() {
+//		for e := range events {
+//			// invalidate cache entries
+//		}
+//	}()
+func (c *Conn) Watch(tables []string) (<-chan ChangeEvent, func()) {
+	w := &watcher{notify: make(chan bool, 1), done: make(chan bool)}
+	if len(tables) > 0 {
+		w.tables = make(map[string]bool, len(tables))
+		for _, table := range tables {
+			w.tables[strings.ToLower(table)] = true
+		}
+	}
+	tokens := []HookToken{
+		c.AddUpdateHook(func(udp interface{}, a Action, dbName, tableName string, rowId int64) {
+			if w.tables == nil || w.tables[strings.ToLower(tableName)] {
+				w.current = append(w.current, RowChange{a, dbName, tableName, rowId})
+			}
+		}, nil),
+		c.AddCommitHook(func(udp interface{}) bool {
+			w.committing = append(w.committing, w.current...)
+			w.current = nil
+			w.marks.reset()
+			return false
+		}, nil),
+		c.AddRollbackHook(func(udp interface{}) {
+			w.current = nil
+			w.marks.reset()
+			w.committing = nil
+		}, nil),
+		c.addSavepointHook(func(op savepointOp, name string) {
+			w.current = w.current[:w.marks.apply(op, name, len(w.current))]
+		}),
+		c.addAfterCommitHook(func() {
+			if len(w.committing) > 0 {
+				w.mu.Lock()
+				w.pending = append(w.pending, w.committing...)
+				w.mu.Unlock()
+				w.committing = nil
+				select {
+				case w.notify <- true:
+				default:
+				}
+			}
+		}),
+	}
+	events := make(chan ChangeEvent)
+	go w.deliver(events)
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			for _, token := range tokens {
+				c.RemoveHook(token)
+			}
+			close(w.done)
+		})
+	}
+}
+
+func (w *watcher) deliver(events chan<- ChangeEvent) {
+	defer close(events)
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.notify:
+		}
+		w.mu.Lock()
+		changes := w.pending
+		w.pending = nil
+		w.mu.Unlock()
+		if len(changes) == 0 {
+			continue
+		}
+		select {
+		case events <- newChangeEvent(changes):
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func newChangeEvent(changes []RowChange) ChangeEvent {
+	var tables []string
+	seen := make(map[string]bool)
+	for _, change := range changes {
+		if !seen[change.TableName] {
+			seen[change.TableName] = true
+			tables = append(tables, change.TableName)
+		}
+	}
+	sort.Strings(tables)
+	return ChangeEvent{Tables: tables, Changes: changes}
+}
@@ -0,0 +1,103 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlite_test
+
+import (
+	. "github.com/gwenn/gosqlite"
+	"testing"
+	"time"
+)
+
+func receiveChange(t *testing.T, events <-chan ChangeEvent) (ChangeEvent, bool) {
+	select {
+	case e, ok := <-events:
+		return e, ok
+	case <-time.After(100 * time.Millisecond):
+		return ChangeEvent{}, false
+	}
+}
+
+func TestWatch(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+	checkNoError(t, db.Exec("CREATE TABLE other (name TEXT)"), "create error: %s")
+
+	events, stop := db.Watch([]string{"TEST"})
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('a')"), "insert error: %s")
+	e, ok := receiveChange(t, events)
+	assert(t, "change event expected", ok)
+	assertEquals(t, "expected %d changes but got %d", 1, len(e.Changes))
+	assertEquals(t, "expected %q but got %q", "test", e.Tables[0])
+	assertEquals(t, "expected %v but got %v", Insert, e.Changes[0].Action)
+	assertEquals(t, "expected %d but got %d", int64(1), e.Changes[0].RowId)
+
+	// changes of other tables are ignored
+	checkNoError(t, db.Exec("INSERT INTO other VALUES ('b')"), "insert error: %s")
+	_, ok = receiveChange(t, events)
+	assert(t, "no change event expected", !ok)
+
+	// changes are dropped on rollback
+	checkNoError(t, db.Begin(), "begin error: %s")
+	checkNoError(t, db.Exec("UPDATE test SET a_string = 'c'"), "update error: %s")
+	checkNoError(t, db.Rollback(), "rollback error: %s")
+	_, ok = receiveChange(t, events)
+	assert(t, "no change event expected", !ok)
+
+	// changes are delivered after commit
+	checkNoError(t, db.Begin(), "begin error: %s")
+	checkNoError(t, db.Exec("INSERT INTO test (a_string) VALUES ('d')"), "insert error: %s")
+	checkNoError(t, db.Exec("DELETE FROM test WHERE id = 1"), "delete error: %s")
+	_, ok = receiveChange(t, events)
+	assert(t, "no change event expected before commit", !ok)
+	checkNoError(t, db.Commit(), "commit error: %s")
+	e, ok = receiveChange(t, events)
+	assert(t, "change event expected", ok)
+	assertEquals(t, "expected %d changes but got %d", 2, len(e.Changes))
+	assertEquals(t, "expected %v but got %v", Delete, e.Changes[1].Action)
+
+	// changes are dropped when another commit hook vetoes the commit
+	token := db.AddCommitHook(func(udp interface{}) bool { return true }, nil)
+	err := db.Exec("INSERT INTO test (a_string) VALUES ('e')")
+	assert(t, "commit hook error expected", err != nil)
+	_, ok = receiveChange(t, events)
+	assert(t, "no change event expected", !ok)
+	db.RemoveHook(token)
+
+	stop()
+	stop()
+	_, ok = <-events
+	assert(t, "closed channel expected", !ok)
+}
+
+func TestWatchRollbackToSavepoint(t *testing.T) {
+	db := open(t)
+	defer checkClose(db, t)
+	createTable(db, t)
+
+	events, stop := db.Watch(nil)
+	defer stop()
+	checkNoError(t, db.Exec("BEGIN; INSERT INTO test (id) VALUES (9); SAVEPOINT a; INSERT INTO test (id) VALUES (10); "+
+		"ROLLBACK TO a; RELEASE a; COMMIT"), "exec error: %s")
+	e, ok := receiveChange(t, events)
+	assert(t, "change event expected", ok)
+	assertEquals(t, "expected %d changes but got %d", 1, len(e.Changes))
+	assertEquals(t, "expected %d but got %d", int64(9), e.Changes[0].RowId)
+
+	// nested savepoints, with the Conn methods and quoted names
+	checkNoError(t, db.Savepoint("outer"), "savepoint error: %s")
+	checkNoError(t, db.Exec("INSERT INTO test (id) VALUES (11)"), "insert error: %s")
+	checkNoError(t, db.Exec(`/* inner */ SAVEPOINT "In""ner"`), "savepoint error: %s")
+	checkNoError(t, db.Exec("INSERT INTO test (id) VALUES (12)"), "insert error: %s")
+	checkNoError(t, db.Exec("RELEASE SAVEPOINT [in\"ner]"), "release error: %s")
+	checkNoError(t, db.Exec("INSERT INTO test (id) VALUES (13)"), "insert error: %s")
+	checkNoError(t, db.Exec("ROLLBACK TRANSACTION TO SAVEPOINT OUTER"), "rollback error: %s")
+	checkNoError(t, db.Exec("INSERT INTO test (id) VALUES (14)"), "insert error: %s")
+	checkNoError(t, db.ReleaseSavepoint("outer"), "release error: %s")
+	e, ok = receiveChange(t, events)
+	assert(t, "change event expected", ok)
+	assertEquals(t, "expected %d changes but got %d", 1, len(e.Changes))
+	assertEquals(t, "expected %d but got %d", int64(14), e.Changes[0].RowId)
+}